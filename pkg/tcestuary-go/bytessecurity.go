@@ -0,0 +1,46 @@
+package tcestuary
+
+import (
+	"io"
+
+	"git.code.oa.com/tce-config/tcestuary-go/v4/tcesecurity"
+)
+
+// 二进制数据 / 流式加解密, s 为 NewStorageSecurity / NewTransportSecurity 等返回的组件.
+// 仅支持 aes-256-gcm / tsm-sm4-128-gcm 及由其组成的密钥环, 其他算法返回 tcesecurity.ErrNotSupportBytes
+
+// EncryptBytes 二进制数据加密
+func EncryptBytes(s StorageSecurity, plaintext []byte) ([]byte, error) {
+	c, ok := s.(tcesecurity.BytesCrypto)
+	if !ok {
+		return nil, tcesecurity.ErrNotSupportBytes
+	}
+	return c.EncryptBytes(plaintext)
+}
+
+// DecryptBytes 二进制数据解密
+func DecryptBytes(s StorageSecurity, ciphertext []byte) ([]byte, error) {
+	c, ok := s.(tcesecurity.BytesCrypto)
+	if !ok {
+		return nil, tcesecurity.ErrNotSupportBytes
+	}
+	return c.DecryptBytes(ciphertext)
+}
+
+// NewEncryptWriter 流式加密, 数据分块加密后写入 w. 写入完成后必须调用 Close, Close 不关闭 w
+func NewEncryptWriter(s StorageSecurity, w io.Writer) (io.WriteCloser, error) {
+	c, ok := s.(tcesecurity.StreamCrypto)
+	if !ok {
+		return nil, tcesecurity.ErrNotSupportBytes
+	}
+	return c.NewEncryptWriter(w)
+}
+
+// NewDecryptReader 流式解密, 数据被篡改或截断时 Read 返回错误
+func NewDecryptReader(s StorageSecurity, r io.Reader) (io.Reader, error) {
+	c, ok := s.(tcesecurity.StreamCrypto)
+	if !ok {
+		return nil, tcesecurity.ErrNotSupportBytes
+	}
+	return c.NewDecryptReader(r)
+}
@@ -0,0 +1,40 @@
+package tcestuary
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"git.code.oa.com/tce-config/tcestuary-go/v4/tcesecurity"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBytesSecurity(t *testing.T) {
+	s, err := NewStorageSecurity()
+	assert.NoError(t, err)
+
+	ciphertext, err := EncryptBytes(s, []byte{0, 1, 2})
+	assert.NoError(t, err)
+	plaintext, err := DecryptBytes(s, ciphertext)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0, 1, 2}, plaintext)
+
+	var buf bytes.Buffer
+	w, err := NewEncryptWriter(s, &buf)
+	assert.NoError(t, err)
+	w.Write(bytes.Repeat([]byte("backup"), 50000))
+	assert.NoError(t, w.Close())
+	r, err := NewDecryptReader(s, &buf)
+	assert.NoError(t, err)
+	plaintext, err = ioutil.ReadAll(r)
+	assert.NoError(t, err)
+	assert.Equal(t, bytes.Repeat([]byte("backup"), 50000), plaintext)
+
+	// transport-secret 为 rsa-2048
+	ts, err := NewTransportSecurity()
+	assert.NoError(t, err)
+	_, err = EncryptBytes(ts, []byte("backup"))
+	assert.Equal(t, tcesecurity.ErrNotSupportBytes, err)
+	_, err = NewEncryptWriter(ts, &buf)
+	assert.Equal(t, tcesecurity.ErrNotSupportBytes, err)
+}
@@ -0,0 +1,178 @@
+package tcestuary
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"git.code.oa.com/tce-config/tcestuary-go/v4/configcenter"
+	"git.code.oa.com/tce-config/tcestuary-go/v4/logger"
+)
+
+// Client 持有独立的配置目录、配置内容、日志输出, 并基于自身配置创建加解密组件.
+// 同一进程需要读取多个配置目录(或单元测试需要隔离配置)时使用.
+// 包级函数(GetMysqlConfig 等)等价于调用默认 Client 的同名方法
+type Client struct {
+	manager *manager
+
+	// WithStorageSecret / WithTransportSecret 指定的密钥配置, 优先于环境变量及 sdk.json
+	storageSecret   *configcenter.SecretConfig
+	transportSecret *configcenter.SecretConfig
+}
+
+// Option Client 构造参数
+type Option func(*Client) error
+
+// WithConfigDirectory 指定配置目录, 目录下必须存在 sdk.json.
+// 默认: /tce/conf/config/tce.config.center
+func WithConfigDirectory(dir string) Option {
+	return func(c *Client) error {
+		dir, file, err := checkConfigDirectory(dir)
+		if err != nil {
+			return err
+		}
+		c.manager.Directory = dir
+		c.manager.ConfigCenterFile = file
+		return nil
+	}
+}
+
+// WithConfigFile 指定配置文件, 文件名可以不是 sdk.json. cc.declare.json 及 SDK 版本文件使用配置文件所在目录
+func WithConfigFile(file string) Option {
+	return func(c *Client) error {
+		stat, err := os.Stat(file)
+		if err != nil {
+			return err
+		}
+		if stat.IsDir() {
+			return fmt.Errorf("need file, %s", file)
+		}
+		if file, err = filepath.Abs(file); err != nil {
+			return err
+		}
+		c.manager.Directory = filepath.Dir(file)
+		c.manager.ConfigCenterFile = file
+		return nil
+	}
+}
+
+// WithLogger 指定 Client 的日志输出. 默认: 转发到 SetLogger 设置的全局日志接口
+func WithLogger(log logger.Logger) Option {
+	return func(c *Client) error {
+		if log == nil {
+			return fmt.Errorf("logger is nil")
+		}
+		c.manager.logger = log
+		return nil
+	}
+}
+
+// WithStorageSecret 指定 Client 的存储加密配置, 优先于环境变量 STORAGE_SECRET 及 sdk.json 中的 storage_secret.
+// 环境变量对进程内所有 Client 生效, 需要按 Client 区分时使用
+func WithStorageSecret(conf configcenter.SecretConfig) Option {
+	return func(c *Client) error {
+		c.storageSecret = &conf
+		return nil
+	}
+}
+
+// WithTransportSecret 指定 Client 的传输加密配置, 优先于环境变量 TRANSPORT_SECRET 及 sdk.json 中的 transport_secret
+func WithTransportSecret(conf configcenter.SecretConfig) Option {
+	return func(c *Client) error {
+		c.transportSecret = &conf
+		return nil
+	}
+}
+
+// New 创建独立的 Client. 配置文件在首次使用时加载.
+// 注意: TencentSM 库在进程内只初始化一次, 使用第一个创建加解密组件的 Client 的 tsm_secret;
+// 环境变量 STORAGE_SECRET / TRANSPORT_SECRET 对所有 Client 生效, 可以通过 WithStorageSecret / WithTransportSecret 覆盖
+func New(opts ...Option) (*Client, error) {
+	c := &Client{manager: newManager()}
+	for _, opt := range opts {
+		if err := opt(c); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// ConfigFile 配置文件路径
+func (c *Client) ConfigFile() string {
+	return c.manager.ConfigCenterFile
+}
+
+// ConfigCenter 返回当前生效的配置. 热加载会整体替换配置对象, 调用方不要长期持有返回值
+func (c *Client) ConfigCenter() *configcenter.ConfigCenter {
+	return c.manager.ConfigCenter()
+}
+
+// Load 首次调用时加载配置文件, 返回首次加载的错误信息
+func (c *Client) Load() error {
+	return c.manager.Load()
+}
+
+// Reload 重新加载配置文件, 参考 Watch
+func (c *Client) Reload() error {
+	return c.manager.Reload()
+}
+
+// Watch 周期检查配置文件, 发生变化时重新加载, 返回 stop 函数
+func (c *Client) Watch(interval time.Duration) (stop func()) {
+	return c.manager.Watch(interval)
+}
+
+// OnChange 注册配置变更回调, 仅在热加载成功且文件内容变化时调用
+func (c *Client) OnChange(f ChangeFunc) {
+	c.manager.OnChange(f)
+}
+
+// OnReloadError 注册热加载失败回调
+func (c *Client) OnReloadError(f func(error)) {
+	c.manager.OnReloadError(f)
+}
+
+// ReloadError 返回最近一次热加载的错误信息, nil 表示成功
+func (c *Client) ReloadError() error {
+	return c.manager.ReloadError()
+}
+
+// Debug 向终端输出已加载配置信息, 密钥、密码已脱敏
+func (c *Client) Debug() {
+	c.manager.Debug()
+}
+
+// DebugUnsafe 同 Debug, 但输出密钥、密码明文, 仅用于本地调试
+func (c *Client) DebugUnsafe() {
+	c.manager.DebugUnsafe()
+}
+
+// checkConfigDirectory 检查配置目录及目录下的 sdk.json, 返回目录绝对路径和 sdk.json 路径
+func checkConfigDirectory(dir string) (string, string, error) {
+	// 检查路径是否存在
+	stat, err := os.Stat(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", "", fmt.Errorf("dir not exist, %s", dir)
+		}
+		return "", "", err
+	}
+	if !stat.IsDir() {
+		return "", "", fmt.Errorf("need directory, %s", dir)
+	}
+
+	// dir 换成绝对路径, 用于调试信息输出
+	dir, err = filepath.Abs(dir)
+	if err != nil {
+		return "", "", err
+	}
+
+	// 检测关键配置, 是否存在
+	sdkFile := filepath.Join(dir, "sdk.json")
+	if _, err := os.Stat(sdkFile); err != nil {
+		return "", "", err
+	}
+
+	return dir, sdkFile, nil
+}
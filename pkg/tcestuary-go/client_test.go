@@ -0,0 +1,289 @@
+package tcestuary
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"git.code.oa.com/tce-config/tcestuary-go/v4/configcenter"
+	"git.code.oa.com/tce-config/tcestuary-go/v4/tcesecurity"
+	"git.code.oa.com/tce-config/tcestuary-go/v4/tcesecurity/kmstest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew(t *testing.T) {
+	t.Run("dir-not-exist", func(t *testing.T) {
+		c, err := New(WithConfigDirectory("./not_exist"))
+		assert.Error(t, err)
+		assert.Nil(t, c)
+	})
+
+	t.Run("logger-nil", func(t *testing.T) {
+		c, err := New(WithLogger(nil))
+		assert.Error(t, err)
+		assert.Nil(t, c)
+	})
+
+	t.Run("default-directory", func(t *testing.T) {
+		c, err := New()
+		assert.NoError(t, err)
+		assert.Equal(t, "/tce/conf/config/tce.config.center/sdk.json", c.ConfigFile())
+	})
+}
+
+// 同一进程中的多个 Client 读取不同的配置目录, 互不影响
+func TestClientIsolation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tcestuary")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	b, err := ioutil.ReadFile("./_example/sdk.json")
+	assert.NoError(t, err)
+	content := strings.Replace(string(b), `"port": 22003`, `"port": 3306`, 1)
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "sdk.json"), []byte(content), 0644))
+
+	a, err := New(WithConfigDirectory("./_example"))
+	assert.NoError(t, err)
+	b2, err := New(WithConfigDirectory(dir))
+	assert.NoError(t, err)
+
+	ma, err := a.GetMysqlConfig("ocloud_api3.api_sync")
+	assert.NoError(t, err)
+	mb, err := b2.GetMysqlConfig("ocloud_api3.api_sync")
+	assert.NoError(t, err)
+
+	assert.Equal(t, 22003, ma.Port)
+	assert.Equal(t, 3306, mb.Port)
+	assert.Equal(t, ma.Password, mb.Password)
+
+	// 默认 Client 不受影响
+	m, err := GetMysqlConfig("ocloud_api3.api_sync")
+	assert.NoError(t, err)
+	assert.Equal(t, 22003, m.Port)
+
+	// 加解密组件基于各自的配置创建
+	s, err := b2.NewStorageSecurity()
+	assert.NoError(t, err)
+	ciphertext, err := s.Encrypt("hello")
+	assert.NoError(t, err)
+	plaintext, err := s.Decrypt(ciphertext)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", plaintext)
+}
+
+// passwd-secret 配置新算法后, 历史 AES+V1 密码仍使用 aeskey 解密
+// WithStorageSecret / WithTransportSecret 优先于进程级环境变量, 只影响当前 Client
+func TestClientSecretOption(t *testing.T) {
+	os.Setenv("STORAGE_SECRET", `{"method": "aes-256-gcm", "aes_key": "5c2bd12683ceefb8830abba988339e67"}`)
+	defer os.Unsetenv("STORAGE_SECRET")
+
+	conf := configcenter.SecretConfig{Method: "aes-256-gcm", AesKey: "0f3c3f40c60db7f32ce6a5e0143f09eb"}
+	a, err := New(WithConfigDirectory("./_example"), WithStorageSecret(conf), WithTransportSecret(conf))
+	assert.NoError(t, err)
+	b, err := New(WithConfigDirectory("./_example"))
+	assert.NoError(t, err)
+
+	sa, err := a.NewStorageSecurity()
+	assert.NoError(t, err)
+	sb, err := b.NewStorageSecurity()
+	assert.NoError(t, err)
+	ta, err := a.NewTransportSecurity()
+	assert.NoError(t, err)
+
+	ciphertext, err := sa.Encrypt("mysql_pass")
+	assert.NoError(t, err)
+	_, err = sb.Decrypt(ciphertext)
+	assert.Error(t, err)
+	plaintext, err := ta.Decrypt(ciphertext)
+	assert.NoError(t, err)
+	assert.Equal(t, "mysql_pass", plaintext)
+}
+
+func TestMysqlPasswdSecretMethod(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tcestuary")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	gcm, err := tcesecurity.NewAesGcmCrypto(tcesecurity.Aes256GcmAlgorithm, []byte("0f3c3f40c60db7f32ce6a5e0143f09eb"))
+	assert.NoError(t, err)
+	ciphertext, err := gcm.Encrypt("gcm_pass")
+	assert.NoError(t, err)
+
+	b, err := ioutil.ReadFile("./_example/sdk.json")
+	assert.NoError(t, err)
+	content := strings.Replace(string(b), `"aeskey": "f13c3f40c60db7f32ce6a5e0143f09ea"`,
+		`"aeskey": "f13c3f40c60db7f32ce6a5e0143f09ea", "method": "aes-256-gcm", "aes_key": "0f3c3f40c60db7f32ce6a5e0143f09eb"`, 1)
+	content = strings.Replace(content, "AES+V1+651baa08c1d1e9ec5a19fa7d90897e6322ab0a111b17ed5048ab52c1e6959e3c", ciphertext, 1)
+	file := filepath.Join(dir, "sdk.new.json")
+	assert.NoError(t, ioutil.WriteFile(file, []byte(content), 0644))
+
+	c, err := New(WithConfigFile(file))
+	assert.NoError(t, err)
+	m, err := c.GetMysqlConfig("ocloud_api3.api_sync")
+	assert.NoError(t, err)
+	assert.Equal(t, "gcm_pass", m.Password)
+
+	items, err := c.GetMysqlConfigAllRegion("dbsql_tcenter_CCDB4.CCDB4")
+	assert.NoError(t, err)
+	assert.Equal(t, "bHs6WmrGAfq2dnbQ", items[0].Password)
+
+	_, err = New(WithConfigFile(dir))
+	assert.Error(t, err)
+}
+
+// writeExampleConfig 修改 _example/sdk.json 后写入 file
+func writeExampleConfig(t *testing.T, file string, edit func(conf map[string]interface{})) {
+	b, err := ioutil.ReadFile("./_example/sdk.json")
+	assert.NoError(t, err)
+	var conf map[string]interface{}
+	assert.NoError(t, json.Unmarshal(b, &conf))
+	edit(conf)
+	b, err = json.Marshal(conf)
+	assert.NoError(t, err)
+	assert.NoError(t, ioutil.WriteFile(file, b, 0644))
+}
+
+// setMysqlPass 修改 mysql 配置项的密码, 多实例时依次使用 passwords
+func setMysqlPass(conf map[string]interface{}, name string, passwords ...string) {
+	switch v := conf["mysql"].(map[string]interface{})[name].(type) {
+	case map[string]interface{}:
+		v["pass"] = passwords[0]
+	case []interface{}:
+		for i, item := range v {
+			item.(map[string]interface{})["_service"].(map[string]interface{})["pass"] = passwords[i]
+		}
+	}
+}
+
+// 密钥环加密的数据库密码(包括带 key id 的 AES+V1 密文)使用 passwd-secret 解密, 同一次调用只创建一个加解密组件
+func TestMysqlPasswdSecretKeyring(t *testing.T) {
+	srv := kmstest.NewServer()
+	defer srv.Close()
+
+	dir, err := ioutil.TempDir("", "tcestuary")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	passwd := configcenter.SecretConfig{
+		V1Aeskey: "f13c3f40c60db7f32ce6a5e0143f09ea",
+		Keys: []configcenter.SecretConfig{
+			{ID: "2021", V1Aeskey: "5c2bd12683ceefb8830abba988339e67"},
+			{ID: "2022", Method: tcesecurity.KMSEnvelopeAlgorithm, KeyId: "passwd-key", SecretId: srv.SecretId, SecretKey: srv.SecretKey,
+				KMSServer: srv.Host(), KMSTransport: &configcenter.KMSTransport{CACert: srv.CACert()}},
+		},
+		ActiveKey: "2022",
+	}
+	// 第二个 region 实例
+	addRegion := func(conf map[string]interface{}) {
+		mysql := conf["mysql"].(map[string]interface{})
+		items := mysql["dbsql_tcenter_CCDB4"].([]interface{})
+		b, _ := json.Marshal(items[0])
+		var item map[string]interface{}
+		json.Unmarshal(b, &item)
+		item["_base"].(map[string]interface{})["region_id"] = 50000006
+		mysql["dbsql_tcenter_CCDB4"] = append(items, item)
+	}
+	file := filepath.Join(dir, "sdk.json")
+	writeExampleConfig(t, file, func(conf map[string]interface{}) {
+		conf["sdk"].(map[string]interface{})["passwd-secret"] = passwd
+		addRegion(conf)
+	})
+	c, err := New(WithConfigFile(file))
+	assert.NoError(t, err)
+
+	s, err := c.NewPasswdSecret()
+	assert.NoError(t, err)
+	envelope := make([]string, 2)
+	for i := range envelope {
+		envelope[i], err = s.Encrypt("envelope_pass")
+		assert.NoError(t, err)
+	}
+	keyed, err := tcesecurity.AesV1Encrypt([]byte(passwd.Keys[0].V1Aeskey), "keyed_pass")
+	assert.NoError(t, err)
+	keyed, err = tcesecurity.WithKeyID(keyed, "2021")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(keyed, "AES+V1.32303231+"))
+
+	writeExampleConfig(t, file, func(conf map[string]interface{}) {
+		conf["sdk"].(map[string]interface{})["passwd-secret"] = passwd
+		addRegion(conf)
+		setMysqlPass(conf, "ocloud_api3", keyed)
+		setMysqlPass(conf, "dbsql_tcenter_CCDB4", envelope...)
+	})
+	assert.NoError(t, c.Reload())
+
+	m, err := c.GetMysqlConfig("ocloud_api3.api_sync")
+	assert.NoError(t, err)
+	assert.Equal(t, "keyed_pass", m.Password)
+
+	decrypts := srv.Calls("Decrypt")
+	items, err := c.GetMysqlConfigAllRegion("dbsql_tcenter_CCDB4.CCDB4")
+	assert.NoError(t, err)
+	assert.Len(t, items, 2)
+	for _, item := range items {
+		assert.Equal(t, "envelope_pass", item.Password)
+	}
+	// 两个实例共用同一个数据密钥, 只解密一次
+	assert.Equal(t, decrypts+1, srv.Calls("Decrypt"))
+
+	// 不带 key id 的历史密文仍使用 aeskey 解密
+	zones, err := c.GetMysqlConfigAllZone("dbsql_yje_yujie_data.yujie_data")
+	assert.NoError(t, err)
+	assert.Equal(t, "bHs6WmrGAfq2dnbQ", zones[0].Password)
+}
+
+// tce-sdk-encipher 使用 passwd-secret 加密: aes-256-cbc 且 aes_key 与 aeskey 不同时, 生成不带 key id 的 AES+V1 密文.
+// 读取时先使用 aes_key 解密, 失败时使用 aeskey 解密历史密文
+func TestMysqlPasswdSecretCbcKey(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tcestuary")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "sdk.json")
+
+	cbc := func(conf map[string]interface{}) {
+		passwd := conf["sdk"].(map[string]interface{})["passwd-secret"].(map[string]interface{})
+		passwd["method"] = tcesecurity.Aes256CbcAlgorithm
+		passwd["aes_key"] = "0123456789abcdef0123456789abcdef"
+	}
+	writeExampleConfig(t, file, cbc)
+	c, err := New(WithConfigDirectory(dir))
+	assert.NoError(t, err)
+	s, err := c.NewPasswdSecret()
+	assert.NoError(t, err)
+	ciphertext, err := s.Encrypt("encipher_pass")
+	assert.NoError(t, err)
+	_, id := tcesecurity.SplitKeyID(ciphertext)
+	assert.Empty(t, id)
+
+	legacy, err := Encrypt("f13c3f40c60db7f32ce6a5e0143f09ea", "legacy_pass")
+	assert.NoError(t, err)
+	writeExampleConfig(t, file, func(conf map[string]interface{}) {
+		cbc(conf)
+		setMysqlPass(conf, "ocloud_api3", ciphertext)
+		setMysqlPass(conf, "dbsql_tcenter_CCDB4", legacy)
+	})
+
+	c, err = New(WithConfigDirectory(dir))
+	assert.NoError(t, err)
+	m, err := c.GetMysqlConfig("ocloud_api3.api_sync")
+	assert.NoError(t, err)
+	assert.Equal(t, "encipher_pass", m.Password)
+
+	items, err := c.GetMysqlConfigAllRegion("dbsql_tcenter_CCDB4.CCDB4")
+	assert.NoError(t, err)
+	assert.Equal(t, "legacy_pass", items[0].Password)
+
+	// aeskey 加密的历史密文不会被 aes_key 误解密
+	decrypt := c.passwordDecrypter(c.ConfigCenter())
+	for i := 0; i < 1000; i++ {
+		origin := tcesecurity.RandomSalt(16)
+		legacy, err := Encrypt("f13c3f40c60db7f32ce6a5e0143f09ea", origin)
+		assert.NoError(t, err)
+		plaintext, err := decrypt(legacy)
+		assert.NoError(t, err)
+		assert.Equal(t, origin, plaintext)
+	}
+}
@@ -3,6 +3,7 @@ package tcestuary
 import (
 	"testing"
 
+	"git.code.oa.com/tce-config/tcestuary-go/v4/tcesecurity"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -10,8 +11,8 @@ import (
 
 // AES密码: 加解密函数测试
 func TestAESFunc(t *testing.T) {
-	aeskey := newEncoder().RandomSalt(32)
-	origin := newEncoder().RandomSalt(16)
+	aeskey := tcesecurity.RandomSalt(32)
+	origin := tcesecurity.RandomSalt(16)
 
 	pass, err := Encrypt(aeskey, origin)
 	assert.NoError(t, err)
@@ -27,11 +28,34 @@ func TestAESFunc(t *testing.T) {
 // 产品需求: 配置文件同时支持明文密码和AES密码
 // 如果是明文密码, 则原样返回
 func TestSourceCipher(t *testing.T) {
-	aeskey := newEncoder().RandomSalt(32)
-	password := newEncoder().RandomSalt(16)
+	aeskey := tcesecurity.RandomSalt(32)
+	password := tcesecurity.RandomSalt(16)
 
 	tpass, err := Decrypt(aeskey, password)
 	assert.NoError(t, err)
 
 	assert.Equal(t, password, tpass)
 }
+
+// passwd-secret 默认算法与 Encrypt / Decrypt 的密文互通
+func TestPasswdSecretCompatible(t *testing.T) {
+	// 测试期间, 临时设置配置路径
+	SetConfigDirectory("./_example")
+
+	s, err := NewPasswdSecret()
+	assert.NoError(t, err)
+	aeskey := GetConfigCenterPtr().SDK.PasswdSecret.V1Aeskey
+
+	ciphertext, err := s.Encrypt("mysql_pass")
+	assert.NoError(t, err)
+	assert.NotEmpty(t, ciphertext)
+	plaintext, err := Decrypt(aeskey, ciphertext)
+	assert.NoError(t, err)
+	assert.Equal(t, "mysql_pass", plaintext)
+
+	ciphertext, err = Encrypt(aeskey, "redis_pass")
+	assert.NoError(t, err)
+	plaintext, err = s.Decrypt(ciphertext)
+	assert.NoError(t, err)
+	assert.Equal(t, "redis_pass", plaintext)
+}
@@ -3,7 +3,9 @@ package configcenter
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"log"
+	"strings"
 
 	"github.com/jinzhu/copier"
 )
@@ -49,6 +51,37 @@ type (
 		KMSServer  string `json:"kms_server,omitempty"`
 		SecretId   string `json:"secret_id,omitempty"`
 		SecretKey  string `json:"secret_key,omitempty"`
+		DataKeyTTL int    `json:"data_key_ttl,omitempty"` // kms-envelope 数据密钥缓存时间, 单位: 秒
+
+		KMSTransport *KMSTransport `json:"kms_transport,omitempty"` // kms-* 算法的传输配置, 默认校验服务端证书
+		KMSRetry     *KMSRetry     `json:"kms_retry,omitempty"`     // kms-* 算法的重试、熔断配置
+
+		// 密钥环, 用于密钥轮转. keys 中未配置的字段继承外层配置
+		ID        string         `json:"id,omitempty"`         // 密钥 ID, 仅用于 keys 中的密钥
+		Keys      []SecretConfig `json:"keys,omitempty"`       // 密钥列表
+		ActiveKey string         `json:"active_key,omitempty"` // 加密使用的密钥 ID
+	}
+
+	// KMSTransport KMS 客户端传输配置. 默认校验服务端证书, 使用系统 CA, 不使用代理
+	KMSTransport struct {
+		CAFile             string `json:"ca_file,omitempty"`              // CA 证书(PEM)路径
+		CACert             string `json:"ca_cert,omitempty"`              // CA 证书 PEM 内容
+		CertFile           string `json:"cert_file,omitempty"`            // 客户端证书路径, 双向认证时配置
+		KeyFile            string `json:"key_file,omitempty"`             // 客户端私钥路径
+		ServerName         string `json:"server_name,omitempty"`          // 校验证书使用的服务端名称, 默认取 kms_server 的主机名
+		InsecureSkipVerify bool   `json:"insecure_skip_verify,omitempty"` // 不校验服务端证书, 存在中间人攻击风险, 仅用于测试环境
+		Timeout            int    `json:"timeout,omitempty"`              // 请求超时, 单位: 秒, 默认 60
+		DialTimeout        int    `json:"dial_timeout,omitempty"`         // 建立连接超时, 单位: 秒, 默认 30
+		Proxy              string `json:"proxy,omitempty"`                // 代理地址, 如 http://proxy:8080
+	}
+
+	// KMSRetry KMS 调用重试、熔断配置. 未配置的字段使用默认值
+	KMSRetry struct {
+		MaxRetries       int `json:"max_retries,omitempty"`       // 最大重试次数, 默认 2, -1 表示不重试
+		BaseDelay        int `json:"base_delay,omitempty"`        // 首次重试等待时间, 单位: 毫秒, 默认 100
+		MaxDelay         int `json:"max_delay,omitempty"`         // 最大重试等待时间, 单位: 毫秒, 默认 2000
+		BreakerThreshold int `json:"breaker_threshold,omitempty"` // 连续失败次数达到阈值后熔断, 默认 5, -1 表示不熔断
+		BreakerCooldown  int `json:"breaker_cooldown,omitempty"`  // 熔断时间, 单位: 秒, 默认 10
 	}
 
 	// TSMConfig
@@ -87,6 +120,14 @@ type (
 		ZoneID     int    `json:"zone_id"`
 	}
 
+	// Gaia 属性信息, Gaia 从属于可用区
+	Gaia struct {
+		RegionID int    `json:"region_id"`
+		ZoneID   int    `json:"zone_id"`
+		GaiaID   int    `json:"gaia_id"`
+		GaiaName string `json:"gaia_name"`
+	}
+
 	// Mysql 映射基础支撑 dbsql 的资源描述
 	// 参考文档: http://tapd.oa.com/OneBank/markdown_wikis/#1010130691010773371@toc15
 	Mysql struct {
@@ -109,6 +150,7 @@ type (
 			Local   json.RawMessage `json:"local"`
 			Regions []Region        `json:"region_list"`
 			Zones   []Zone          `json:"zone_list"`
+			Gaias   []Gaia          `json:"gaia_list"`
 		} `json:"base"`
 		SDK struct {
 			PasswdSecret    SecretConfig `json:"passwd-secret"`
@@ -126,8 +168,10 @@ type (
 		Base struct {
 			Region       Region
 			Zone         Zone
+			Gaia         Gaia
 			Regions      []Region
 			Zones        []Zone
+			Gaias        []Gaia
 			ScopeExtInfo ScopeExtInfo
 		}
 		SDK struct {
@@ -139,11 +183,20 @@ type (
 			HashSecret      HashConfig
 		}
 		Mysqls map[string]*MysqlWrapper
+
+		// Services 所有中间件配置, 第一层 key 为中间件类型(mysql/redis/...), 第二层 key 为服务名称
+		Services map[string]map[string]*ServiceWrapper
+
+		// Declaration cc.declare.json 声明, nil 表示配置目录中没有声明文件
+		Declaration *Declaration
+
+		// DeclareIssues 声明与 sdk.json 不一致的问题, 包括按声明的资源级别解析时字段不合法而被忽略的 dbsql
+		DeclareIssues []DeclareIssue
 	}
 
-	// MysqlWrapper 当 scope = ALL_REGION / ALL_ZONE 时, 资源描述结构是 JSON 数组
+	// MysqlWrapper 当 scope = ALL_REGION / ALL_ZONE / ALL_GAIA 时, 资源描述结构是 JSON 数组
 	// 其它 scope 时, 是 JSON 对象
-	// Scope 可能取值: ScopeAllRegion / ScopeAllZone / ScopeFlat
+	// Scope 可能取值: ScopeAllRegion / ScopeAllZone / ScopeAllGaia / ScopeFlat
 	MysqlWrapper struct {
 		Scope  Scope
 		Object interface{}
@@ -160,17 +213,89 @@ type (
 		Base    Zone  `json:"_base"`
 		Service Mysql `json:"_service"`
 	}
+
+	// MysqlWithGaia dbsql.scope 属性为 all_gaia, 资源描述结构中有 Gaia 信息
+	MysqlWithGaia struct {
+		Base    Gaia  `json:"_base"`
+		Service Mysql `json:"_service"`
+	}
+
+	// ServiceWrapper 中间件资源描述, 与 MysqlWrapper 的 scope 判断规则相同
+	// Object 可能取值:
+	// ScopeFlat: json.RawMessage
+	// ScopeAllRegion: []*ServiceWithRegion
+	// ScopeAllZone: []*ServiceWithZone
+	// ScopeAllGaia: []*ServiceWithGaia
+	// 由使用方反序列化为具体的中间件结构
+	ServiceWrapper struct {
+		Kind   string
+		Scope  Scope
+		Object interface{}
+	}
+
+	// ServiceWithRegion scope 属性为 all_region, 资源描述结构中有 Region 信息
+	ServiceWithRegion struct {
+		Base    Region          `json:"_base"`
+		Service json.RawMessage `json:"_service"`
+	}
+
+	// ServiceWithZone scope 属性为 all_zone, 资源描述结构中有 Zone 信息
+	ServiceWithZone struct {
+		Base    Zone            `json:"_base"`
+		Service json.RawMessage `json:"_service"`
+	}
+
+	// ServiceWithGaia scope 属性为 all_gaia, 资源描述结构中有 Gaia 信息
+	ServiceWithGaia struct {
+		Base    Gaia            `json:"_base"`
+		Service json.RawMessage `json:"_service"`
+	}
+
+	// Endpoint 各类中间件资源描述的公共字段, 用于配置项检查
+	Endpoint struct {
+		Host string `json:"host"`
+		IP   string `json:"ip"`
+		IPV4 string `json:"ipv4"`
+		URL  string `json:"url"`
+		Port *int   `json:"port"`
+	}
 )
 
+// 中间件类型, 对应 sdk.json 中的顶层 key
+const (
+	KindMysql   = "mysql"
+	KindRedis   = "redis"
+	KindES      = "es"
+	KindZK      = "zk"
+	KindMongodb = "mongodb"
+	KindCSP     = "csp"
+	KindKafka   = "kafka"
+	KindCMQ     = "cmq"
+	KindHdfs    = "hdfs"
+)
+
+// ServiceKinds SDK 支持的中间件类型
+var ServiceKinds = []string{
+	KindMysql, KindRedis, KindES, KindZK, KindMongodb, KindCSP, KindKafka, KindCMQ, KindHdfs,
+}
+
 // NewConfigCenter 初始化结构内部资源
 func NewConfigCenter() *ConfigCenter {
 	return &ConfigCenter{
-		Mysqls: make(map[string]*MysqlWrapper, 0),
+		Mysqls:   make(map[string]*MysqlWrapper, 0),
+		Services: make(map[string]map[string]*ServiceWrapper, 0),
 	}
 }
 
-// Parse 解析配置文件
+// Parse 解析配置文件, 根据资源描述结构推断资源级别
 func (c *ConfigCenter) Parse(data []byte) error {
+	return c.ParseWithDeclaration(data, nil)
+}
+
+// ParseWithDeclaration 解析配置文件. declaration 中声明的服务按声明的资源级别解析,
+// 资源描述结构与声明不一致时, 忽略该配置项并记录到 DeclareIssues
+func (c *ConfigCenter) ParseWithDeclaration(data []byte, declaration *Declaration) error {
+	c.Declaration = declaration
 
 	sourceCC := new(OriginConfigCenter)
 	if err := json.Unmarshal(data, sourceCC); err != nil {
@@ -182,9 +307,10 @@ func (c *ConfigCenter) Parse(data []byte) error {
 		return err
 	}
 	if sourceCC.Base.Local != nil { // 兼容 conf.base 未声明的场景
-		if region, zone, scopeExtInfo, err := sourceCC.parseBaseLocal(sourceCC.Base.Local); err == nil {
+		if region, zone, gaia, scopeExtInfo, err := sourceCC.parseBaseLocal(sourceCC.Base.Local); err == nil {
 			c.Base.Region = *region
 			c.Base.Zone = *zone
+			c.Base.Gaia = *gaia
 			c.Base.ScopeExtInfo = *scopeExtInfo
 		} else {
 			return err
@@ -196,80 +322,248 @@ func (c *ConfigCenter) Parse(data []byte) error {
 		return err
 	}
 
-	// Mysql 解析
+	// Mysql 解析. 按声明的资源级别解析失败时记录到 DeclareIssues, 包括 _service 字段不合法
 	for dbsql, message := range sourceCC.Mysqls {
-		if w, err := sourceCC.parseMysqls(message); err == nil {
+		declared := declaration.FindScope(dbsql)
+		if w, err := sourceCC.parseMysqls(message, declared); err == nil {
 			c.Mysqls[dbsql] = w
-			//} else {
-			//	return err
+		} else if declared != ScopeUnknown {
+			c.addDeclareIssue(dbsql, err.Error())
+		}
+	}
+
+	// 中间件解析. 顶层 key 逐个解析, 单个中间件类型格式错误不影响其它类型
+	sections := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(data, &sections); err != nil {
+		return err
+	}
+	present := make(map[string]bool) // 出现过的配置项名称, 用于检查声明
+	for _, kind := range ServiceKinds {
+		message, ok := sections[kind]
+		if !ok {
+			continue
+		}
+		services := make(map[string]json.RawMessage)
+		if err := json.Unmarshal(message, &services); err != nil {
+			continue
+		}
+		c.Services[kind] = make(map[string]*ServiceWrapper, len(services))
+		for name, message := range services {
+			present[name] = true
+			declared := declaration.FindScope(name)
+			if w, err := parseService(kind, message, declared); err == nil {
+				c.Services[kind][name] = w
+			} else if declared != ScopeUnknown && kind != KindMysql { // mysql 在 Mysql 解析时已记录
+				c.addDeclareIssue(name, fmt.Sprintf("%s config does not match declared scope %s", kind, declared))
+			}
+		}
+	}
+
+	if declaration != nil {
+		// sdk 段的配置项以 _service_id 声明, 例如 passwd-secret
+		if message, ok := sections["sdk"]; ok {
+			secrets := make(map[string]json.RawMessage)
+			if err := json.Unmarshal(message, &secrets); err == nil {
+				for name := range secrets {
+					present[name] = true
+				}
+			}
 		}
+		c.checkDeclaration(present)
 	}
 
 	return nil
 }
 
 // Local 字段表示: 当前地域/可用区/Gaia. 配置中将信息揉合在一个 JSON 对象中传递, 逻辑上拆解开, 方便后续使用
-func (c *OriginConfigCenter) parseBaseLocal(message json.RawMessage) (*Region, *Zone, *ScopeExtInfo, error) {
-	region, zone, scopeExtInfo := new(Region), new(Zone), new(ScopeExtInfo)
+func (c *OriginConfigCenter) parseBaseLocal(message json.RawMessage) (*Region, *Zone, *Gaia, *ScopeExtInfo, error) {
+	region, zone, gaia, scopeExtInfo := new(Region), new(Zone), new(Gaia), new(ScopeExtInfo)
 
 	if err := json.Unmarshal(message, region); err != nil {
-		return nil, nil, nil, err
+		return nil, nil, nil, nil, err
 	}
 
 	if err := json.Unmarshal(message, zone); err != nil {
-		return nil, nil, nil, err
+		return nil, nil, nil, nil, err
+	}
+
+	if err := json.Unmarshal(message, gaia); err != nil {
+		return nil, nil, nil, nil, err
 	}
 
 	if err := json.Unmarshal(message, scopeExtInfo); err != nil {
-		return nil, nil, nil, err
+		return nil, nil, nil, nil, err
 	}
 
-	return region, zone, scopeExtInfo, nil
+	return region, zone, gaia, scopeExtInfo, nil
 }
 
 // 不同的 Scope 对应的资源描述结构不同, 此处根据描述结构类型, 将配置转化成格式化内存结构, 方便后续使用
-func (c *OriginConfigCenter) parseMysqls(message json.RawMessage) (*MysqlWrapper, error) {
-	w := new(MysqlWrapper)
-
-	// 判断是否为扁平资源描述
-	mysql := &Mysql{}
-	if err := json.Unmarshal(message, mysql); err == nil {
-		if mysqlErr := mysql.Valid(); mysqlErr == nil {
-			w.Scope = ScopeFlat
-			w.Object = mysql
+// declared 为声明的资源级别, ScopeUnknown 表示未声明, 按 detectScopes 的顺序推断
+func (c *OriginConfigCenter) parseMysqls(message json.RawMessage, declared Scope) (*MysqlWrapper, error) {
+	var invalid error // 资源描述结构匹配, 但字段不合法
+	for _, scope := range detectScopes(declared) {
+		w, err := parseMysqlAs(message, scope)
+		if err == nil {
 			return w, nil
 		}
+		if err != errScopeMismatch && invalid == nil {
+			invalid = err
+		}
+	}
+	switch {
+	case declared != ScopeUnknown && invalid != nil:
+		return nil, fmt.Errorf("mysql config does not match declared scope %s, %s", declared, invalid)
+	case declared != ScopeUnknown:
+		return nil, fmt.Errorf("mysql config does not match declared scope %s", declared)
 	}
+	return nil, errors.New("unknown msyql config type")
+}
 
-	// 判断是否为 ALL_REGION 数组类型
-	mysqlR := make([]*MysqlWithRegion, 0)
-	if err := json.Unmarshal(message, &mysqlR); err == nil && len(mysqlR) > 0 {
-		mr := mysqlR[0]
-		serviceErr, baseErr := mr.Service.Valid(), mr.Base.Valid()
-		if serviceErr == nil && baseErr == nil {
-			w.Scope = ScopeAllRegion
-			w.Object = mysqlR
+// errScopeMismatch 资源描述结构与 scope 不匹配
+var errScopeMismatch = errors.New("scope mismatch")
+
+// parseMysqlAs 按指定的资源描述结构解析.
+// 结构不匹配时返回 errScopeMismatch, 结构匹配但 _service 不合法时返回 Mysql.Valid 的错误
+func parseMysqlAs(message json.RawMessage, scope Scope) (*MysqlWrapper, error) {
+	w := &MysqlWrapper{Scope: scope}
+
+	switch scope {
+	case ScopeFlat:
+		// 判断是否为扁平资源描述
+		mysql := &Mysql{}
+		if err := json.Unmarshal(message, mysql); err == nil {
+			if err := mysql.Valid(); err != nil {
+				return nil, err
+			}
+			w.Object = mysql
 			return w, nil
 		}
+
+	case ScopeAllGaia:
+		// 判断是否为 ALL_GAIA 数组类型
+		mysqlG := make([]*MysqlWithGaia, 0)
+		if err := json.Unmarshal(message, &mysqlG); err == nil && len(mysqlG) > 0 {
+			mg := mysqlG[0]
+			if mg.Base.Valid() == nil {
+				if err := mg.Service.Valid(); err != nil {
+					return nil, err
+				}
+				w.Object = mysqlG
+				return w, nil
+			}
+		}
+
+	case ScopeAllRegion:
+		// 判断是否为 ALL_REGION 数组类型
+		mysqlR := make([]*MysqlWithRegion, 0)
+		if err := json.Unmarshal(message, &mysqlR); err == nil && len(mysqlR) > 0 {
+			mr := mysqlR[0]
+			if mr.Base.Valid() == nil {
+				if err := mr.Service.Valid(); err != nil {
+					return nil, err
+				}
+				w.Object = mysqlR
+				return w, nil
+			}
+		}
+
+	case ScopeAllZone:
+		// 判断是否为 ALL_ZONE 数组类型
+		mysqlZ := make([]*MysqlWithZone, 0)
+		if err := json.Unmarshal(message, &mysqlZ); err == nil && len(mysqlZ) > 0 {
+			mz := mysqlZ[0]
+			if mz.Base.Valid() == nil {
+				if err := mz.Service.Valid(); err != nil {
+					return nil, err
+				}
+				w.Object = mysqlZ
+				return w, nil
+			}
+		}
 	}
 
-	// 判断是否为 ALL_ZONE 数组类型
-	mysqlZ := make([]*MysqlWithZone, 0)
-	if err := json.Unmarshal(message, &mysqlZ); err == nil && len(mysqlZ) > 0 {
-		mz := mysqlZ[0]
-		serviceErr, baseErr := mz.Service.Valid(), mz.Base.Valid()
-		if serviceErr == nil && baseErr == nil {
-			w.Scope = ScopeAllZone
-			w.Object = mysqlZ
+	return nil, errScopeMismatch
+}
+
+// 中间件资源描述结构的判断规则与 parseMysqls 相同, 但只根据结构及 _base 判断资源级别,
+// 不检查 _service 中的字段, 缺少访问地址等问题不会导致配置项被忽略
+func parseService(kind string, message json.RawMessage, declared Scope) (*ServiceWrapper, error) {
+	for _, scope := range detectScopes(declared) {
+		if w := parseServiceAs(kind, message, scope); w != nil {
 			return w, nil
 		}
 	}
+	return nil, fmt.Errorf("unknown %s config type", kind)
+}
 
-	return nil, errors.New("unknown msyql config type")
+// parseServiceAs 按指定的资源描述结构解析, 不匹配时返回 nil
+func parseServiceAs(kind string, message json.RawMessage, scope Scope) *ServiceWrapper {
+	w := &ServiceWrapper{Kind: kind, Scope: scope}
+
+	switch scope {
+	case ScopeFlat:
+		// 判断是否为扁平资源描述. 只判断结构, 字段内容由使用方解析, 问题通过 tcestuary.Validate 检查
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(message, &fields); err == nil && fields != nil {
+			w.Object = message
+			return w
+		}
+
+	case ScopeAllGaia:
+		// 判断是否为 ALL_GAIA 数组类型
+		serviceG := make([]*ServiceWithGaia, 0)
+		if err := json.Unmarshal(message, &serviceG); err == nil && len(serviceG) > 0 {
+			if err := serviceG[0].Base.Valid(); err == nil {
+				w.Object = serviceG
+				return w
+			}
+		}
+
+	case ScopeAllRegion:
+		// 判断是否为 ALL_REGION 数组类型
+		serviceR := make([]*ServiceWithRegion, 0)
+		if err := json.Unmarshal(message, &serviceR); err == nil && len(serviceR) > 0 {
+			if err := serviceR[0].Base.Valid(); err == nil {
+				w.Object = serviceR
+				return w
+			}
+		}
+
+	case ScopeAllZone:
+		// 判断是否为 ALL_ZONE 数组类型
+		serviceZ := make([]*ServiceWithZone, 0)
+		if err := json.Unmarshal(message, &serviceZ); err == nil && len(serviceZ) > 0 {
+			if err := serviceZ[0].Base.Valid(); err == nil {
+				w.Object = serviceZ
+				return w
+			}
+		}
+	}
+
+	return nil
+}
+
+// FindService 查找中间件配置, kind 取值参考 ServiceKinds.
+// nil 表示: 配置中不存在, 或资源描述结构不合法
+func (c *ConfigCenter) FindService(kind string, name string) *ServiceWrapper {
+	if services, ok := c.Services[kind]; ok {
+		return services[name]
+	}
+	return nil
+}
+
+// FindServiceScope 判断中间件资源级别, 可能是: ScopeAllRegion / ScopeAllZone / ScopeAllGaia / ScopeFlat
+// 配置文件中不存在时, 返回 ScopeUnknown
+func (c *ConfigCenter) FindServiceScope(kind string, name string) Scope {
+	if w := c.FindService(kind, name); w != nil {
+		return w.Scope
+	}
+	return ScopeUnknown
 }
 
 // FindMysqlScope 判断资源级别
-// Mysql 资源级别可能是: ScopeAllRegion / ScopeAllZone / ScopeFlat
+// Mysql 资源级别可能是: ScopeAllRegion / ScopeAllZone / ScopeAllGaia / ScopeFlat
 //
 // 如果, 配置文件中不存在 dbsql, 返回 ErrNotFound
 func (c *ConfigCenter) FindMysqlScope(dbsql string) Scope {
@@ -280,34 +574,75 @@ func (c *ConfigCenter) FindMysqlScope(dbsql string) Scope {
 }
 
 // FindMysql 判断 database 是否属于 dbsql 实例
-// nil 表示: database 配置中不存在
+// nil 表示: database 配置中不存在, 或 dbsql 不是 flat 级别
 func (c *ConfigCenter) FindMysql(dbsql string, database string) *Mysql {
-	if mysql, ok := c.Mysqls[dbsql]; ok {
-		for _, dbname := range mysql.Object.(*Mysql).Databases {
-			if dbname == database {
-				return mysql.Object.(*Mysql)
-			}
+	if w, ok := c.Mysqls[dbsql]; ok {
+		if mysql, ok := w.Object.(*Mysql); ok && mysql.hasDatabase(database) {
+			return mysql
 		}
 	}
 	return nil
 }
 
-// FindMysqlAllRegion 判断 database 是否属于 dbsql 实例
-// nil 表示: database 配置中不存在
+// FindMysqlAllRegion 查找 dbsql 中包含 database 的 region 实例
+// nil 表示: database 配置中不存在, 或 dbsql 不是 all_region 级别
 func (c *ConfigCenter) FindMysqlAllRegion(dbsql string, database string) []*MysqlWithRegion {
-	if w, ok := c.Mysqls[dbsql]; ok {
-		return w.Object.([]*MysqlWithRegion)
+	w, ok := c.Mysqls[dbsql]
+	if !ok {
+		return nil
+	}
+	all, _ := w.Object.([]*MysqlWithRegion)
+	var mysqls []*MysqlWithRegion
+	for _, m := range all {
+		if m.Service.hasDatabase(database) {
+			mysqls = append(mysqls, m)
+		}
 	}
-	return nil
+	return mysqls
 }
 
-// FindMysqlAllZone 判断 database 是否属于 dbsql 实例
-// nil 表示: database 配置中不存在
+// FindMysqlAllZone 查找 dbsql 中包含 database 的 zone 实例
+// nil 表示: database 配置中不存在, 或 dbsql 不是 all_zone 级别
 func (c *ConfigCenter) FindMysqlAllZone(dbsql string, database string) []*MysqlWithZone {
-	if w, ok := c.Mysqls[dbsql]; ok {
-		return w.Object.([]*MysqlWithZone)
+	w, ok := c.Mysqls[dbsql]
+	if !ok {
+		return nil
+	}
+	all, _ := w.Object.([]*MysqlWithZone)
+	var mysqls []*MysqlWithZone
+	for _, m := range all {
+		if m.Service.hasDatabase(database) {
+			mysqls = append(mysqls, m)
+		}
 	}
-	return nil
+	return mysqls
+}
+
+// FindMysqlAllGaia 查找 dbsql 中包含 database 的 gaia 实例
+// nil 表示: database 配置中不存在, 或 dbsql 不是 all_gaia 级别
+func (c *ConfigCenter) FindMysqlAllGaia(dbsql string, database string) []*MysqlWithGaia {
+	w, ok := c.Mysqls[dbsql]
+	if !ok {
+		return nil
+	}
+	all, _ := w.Object.([]*MysqlWithGaia)
+	var mysqls []*MysqlWithGaia
+	for _, m := range all {
+		if m.Service.hasDatabase(database) {
+			mysqls = append(mysqls, m)
+		}
+	}
+	return mysqls
+}
+
+// hasDatabase 判断 database 是否在 db_name_list 中
+func (m *Mysql) hasDatabase(database string) bool {
+	for _, dbname := range m.Databases {
+		if dbname == database {
+			return true
+		}
+	}
+	return false
 }
 
 // FindRegion 查找 Region 属性
@@ -330,63 +665,183 @@ func (c *ConfigCenter) FindZone(regionID int, zoneID int) *Zone {
 	return nil
 }
 
-// Debug 输出已加载配置内容, 支持异常调试
+// FindGaia 查找 Gaia 属性
+func (c *ConfigCenter) FindGaia(gaiaID int) *Gaia {
+	for i, gaia := range c.Base.Gaias {
+		if gaia.GaiaID == gaiaID {
+			return &c.Base.Gaias[i]
+		}
+	}
+	return nil
+}
+
+// Debug 输出已加载配置内容, 支持异常调试. 密钥、密码等敏感字段只输出长度及指纹
 func (c *ConfigCenter) Debug() {
-	buff := []byte{}
+	c.debug(false)
+}
 
-	buff, _ = json.MarshalIndent(c.Base, "", "  ")
-	log.Printf("base: %s\n", string(buff))
+// DebugUnsafe 输出已加载配置内容, 包括密钥、密码明文, 仅用于本地调试
+func (c *ConfigCenter) DebugUnsafe() {
+	c.debug(true)
+}
+
+func (c *ConfigCenter) debug(unsafe bool) {
+	marshal := func(v interface{}) string {
+		buff, _ := json.MarshalIndent(v, "", "  ")
+		if !unsafe {
+			if redacted, err := RedactJSON(buff); err == nil {
+				buff = redacted
+			}
+		}
+		return string(buff)
+	}
 
-	buff, _ = json.MarshalIndent(c.SDK, "", "  ")
-	log.Printf("sdk: %s\n", string(buff))
+	log.Printf("base: %s\n", marshal(c.Base))
+
+	log.Printf("sdk: %s\n", marshal(c.SDK))
 
 	for dbsql, mysql := range c.Mysqls {
-		buff, _ = json.MarshalIndent(*mysql, "", "  ")
-		log.Printf("mysql %s %+v\n", dbsql, string(buff))
+		log.Printf("mysql %s %+v\n", dbsql, marshal(*mysql))
 	}
-}
 
-// Valid 检查配置项
-func (region *Region) Valid() error {
-	if region.RegionID <= 0 {
-		return errors.New("region id error")
-	}
-	if region.RegionName == "" {
-		return errors.New("region name empty")
+	for kind, services := range c.Services {
+		if kind == KindMysql {
+			continue
+		}
+		for name, service := range services {
+			log.Printf("%s %s %+v\n", kind, name, marshal(*service))
+		}
 	}
-	return nil
-}
 
-// Valid 检查配置项
-func (zone *Zone) Valid() error {
-	if zone.RegionID <= 0 {
-		return errors.New("region id error")
-	}
-	if zone.ZoneID <= 0 {
-		return errors.New("zone id error")
+	if c.Declaration != nil {
+		for _, service := range c.Declaration.ServiceList() {
+			log.Printf("declare %s scope: %s, service_id: %s\n", service.Name, service.Scope, service.ServiceID)
+		}
 	}
-	if zone.ZoneName == "" {
-		return errors.New("zone name empty")
+	for _, issue := range c.DeclareIssues {
+		log.Printf("declare issue: %s\n", issue)
 	}
-	return nil
 }
 
-// Valid 配置项检查
-func (mysql *Mysql) Valid() error {
-	if mysql.Host == "" {
-		return errors.New("host is empty")
+// FieldError 配置项字段检查错误. Field 为字段在 sdk.json 中的名称, 为空表示整个配置项
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+func (e *FieldError) Error() string {
+	return e.Message
+}
+
+// FieldErrors Valid 发现的全部字段错误
+type FieldErrors []*FieldError
+
+func (errs FieldErrors) Error() string {
+	messages := make([]string, len(errs))
+	for i, e := range errs {
+		messages[i] = e.Message
 	}
-	if mysql.IP == "" {
-		return errors.New("ip is empty")
+	return strings.Join(messages, "; ")
+}
+
+// check 条件不满足时记录字段错误
+func (errs *FieldErrors) check(ok bool, field, format string, args ...interface{}) {
+	if !ok {
+		*errs = append(*errs, &FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
 	}
-	if mysql.Port < 1 || mysql.Port > 65535 {
-		return errors.New("port not valid")
+}
+
+// err 没有字段错误时返回 nil
+func (errs FieldErrors) err() error {
+	if len(errs) == 0 {
+		return nil
 	}
-	if mysql.User == "" {
-		return errors.New("user is empty")
+	return errs
+}
+
+// Valid 检查配置项, 返回 FieldErrors
+func (region *Region) Valid() error {
+	var errs FieldErrors
+	errs.check(region.RegionID > 0, "region_id", "region id error")
+	errs.check(region.RegionName != "", "region_name", "region name empty")
+	return errs.err()
+}
+
+// Valid 检查配置项, 返回 FieldErrors
+func (zone *Zone) Valid() error {
+	var errs FieldErrors
+	errs.check(zone.RegionID > 0, "region_id", "region id error")
+	errs.check(zone.ZoneID > 0, "zone_id", "zone id error")
+	errs.check(zone.ZoneName != "", "zone_name", "zone name empty")
+	return errs.err()
+}
+
+// Valid 检查配置项, 返回 FieldErrors
+func (gaia *Gaia) Valid() error {
+	var errs FieldErrors
+	errs.check(gaia.RegionID > 0, "region_id", "region id error")
+	errs.check(gaia.ZoneID > 0, "zone_id", "zone id error")
+	errs.check(gaia.GaiaID > 0, "gaia_id", "gaia id error")
+	errs.check(gaia.GaiaName != "", "gaia_name", "gaia name empty")
+	return errs.err()
+}
+
+// Valid 配置项检查: 至少提供一种访问地址, port 存在时必须合法. 返回 FieldErrors
+func (endpoint *Endpoint) Valid() error {
+	var errs FieldErrors
+	errs.check(endpoint.Host != "" || endpoint.IP != "" || endpoint.IPV4 != "" || endpoint.URL != "", "",
+		"address is empty, one of host / ip / ipv4 / url is required")
+	if endpoint.Port != nil {
+		errs.check(*endpoint.Port >= 1 && *endpoint.Port <= 65535, "port", "port not valid: %d", *endpoint.Port)
 	}
-	if mysql.Password == "" {
-		return errors.New("password is empty")
+	return errs.err()
+}
+
+// Valid 配置项检查, 返回 FieldErrors
+func (mysql *Mysql) Valid() error {
+	var errs FieldErrors
+	errs.check(mysql.Host != "", "host", "host is empty")
+	errs.check(mysql.IP != "", "ipv4", "ip is empty")
+	errs.check(mysql.Port >= 1 && mysql.Port <= 65535, "port", "port not valid: %d", mysql.Port)
+	errs.check(mysql.User != "", "user", "user is empty")
+	errs.check(mysql.Password != "", "pass", "password is empty")
+	return errs.err()
+}
+
+// KeyConfig 返回密钥环中 id 对应的密钥配置, 未配置的字段继承外层配置
+func (secret SecretConfig) KeyConfig(id string) (SecretConfig, bool) {
+	for _, key := range secret.Keys {
+		if key.ID != id {
+			continue
+		}
+		merged := secret
+		merged.ID, merged.Keys, merged.ActiveKey = key.ID, nil, ""
+		for dst, src := range map[*string]string{
+			&merged.Method:     key.Method,
+			&merged.PublicKey:  key.PublicKey,
+			&merged.PrivateKey: key.PrivateKey,
+			&merged.AesKey:     key.AesKey,
+			&merged.V1Aeskey:   key.V1Aeskey,
+			&merged.Sm4Key:     key.Sm4Key,
+			&merged.KeyId:      key.KeyId,
+			&merged.KMSServer:  key.KMSServer,
+			&merged.SecretId:   key.SecretId,
+			&merged.SecretKey:  key.SecretKey,
+		} {
+			if src != "" {
+				*dst = src
+			}
+		}
+		if key.DataKeyTTL != 0 {
+			merged.DataKeyTTL = key.DataKeyTTL
+		}
+		if key.KMSTransport != nil {
+			merged.KMSTransport = key.KMSTransport
+		}
+		if key.KMSRetry != nil {
+			merged.KMSRetry = key.KMSRetry
+		}
+		return merged, true
 	}
-	return nil
+	return SecretConfig{}, false
 }
@@ -0,0 +1,331 @@
+package configcenter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const servicesConfig = `{
+  "mysql": {
+    "ocloud_api3": {
+      "db_name_list": ["api_sync"],
+      "host": "db-2.db",
+      "ipv4": "10.21.70.10",
+      "pass": "mysql_pass",
+      "port": 22003,
+      "user": "mysql_user"
+    }
+  },
+  "redis": {
+    "ckv_cas": {"host": "redis.db", "ip": "10.0.0.1", "port": 6379, "password": "redis_pass"},
+    "empty": {},
+    "bad_port": {"host": "redis.db", "port": 0}
+  },
+  "kafka": {
+    "wtag": [
+      {
+        "_base": {"region_id": 50000005, "region_name": "chongqing"},
+        "_service": {"host": "kafka.cq", "port": 9092, "password": "kafka_pass"}
+      }
+    ]
+  },
+  "zk": {
+    "yunapi3_zk": [
+      {
+        "_base": {"region_id": 50000005, "zone_id": 50050002, "zone_name": "yf-1"},
+        "_service": {"url": "zk://10.0.0.2:2181"}
+      }
+    ]
+  },
+  "hdfs": {
+    "file_server": [
+      {
+        "_base": {"region_id": 50000005, "zone_id": 50050002, "zone_name": "yf-1", "gaia_id": 50050201, "gaia_name": "gaia-1"},
+        "_service": {"host": "hdfs.gaia-1", "port": 8020}
+      }
+    ]
+  },
+  "es": "not an object"
+}`
+
+func TestParseServices(t *testing.T) {
+	c := NewConfigCenter()
+	assert.NoError(t, c.Parse([]byte(servicesConfig)))
+
+	t.Run("flat", func(t *testing.T) {
+		w := c.FindService(KindRedis, "ckv_cas")
+		assert.NotNil(t, w)
+		assert.Equal(t, ScopeFlat, w.Scope)
+		assert.Equal(t, KindRedis, w.Kind)
+
+		var redis struct {
+			Host     string `json:"host"`
+			Password string `json:"password"`
+		}
+		assert.NoError(t, json.Unmarshal(w.Object.(json.RawMessage), &redis))
+		assert.Equal(t, "redis.db", redis.Host)
+		assert.Equal(t, "redis_pass", redis.Password)
+	})
+
+	t.Run("mysql", func(t *testing.T) {
+		assert.Equal(t, ScopeFlat, c.FindServiceScope(KindMysql, "ocloud_api3"))
+		assert.Equal(t, ScopeFlat, c.FindMysqlScope("ocloud_api3"))
+	})
+
+	t.Run("all-region", func(t *testing.T) {
+		w := c.FindService(KindKafka, "wtag")
+		assert.NotNil(t, w)
+		assert.Equal(t, ScopeAllRegion, w.Scope)
+		services := w.Object.([]*ServiceWithRegion)
+		assert.Len(t, services, 1)
+		assert.Equal(t, 50000005, services[0].Base.RegionID)
+	})
+
+	t.Run("all-zone", func(t *testing.T) {
+		w := c.FindService(KindZK, "yunapi3_zk")
+		assert.NotNil(t, w)
+		assert.Equal(t, ScopeAllZone, w.Scope)
+		services := w.Object.([]*ServiceWithZone)
+		assert.Equal(t, 50050002, services[0].Base.ZoneID)
+	})
+
+	t.Run("all-gaia", func(t *testing.T) {
+		w := c.FindService(KindHdfs, "file_server")
+		assert.NotNil(t, w)
+		assert.Equal(t, ScopeAllGaia, w.Scope)
+		services := w.Object.([]*ServiceWithGaia)
+		assert.Equal(t, 50050201, services[0].Base.GaiaID)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		// 字段不合法时保留配置项, 由使用方处理
+		assert.Equal(t, ScopeFlat, c.FindServiceScope(KindRedis, "empty"))
+		assert.Equal(t, ScopeFlat, c.FindServiceScope(KindRedis, "bad_port"))
+		assert.Nil(t, c.FindService(KindRedis, "not_exist"))
+		assert.Nil(t, c.FindService(KindES, "any"))
+		assert.Equal(t, ScopeUnknown, c.FindServiceScope(KindCMQ, "any"))
+	})
+}
+
+const declareConfig = `{
+  "config": {
+    "base": {"_scop": ["global", "region", "zone", "all_region", "all_zone"]},
+    "service": {
+      "ckv_cas": {"_service_id": "redis-ckv_cas"},
+      "wtag": {"_scop": "all_zone", "_service_id": "kafka-wtag"},
+      "yunapi3_zk": {"_scop": "all_zone", "_service_id": "zk-yunapi3_zk"},
+      "zone_with_region_name": {"_scop": "all_zone", "_service_id": "redis-zone"},
+      "file_server": {"_scop": "all_gaia", "_service_id": "hdfs-file_server"},
+      "bad_scope": {"_scop": "all_city", "_service_id": "redis-bad_scope"},
+      "not_exist": {"_service_id": "redis-not_exist"},
+      "passwd_secret": {"_service_id": "passwd-secret"}
+    }
+  }
+}`
+
+func TestParseWithDeclaration(t *testing.T) {
+	d, err := ParseDeclaration([]byte(declareConfig))
+	assert.NoError(t, err)
+	assert.Equal(t, ScopeFlat, d.FindScope("ckv_cas"))
+	assert.Equal(t, ScopeAllZone, d.FindScope("wtag"))
+	assert.Equal(t, ScopeUnknown, d.FindScope("bad_scope"))
+	assert.Equal(t, ScopeUnknown, d.FindScope("undeclared"))
+	assert.Equal(t, "bad_scope", d.ServiceList()[0].Name)
+
+	// Zone 信息中带有 region_name 时, 按结构推断会被识别为 all_region
+	config := strings.Replace(servicesConfig, `"bad_port": {"host": "redis.db", "port": 0}`,
+		`"bad_port": {"host": "redis.db", "port": 0},
+    "zone_with_region_name": [{
+      "_base": {"region_id": 50000005, "region_name": "chongqing", "zone_id": 50050002, "zone_name": "yf-1"},
+      "_service": {"host": "redis.yf-1", "port": 6379}
+    }],
+    "bad_scope": {"host": "redis.db"}`, 1)
+	config = strings.Replace(config, `"es": "not an object"`, `"sdk": {"passwd-secret": {}}`, 1)
+
+	inferred := NewConfigCenter()
+	assert.NoError(t, inferred.Parse([]byte(config)))
+	assert.Equal(t, ScopeAllRegion, inferred.FindServiceScope(KindRedis, "zone_with_region_name"))
+	assert.Empty(t, inferred.DeclareIssues)
+
+	c := NewConfigCenter()
+	assert.NoError(t, c.ParseWithDeclaration([]byte(config), d))
+
+	t.Run("declared-scope", func(t *testing.T) {
+		assert.Equal(t, ScopeAllZone, c.FindServiceScope(KindRedis, "zone_with_region_name"))
+		assert.Equal(t, ScopeAllZone, c.FindServiceScope(KindZK, "yunapi3_zk"))
+		assert.Equal(t, ScopeFlat, c.FindServiceScope(KindRedis, "ckv_cas"))
+		assert.Equal(t, ScopeAllGaia, c.FindServiceScope(KindHdfs, "file_server"))
+		// 声明不合法时按结构推断
+		assert.Equal(t, ScopeFlat, c.FindServiceScope(KindRedis, "bad_scope"))
+		// 未声明时按结构推断
+		assert.Equal(t, ScopeFlat, c.FindMysqlScope("ocloud_api3"))
+	})
+
+	t.Run("scope-mismatch", func(t *testing.T) {
+		assert.Nil(t, c.FindService(KindKafka, "wtag"))
+	})
+
+	t.Run("issues", func(t *testing.T) {
+		issues := make([]string, 0)
+		for _, issue := range c.DeclareIssues {
+			issues = append(issues, issue.String())
+		}
+		assert.ElementsMatch(t, []string{
+			`wtag: kafka config does not match declared scope all_zone`,
+			`bad_scope: unknown scope "all_city"`,
+			`file_server: scope "all_gaia" not in base._scop`,
+			`not_exist: declared but missing in sdk.json`,
+		}, issues)
+	})
+}
+
+// 资源级别不匹配时返回 nil, 不会 panic; 只返回包含 database 的实例
+func TestFindMysqlAll(t *testing.T) {
+	c := NewConfigCenter()
+	assert.NoError(t, c.Parse([]byte(`{
+  "mysql": {
+    "flat": {"db_name_list": ["api_sync"], "host": "db-1", "ipv4": "10.0.0.1", "port": 3306, "user": "u", "pass": "p"},
+    "regions": [
+      {"_base": {"region_id": 1, "region_name": "r1"}, "_service": {"db_name_list": ["a"], "host": "db-r1", "ipv4": "10.0.0.2", "port": 3306, "user": "u", "pass": "p"}},
+      {"_base": {"region_id": 2, "region_name": "r2"}, "_service": {"db_name_list": ["a", "b"], "host": "db-r2", "ipv4": "10.0.0.3", "port": 3306, "user": "u", "pass": "p"}}
+    ]
+  }
+}`)))
+
+	assert.NotNil(t, c.FindMysql("flat", "api_sync"))
+	assert.Nil(t, c.FindMysql("flat", "not_exist"))
+	assert.Nil(t, c.FindMysql("regions", "a"))
+
+	assert.Len(t, c.FindMysqlAllRegion("regions", "a"), 2)
+	b := c.FindMysqlAllRegion("regions", "b")
+	assert.Len(t, b, 1)
+	assert.Equal(t, 2, b[0].Base.RegionID)
+	assert.Nil(t, c.FindMysqlAllRegion("regions", "not_exist"))
+	assert.Nil(t, c.FindMysqlAllRegion("flat", "api_sync"))
+
+	assert.Nil(t, c.FindMysqlAllZone("regions", "a"))
+	assert.Nil(t, c.FindMysqlAllGaia("regions", "a"))
+	assert.Nil(t, c.FindMysqlAllGaia("not_exist", "a"))
+}
+
+// 按声明的资源级别解析 dbsql 失败时记录问题, 字段不合法时给出具体字段
+func TestParseMysqlDeclareIssue(t *testing.T) {
+	d, err := ParseDeclaration([]byte(`{
+  "config": {
+    "base": {"_scop": ["global", "region", "all_region", "all_zone"]},
+    "service": {
+      "no_pass": {"_scop": "all_region", "_service_id": "dbsql-no_pass"},
+      "mismatch": {"_scop": "all_zone", "_service_id": "dbsql-mismatch"},
+      "flat_no_ip": {"_service_id": "dbsql-flat_no_ip"},
+      "ok": {"_scop": "all_region", "_service_id": "dbsql-ok"}
+    }
+  }
+}`))
+	assert.NoError(t, err)
+
+	region := `{"_base": {"region_id": 1, "region_name": "r1"}, "_service": {"db_name_list": ["a"], "host": "db-r1", "ipv4": "10.0.0.2", "port": 3306, "user": "u", "pass": "p"}}`
+	c := NewConfigCenter()
+	assert.NoError(t, c.ParseWithDeclaration([]byte(`{
+  "mysql": {
+    "no_pass": [`+strings.Replace(region, `"pass": "p"`, `"pass": ""`, 1)+`],
+    "mismatch": [`+region+`],
+    "flat_no_ip": {"db_name_list": ["a"], "host": "db-1", "port": 3306, "user": "u", "pass": "p"},
+    "undeclared_no_ip": {"db_name_list": ["a"], "host": "db-1", "port": 3306, "user": "u", "pass": "p"},
+    "ok": [`+region+`]
+  }
+}`), d))
+
+	assert.Equal(t, ScopeAllRegion, c.FindMysqlScope("ok"))
+	for _, dbsql := range []string{"no_pass", "mismatch", "flat_no_ip", "undeclared_no_ip"} {
+		assert.Equal(t, ScopeUnknown, c.FindMysqlScope(dbsql), dbsql)
+	}
+
+	issues := make([]string, 0)
+	for _, issue := range c.DeclareIssues {
+		issues = append(issues, issue.String())
+	}
+	assert.ElementsMatch(t, []string{
+		`no_pass: mysql config does not match declared scope all_region, password is empty`,
+		`mismatch: mysql config does not match declared scope all_zone`,
+		`flat_no_ip: mysql config does not match declared scope flat, ip is empty`,
+	}, issues)
+}
+
+func TestDebugRedact(t *testing.T) {
+	c := NewConfigCenter()
+	assert.NoError(t, c.Parse([]byte(servicesConfig)))
+	c.SDK.PasswdSecret = SecretConfig{Method: "sm4", Sm4Key: "sm4_secret_key",
+		Keys: []SecretConfig{{ID: "v2", AesKey: "aes_secret_key"}}}
+
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	c.Debug()
+	out := buf.String()
+	for _, secret := range []string{"mysql_pass", "redis_pass", "sm4_secret_key", "aes_secret_key"} {
+		assert.NotContains(t, out, secret)
+	}
+	assert.Contains(t, out, Redact("mysql_pass"))
+	assert.Contains(t, out, Redact("sm4_secret_key"))
+	assert.Contains(t, out, "redis.db")
+
+	buf.Reset()
+	c.DebugUnsafe()
+	assert.Contains(t, buf.String(), "mysql_pass")
+	assert.Contains(t, buf.String(), "sm4_secret_key")
+}
+
+func TestRedactString(t *testing.T) {
+	assert.Equal(t, "", Redact(""))
+	assert.True(t, strings.HasPrefix(Redact("secret"), "[redacted len=6 sha256="))
+	assert.Equal(t, Redact("secret"), Redact("secret"))
+	assert.NotEqual(t, Redact("secret"), Redact("secret2"))
+
+	conf := SecretConfig{Method: "sm4", Sm4Key: "sm4_secret_key",
+		Keys: []SecretConfig{{ID: "v2", AesKey: "aes_secret_key"}}}
+	for _, out := range []string{fmt.Sprintf("%v", conf), fmt.Sprintf("%+v", &conf), fmt.Sprint([]SecretConfig{conf})} {
+		assert.NotContains(t, out, "sm4_secret_key")
+		assert.NotContains(t, out, "aes_secret_key")
+		assert.Contains(t, out, Redact("aes_secret_key"))
+	}
+	assert.Equal(t, "sm4_secret_key", conf.Sm4Key)
+	assert.Equal(t, Redact("aes_secret_key"), conf.Redacted().Keys[0].AesKey)
+	assert.Equal(t, "aes_secret_key", conf.Keys[0].AesKey)
+
+	db := Mysql{Host: "db-2.db", Password: "mysql_pass"}
+	assert.NotContains(t, fmt.Sprintf("%v", db), "mysql_pass")
+	assert.Contains(t, fmt.Sprintf("%v", &db), "db-2.db")
+}
+
+func TestValidFieldErrors(t *testing.T) {
+	db := Mysql{Host: "db-2.db", IP: "10.21.70.10", User: "root"}
+	err := db.Valid()
+	errs, ok := err.(FieldErrors)
+	assert.True(t, ok)
+	assert.Equal(t, FieldErrors{
+		{Field: "port", Message: "port not valid: 0"},
+		{Field: "pass", Message: "password is empty"},
+	}, errs)
+	assert.Equal(t, "port not valid: 0; password is empty", err.Error())
+
+	db.Port, db.Password = 3306, "mysql_pass"
+	assert.NoError(t, db.Valid())
+
+	port := 70000
+	errs, _ = (&Endpoint{Port: &port}).Valid().(FieldErrors)
+	assert.Len(t, errs, 2)
+	assert.Equal(t, "", errs[0].Field)
+	assert.Equal(t, "port", errs[1].Field)
+
+	assert.NoError(t, (&Region{RegionID: 1, RegionName: "chongqing"}).Valid())
+	errs, _ = (&Gaia{RegionID: 1, ZoneID: 1}).Valid().(FieldErrors)
+	assert.Equal(t, []string{"gaia_id", "gaia_name"}, []string{errs[0].Field, errs[1].Field})
+}
@@ -0,0 +1,179 @@
+package configcenter
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// scopeNames cc.declare.json 中 _scop 取值与 Scope 的对应关系
+var scopeNames = map[string]Scope{
+	"global":     ScopeGlobal,
+	"region":     ScopeRegion,
+	"zone":       ScopeZone,
+	"gaia":       ScopeGaia,
+	"all_region": ScopeAllRegion,
+	"all_zone":   ScopeAllZone,
+	"all_gaia":   ScopeAllGaia,
+}
+
+// ParseScope 将 cc.declare.json 中的 _scop 取值转换为 Scope, 不支持的取值返回 ScopeUnknown
+func ParseScope(name string) Scope {
+	if scope, ok := scopeNames[name]; ok {
+		return scope
+	}
+	return ScopeUnknown
+}
+
+// String 返回 cc.declare.json 中的 _scop 取值. ScopeFlat 表示未声明具体级别的扁平资源
+func (s Scope) String() string {
+	for name, scope := range scopeNames {
+		if scope == s {
+			return name
+		}
+	}
+	if s == ScopeFlat {
+		return "flat"
+	}
+	return "unknown"
+}
+
+type (
+	// OriginDeclaration cc.declare.json 原始结构
+	OriginDeclaration struct {
+		Config struct {
+			Base struct {
+				Scopes []string `json:"_scop"`
+			} `json:"base"`
+			Services map[string]struct {
+				Scope     string `json:"_scop"`
+				ServiceID string `json:"_service_id"`
+			} `json:"service"`
+		} `json:"config"`
+	}
+
+	// Declaration cc.declare.json 结构化表示, 声明业务引用的服务及其资源级别
+	Declaration struct {
+		// Scopes 部署架构支持的资源级别
+		Scopes []Scope
+
+		// Services key 为服务名称, 与 sdk.json 中的配置项名称一致
+		Services map[string]*DeclaredService
+	}
+
+	// DeclaredService 声明的服务
+	// Scope 可能取值:
+	// 未声明 _scop: ScopeFlat, 资源描述结构为 JSON 对象
+	// _scop 不合法: ScopeUnknown, 按资源描述结构推断
+	DeclaredService struct {
+		Name      string
+		ServiceID string
+		ScopeName string
+		Scope     Scope
+	}
+
+	// DeclareIssue cc.declare.json 与 sdk.json 不一致的问题, 不影响配置加载
+	DeclareIssue struct {
+		Service string
+		Message string
+	}
+)
+
+// ParseDeclaration 解析 cc.declare.json
+func ParseDeclaration(data []byte) (*Declaration, error) {
+	origin := new(OriginDeclaration)
+	if err := json.Unmarshal(data, origin); err != nil {
+		return nil, err
+	}
+
+	d := &Declaration{
+		Scopes:   make([]Scope, 0, len(origin.Config.Base.Scopes)),
+		Services: make(map[string]*DeclaredService, len(origin.Config.Services)),
+	}
+	for _, name := range origin.Config.Base.Scopes {
+		d.Scopes = append(d.Scopes, ParseScope(name))
+	}
+	for name, service := range origin.Config.Services {
+		s := &DeclaredService{
+			Name:      name,
+			ServiceID: service.ServiceID,
+			ScopeName: service.Scope,
+			Scope:     ScopeFlat,
+		}
+		if service.Scope != "" {
+			s.Scope = ParseScope(service.Scope)
+		}
+		d.Services[name] = s
+	}
+	return d, nil
+}
+
+// ServiceList 按名称排序的声明服务列表
+func (d *Declaration) ServiceList() []*DeclaredService {
+	services := make([]*DeclaredService, 0, len(d.Services))
+	for _, s := range d.Services {
+		services = append(services, s)
+	}
+	sort.Slice(services, func(i, j int) bool { return services[i].Name < services[j].Name })
+	return services
+}
+
+// FindScope 查找服务声明的资源级别, 未声明或声明不合法时返回 ScopeUnknown
+func (d *Declaration) FindScope(name string) Scope {
+	if d == nil {
+		return ScopeUnknown
+	}
+	if s, ok := d.Services[name]; ok {
+		return s.Scope
+	}
+	return ScopeUnknown
+}
+
+// supportScope 判断资源级别是否在 base._scop 中声明. 未声明 base._scop 时不检查
+func (d *Declaration) supportScope(scope Scope) bool {
+	if len(d.Scopes) == 0 {
+		return true
+	}
+	for _, s := range d.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func (issue DeclareIssue) String() string {
+	return fmt.Sprintf("%s: %s", issue.Service, issue.Message)
+}
+
+// detectScopes 资源描述结构的判断顺序.
+// 已声明资源级别时, 仅按声明的结构解析; 否则依次尝试: 扁平对象 / ALL_GAIA / ALL_REGION / ALL_ZONE.
+// Gaia 信息中同时包含 region_id / zone_id, 需要先于 ALL_REGION / ALL_ZONE 判断
+func detectScopes(declared Scope) []Scope {
+	switch {
+	case declared == ScopeUnknown:
+		return []Scope{ScopeFlat, ScopeAllGaia, ScopeAllRegion, ScopeAllZone}
+	case declared&ScopeFlat != 0:
+		return []Scope{ScopeFlat}
+	default:
+		return []Scope{declared}
+	}
+}
+
+// checkDeclaration 对比声明与 sdk.json. present 为 sdk.json 中出现过的配置项名称(无论格式是否合法)
+func (c *ConfigCenter) checkDeclaration(present map[string]bool) {
+	for _, s := range c.Declaration.ServiceList() {
+		if s.Scope == ScopeUnknown {
+			c.addDeclareIssue(s.Name, fmt.Sprintf("unknown scope %q", s.ScopeName))
+		} else if s.ScopeName != "" && !c.Declaration.supportScope(s.Scope) {
+			c.addDeclareIssue(s.Name, fmt.Sprintf("scope %q not in base._scop", s.ScopeName))
+		}
+		if !present[s.Name] && !present[s.ServiceID] {
+			c.addDeclareIssue(s.Name, "declared but missing in sdk.json")
+		}
+	}
+}
+
+func (c *ConfigCenter) addDeclareIssue(service, message string) {
+	c.DeclareIssues = append(c.DeclareIssues, DeclareIssue{Service: service, Message: message})
+}
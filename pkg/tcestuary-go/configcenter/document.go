@@ -0,0 +1,475 @@
+package configcenter
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// Document sdk.json 的无损表示, 用于工具修改配置文件.
+// 保留 ConfigCenter 未解析的字段(base.local 的扩展字段、中间件配置等)、key 顺序及缩进,
+// 未修改时 Bytes 与输入完全相同; 修改时只改动目标字段, 新增内容沿用文档的缩进格式
+type Document struct {
+	root       *Node
+	head, tail []byte // 根节点前后的空白
+	indent     string // 每级缩进, 文档为单行格式时为空
+	pretty     bool
+}
+
+// NodeKind JSON 节点类型
+type NodeKind int
+
+const (
+	NodeNull NodeKind = iota
+	NodeBool
+	NodeNumber
+	NodeString
+	NodeArray
+	NodeObject
+)
+
+// Node JSON 节点. 标量保存原始字节, 对象、数组保存成员及成员前后的空白
+type Node struct {
+	doc    *Document
+	kind   NodeKind
+	raw    []byte    // 标量的原始字节
+	fields []*member // 对象成员, 保持原始顺序
+	items  []*member // 数组元素, key 为空
+	inner  []byte    // 空对象、空数组括号内的空白
+	indent string    // 节点所在行的缩进, 用于新增成员
+}
+
+// member 对象成员或数组元素. 格式: before "key" colon value after
+type member struct {
+	before []byte
+	key    string
+	rawKey []byte
+	colon  []byte
+	value  *Node
+	after  []byte
+}
+
+// ParseDocument 解析 sdk.json
+func ParseDocument(data []byte) (*Document, error) {
+	if !json.Valid(data) {
+		return nil, errors.New("invalid json")
+	}
+	d := &Document{}
+	p := &docParser{doc: d, data: data}
+	d.head = p.space()
+	d.root = p.value("")
+	d.tail = p.space()
+
+	// 取第一个换行后的空白作为每级缩进
+	if d.root.kind == NodeObject || d.root.kind == NodeArray {
+		for _, m := range append(d.root.fields, d.root.items...) {
+			if i := bytes.LastIndexByte(m.before, '\n'); i >= 0 {
+				d.pretty = true
+				d.indent = string(m.before[i+1:])
+				break
+			}
+		}
+	}
+	return d, nil
+}
+
+// Root 根节点
+func (d *Document) Root() *Node {
+	return d.root
+}
+
+// Get 按路径查找节点, 数组使用下标, 如 Get("mysql", "dbsql_tcenter_CCDB4", "0", "_service", "pass").
+// 节点不存在时返回 nil
+func (d *Document) Get(path ...string) *Node {
+	n := d.root
+	for _, key := range path {
+		if n == nil {
+			return nil
+		}
+		switch n.kind {
+		case NodeObject:
+			n = n.Field(key)
+		case NodeArray:
+			i, err := strconv.Atoi(key)
+			if err != nil {
+				return nil
+			}
+			n = n.Index(i)
+		default:
+			return nil
+		}
+	}
+	return n
+}
+
+// Bytes 序列化, 未修改的部分与输入完全相同
+func (d *Document) Bytes() []byte {
+	var buf bytes.Buffer
+	buf.Write(d.head)
+	d.root.write(&buf)
+	buf.Write(d.tail)
+	return buf.Bytes()
+}
+
+// Origin 按 OriginConfigCenter 解析当前内容
+func (d *Document) Origin() (*OriginConfigCenter, error) {
+	origin := new(OriginConfigCenter)
+	if err := d.root.Decode(origin); err != nil {
+		return nil, err
+	}
+	return origin, nil
+}
+
+// SetMysqlPassword 设置 dbsql 实例的密码(pass 字段), 密码需由调用方加密. 只支持 scope 为 flat 的实例,
+// all_region 等列表格式的实例通过 Get("mysql", dbsql, "0", "_service") 逐个修改
+func (d *Document) SetMysqlPassword(dbsql, password string) error {
+	n := d.Get("mysql", dbsql)
+	if n == nil {
+		return fmt.Errorf("mysql %s not found", dbsql)
+	}
+	if n.kind != NodeObject {
+		return fmt.Errorf("mysql %s is not flat scope", dbsql)
+	}
+	return n.Set("pass", password)
+}
+
+// AddMysqlDatabase 向 dbsql 实例的 db_name_list 追加数据库, 已存在时不做修改.
+// 列表格式的实例向每一项的 _service 追加
+func (d *Document) AddMysqlDatabase(dbsql, database string) error {
+	n := d.Get("mysql", dbsql)
+	if n == nil {
+		return fmt.Errorf("mysql %s not found", dbsql)
+	}
+	services := []*Node{n}
+	if n.kind == NodeArray {
+		services = services[:0]
+		for i := 0; i < n.Len(); i++ {
+			services = append(services, n.Index(i).Field("_service"))
+		}
+	}
+	for i, service := range services {
+		if service == nil || service.kind != NodeObject {
+			return fmt.Errorf("mysql %s[%d] _service is not object", dbsql, i)
+		}
+		list := service.Field("db_name_list")
+		if list == nil {
+			if err := service.Set("db_name_list", []string{database}); err != nil {
+				return err
+			}
+			continue
+		}
+		var names []string
+		if err := list.Decode(&names); err != nil {
+			return fmt.Errorf("mysql %s db_name_list is not string list", dbsql)
+		}
+		exist := false
+		for _, name := range names {
+			exist = exist || name == database
+		}
+		if !exist {
+			if err := list.Append(database); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Kind 节点类型
+func (n *Node) Kind() NodeKind {
+	return n.kind
+}
+
+// Bytes 节点序列化后的内容
+func (n *Node) Bytes() []byte {
+	var buf bytes.Buffer
+	n.write(&buf)
+	return buf.Bytes()
+}
+
+// Decode 将节点反序列化到 v
+func (n *Node) Decode(v interface{}) error {
+	return json.Unmarshal(n.Bytes(), v)
+}
+
+// StringValue 字符串节点的值, 非字符串节点返回 false
+func (n *Node) StringValue() (string, bool) {
+	var s string
+	if n.kind != NodeString || json.Unmarshal(n.raw, &s) != nil {
+		return "", false
+	}
+	return s, true
+}
+
+// Keys 对象的 key, 保持原始顺序
+func (n *Node) Keys() []string {
+	keys := make([]string, 0, len(n.fields))
+	for _, m := range n.fields {
+		keys = append(keys, m.key)
+	}
+	return keys
+}
+
+// Field 对象成员, 不存在时返回 nil
+func (n *Node) Field(key string) *Node {
+	for _, m := range n.fields {
+		if m.key == key {
+			return m.value
+		}
+	}
+	return nil
+}
+
+// Len 数组长度
+func (n *Node) Len() int {
+	return len(n.items)
+}
+
+// Index 数组元素, 越界时返回 nil
+func (n *Node) Index(i int) *Node {
+	if i < 0 || i >= len(n.items) {
+		return nil
+	}
+	return n.items[i].value
+}
+
+// SetValue 替换节点的值, 节点在文档中的位置及前后空白不变
+func (n *Node) SetValue(v interface{}) error {
+	value, err := n.doc.newNode(v, n.indent)
+	if err != nil {
+		return err
+	}
+	n.kind, n.raw, n.fields, n.items, n.inner = value.kind, value.raw, value.fields, value.items, value.inner
+	return nil
+}
+
+// Set 设置对象成员, 已存在时替换值, 否则追加到末尾
+func (n *Node) Set(key string, v interface{}) error {
+	if n.kind != NodeObject {
+		return errors.New("node is not object")
+	}
+	for _, m := range n.fields {
+		if m.key == key {
+			return m.value.SetValue(v)
+		}
+	}
+	rawKey, _ := json.Marshal(key)
+	m, err := n.add(&n.fields, v)
+	if err != nil {
+		return err
+	}
+	m.key, m.rawKey = key, rawKey
+	return nil
+}
+
+// Delete 删除对象成员, 不存在时返回 false
+func (n *Node) Delete(key string) bool {
+	for i, m := range n.fields {
+		if m.key != key {
+			continue
+		}
+		if i == len(n.fields)-1 {
+			if i > 0 {
+				n.fields[i-1].after = m.after
+			} else {
+				n.inner = m.after
+			}
+		}
+		n.fields = append(n.fields[:i], n.fields[i+1:]...)
+		return true
+	}
+	return false
+}
+
+// Append 向数组末尾追加元素
+func (n *Node) Append(v interface{}) error {
+	if n.kind != NodeArray {
+		return errors.New("node is not array")
+	}
+	_, err := n.add(&n.items, v)
+	return err
+}
+
+// add 追加成员, 空白沿用最后一个成员; 对象、数组为空时按文档缩进
+func (n *Node) add(members *[]*member, v interface{}) (*member, error) {
+	m := &member{colon: []byte(":")}
+	if last := len(*members) - 1; last >= 0 {
+		prev := (*members)[last]
+		m.before = append([]byte(nil), prev.before...)
+		m.colon = append([]byte(nil), prev.colon...)
+		m.after, prev.after = prev.after, nil
+		// 单行格式且只有一个成员时, 无法从第一个成员得知分隔符后的空白
+		if last == 0 && n.doc.pretty && bytes.IndexByte(m.before, '\n') < 0 {
+			m.before = []byte(" ")
+		}
+	} else if n.doc.pretty {
+		m.before = []byte("\n" + n.indent + n.doc.indent)
+		m.colon = []byte(": ")
+		m.after = []byte("\n" + n.indent)
+		n.inner = nil
+	}
+	if n.kind == NodeObject && len(m.colon) == 0 {
+		m.colon = []byte(":")
+	}
+
+	value, err := n.doc.newNode(v, lineIndent(m.before, n.indent))
+	if err != nil {
+		return nil, err
+	}
+	m.value = value
+	*members = append(*members, m)
+	return m, nil
+}
+
+// newNode 序列化 v 并解析为节点, 多行格式的内容以 indent 为起始缩进
+func (d *Document) newNode(v interface{}, indent string) (*Node, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if d.pretty {
+		enc.SetIndent(indent, d.indent)
+	}
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	p := &docParser{doc: d, data: bytes.TrimRight(buf.Bytes(), "\n")}
+	return p.value(indent), nil
+}
+
+// lineIndent before 中最后一个换行后的空白, 没有换行时为 parent
+func lineIndent(before []byte, parent string) string {
+	if i := bytes.LastIndexByte(before, '\n'); i >= 0 {
+		return string(before[i+1:])
+	}
+	return parent
+}
+
+func (n *Node) write(buf *bytes.Buffer) {
+	switch n.kind {
+	case NodeObject:
+		buf.WriteByte('{')
+		n.writeMembers(buf, n.fields)
+		buf.WriteByte('}')
+	case NodeArray:
+		buf.WriteByte('[')
+		n.writeMembers(buf, n.items)
+		buf.WriteByte(']')
+	default:
+		buf.Write(n.raw)
+	}
+}
+
+func (n *Node) writeMembers(buf *bytes.Buffer, members []*member) {
+	if len(members) == 0 {
+		buf.Write(n.inner)
+		return
+	}
+	for i, m := range members {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		buf.Write(m.before)
+		if n.kind == NodeObject {
+			buf.Write(m.rawKey)
+			buf.Write(m.colon)
+		}
+		m.value.write(buf)
+		buf.Write(m.after)
+	}
+}
+
+// docParser 输入已通过 json.Valid 检查, 不再处理格式错误
+type docParser struct {
+	doc  *Document
+	data []byte
+	pos  int
+}
+
+func (p *docParser) space() []byte {
+	start := p.pos
+	for p.pos < len(p.data) {
+		switch p.data[p.pos] {
+		case ' ', '\t', '\r', '\n':
+			p.pos++
+			continue
+		}
+		break
+	}
+	return p.data[start:p.pos]
+}
+
+func (p *docParser) str() []byte {
+	start := p.pos
+	for p.pos++; p.data[p.pos] != '"'; p.pos++ {
+		if p.data[p.pos] == '\\' {
+			p.pos++
+		}
+	}
+	p.pos++
+	return p.data[start:p.pos]
+}
+
+func (p *docParser) value(indent string) *Node {
+	n := &Node{doc: p.doc, indent: indent}
+	switch c := p.data[p.pos]; c {
+	case '{', '[':
+		n.kind = NodeObject
+		if c == '[' {
+			n.kind = NodeArray
+		}
+		p.pos++
+		var members []*member
+		for {
+			before := p.space()
+			if c := p.data[p.pos]; c == '}' || c == ']' {
+				p.pos++
+				if len(members) == 0 {
+					n.inner = before
+				}
+				break
+			}
+			if p.data[p.pos] == ',' {
+				p.pos++
+				before = p.space()
+			}
+			m := &member{before: before}
+			if n.kind == NodeObject {
+				m.rawKey = p.str()
+				json.Unmarshal(m.rawKey, &m.key)
+				start := p.pos
+				p.space()
+				p.pos++ // ':'
+				p.space()
+				m.colon = p.data[start:p.pos]
+			}
+			m.value = p.value(lineIndent(before, indent))
+			m.after = p.space()
+			members = append(members, m)
+		}
+		if n.kind == NodeObject {
+			n.fields = members
+		} else {
+			n.items = members
+		}
+	case '"':
+		n.kind = NodeString
+		n.raw = p.str()
+	default:
+		start := p.pos
+		for p.pos < len(p.data) && bytes.IndexByte([]byte(",]} \t\r\n"), p.data[p.pos]) < 0 {
+			p.pos++
+		}
+		n.raw = p.data[start:p.pos]
+		switch n.raw[0] {
+		case 'n':
+			n.kind = NodeNull
+		case 't', 'f':
+			n.kind = NodeBool
+		default:
+			n.kind = NodeNumber
+		}
+	}
+	return n
+}
@@ -0,0 +1,102 @@
+package configcenter
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDocumentRoundTrip(t *testing.T) {
+	example, err := ioutil.ReadFile("../_example/sdk.json")
+	assert.NoError(t, err)
+
+	for _, data := range [][]byte{
+		example,
+		[]byte(servicesConfig),
+		[]byte(` {"a":[1, 2 ,{ }],"b" :  "é\"x" , "c":{"d":null,"e":[ ]},"f":-1.5e3,"g":true}` + "\n"),
+		[]byte("\t[\r\n\t\"x\"\r\n]"),
+		[]byte(`"scalar"`),
+	} {
+		doc, err := ParseDocument(data)
+		assert.NoError(t, err)
+		assert.Equal(t, string(data), string(doc.Bytes()))
+	}
+
+	_, err = ParseDocument([]byte(`{"a":`))
+	assert.Error(t, err)
+
+	// 未知字段可通过节点读取
+	doc, _ := ParseDocument(example)
+	port, _ := doc.Get("base", "local", "default_host_port").StringValue()
+	assert.Equal(t, "36000", port)
+	assert.Equal(t, NodeNumber, doc.Get("kafka", "wtag", "0", "_service", "port").Kind())
+	assert.Nil(t, doc.Get("kafka", "wtag", "1"))
+	assert.Nil(t, doc.Get("mysql", "ocloud_api3", "host", "x"))
+
+	origin, err := doc.Origin()
+	assert.NoError(t, err)
+	assert.Len(t, origin.Mysqls, 4)
+}
+
+func TestDocumentEdit(t *testing.T) {
+	doc, err := ParseDocument([]byte(servicesConfig))
+	assert.NoError(t, err)
+
+	assert.NoError(t, doc.SetMysqlPassword("ocloud_api3", "AES+V1+new"))
+	assert.NoError(t, doc.AddMysqlDatabase("ocloud_api3", "api_new"))
+	assert.NoError(t, doc.AddMysqlDatabase("ocloud_api3", "api_sync"))
+	assert.Error(t, doc.SetMysqlPassword("not_exist", "x"))
+
+	// 只改动目标字段
+	expected := `{
+  "mysql": {
+    "ocloud_api3": {
+      "db_name_list": ["api_sync", "api_new"],
+      "host": "db-2.db",
+      "ipv4": "10.21.70.10",
+      "pass": "AES+V1+new",
+      "port": 22003,
+      "user": "mysql_user"
+    }
+  },
+  "redis": {`
+	assert.Equal(t, expected, string(doc.Bytes())[:len(expected)])
+
+	// 新增字段沿用文档缩进
+	redis := doc.Get("redis")
+	assert.NoError(t, redis.Set("new", map[string]interface{}{"host": "a&b", "port": 1}))
+	assert.NoError(t, doc.Get("redis", "empty").Set("port", 6379))
+	assert.Contains(t, string(doc.Bytes()), `"bad_port": {"host": "redis.db", "port": 0},
+    "new": {
+      "host": "a&b",
+      "port": 1
+    }
+  },`)
+	assert.Contains(t, string(doc.Bytes()), `"empty": {
+      "port": 6379
+    },`)
+
+	assert.True(t, redis.Delete("new"))
+	assert.False(t, redis.Delete("new"))
+	assert.Contains(t, string(doc.Bytes()), `"bad_port": {"host": "redis.db", "port": 0}
+  },`)
+
+	// 修改后仍可被 ConfigCenter 解析
+	c := NewConfigCenter()
+	assert.NoError(t, c.Parse(doc.Bytes()))
+	assert.Equal(t, "AES+V1+new", c.FindMysql("ocloud_api3", "api_new").Password)
+}
+
+func TestDocumentEditList(t *testing.T) {
+	data := []byte(`{"mysql":{"db":[{"_base":{"region_id":1},"_service":{"pass":"p","db_name_list":["a"]}},` +
+		`{"_base":{"region_id":2},"_service":{"pass":"p"}}]}}`)
+	doc, err := ParseDocument(data)
+	assert.NoError(t, err)
+
+	assert.Error(t, doc.SetMysqlPassword("db", "x"))
+	assert.NoError(t, doc.Get("mysql", "db", "1", "_service").Set("pass", "x"))
+	assert.NoError(t, doc.AddMysqlDatabase("db", "b"))
+	assert.Equal(t, `{"mysql":{"db":[{"_base":{"region_id":1},"_service":{"pass":"p","db_name_list":["a","b"]}},`+
+		`{"_base":{"region_id":2},"_service":{"pass":"x","db_name_list":["b"]}}]}}`, string(doc.Bytes()))
+}
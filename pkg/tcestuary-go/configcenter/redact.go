@@ -0,0 +1,100 @@
+package configcenter
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// 日志输出时隐藏密钥、密码等敏感字段, 只保留长度及指纹, 便于比对配置是否一致
+
+// secretFields 敏感字段的 json key, 用于隐藏中间件等未结构化的配置
+var secretFields = map[string]bool{
+	"aes_key":     true,
+	"aeskey":      true,
+	"sm4_key":     true,
+	"private_key": true,
+	"secret_key":  true,
+	"pass":        true,
+	"passwd":      true,
+	"password":    true,
+}
+
+// Redact 隐藏敏感信息, 返回长度及 sha256 前 8 位. 空值原样返回
+func Redact(s string) string {
+	if s == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(s))
+	return fmt.Sprintf("[redacted len=%d sha256=%s]", len(s), hex.EncodeToString(sum[:4]))
+}
+
+// Redacted 返回隐藏敏感字段后的副本, 包括 keys 中的密钥
+func (s SecretConfig) Redacted() SecretConfig {
+	s = s.redactFields()
+	if len(s.Keys) > 0 {
+		keys := make([]SecretConfig, len(s.Keys))
+		for i, key := range s.Keys {
+			keys[i] = key.Redacted()
+		}
+		s.Keys = keys
+	}
+	return s
+}
+
+// redactFields 只隐藏本层字段, keys 中的密钥由其 String 处理
+func (s SecretConfig) redactFields() SecretConfig {
+	s.PrivateKey = Redact(s.PrivateKey)
+	s.AesKey = Redact(s.AesKey)
+	s.V1Aeskey = Redact(s.V1Aeskey)
+	s.Sm4Key = Redact(s.Sm4Key)
+	s.SecretKey = Redact(s.SecretKey)
+	return s
+}
+
+// String 隐藏敏感字段, 避免 %v 输出密钥
+func (s SecretConfig) String() string {
+	type plain SecretConfig
+	return fmt.Sprintf("%+v", plain(s.redactFields()))
+}
+
+// Redacted 返回隐藏密码后的副本
+func (m Mysql) Redacted() Mysql {
+	m.Password = Redact(m.Password)
+	return m
+}
+
+// String 隐藏密码, 避免 %v 输出密码
+func (m Mysql) String() string {
+	type plain Mysql
+	return fmt.Sprintf("%+v", plain(m.Redacted()))
+}
+
+// RedactJSON 隐藏 JSON 中 key 为敏感字段的字符串值, 用于输出中间件配置. 输出对象的 key 按字母排序
+func RedactJSON(data []byte) ([]byte, error) {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(redactValue(v), "", "  ")
+}
+
+func redactValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for k, item := range val {
+			if s, ok := item.(string); ok && secretFields[strings.ToLower(k)] {
+				val[k] = Redact(s)
+				continue
+			}
+			val[k] = redactValue(item)
+		}
+	case []interface{}:
+		for i, item := range val {
+			val[i] = redactValue(item)
+		}
+	}
+	return v
+}
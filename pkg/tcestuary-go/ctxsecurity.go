@@ -0,0 +1,54 @@
+package tcestuary
+
+import (
+	"context"
+
+	"git.code.oa.com/tce-config/tcestuary-go/v4/tcesecurity"
+)
+
+// 支持 context 的加解密、签名, ctx 控制 KMS 调用(含重试)的超时、取消.
+// s 为 NewStorageSecurity / NewTransportSecurity / NewSigner 等返回的组件, 不访问 KMS 的算法仅检查 ctx 是否已结束
+
+// EncryptCtx 加密
+func EncryptCtx(ctx context.Context, s StorageSecurity, plaintext string) (string, error) {
+	if c, ok := s.(tcesecurity.CtxCrypto); ok {
+		return c.EncryptCtx(ctx, plaintext)
+	}
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	return s.Encrypt(plaintext)
+}
+
+// DecryptCtx 解密
+func DecryptCtx(ctx context.Context, s StorageSecurity, ciphertext string) (string, error) {
+	if c, ok := s.(tcesecurity.CtxCrypto); ok {
+		return c.DecryptCtx(ctx, ciphertext)
+	}
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	return s.Decrypt(ciphertext)
+}
+
+// SignCtx 生成签名
+func SignCtx(ctx context.Context, s Signer, msg string) (string, error) {
+	if c, ok := s.(tcesecurity.CtxSigner); ok {
+		return c.SignCtx(ctx, msg)
+	}
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	return s.Sign(msg)
+}
+
+// VerifyCtx 验证签名
+func VerifyCtx(ctx context.Context, s Signer, msg, signValue string) (bool, error) {
+	if c, ok := s.(tcesecurity.CtxSigner); ok {
+		return c.VerifyCtx(ctx, msg, signValue)
+	}
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	return s.Verify(msg, signValue)
+}
@@ -0,0 +1,52 @@
+package tcestuary
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"git.code.oa.com/tce-config/tcestuary-go/v4/configcenter"
+	"git.code.oa.com/tce-config/tcestuary-go/v4/tcesecurity"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncryptCtx(t *testing.T) {
+	s, err := NewStorageSecurity()
+	assert.NoError(t, err)
+	ciphertext, err := EncryptCtx(context.Background(), s, "mysql_pass")
+	assert.NoError(t, err)
+	plaintext, err := DecryptCtx(context.Background(), s, ciphertext)
+	assert.NoError(t, err)
+	assert.Equal(t, "mysql_pass", plaintext)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = EncryptCtx(ctx, s, "mysql_pass")
+	assert.Equal(t, context.Canceled, err)
+	_, err = DecryptCtx(ctx, s, ciphertext)
+	assert.Equal(t, context.Canceled, err)
+}
+
+func TestKMSRetryConfig(t *testing.T) {
+	var conf configcenter.SecretConfig
+	assert.NoError(t, json.Unmarshal([]byte(`{
+		"method": "kms-sm4-128-gcm",
+		"kms_retry": {"max_retries": -1, "base_delay": 50, "max_delay": 500, "breaker_threshold": 3, "breaker_cooldown": 5},
+		"keys": [{"id": "k1", "key_id": "key-1"}],
+		"active_key": "k1"
+	}`), &conf))
+	assert.Equal(t, tcesecurity.KMSRetryOpts{
+		MaxRetries:       -1,
+		BaseDelay:        50 * time.Millisecond,
+		MaxDelay:         500 * time.Millisecond,
+		BreakerThreshold: 3,
+		BreakerCooldown:  5 * time.Second,
+	}, std.newCryptoOpts(conf).Retry)
+
+	// keys 中未配置时继承外层配置
+	key, ok := conf.KeyConfig("k1")
+	assert.True(t, ok)
+	assert.Equal(t, conf.KMSRetry, key.KMSRetry)
+	assert.Equal(t, tcesecurity.KMSRetryOpts{}, newKMSRetryOpts(nil))
+}
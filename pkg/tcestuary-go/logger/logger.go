@@ -21,3 +21,14 @@ func SetLogger(log Logger) {
 func Printf(format string, args ...interface{}) {
 	std.Printf(format, args...)
 }
+
+// Global 返回转发到全局日志接口的 Logger, SetLogger 修改后立即生效
+func Global() Logger {
+	return global{}
+}
+
+type global struct{}
+
+func (global) Printf(format string, args ...interface{}) {
+	Printf(format, args...)
+}
@@ -1,25 +1,44 @@
 package tcestuary
 
 import (
+	"bytes"
 	"io/ioutil"
 	"log"
+	"os"
 	"path/filepath"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"git.code.oa.com/tce-config/tcestuary-go/v4/configcenter"
 	"git.code.oa.com/tce-config/tcestuary-go/v4/logger"
 )
 
+// declareFileName 配置目录下的服务声明文件, 不存在时按资源描述结构推断资源级别
+const declareFileName = "cc.declare.json"
+
+// ChangeFunc 配置热加载成功后的回调, old 为替换前的配置, new 为新配置
+type ChangeFunc func(old, new *configcenter.ConfigCenter)
+
 type manager struct {
 	// 配置目录
 	Directory string
 
 	// 配置目录下的 sdk.json
-	ConfigCenter     *configcenter.ConfigCenter
 	ConfigCenterFile string
 
-	loadError        error // 加载错误信息
+	logger logger.Logger // 日志输出, 默认转发到全局日志接口
+
+	center    atomic.Value // *configcenter.ConfigCenter, 热加载时整体替换
+	content   []byte       // 最近一次成功解析的文件内容, 用于判断文件是否变化
+	declare   []byte       // 最近一次成功解析的 cc.declare.json 内容
+	reloadMu  sync.Mutex   // 串行化文件解析, 防止新旧配置乱序替换
+	mu        sync.Mutex   // 保护 content / 回调列表 / 加载错误 / 热加载错误
+	onChange  []ChangeFunc
+	onError   []func(error)
+	reloadErr error
+
+	loadError        error // 加载错误信息, 热加载成功后清空
 	loadCounter      int32 // 单元测试, 验证加载次数
 	loadOnce         sync.Once
 	writeCounter     int32 // 单元测试, 验证写出次数
@@ -30,41 +49,214 @@ func newManager() *manager {
 	c := &manager{
 		Directory:        "/tce/conf/config/tce.config.center",
 		ConfigCenterFile: "/tce/conf/config/tce.config.center/sdk.json",
-		ConfigCenter:     configcenter.NewConfigCenter(),
+		logger:           logger.Global(),
 	}
+	c.center.Store(configcenter.NewConfigCenter())
 	return c
 }
 
+// ConfigCenter 返回当前生效的配置. 热加载会整体替换配置对象, 调用方不要长期持有返回值
+func (c *manager) ConfigCenter() *configcenter.ConfigCenter {
+	return c.center.Load().(*configcenter.ConfigCenter)
+}
+
 // Load 整个生命周期中, 仅在启动时加载一次配置文件
 // 返回值表示“首次加载配置”的错误信息, 通常为 nil
 func (c *manager) Load() error {
 
 	c.writeVersionOnce.Do(c.WriteSDKVersion)
 
-	// 通用加载逻辑. 其中, object 必须是对象指针
-	load := func(file string, object interface{}) error {
-		b, err := ioutil.ReadFile(file)
-		if err != nil {
-			logger.Printf("read %s error, %s", file, err)
-			return err
+	c.loadOnce.Do(func() {
+		atomic.AddInt32(&c.loadCounter, 1)
+		if err := c.reload(false); err != nil {
+			c.mu.Lock()
+			c.loadError = err
+			c.mu.Unlock()
+			c.logger.Printf("for the first time, load cofig file error")
 		}
+	})
 
-		if err := c.ConfigCenter.Parse(b); err != nil {
-			return err
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.loadError
+}
+
+// Reload 重新读取并解析配置文件, 成功后原子替换当前配置并通知 OnChange 回调.
+// 解析失败时继续使用上一次成功加载的配置, 错误通过返回值和 OnReloadError 回调上报
+func (c *manager) Reload() error {
+	// 保证首次加载已完成, 避免与 Load 并发解析
+	c.Load()
+
+	err := c.reload(true)
+
+	c.mu.Lock()
+	c.reloadErr = err
+	handlers := append([]func(error){}, c.onError...)
+	c.mu.Unlock()
+
+	if err != nil {
+		c.logger.Printf("reload %s error, keep last good config, %s", c.ConfigCenterFile, err)
+		for _, f := range handlers {
+			f(err)
 		}
+	}
+	return err
+}
 
-		return nil
+// reload 读取配置文件, 内容有变化时重新解析. notify 表示是否触发 OnChange 回调.
+// 回调在释放 reloadMu 后调用, 回调中可以调用 Reload / Load
+func (c *manager) reload(notify bool) error {
+	old, center, err := c.swap()
+	if err != nil || center == nil || !notify {
+		return err
 	}
 
-	c.loadOnce.Do(func() {
-		atomic.AddInt32(&c.loadCounter, 1)
-		c.loadError = load(c.ConfigCenterFile, c.ConfigCenter)
-		if c.loadError != nil {
-			logger.Printf("for the first time, load cofig file error")
+	c.mu.Lock()
+	handlers := append([]ChangeFunc{}, c.onChange...)
+	c.mu.Unlock()
+	for _, f := range handlers {
+		f(old, center)
+	}
+	return nil
+}
+
+// swap 解析配置文件并替换当前配置, 返回替换前后的配置. 文件未变化时 center 为 nil
+func (c *manager) swap() (old, center *configcenter.ConfigCenter, err error) {
+	c.reloadMu.Lock()
+	defer c.reloadMu.Unlock()
+
+	file := c.ConfigCenterFile
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		c.logger.Printf("read %s error, %s", file, err)
+		return nil, nil, err
+	}
+
+	declareFile := filepath.Join(c.Directory, declareFileName)
+	d, err := ioutil.ReadFile(declareFile)
+	if err != nil && !os.IsNotExist(err) {
+		c.logger.Printf("read %s error, %s", declareFile, err)
+	}
+
+	c.mu.Lock()
+	unchanged := c.content != nil && bytes.Equal(c.content, b) && bytes.Equal(c.declare, d)
+	c.mu.Unlock()
+	if unchanged {
+		return nil, nil, nil
+	}
+
+	// 声明文件仅用于确定资源级别, 解析失败时按资源描述结构推断, 不影响配置加载
+	var declaration *configcenter.Declaration
+	if len(d) > 0 {
+		if declaration, err = configcenter.ParseDeclaration(d); err != nil {
+			c.logger.Printf("parse %s error, ignore declaration, %s", declareFile, err)
 		}
-	})
+	}
 
-	return c.loadError
+	// 解析到新对象中, 失败时不影响当前配置
+	center = configcenter.NewConfigCenter()
+	if err := center.ParseWithDeclaration(b, declaration); err != nil {
+		return nil, nil, err
+	}
+	for _, issue := range center.DeclareIssues {
+		c.logger.Printf("%s not match %s, %s", declareFile, file, issue)
+	}
+
+	c.mu.Lock()
+	old = c.ConfigCenter()
+	c.center.Store(center)
+	c.content = b
+	c.declare = d
+	c.loadError = nil
+	c.mu.Unlock()
+	return old, center, nil
+}
+
+// OnChange 注册配置变更回调, 仅在热加载成功且文件内容变化时调用
+func (c *manager) OnChange(f ChangeFunc) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onChange = append(c.onChange, f)
+}
+
+// OnReloadError 注册热加载失败回调
+func (c *manager) OnReloadError(f func(error)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onError = append(c.onError, f)
+}
+
+// ReloadError 返回最近一次热加载的错误信息, nil 表示成功
+func (c *manager) ReloadError() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.reloadErr
+}
+
+// fileState 文件的修改时间和大小, 文件不存在时为零值
+type fileState struct {
+	modTime int64
+	size    int64
+}
+
+func statFile(file string) (fileState, error) {
+	stat, err := os.Stat(file)
+	if err != nil {
+		return fileState{}, err
+	}
+	return fileState{modTime: stat.ModTime().UnixNano(), size: stat.Size()}, nil
+}
+
+// watchState sdk.json 及 cc.declare.json 的状态. sdk.json 不存在时返回错误;
+// cc.declare.json 为可选文件, 不存在时为零值, 新增、删除均视为变化
+func (c *manager) watchState() ([2]fileState, error) {
+	var state [2]fileState
+	var err error
+	if state[0], err = statFile(c.ConfigCenterFile); err != nil {
+		return state, err
+	}
+	state[1], _ = statFile(filepath.Join(c.Directory, declareFileName))
+	return state, nil
+}
+
+// Watch 按 interval 周期检查 sdk.json 及 cc.declare.json 的修改时间和大小, 发生变化时触发 Reload.
+// 返回的 stop 函数用于停止检查, 可以重复调用
+func (c *manager) Watch(interval time.Duration) (stop func()) {
+	c.Load()
+
+	done := make(chan struct{})
+	var once sync.Once
+
+	// 在启动检查前记录文件状态, 避免漏掉启动期间发生的变化
+	last, _ := c.watchState()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+
+			state, err := c.watchState()
+			if err != nil {
+				// 文件暂时不存在(例如重新渲染过程中), 等待下一个周期
+				continue
+			}
+			if state == last {
+				continue
+			}
+			last = state
+			c.Reload()
+		}
+	}()
+
+	return func() {
+		once.Do(func() { close(done) })
+	}
 }
 
 // Debug 向终端输出已加载配置信息, 支持异常调试
@@ -72,7 +264,15 @@ func (c *manager) Debug() {
 	log.Printf("config directory: %s\n", c.Directory)
 	log.Printf("config file: %s\n", c.ConfigCenterFile)
 	log.Printf("config info:\n")
-	c.ConfigCenter.Debug()
+	c.ConfigCenter().Debug()
+}
+
+// DebugUnsafe 同 Debug, 但输出密钥、密码明文, 仅用于本地调试
+func (c *manager) DebugUnsafe() {
+	log.Printf("config directory: %s\n", c.Directory)
+	log.Printf("config file: %s\n", c.ConfigCenterFile)
+	log.Printf("config info:\n")
+	c.ConfigCenter().DebugUnsafe()
 }
 
 // WriteSDKVersion 向配置目录下输出 SDK 版本号,追踪使用情况, 支持SDK升级
@@ -83,6 +283,6 @@ func (c *manager) WriteSDKVersion() {
 
 	err := ioutil.WriteFile(filename, []byte(Version), 0644)
 	if err != nil {
-		logger.Printf("write version file error, %s", err)
+		c.logger.Printf("write version file error, %s", err)
 	}
 }
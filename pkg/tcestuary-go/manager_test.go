@@ -0,0 +1,154 @@
+package tcestuary
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"git.code.oa.com/tce-config/tcestuary-go/v4/configcenter"
+	"github.com/stretchr/testify/assert"
+)
+
+// 在临时目录中创建独立的 manager, 避免影响全局配置
+// 返回值: manager, 写配置文件函数, 清理函数
+func newTestManager(t *testing.T) (*manager, func(string), func()) {
+	dir, err := ioutil.TempDir("", "tcestuary")
+	assert.NoError(t, err)
+
+	b, err := ioutil.ReadFile("./_example/sdk.json")
+	assert.NoError(t, err)
+
+	m := newManager()
+	m.Directory = dir
+	m.ConfigCenterFile = filepath.Join(dir, "sdk.json")
+
+	write := func(content string) {
+		assert.NoError(t, ioutil.WriteFile(m.ConfigCenterFile, []byte(content), 0644))
+	}
+	write(string(b))
+	return m, write, func() { os.RemoveAll(dir) }
+}
+
+func TestReload(t *testing.T) {
+	m, write, cleanup := newTestManager(t)
+	defer cleanup()
+	assert.NoError(t, m.Load())
+
+	origin, _ := ioutil.ReadFile(m.ConfigCenterFile)
+	var changed int
+	m.OnChange(func(old, new *configcenter.ConfigCenter) {
+		changed++
+		assert.NotEqual(t, old, new)
+		assert.Equal(t, "renamed_user", new.FindMysql("ocloud_api3", "api_sync").User)
+		assert.Equal(t, "mysql_user", old.FindMysql("ocloud_api3", "api_sync").User)
+	})
+
+	t.Run("unchanged", func(t *testing.T) {
+		assert.NoError(t, m.Reload())
+		assert.Equal(t, 0, changed)
+	})
+
+	t.Run("changed", func(t *testing.T) {
+		write(strings.Replace(string(origin), `"user": "mysql_user"`, `"user": "renamed_user"`, 1))
+		assert.NoError(t, m.Reload())
+		assert.Equal(t, 1, changed)
+		assert.Equal(t, "renamed_user", m.ConfigCenter().FindMysql("ocloud_api3", "api_sync").User)
+	})
+
+	t.Run("broken-keep-last-good", func(t *testing.T) {
+		var reported error
+		m.OnReloadError(func(err error) { reported = err })
+
+		write("{ broken json")
+		err := m.Reload()
+		assert.Error(t, err)
+		assert.Equal(t, err, reported)
+		assert.Equal(t, err, m.ReloadError())
+		assert.Equal(t, 1, changed)
+
+		// 继续使用上一次成功加载的配置
+		assert.NoError(t, m.Load())
+		assert.Equal(t, "renamed_user", m.ConfigCenter().FindMysql("ocloud_api3", "api_sync").User)
+	})
+}
+
+func TestReloadFromCallback(t *testing.T) {
+	m, write, cleanup := newTestManager(t)
+	defer cleanup()
+	assert.NoError(t, m.Load())
+
+	origin, _ := ioutil.ReadFile(m.ConfigCenterFile)
+	var changed int
+	m.OnChange(func(old, new *configcenter.ConfigCenter) {
+		changed++
+		// 回调中重新加载不会死锁, 文件未变化时不再触发回调
+		assert.NoError(t, m.Reload())
+		assert.NoError(t, m.Load())
+	})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		write(strings.Replace(string(origin), `"user": "mysql_user"`, `"user": "renamed_user"`, 1))
+		assert.NoError(t, m.Reload())
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("reload from OnChange callback deadlocked")
+	}
+	assert.Equal(t, 1, changed)
+}
+
+func TestWatch(t *testing.T) {
+	m, write, cleanup := newTestManager(t)
+	defer cleanup()
+	origin, _ := ioutil.ReadFile(m.ConfigCenterFile)
+
+	changed := make(chan *configcenter.ConfigCenter, 1)
+	m.OnChange(func(old, new *configcenter.ConfigCenter) { changed <- new })
+
+	stop := m.Watch(10 * time.Millisecond)
+	defer stop()
+
+	// 修改时间精度有限, 同时改变文件大小以保证能检测到变化
+	write(strings.Replace(string(origin), `"port": 22003`, `"port": 22004`, 1) + "\n")
+
+	select {
+	case c := <-changed:
+		assert.Equal(t, 22004, c.FindMysql("ocloud_api3", "api_sync").Port)
+	case <-time.After(2 * time.Second):
+		t.Fatal("config change not detected")
+	}
+
+	stop()
+	stop()
+}
+
+// 只修改 cc.declare.json 时也触发热加载
+func TestWatchDeclare(t *testing.T) {
+	m, _, cleanup := newTestManager(t)
+	defer cleanup()
+
+	changed := make(chan *configcenter.ConfigCenter, 1)
+	m.OnChange(func(old, new *configcenter.ConfigCenter) { changed <- new })
+
+	stop := m.Watch(10 * time.Millisecond)
+	defer stop()
+	assert.Nil(t, m.ConfigCenter().Declaration)
+
+	b, err := ioutil.ReadFile("./_example/cc.declare.json")
+	assert.NoError(t, err)
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(m.Directory, declareFileName), b, 0644))
+
+	select {
+	case c := <-changed:
+		assert.NotNil(t, c.Declaration)
+	case <-time.After(2 * time.Second):
+		t.Fatal("declaration change not detected")
+	}
+}
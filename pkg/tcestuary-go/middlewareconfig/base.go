@@ -3,93 +3,225 @@ package middlewareconfig
 import (
 	"encoding/json"
 	"fmt"
-	"git.code.oa.com/tce-config/tcestuary-go/v4"
-	"io/ioutil"
 	"os"
+	"path/filepath"
 	"reflect"
+	"sort"
 	"strings"
+	"sync"
+
+	"git.code.oa.com/tce-config/tcestuary-go/v4"
+	"git.code.oa.com/tce-config/tcestuary-go/v4/configcenter"
 )
 
+// MiddleWareConfig 中间件配置.
+// scope 声明为 all_region / all_zone 时, 使用 GetConfigAllRegion / GetConfigAllZone 获取配置列表
 type MiddleWareConfig interface {
+	// GetConfig scope 声明为 global / region / zone 时使用
 	GetConfig(name string) error
 }
 
+// MainRegionConfig 可选接口, 本包中的中间件配置均已实现
+type MainRegionConfig interface {
+	// GetConfigMainRegion scope 声明为 all_region 时, 获取主地域的配置
+	GetConfigMainRegion(name string) error
+}
+
 const encryptedTagName = "encrypted"
 
-var (
+// configTypes 各类中间件的配置结构
+var configTypes = map[string]reflect.Type{
+	ConfigMysql:   reflect.TypeOf(MysqlConfig{}),
+	ConfigRedis:   reflect.TypeOf(RedisConfig{}),
+	ConfigKafka:   reflect.TypeOf(KafkaConfig{}),
+	ConfigCMQ:     reflect.TypeOf(CMQConfig{}),
+	ConfigCSP:     reflect.TypeOf(CSPConfig{}),
+	ConfigMongodb: reflect.TypeOf(MongodbConfig{}),
+	ConfigES:      reflect.TypeOf(ESConfig{}),
+	ConfigZK:      reflect.TypeOf(ZKConfig{}),
+	ConfigHdfs:    reflect.TypeOf(HdfsConfig{}),
+}
+
+// Kinds 支持的中间件类型, 按名称排序
+func Kinds() []string {
+	kinds := make([]string, 0, len(configTypes))
+	for kind := range configTypes {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+// EncryptedFields 中间件配置中标记为 encrypted:"true" 的字段, 返回 sdk.json 中的 key.
+// 未设置 json tag 的字段返回结构体字段名, 与 json 解析相同, 比较时不区分大小写.
+// 不支持的中间件类型返回 nil
+func EncryptedFields(kind string) []string {
+	t, ok := configTypes[kind]
+	if !ok {
+		return nil
+	}
+	var fields []string
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Tag.Get(encryptedTagName) != "true" {
+			continue
+		}
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if name == "" {
+			name = f.Name
+		}
+		fields = append(fields, name)
+	}
+	return fields
+}
+
+// source 某个配置目录下已加载的配置, 以及用于解密的 StorageSecurity
+type source struct {
+	file            string
+	center          *configcenter.ConfigCenter
 	storageSecurity tcestuary.StorageSecurity
-	config          *Config
+}
+
+var (
+	loadMu sync.Mutex
+	loaded *source
 )
 
 func init() {
-	config = &Config{}
-	configPath := os.Getenv("CONFIG_FILE_PATH")
-	if configPath != "" {
+	// 仅记录配置目录, 配置文件在首次 GetConfig 时加载
+	if configPath := os.Getenv("CONFIG_FILE_PATH"); configPath != "" {
 		tcestuary.SetConfigDirectory(configPath)
 	}
-	b, err := ioutil.ReadFile(tcestuary.GetConfigDirectory())
+
+	// 默认 Client 热加载 (Watch / Reload) 检测到配置变更时, 丢弃缓存, 下次调用时重新加载
+	tcestuary.OnChange(func(old, new *configcenter.ConfigCenter) {
+		loadMu.Lock()
+		loaded = nil
+		loadMu.Unlock()
+	})
+}
+
+// load 加载 tcestuary.GetConfigDirectory() 对应的配置文件.
+// 同一配置文件只成功加载一次; 调用 tcestuary.SetConfigDirectory 修改目录,
+// 或默认 Client 热加载到新配置后, 下次调用时重新加载.
+// 加载失败时不缓存错误, 下次调用时重试
+func load() (*source, error) {
+	file := tcestuary.GetConfigDirectory()
+
+	loadMu.Lock()
+	defer loadMu.Unlock()
+
+	if loaded != nil && loaded.file == file {
+		return loaded, nil
+	}
+
+	client, err := tcestuary.New(tcestuary.WithConfigDirectory(filepath.Dir(file)))
 	if err != nil {
-		panic(err)
+		return nil, err
+	}
+	if err := client.Load(); err != nil {
+		return nil, err
 	}
-	err = json.Unmarshal(b, config)
+	storageSecurity, err := client.NewPasswdSecret()
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
-	storageSecurity, err = tcestuary.NewPasswdSecret()
-	if err != nil {
-		panic(err)
+	loaded = &source{
+		file:            file,
+		center:          client.ConfigCenter(),
+		storageSecurity: storageSecurity,
 	}
+	return loaded, nil
+}
 
+// findService 查找中间件配置, 并检查资源级别
+// 错误码:
+// 1. 配置项不存在或格式不合法, 返回 tcestuary.ErrNotFound
+// 2. Scope不支持, 返回 tcestuary.ErrUsageInvalid
+// 3. 配置文件加载失败, 返回加载错误
+func findService(kind, name string, scope configcenter.Scope) (*source, *configcenter.ServiceWrapper, error) {
+	src, err := load()
+	if err != nil {
+		return nil, nil, err
+	}
+	w := src.center.FindService(kind, name)
+	if w == nil {
+		return nil, nil, tcestuary.ErrNotFound
+	}
+	if w.Scope != scope {
+		return nil, nil, tcestuary.ErrUsageInvalid
+	}
+	return src, w, nil
 }
 
-type Config struct {
-	Mysql   map[string]*json.RawMessage `json:"mysql"`
-	ES      map[string]*json.RawMessage `json:"es"`
-	Redis   map[string]*json.RawMessage `json:"redis"`
-	ZK      map[string]*json.RawMessage `json:"zk"`
-	Mongodb map[string]*json.RawMessage `json:"mongodb"`
-	CSP     map[string]*json.RawMessage `json:"csp"`
-	Kafka   map[string]*json.RawMessage `json:"kafka"`
-	CMQ     map[string]*json.RawMessage `json:"cmq"`
-	Hdfs    map[string]*json.RawMessage `json:"hdfs"`
+func unmarshallConfig(kind, name string, inStructPtr interface{}) error {
+	src, w, err := findService(kind, name, configcenter.ScopeFlat)
+	if err != nil {
+		return err
+	}
+	if err = json.Unmarshal(w.Object.(json.RawMessage), inStructPtr); err != nil {
+		return err
+	}
+	return structByReflect(src.storageSecurity, inStructPtr)
 }
 
-func (c *Config) GetMiddleWareFiled(name, filed string) (*json.RawMessage, error) {
-	t := reflect.TypeOf(c)
-	v := reflect.ValueOf(c)
-	if t.Kind() == reflect.Ptr {
-		t = t.Elem()
-		v = v.Elem()
-	}
-	if t.Kind() != reflect.Struct {
-		return nil, fmt.Errorf("config is not a struct")
-	}
-	filedNum := t.NumField()
-	for i := 0; i < filedNum; i++ {
-		if strings.ToLower(t.Field(i).Name) == strings.ToLower(name) {
-			val := v.FieldByName(t.Field(i).Name)
-			if message, ok := val.Interface().(map[string]*json.RawMessage); ok {
-				return message[filed], nil
-			}
+// unmarshallConfigAllRegion 逐个解析 all_region 配置项. newItem 根据 Region 信息创建配置对象, 返回对象指针
+func unmarshallConfigAllRegion(kind, name string, newItem func(base configcenter.Region) interface{}) error {
+	src, w, err := findService(kind, name, configcenter.ScopeAllRegion)
+	if err != nil {
+		return err
+	}
+	for _, item := range w.Object.([]*configcenter.ServiceWithRegion) {
+		inStructPtr := newItem(item.Base)
+		if err := json.Unmarshal(item.Service, inStructPtr); err != nil {
+			return err
+		}
+		if err := structByReflect(src.storageSecurity, inStructPtr); err != nil {
+			return err
 		}
 	}
-	return nil, nil
-
+	return nil
 }
 
-func unmarshallConfig(name, filed string, inStructPtr interface{}) error {
-	val, err := config.GetMiddleWareFiled(name, filed)
+// unmarshallConfigAllZone 逐个解析 all_zone 配置项. newItem 根据 Zone 信息创建配置对象, 返回对象指针
+func unmarshallConfigAllZone(kind, name string, newItem func(base configcenter.Zone) interface{}) error {
+	src, w, err := findService(kind, name, configcenter.ScopeAllZone)
 	if err != nil {
 		return err
 	}
-	if err = json.Unmarshal(*val, inStructPtr); err != nil {
+	for _, item := range w.Object.([]*configcenter.ServiceWithZone) {
+		inStructPtr := newItem(item.Base)
+		if err := json.Unmarshal(item.Service, inStructPtr); err != nil {
+			return err
+		}
+		if err := structByReflect(src.storageSecurity, inStructPtr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unmarshallConfigMainRegion 从 all_region 配置中, 解析 base.local.main_region_name 对应的配置项
+func unmarshallConfigMainRegion(kind, name string, inStructPtr interface{}) error {
+	src, w, err := findService(kind, name, configcenter.ScopeAllRegion)
+	if err != nil {
 		return err
 	}
-	return structByReflect(inStructPtr)
+	mainRegionName := src.center.Base.ScopeExtInfo.MainRegionName
+	for _, item := range w.Object.([]*configcenter.ServiceWithRegion) {
+		if item.Base.RegionName != mainRegionName {
+			continue
+		}
+		if err := json.Unmarshal(item.Service, inStructPtr); err != nil {
+			return err
+		}
+		return structByReflect(src.storageSecurity, inStructPtr)
+	}
+	return tcestuary.ErrNotFound
 }
 
-func structByReflect(inStructPtr interface{}) error {
+func structByReflect(storageSecurity tcestuary.StorageSecurity, inStructPtr interface{}) error {
 	rType := reflect.TypeOf(inStructPtr)
 	rVal := reflect.ValueOf(inStructPtr)
 
@@ -136,13 +268,13 @@ func structByReflect(inStructPtr interface{}) error {
 				}
 			} else if ele.Kind() == reflect.Struct {
 				for j := 0; j < f.Len(); j++ {
-					if err := structByReflect(f.Index(j)); err != nil {
+					if err := structByReflect(storageSecurity, f.Index(j)); err != nil {
 						return err
 					}
 				}
 			}
 		} else if t.Type.Kind() == reflect.Struct {
-			if err := structByReflect(f); err != nil {
+			if err := structByReflect(storageSecurity, f); err != nil {
 				return err
 			}
 		}
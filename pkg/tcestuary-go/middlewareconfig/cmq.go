@@ -1,5 +1,7 @@
 package middlewareconfig
 
+import "git.code.oa.com/tce-config/tcestuary-go/v4/configcenter"
+
 type CMQConfig struct {
 	Host     string
 	IPV4     string `json:"ipv4"`
@@ -16,3 +18,48 @@ const ConfigCMQ = "cmq"
 func (m *CMQConfig) GetConfig(name string) error {
 	return unmarshallConfig(ConfigCMQ, name, m)
 }
+
+// GetConfigMainRegion scope 声明为 all_region 时, 获取主地域的配置
+func (m *CMQConfig) GetConfigMainRegion(name string) error {
+	return unmarshallConfigMainRegion(ConfigCMQ, name, m)
+}
+
+// CMQConfigWithRegion all_region 配置项及其 Region 信息
+type CMQConfigWithRegion struct {
+	CMQConfig
+	Region configcenter.Region
+}
+
+// CMQConfigWithZone all_zone 配置项及其 Zone 信息
+type CMQConfigWithZone struct {
+	CMQConfig
+	Zone configcenter.Zone
+}
+
+// GetCMQConfigAllRegion scope 声明为 all_region 时, 获取所有 Region 的配置列表
+func GetCMQConfigAllRegion(name string) ([]*CMQConfigWithRegion, error) {
+	configs := make([]*CMQConfigWithRegion, 0)
+	err := unmarshallConfigAllRegion(ConfigCMQ, name, func(base configcenter.Region) interface{} {
+		item := &CMQConfigWithRegion{Region: base}
+		configs = append(configs, item)
+		return &item.CMQConfig
+	})
+	if err != nil {
+		return nil, err
+	}
+	return configs, nil
+}
+
+// GetCMQConfigAllZone scope 声明为 all_zone 时, 获取所有 Zone 的配置列表
+func GetCMQConfigAllZone(name string) ([]*CMQConfigWithZone, error) {
+	configs := make([]*CMQConfigWithZone, 0)
+	err := unmarshallConfigAllZone(ConfigCMQ, name, func(base configcenter.Zone) interface{} {
+		item := &CMQConfigWithZone{Zone: base}
+		configs = append(configs, item)
+		return &item.CMQConfig
+	})
+	if err != nil {
+		return nil, err
+	}
+	return configs, nil
+}
@@ -1,5 +1,7 @@
 package middlewareconfig
 
+import "git.code.oa.com/tce-config/tcestuary-go/v4/configcenter"
+
 type CSPConfig struct {
 	Host      string
 	IP        string `json:"ip"`
@@ -16,3 +18,48 @@ const ConfigCSP = "csp"
 func (m *CSPConfig) GetConfig(name string) error {
 	return unmarshallConfig(ConfigCSP, name, m)
 }
+
+// GetConfigMainRegion scope 声明为 all_region 时, 获取主地域的配置
+func (m *CSPConfig) GetConfigMainRegion(name string) error {
+	return unmarshallConfigMainRegion(ConfigCSP, name, m)
+}
+
+// CSPConfigWithRegion all_region 配置项及其 Region 信息
+type CSPConfigWithRegion struct {
+	CSPConfig
+	Region configcenter.Region
+}
+
+// CSPConfigWithZone all_zone 配置项及其 Zone 信息
+type CSPConfigWithZone struct {
+	CSPConfig
+	Zone configcenter.Zone
+}
+
+// GetCSPConfigAllRegion scope 声明为 all_region 时, 获取所有 Region 的配置列表
+func GetCSPConfigAllRegion(name string) ([]*CSPConfigWithRegion, error) {
+	configs := make([]*CSPConfigWithRegion, 0)
+	err := unmarshallConfigAllRegion(ConfigCSP, name, func(base configcenter.Region) interface{} {
+		item := &CSPConfigWithRegion{Region: base}
+		configs = append(configs, item)
+		return &item.CSPConfig
+	})
+	if err != nil {
+		return nil, err
+	}
+	return configs, nil
+}
+
+// GetCSPConfigAllZone scope 声明为 all_zone 时, 获取所有 Zone 的配置列表
+func GetCSPConfigAllZone(name string) ([]*CSPConfigWithZone, error) {
+	configs := make([]*CSPConfigWithZone, 0)
+	err := unmarshallConfigAllZone(ConfigCSP, name, func(base configcenter.Zone) interface{} {
+		item := &CSPConfigWithZone{Zone: base}
+		configs = append(configs, item)
+		return &item.CSPConfig
+	})
+	if err != nil {
+		return nil, err
+	}
+	return configs, nil
+}
@@ -1,5 +1,7 @@
 package middlewareconfig
 
+import "git.code.oa.com/tce-config/tcestuary-go/v4/configcenter"
+
 type ESConfig struct {
 	Host        string
 	IPV4        string `json:"ipv4"`
@@ -17,3 +19,48 @@ const ConfigES = "es"
 func (m *ESConfig) GetConfig(name string) error {
 	return unmarshallConfig(ConfigES, name, m)
 }
+
+// GetConfigMainRegion scope 声明为 all_region 时, 获取主地域的配置
+func (m *ESConfig) GetConfigMainRegion(name string) error {
+	return unmarshallConfigMainRegion(ConfigES, name, m)
+}
+
+// ESConfigWithRegion all_region 配置项及其 Region 信息
+type ESConfigWithRegion struct {
+	ESConfig
+	Region configcenter.Region
+}
+
+// ESConfigWithZone all_zone 配置项及其 Zone 信息
+type ESConfigWithZone struct {
+	ESConfig
+	Zone configcenter.Zone
+}
+
+// GetESConfigAllRegion scope 声明为 all_region 时, 获取所有 Region 的配置列表
+func GetESConfigAllRegion(name string) ([]*ESConfigWithRegion, error) {
+	configs := make([]*ESConfigWithRegion, 0)
+	err := unmarshallConfigAllRegion(ConfigES, name, func(base configcenter.Region) interface{} {
+		item := &ESConfigWithRegion{Region: base}
+		configs = append(configs, item)
+		return &item.ESConfig
+	})
+	if err != nil {
+		return nil, err
+	}
+	return configs, nil
+}
+
+// GetESConfigAllZone scope 声明为 all_zone 时, 获取所有 Zone 的配置列表
+func GetESConfigAllZone(name string) ([]*ESConfigWithZone, error) {
+	configs := make([]*ESConfigWithZone, 0)
+	err := unmarshallConfigAllZone(ConfigES, name, func(base configcenter.Zone) interface{} {
+		item := &ESConfigWithZone{Zone: base}
+		configs = append(configs, item)
+		return &item.ESConfig
+	})
+	if err != nil {
+		return nil, err
+	}
+	return configs, nil
+}
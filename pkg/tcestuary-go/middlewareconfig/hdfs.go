@@ -1,5 +1,7 @@
 package middlewareconfig
 
+import "git.code.oa.com/tce-config/tcestuary-go/v4/configcenter"
+
 type HdfsConfig struct {
 	Host       string
 	IP         string `json:"ip"`
@@ -14,3 +16,48 @@ const ConfigHdfs = "hdfs"
 func (m *HdfsConfig) GetConfig(name string) error {
 	return unmarshallConfig(ConfigHdfs, name, m)
 }
+
+// GetConfigMainRegion scope 声明为 all_region 时, 获取主地域的配置
+func (m *HdfsConfig) GetConfigMainRegion(name string) error {
+	return unmarshallConfigMainRegion(ConfigHdfs, name, m)
+}
+
+// HdfsConfigWithRegion all_region 配置项及其 Region 信息
+type HdfsConfigWithRegion struct {
+	HdfsConfig
+	Region configcenter.Region
+}
+
+// HdfsConfigWithZone all_zone 配置项及其 Zone 信息
+type HdfsConfigWithZone struct {
+	HdfsConfig
+	Zone configcenter.Zone
+}
+
+// GetHdfsConfigAllRegion scope 声明为 all_region 时, 获取所有 Region 的配置列表
+func GetHdfsConfigAllRegion(name string) ([]*HdfsConfigWithRegion, error) {
+	configs := make([]*HdfsConfigWithRegion, 0)
+	err := unmarshallConfigAllRegion(ConfigHdfs, name, func(base configcenter.Region) interface{} {
+		item := &HdfsConfigWithRegion{Region: base}
+		configs = append(configs, item)
+		return &item.HdfsConfig
+	})
+	if err != nil {
+		return nil, err
+	}
+	return configs, nil
+}
+
+// GetHdfsConfigAllZone scope 声明为 all_zone 时, 获取所有 Zone 的配置列表
+func GetHdfsConfigAllZone(name string) ([]*HdfsConfigWithZone, error) {
+	configs := make([]*HdfsConfigWithZone, 0)
+	err := unmarshallConfigAllZone(ConfigHdfs, name, func(base configcenter.Zone) interface{} {
+		item := &HdfsConfigWithZone{Zone: base}
+		configs = append(configs, item)
+		return &item.HdfsConfig
+	})
+	if err != nil {
+		return nil, err
+	}
+	return configs, nil
+}
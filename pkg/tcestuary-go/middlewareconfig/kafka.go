@@ -1,5 +1,7 @@
 package middlewareconfig
 
+import "git.code.oa.com/tce-config/tcestuary-go/v4/configcenter"
+
 type KafkaConfig struct {
 	Host     string
 	IPV4     string `json:"ipv4"`
@@ -15,3 +17,48 @@ const ConfigKafka = "kafka"
 func (m *KafkaConfig) GetConfig(name string) error {
 	return unmarshallConfig(ConfigKafka, name, m)
 }
+
+// GetConfigMainRegion scope 声明为 all_region 时, 获取主地域的配置
+func (m *KafkaConfig) GetConfigMainRegion(name string) error {
+	return unmarshallConfigMainRegion(ConfigKafka, name, m)
+}
+
+// KafkaConfigWithRegion all_region 配置项及其 Region 信息
+type KafkaConfigWithRegion struct {
+	KafkaConfig
+	Region configcenter.Region
+}
+
+// KafkaConfigWithZone all_zone 配置项及其 Zone 信息
+type KafkaConfigWithZone struct {
+	KafkaConfig
+	Zone configcenter.Zone
+}
+
+// GetKafkaConfigAllRegion scope 声明为 all_region 时, 获取所有 Region 的配置列表
+func GetKafkaConfigAllRegion(name string) ([]*KafkaConfigWithRegion, error) {
+	configs := make([]*KafkaConfigWithRegion, 0)
+	err := unmarshallConfigAllRegion(ConfigKafka, name, func(base configcenter.Region) interface{} {
+		item := &KafkaConfigWithRegion{Region: base}
+		configs = append(configs, item)
+		return &item.KafkaConfig
+	})
+	if err != nil {
+		return nil, err
+	}
+	return configs, nil
+}
+
+// GetKafkaConfigAllZone scope 声明为 all_zone 时, 获取所有 Zone 的配置列表
+func GetKafkaConfigAllZone(name string) ([]*KafkaConfigWithZone, error) {
+	configs := make([]*KafkaConfigWithZone, 0)
+	err := unmarshallConfigAllZone(ConfigKafka, name, func(base configcenter.Zone) interface{} {
+		item := &KafkaConfigWithZone{Zone: base}
+		configs = append(configs, item)
+		return &item.KafkaConfig
+	})
+	if err != nil {
+		return nil, err
+	}
+	return configs, nil
+}
@@ -1,63 +1,238 @@
 package middlewareconfig
 
 import (
-	"fmt"
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
 	"testing"
-)
 
-func TestMysqlGetConfig(t *testing.T) {
-	mysqlConfig := &MysqlConfig{}
-	mysqlConfig.GetConfig("dbsql_billing")
-	fmt.Printf("%v", mysqlConfig)
+	"git.code.oa.com/tce-config/tcestuary-go/v4"
+	"github.com/stretchr/testify/assert"
+)
 
+func TestMain(m *testing.M) {
+	if os.Getenv("CONFIG_FILE_PATH") == "" {
+		if err := tcestuary.SetConfigDirectory("../_example"); err != nil {
+			panic(err)
+		}
+	}
+	os.Exit(m.Run())
 }
 
-func TestRedisGetConfig(t *testing.T) {
+// 每类中间件增加一个 flat 配置项, 加密字段使用 passwd-secret 加密, GetConfig 返回解密后的配置
+func TestGetConfig(t *testing.T) {
+	origin := filepath.Dir(tcestuary.GetConfigDirectory())
+	defer tcestuary.SetConfigDirectory(origin)
+
+	client, err := tcestuary.New(tcestuary.WithConfigDirectory(origin))
+	assert.NoError(t, err)
+	s, err := client.NewPasswdSecret()
+	assert.NoError(t, err)
+	encrypt := func(plaintext string) string {
+		ciphertext, err := s.Encrypt(plaintext)
+		assert.NoError(t, err)
+		return ciphertext
+	}
+
+	services := map[string]map[string]interface{}{
+		ConfigMysql:   {"dbsql_billing": map[string]interface{}{"host": "db-1", "port": 3306, "user": "billing", "pass": encrypt("mysql_pass"), "db_name_list": []string{"billing"}}},
+		ConfigRedis:   {"ckv_cas": map[string]interface{}{"host": "redis-1", "port": 6379, "password": encrypt("redis_pass")}},
+		ConfigKafka:   {"kafka_flat": map[string]interface{}{"host": "kafka-1", "port": 9092, "username": "kafka_user", "password": encrypt("kafka_pass")}},
+		ConfigCMQ:     {"mq_waccount": map[string]interface{}{"host": "cmq-1", "port": 8080, "userName": "cmq_user", "password": encrypt("cmq_pass")}},
+		ConfigCSP:     {"yehe_file": map[string]interface{}{"host": "csp-1", "port": 80, "access_key": "csp_ak", "secret_key": encrypt("csp_sk")}},
+		ConfigMongodb: {"bsp_document": map[string]interface{}{"host": "mongo-1", "port": 27017, "user": "mongo_user", "password": encrypt("mongo_pass"), "admin_pass": encrypt("mongo_admin"), "db_name": "bsp"}},
+		ConfigES:      {"es_audit": map[string]interface{}{"host": "es-1", "port": 9200, "admin_user": "elastic", "admin_pass": encrypt("es_admin"), "password": encrypt("es_pass")}},
+		ConfigZK:      {"zk_flat": map[string]interface{}{"host": "zk-1", "port": 2181, "url": "zk-1:2181", "password": encrypt("zk_pass"), "auth_enabled": true}},
+		ConfigHdfs:    {"file_server": map[string]interface{}{"host": "hdfs-1", "port": 8020, "principal": encrypt("hdfs/file_server"), "keytab_file": "/etc/hdfs.keytab"}},
+	}
+
+	b, err := ioutil.ReadFile(filepath.Join(origin, "sdk.json"))
+	assert.NoError(t, err)
+	conf := make(map[string]interface{})
+	assert.NoError(t, json.Unmarshal(b, &conf))
+	for kind, items := range services {
+		section, _ := conf[kind].(map[string]interface{})
+		if section == nil {
+			section = make(map[string]interface{})
+			conf[kind] = section
+		}
+		for name, item := range items {
+			section[name] = item
+		}
+	}
+	b, err = json.Marshal(conf)
+	assert.NoError(t, err)
+
+	dir, err := ioutil.TempDir("", "middlewareconfig")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "sdk.json"), b, 0644))
+	assert.NoError(t, tcestuary.SetConfigDirectory(dir))
+
+	mysqlConfig := &MysqlConfig{}
+	assert.NoError(t, mysqlConfig.GetConfig("dbsql_billing"))
+	assert.Equal(t, MysqlConfig{Host: "db-1", Port: 3306, User: "billing", Pass: "mysql_pass", DBNameList: []string{"billing"}}, *mysqlConfig)
+
 	redisConfig := &RedisConfig{}
-	redisConfig.GetConfig("ckv_cas")
-	fmt.Printf("%v", redisConfig)
+	assert.NoError(t, redisConfig.GetConfig("ckv_cas"))
+	assert.Equal(t, RedisConfig{Host: "redis-1", Port: 6379, Password: "redis_pass"}, *redisConfig)
+
+	kafkaConfig := &KafkaConfig{}
+	assert.NoError(t, kafkaConfig.GetConfig("kafka_flat"))
+	assert.Equal(t, KafkaConfig{Host: "kafka-1", Port: 9092, Username: "kafka_user", Password: "kafka_pass"}, *kafkaConfig)
 
+	cmqConfig := &CMQConfig{}
+	assert.NoError(t, cmqConfig.GetConfig("mq_waccount"))
+	assert.Equal(t, CMQConfig{Host: "cmq-1", Port: 8080, Username: "cmq_user", Password: "cmq_pass"}, *cmqConfig)
+
+	cspConfig := &CSPConfig{}
+	assert.NoError(t, cspConfig.GetConfig("yehe_file"))
+	assert.Equal(t, CSPConfig{Host: "csp-1", Port: 80, AccessKey: "csp_ak", SecretKey: "csp_sk"}, *cspConfig)
+
+	mongodbConfig := &MongodbConfig{}
+	assert.NoError(t, mongodbConfig.GetConfig("bsp_document"))
+	assert.Equal(t, MongodbConfig{Host: "mongo-1", Port: 27017, User: "mongo_user", Password: "mongo_pass", AdminPass: "mongo_admin", DBName: "bsp"}, *mongodbConfig)
+
+	esConfig := &ESConfig{}
+	assert.NoError(t, esConfig.GetConfig("es_audit"))
+	assert.Equal(t, ESConfig{Host: "es-1", Port: 9200, AdminUser: "elastic", AdminPass: "es_admin", Password: "es_pass"}, *esConfig)
+
+	zkConfig := &ZKConfig{}
+	assert.NoError(t, zkConfig.GetConfig("zk_flat"))
+	assert.Equal(t, ZKConfig{Host: "zk-1", Port: 2181, URL: "zk-1:2181", Password: "zk_pass", AuthEnabled: true}, *zkConfig)
+
+	hdfsConfig := &HdfsConfig{}
+	assert.NoError(t, hdfsConfig.GetConfig("file_server"))
+	assert.Equal(t, HdfsConfig{Host: "hdfs-1", Port: 8020, Principal: "hdfs/file_server", KeytabFile: "/etc/hdfs.keytab"}, *hdfsConfig)
+
+	// 配置项不存在
+	assert.Equal(t, tcestuary.ErrNotFound, (&RedisConfig{}).GetConfig("not_exist"))
 }
 
-func TestKafkaGetConfig(t *testing.T) {
-	kafkaConfig := &KafkaConfig{}
-	kafkaConfig.GetConfig("wtag")
-	fmt.Printf("%v", kafkaConfig)
+func TestGetConfigAllRegion(t *testing.T) {
+	configs, err := GetKafkaConfigAllRegion("wtag")
+	assert.NoError(t, err)
+	assert.Len(t, configs, 1)
+	assert.Equal(t, 50000005, configs[0].Region.RegionID)
+	assert.Equal(t, "chongqing", configs[0].Region.RegionName)
+	assert.Equal(t, 9092, configs[0].Port)
+	assert.Equal(t, "kafka_pass", configs[0].Password)
 
+	// scope 不匹配
+	zoneConfigs, err := GetKafkaConfigAllZone("wtag")
+	assert.Equal(t, tcestuary.ErrUsageInvalid, err)
+	assert.Nil(t, zoneConfigs)
+	assert.Equal(t, tcestuary.ErrUsageInvalid, (&KafkaConfig{}).GetConfig("wtag"))
 }
 
-func TestCMQGetConfig(t *testing.T) {
-	cmqConfig := &CMQConfig{}
-	cmqConfig.GetConfig("mq_waccount")
-	fmt.Printf("%v", cmqConfig)
+func TestGetConfigAllZone(t *testing.T) {
+	configs, err := GetZKConfigAllZone("yunapi3_zk")
+	assert.NoError(t, err)
+	assert.Len(t, configs, 1)
+	assert.Equal(t, 50000005, configs[0].Zone.RegionID)
+	assert.Equal(t, 50050002, configs[0].Zone.ZoneID)
+	assert.Equal(t, 2181, configs[0].Port)
+
+	_, err = GetZKConfigAllRegion("yunapi3_zk")
+	assert.Equal(t, tcestuary.ErrUsageInvalid, err)
 }
 
-func TestCSPGetConfig(t *testing.T) {
-	cspConfig := &CSPConfig{}
-	cspConfig.GetConfig("yehe_file")
-	fmt.Printf("%v", cspConfig)
+// 所有中间件类型使用相同的实现, 只检查配置项不存在时的错误
+func TestGetConfigAllKinds(t *testing.T) {
+	allRegion := map[string]func(string) error{
+		ConfigMysql:   func(name string) error { _, err := GetMysqlConfigAllRegion(name); return err },
+		ConfigRedis:   func(name string) error { _, err := GetRedisConfigAllRegion(name); return err },
+		ConfigKafka:   func(name string) error { _, err := GetKafkaConfigAllRegion(name); return err },
+		ConfigCMQ:     func(name string) error { _, err := GetCMQConfigAllRegion(name); return err },
+		ConfigCSP:     func(name string) error { _, err := GetCSPConfigAllRegion(name); return err },
+		ConfigMongodb: func(name string) error { _, err := GetMongodbConfigAllRegion(name); return err },
+		ConfigES:      func(name string) error { _, err := GetESConfigAllRegion(name); return err },
+		ConfigZK:      func(name string) error { _, err := GetZKConfigAllRegion(name); return err },
+		ConfigHdfs:    func(name string) error { _, err := GetHdfsConfigAllRegion(name); return err },
+	}
+	allZone := map[string]func(string) error{
+		ConfigMysql:   func(name string) error { _, err := GetMysqlConfigAllZone(name); return err },
+		ConfigRedis:   func(name string) error { _, err := GetRedisConfigAllZone(name); return err },
+		ConfigKafka:   func(name string) error { _, err := GetKafkaConfigAllZone(name); return err },
+		ConfigCMQ:     func(name string) error { _, err := GetCMQConfigAllZone(name); return err },
+		ConfigCSP:     func(name string) error { _, err := GetCSPConfigAllZone(name); return err },
+		ConfigMongodb: func(name string) error { _, err := GetMongodbConfigAllZone(name); return err },
+		ConfigES:      func(name string) error { _, err := GetESConfigAllZone(name); return err },
+		ConfigZK:      func(name string) error { _, err := GetZKConfigAllZone(name); return err },
+		ConfigHdfs:    func(name string) error { _, err := GetHdfsConfigAllZone(name); return err },
+	}
+	for _, kind := range Kinds() {
+		assert.Equal(t, tcestuary.ErrNotFound, allRegion[kind]("not_exist"), kind)
+		assert.Equal(t, tcestuary.ErrNotFound, allZone[kind]("not_exist"), kind)
+
+		// 各类型均实现 MainRegionConfig
+		m, ok := reflect.New(configTypes[kind]).Interface().(MainRegionConfig)
+		assert.True(t, ok, kind)
+		assert.Equal(t, tcestuary.ErrNotFound, m.GetConfigMainRegion("not_exist"), kind)
+	}
 }
 
-func TestMongodbConfig(t *testing.T) {
-	mongodbConfig := &MongodbConfig{}
-	mongodbConfig.GetConfig("bsp_document")
-	fmt.Printf("%v", mongodbConfig)
+func TestGetConfigMainRegion(t *testing.T) {
+	kafkaConfig := &KafkaConfig{}
+	assert.NoError(t, kafkaConfig.GetConfigMainRegion("wtag"))
+	assert.Equal(t, "kafka-1.yf-1.tcepoc.fsphere.cn", kafkaConfig.Host)
+
+	assert.Equal(t, tcestuary.ErrNotFound, kafkaConfig.GetConfigMainRegion("not_exist"))
 }
 
-func TestESConfig(t *testing.T) {
-	esConfig := &ESConfig{}
-	esConfig.GetConfig("es_audit")
-	fmt.Printf("%v", esConfig)
+func TestEncryptedFields(t *testing.T) {
+	assert.Equal(t, []string{"Pass"}, EncryptedFields(ConfigMysql))
+	assert.Equal(t, []string{"password", "admin_pass"}, EncryptedFields(ConfigMongodb))
+	assert.Equal(t, []string{"secret_key"}, EncryptedFields(ConfigCSP))
+	assert.Nil(t, EncryptedFields("not_exist"))
+	assert.Contains(t, Kinds(), ConfigHdfs)
 }
 
-func TestZKConfig(t *testing.T) {
-	zkConfig := &ZKConfig{}
-	zkConfig.GetConfig("yunapi3_zk")
-	fmt.Printf("%v", zkConfig)
+// 配置文件不合法时 GetConfig 返回错误; 修改配置目录后重新加载
+func TestLazyLoad(t *testing.T) {
+	origin := filepath.Dir(tcestuary.GetConfigDirectory())
+	defer tcestuary.SetConfigDirectory(origin)
+
+	dir, err := ioutil.TempDir("", "middlewareconfig")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "sdk.json"), []byte("{ broken json"), 0644))
+
+	redisConfig := &RedisConfig{}
+	assert.NoError(t, tcestuary.SetConfigDirectory(dir))
+	assert.Error(t, redisConfig.GetConfig("ckv_cas"))
+
+	assert.NoError(t, tcestuary.SetConfigDirectory(origin))
+	assert.NoError(t, redisConfig.GetConfig("ckv_cas"))
+	assert.Equal(t, 6379, redisConfig.Port)
 }
 
-func TestHdfsConfig(t *testing.T) {
-	hdfsConfig := &HdfsConfig{}
-	hdfsConfig.GetConfig("file_server")
-	fmt.Printf("%v", hdfsConfig)
+func TestReloadDropsCache(t *testing.T) {
+	origin := filepath.Dir(tcestuary.GetConfigDirectory())
+	defer tcestuary.SetConfigDirectory(origin)
+
+	dir, err := ioutil.TempDir("", "middlewareconfig")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	b, err := ioutil.ReadFile(filepath.Join(origin, "sdk.json"))
+	assert.NoError(t, err)
+	file := filepath.Join(dir, "sdk.json")
+	assert.NoError(t, ioutil.WriteFile(file, b, 0644))
+
+	assert.NoError(t, tcestuary.SetConfigDirectory(dir))
+	assert.NoError(t, tcestuary.Reload())
+
+	redisConfig := &RedisConfig{}
+	assert.NoError(t, redisConfig.GetConfig("ckv_cas"))
+	assert.Equal(t, 6379, redisConfig.Port)
+
+	b = bytes.Replace(b, []byte(`"port": 6379`), []byte(`"port": 6380`), 1)
+	assert.NoError(t, ioutil.WriteFile(file, b, 0644))
+	assert.NoError(t, tcestuary.Reload())
+
+	assert.NoError(t, redisConfig.GetConfig("ckv_cas"))
+	assert.Equal(t, 6380, redisConfig.Port)
 }
@@ -1,5 +1,7 @@
 package middlewareconfig
 
+import "git.code.oa.com/tce-config/tcestuary-go/v4/configcenter"
+
 type MongodbConfig struct {
 	Host      string
 	IP        string `json:"ip"`
@@ -19,3 +21,48 @@ const ConfigMongodb = "mongodb"
 func (m *MongodbConfig) GetConfig(name string) error {
 	return unmarshallConfig(ConfigMongodb, name, m)
 }
+
+// GetConfigMainRegion scope 声明为 all_region 时, 获取主地域的配置
+func (m *MongodbConfig) GetConfigMainRegion(name string) error {
+	return unmarshallConfigMainRegion(ConfigMongodb, name, m)
+}
+
+// MongodbConfigWithRegion all_region 配置项及其 Region 信息
+type MongodbConfigWithRegion struct {
+	MongodbConfig
+	Region configcenter.Region
+}
+
+// MongodbConfigWithZone all_zone 配置项及其 Zone 信息
+type MongodbConfigWithZone struct {
+	MongodbConfig
+	Zone configcenter.Zone
+}
+
+// GetMongodbConfigAllRegion scope 声明为 all_region 时, 获取所有 Region 的配置列表
+func GetMongodbConfigAllRegion(name string) ([]*MongodbConfigWithRegion, error) {
+	configs := make([]*MongodbConfigWithRegion, 0)
+	err := unmarshallConfigAllRegion(ConfigMongodb, name, func(base configcenter.Region) interface{} {
+		item := &MongodbConfigWithRegion{Region: base}
+		configs = append(configs, item)
+		return &item.MongodbConfig
+	})
+	if err != nil {
+		return nil, err
+	}
+	return configs, nil
+}
+
+// GetMongodbConfigAllZone scope 声明为 all_zone 时, 获取所有 Zone 的配置列表
+func GetMongodbConfigAllZone(name string) ([]*MongodbConfigWithZone, error) {
+	configs := make([]*MongodbConfigWithZone, 0)
+	err := unmarshallConfigAllZone(ConfigMongodb, name, func(base configcenter.Zone) interface{} {
+		item := &MongodbConfigWithZone{Zone: base}
+		configs = append(configs, item)
+		return &item.MongodbConfig
+	})
+	if err != nil {
+		return nil, err
+	}
+	return configs, nil
+}
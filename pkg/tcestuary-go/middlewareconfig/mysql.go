@@ -1,5 +1,7 @@
 package middlewareconfig
 
+import "git.code.oa.com/tce-config/tcestuary-go/v4/configcenter"
+
 type MysqlConfig struct {
 	Host       string
 	Port       int
@@ -14,3 +16,48 @@ const ConfigMysql = "mysql"
 func (m *MysqlConfig) GetConfig(name string) error {
 	return unmarshallConfig(ConfigMysql, name, m)
 }
+
+// GetConfigMainRegion scope 声明为 all_region 时, 获取主地域的配置
+func (m *MysqlConfig) GetConfigMainRegion(name string) error {
+	return unmarshallConfigMainRegion(ConfigMysql, name, m)
+}
+
+// MysqlConfigWithRegion all_region 配置项及其 Region 信息
+type MysqlConfigWithRegion struct {
+	MysqlConfig
+	Region configcenter.Region
+}
+
+// MysqlConfigWithZone all_zone 配置项及其 Zone 信息
+type MysqlConfigWithZone struct {
+	MysqlConfig
+	Zone configcenter.Zone
+}
+
+// GetMysqlConfigAllRegion scope 声明为 all_region 时, 获取所有 Region 的配置列表
+func GetMysqlConfigAllRegion(name string) ([]*MysqlConfigWithRegion, error) {
+	configs := make([]*MysqlConfigWithRegion, 0)
+	err := unmarshallConfigAllRegion(ConfigMysql, name, func(base configcenter.Region) interface{} {
+		item := &MysqlConfigWithRegion{Region: base}
+		configs = append(configs, item)
+		return &item.MysqlConfig
+	})
+	if err != nil {
+		return nil, err
+	}
+	return configs, nil
+}
+
+// GetMysqlConfigAllZone scope 声明为 all_zone 时, 获取所有 Zone 的配置列表
+func GetMysqlConfigAllZone(name string) ([]*MysqlConfigWithZone, error) {
+	configs := make([]*MysqlConfigWithZone, 0)
+	err := unmarshallConfigAllZone(ConfigMysql, name, func(base configcenter.Zone) interface{} {
+		item := &MysqlConfigWithZone{Zone: base}
+		configs = append(configs, item)
+		return &item.MysqlConfig
+	})
+	if err != nil {
+		return nil, err
+	}
+	return configs, nil
+}
@@ -1,13 +1,15 @@
 package middlewareconfig
 
+import "git.code.oa.com/tce-config/tcestuary-go/v4/configcenter"
+
 type RedisConfig struct {
-	Host string
-	IP   string `json:"ip"`
-	IPV4 string `json:"ipv4"`
-	Port int
-	User string `json:"user"`
+	Host     string
+	IP       string `json:"ip"`
+	IPV4     string `json:"ipv4"`
+	Port     int
+	User     string `json:"user"`
 	Password string `encrypted:"true" json:"password"`
-	Pass string `encrypted:"true" json:"pass"`
+	Pass     string `encrypted:"true" json:"pass"`
 }
 
 const ConfigRedis = "redis"
@@ -15,3 +17,48 @@ const ConfigRedis = "redis"
 func (m *RedisConfig) GetConfig(name string) error {
 	return unmarshallConfig(ConfigRedis, name, m)
 }
+
+// GetConfigMainRegion scope 声明为 all_region 时, 获取主地域的配置
+func (m *RedisConfig) GetConfigMainRegion(name string) error {
+	return unmarshallConfigMainRegion(ConfigRedis, name, m)
+}
+
+// RedisConfigWithRegion all_region 配置项及其 Region 信息
+type RedisConfigWithRegion struct {
+	RedisConfig
+	Region configcenter.Region
+}
+
+// RedisConfigWithZone all_zone 配置项及其 Zone 信息
+type RedisConfigWithZone struct {
+	RedisConfig
+	Zone configcenter.Zone
+}
+
+// GetRedisConfigAllRegion scope 声明为 all_region 时, 获取所有 Region 的配置列表
+func GetRedisConfigAllRegion(name string) ([]*RedisConfigWithRegion, error) {
+	configs := make([]*RedisConfigWithRegion, 0)
+	err := unmarshallConfigAllRegion(ConfigRedis, name, func(base configcenter.Region) interface{} {
+		item := &RedisConfigWithRegion{Region: base}
+		configs = append(configs, item)
+		return &item.RedisConfig
+	})
+	if err != nil {
+		return nil, err
+	}
+	return configs, nil
+}
+
+// GetRedisConfigAllZone scope 声明为 all_zone 时, 获取所有 Zone 的配置列表
+func GetRedisConfigAllZone(name string) ([]*RedisConfigWithZone, error) {
+	configs := make([]*RedisConfigWithZone, 0)
+	err := unmarshallConfigAllZone(ConfigRedis, name, func(base configcenter.Zone) interface{} {
+		item := &RedisConfigWithZone{Zone: base}
+		configs = append(configs, item)
+		return &item.RedisConfig
+	})
+	if err != nil {
+		return nil, err
+	}
+	return configs, nil
+}
@@ -1,5 +1,7 @@
 package middlewareconfig
 
+import "git.code.oa.com/tce-config/tcestuary-go/v4/configcenter"
+
 type ZKConfig struct {
 	Host        string
 	IP          string `json:"ip"`
@@ -16,3 +18,48 @@ const ConfigZK = "zk"
 func (m *ZKConfig) GetConfig(name string) error {
 	return unmarshallConfig(ConfigZK, name, m)
 }
+
+// GetConfigMainRegion scope 声明为 all_region 时, 获取主地域的配置
+func (m *ZKConfig) GetConfigMainRegion(name string) error {
+	return unmarshallConfigMainRegion(ConfigZK, name, m)
+}
+
+// ZKConfigWithRegion all_region 配置项及其 Region 信息
+type ZKConfigWithRegion struct {
+	ZKConfig
+	Region configcenter.Region
+}
+
+// ZKConfigWithZone all_zone 配置项及其 Zone 信息
+type ZKConfigWithZone struct {
+	ZKConfig
+	Zone configcenter.Zone
+}
+
+// GetZKConfigAllRegion scope 声明为 all_region 时, 获取所有 Region 的配置列表
+func GetZKConfigAllRegion(name string) ([]*ZKConfigWithRegion, error) {
+	configs := make([]*ZKConfigWithRegion, 0)
+	err := unmarshallConfigAllRegion(ConfigZK, name, func(base configcenter.Region) interface{} {
+		item := &ZKConfigWithRegion{Region: base}
+		configs = append(configs, item)
+		return &item.ZKConfig
+	})
+	if err != nil {
+		return nil, err
+	}
+	return configs, nil
+}
+
+// GetZKConfigAllZone scope 声明为 all_zone 时, 获取所有 Zone 的配置列表
+func GetZKConfigAllZone(name string) ([]*ZKConfigWithZone, error) {
+	configs := make([]*ZKConfigWithZone, 0)
+	err := unmarshallConfigAllZone(ConfigZK, name, func(base configcenter.Zone) interface{} {
+		item := &ZKConfigWithZone{Zone: base}
+		configs = append(configs, item)
+		return &item.ZKConfig
+	})
+	if err != nil {
+		return nil, err
+	}
+	return configs, nil
+}
@@ -0,0 +1,29 @@
+package tcestuary
+
+import (
+	"fmt"
+
+	"git.code.oa.com/tce-config/tcestuary-go/v4/configcenter"
+)
+
+// String 隐藏密码, 避免 %v 输出解密后的密码
+func (m Mysql) String() string {
+	type plain Mysql
+	m.Password = configcenter.Redact(m.Password)
+	return fmt.Sprintf("%+v", plain(m))
+}
+
+// String 隐藏密码; 显式实现, 避免嵌入的 Mysql.String 隐藏 region 字段
+func (m MysqlWithRegion) String() string {
+	return fmt.Sprintf("{Mysql:%s RegionID:%d RegionName:%s}", m.Mysql, m.RegionID, m.RegionName)
+}
+
+// String 隐藏密码
+func (m MysqlWithZone) String() string {
+	return fmt.Sprintf("{Mysql:%s RegionID:%d ZoneID:%d}", m.Mysql, m.RegionID, m.ZoneID)
+}
+
+// String 隐藏密码
+func (m MysqlWithGaia) String() string {
+	return fmt.Sprintf("{Mysql:%s RegionID:%d ZoneID:%d GaiaID:%d}", m.Mysql, m.RegionID, m.ZoneID, m.GaiaID)
+}
@@ -0,0 +1,89 @@
+package tcestuary
+
+import (
+	"fmt"
+	"strings"
+
+	"git.code.oa.com/tce-config/tcestuary-go/v4/tcesecurity"
+)
+
+// Reencrypt 使用 from 解密后再使用 to 加密, 用于切换加密算法或轮转密钥.
+// 明文(未加密数据)直接使用 to 加密.
+// from 和 to 均为 kms-sm4-128-gcm 且使用同一 KMS 服务时, 调用 KMS ReEncrypt 接口, 明文不离开 KMS
+func Reencrypt(ciphertext string, from, to StorageSecurity) (string, error) {
+	if ret, ok, err := kmsReencrypt(ciphertext, from, to); ok {
+		return ret, err
+	}
+	plaintext, err := from.Decrypt(ciphertext)
+	if err != nil {
+		return "", err
+	}
+	return to.Encrypt(plaintext)
+}
+
+// ReencryptAll 批量重新加密, 结果与输入顺序一致. 任一条失败时返回错误, 错误信息包含下标
+func ReencryptAll(ciphertexts []string, from, to StorageSecurity) ([]string, error) {
+	ret := make([]string, len(ciphertexts))
+	for i, ciphertext := range ciphertexts {
+		s, err := Reencrypt(ciphertext, from, to)
+		if err != nil {
+			return nil, fmt.Errorf("reencrypt [%d]: %s", i, err)
+		}
+		ret[i] = s
+	}
+	return ret, nil
+}
+
+// ReencryptMap 重新加密 map 中所有的值, 如 配置项 -> 密文. 任一条失败时返回错误, 错误信息包含 key
+func ReencryptMap(values map[string]string, from, to StorageSecurity) (map[string]string, error) {
+	ret := make(map[string]string, len(values))
+	for k, ciphertext := range values {
+		s, err := Reencrypt(ciphertext, from, to)
+		if err != nil {
+			return nil, fmt.Errorf("reencrypt %q: %s", k, err)
+		}
+		ret[k] = s
+	}
+	return ret, nil
+}
+
+// kmsReencrypt 源和目标均为 KMS 对称加密时使用 KMS ReEncrypt 接口, ok 为 false 表示不适用
+func kmsReencrypt(ciphertext string, from, to StorageSecurity) (ret string, ok bool, err error) {
+	src, origin := decrypterFor(from, ciphertext)
+	dst, id := encrypterOf(to)
+	s, isKMS := src.(*tcesecurity.KMSSm4Crypto)
+	if !isKMS || !strings.HasPrefix(origin, s.Prefix) {
+		return "", false, nil
+	}
+	d, isKMS := dst.(*tcesecurity.KMSSm4Crypto)
+	if !isKMS || d.KMSServer != s.KMSServer {
+		return "", false, nil
+	}
+	ret, err = s.ReEncrypt(origin, d.KeyId)
+	if err != nil || id == "" {
+		return ret, true, err
+	}
+	ret, err = tcesecurity.WithKeyID(ret, id)
+	return ret, true, err
+}
+
+// decrypterFor 返回实际用于解密的组件及去掉 key id 后的密文
+func decrypterFor(c StorageSecurity, ciphertext string) (StorageSecurity, string) {
+	ring, ok := c.(*tcesecurity.KeyringCrypto)
+	if !ok {
+		return c, ciphertext
+	}
+	origin, id := tcesecurity.SplitKeyID(ciphertext)
+	if id == "" {
+		return ring.Default, ciphertext
+	}
+	return ring.Keys[id], origin
+}
+
+// encrypterOf 返回实际用于加密的组件, 密钥环同时返回 active key id
+func encrypterOf(c StorageSecurity) (StorageSecurity, string) {
+	if ring, ok := c.(*tcesecurity.KeyringCrypto); ok {
+		return ring.Keys[ring.Active], ring.Active
+	}
+	return c, ""
+}
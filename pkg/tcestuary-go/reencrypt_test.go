@@ -0,0 +1,84 @@
+package tcestuary
+
+import (
+	"strings"
+	"testing"
+
+	"git.code.oa.com/tce-config/tcestuary-go/v4/tcesecurity"
+	"git.code.oa.com/tce-config/tcestuary-go/v4/tcesecurity/kmstest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReencrypt(t *testing.T) {
+	from, _ := tcesecurity.NewAesCbcCrypto(tcesecurity.Aes256CbcAlgorithm, []byte("f13c3f40c60db7f32ce6a5e0143f09ea"))
+	to, _ := tcesecurity.NewAesGcmCrypto(tcesecurity.Aes256GcmAlgorithm, []byte("5c2bd12683ceefb8830abba988339e67"))
+
+	old, _ := from.Encrypt("mysql_pass")
+	ciphertext, err := Reencrypt(old, from, to)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(ciphertext, tcesecurity.AlreadyEncryptPrefix))
+	plaintext, err := to.Decrypt(ciphertext)
+	assert.NoError(t, err)
+	assert.Equal(t, "mysql_pass", plaintext)
+
+	// 明文直接加密, 已是目标格式的密文原样返回
+	ret, err := ReencryptAll([]string{"redis_pass", ciphertext}, from, to)
+	assert.NoError(t, err)
+	assert.NotEqual(t, "redis_pass", ret[0])
+	assert.Equal(t, ciphertext, ret[1])
+
+	_, err = ReencryptMap(map[string]string{"mysql": "AES+V1+00"}, from, to)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), `"mysql"`)
+}
+
+func TestReencryptKeyring(t *testing.T) {
+	k1, _ := tcesecurity.NewAesGcmCrypto(tcesecurity.Aes256GcmAlgorithm, []byte("5c2bd12683ceefb8830abba988339e67"))
+	k2, _ := tcesecurity.NewAesGcmCrypto(tcesecurity.Aes256GcmAlgorithm, []byte("0f3c3f40c60db7f32ce6a5e0143f09eb"))
+	before, _ := tcesecurity.NewKeyringCrypto("k1", map[string]tcesecurity.Crypto{"k1": k1}, nil)
+	after, _ := tcesecurity.NewKeyringCrypto("k2", map[string]tcesecurity.Crypto{"k1": k1, "k2": k2}, nil)
+
+	old, _ := before.Encrypt("mysql_pass")
+	ciphertext, err := Reencrypt(old, after, after)
+	assert.NoError(t, err)
+	_, id := tcesecurity.SplitKeyID(ciphertext)
+	assert.Equal(t, "k2", id)
+	plaintext, err := after.Decrypt(ciphertext)
+	assert.NoError(t, err)
+	assert.Equal(t, "mysql_pass", plaintext)
+}
+
+func TestReencryptKMS(t *testing.T) {
+	srv := kmstest.NewServer()
+	defer srv.Close()
+	newKMS := func(keyId string) tcesecurity.Crypto {
+		c, err := tcesecurity.SupportAlgorithm[tcesecurity.KMSSm4Algorithm](tcesecurity.CryptoOpts{
+			Method: tcesecurity.KMSSm4Algorithm, KeyId: keyId, KMSServer: srv.Host(),
+			SecretId: srv.SecretId, SecretKey: srv.SecretKey,
+			Transport: tcesecurity.KMSTransportOpts{CACert: srv.CACert()},
+		})
+		assert.NoError(t, err)
+		return c
+	}
+	from, to := newKMS("key-1"), newKMS("key-2")
+
+	old, err := from.Encrypt("mysql_pass")
+	assert.NoError(t, err)
+	ciphertext, err := Reencrypt(old, from, to)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, srv.Calls("ReEncrypt"))
+	plaintext, err := to.Decrypt(ciphertext)
+	assert.NoError(t, err)
+	assert.Equal(t, "mysql_pass", plaintext)
+
+	// 目标为密钥环时, 结果携带 active key id
+	ring, _ := tcesecurity.NewKeyringCrypto("2021", map[string]tcesecurity.Crypto{"2020": from, "2021": to}, from)
+	ciphertext, err = Reencrypt(old, ring, ring)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, srv.Calls("ReEncrypt"))
+	_, id := tcesecurity.SplitKeyID(ciphertext)
+	assert.Equal(t, "2021", id)
+	plaintext, err = ring.Decrypt(ciphertext)
+	assert.NoError(t, err)
+	assert.Equal(t, "mysql_pass", plaintext)
+}
@@ -0,0 +1,180 @@
+package tcestuary
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"text/template"
+
+	"git.code.oa.com/tce-config/tcestuary-go/v4/configcenter"
+)
+
+// Redis 缓存资源描述, 密码已解密
+type Redis struct {
+	Host     string
+	IP       string
+	Port     int
+	User     string
+	Password string
+}
+
+// GetRedisConfig 获取 redis 配置, 密码解密规则与 GetMysqlConfig 相同
+// 使用条件:
+// 1. scope 声明为 Global / Region / Zone
+// 错误码:
+// 1. 配置项不存在, 返回 ErrNotFound
+// 2. 配置字段不合法, 返回 ErrConfigInValid
+// 3. Scope不支持, 返回 ErrUsageInvalid
+// 4. 无法解密密码, 返回 ErrDecryptFail
+func (c *Client) GetRedisConfig(name string) (*Redis, error) {
+	// Load 内部逻辑保证仅加载一次配置
+	if err := c.Load(); err != nil {
+		return nil, err
+	}
+	center := c.ConfigCenter()
+	return getRedisConfig(center, c.passwordDecrypter(center), name)
+}
+
+// GetRedisConfig 使用默认 Client, 参考 Client.GetRedisConfig
+func GetRedisConfig(name string) (*Redis, error) {
+	return std.GetRedisConfig(name)
+}
+
+// getRedisConfig 从 center 中读取 redis 配置, 使用 decrypt 解密密码
+func getRedisConfig(center *configcenter.ConfigCenter, decrypt func(string) (string, error), name string) (*Redis, error) {
+	w := center.FindService("redis", name)
+	if w == nil {
+		return nil, ErrNotFound
+	} else if w.Scope != configcenter.ScopeFlat {
+		return nil, ErrUsageInvalid
+	}
+
+	// 字段与 middlewareconfig.RedisConfig 相同, ip / password 缺失时使用 ipv4 / pass
+	var raw struct {
+		Host     string
+		IP       string
+		IPV4     string
+		Port     int
+		User     string
+		Password string
+		Pass     string
+	}
+	if err := json.Unmarshal(w.Object.(json.RawMessage), &raw); err != nil {
+		return nil, ErrConfigInValid
+	}
+	redis := &Redis{Host: raw.Host, IP: raw.IP, Port: raw.Port, User: raw.User, Password: raw.Password}
+	if redis.IP == "" {
+		redis.IP = raw.IPV4
+	}
+	if redis.Password == "" {
+		redis.Password = raw.Pass
+	}
+
+	var err error
+	if redis.Password, err = decrypt(redis.Password); err != nil {
+		return nil, ErrDecryptFail
+	}
+	return redis, nil
+}
+
+// renderFuncs 模板函数. 同一次渲染使用同一份配置, 密码共用同一个解密组件
+func (c *Client) renderFuncs() template.FuncMap {
+	center := c.ConfigCenter()
+	decrypt := c.passwordDecrypter(center)
+	base := center.Base
+	return template.FuncMap{
+		"mysql": func(key string) (*Mysql, error) {
+			return getMysqlConfig(center, decrypt, key)
+		},
+		"redis": func(name string) (*Redis, error) {
+			return getRedisConfig(center, decrypt, name)
+		},
+		"region": func() (*Region, error) {
+			return getRegion(center, base.Region.RegionID)
+		},
+		"zone": func() (*Zone, error) {
+			return getZone(center, base.Zone.RegionID, base.Zone.ZoneID)
+		},
+		"gaia": func() (*Gaia, error) {
+			return getGaia(center, base.Gaia.GaiaID)
+		},
+		"mainRegion": func() string {
+			return base.ScopeExtInfo.MainRegionName
+		},
+	}
+}
+
+// Render 使用配置渲染 Go text/template 模板, 用于生成 nginx、php-fpm 等组件的配置文件
+// 模板函数:
+// 1. mysql "ocloud_api3.api_sync", 返回 *Mysql, 如 {{(mysql "ocloud_api3.api_sync").Password}}
+// 2. redis "ckv_cas", 返回 *Redis
+// 3. region / zone / gaia, 返回当前 *Region / *Zone / *Gaia
+// 4. mainRegion, 返回主地域名称
+// 模板引用不存在的配置项时返回错误, 且不向 w 写入任何内容
+func (c *Client) Render(w io.Writer, tmpl string) error {
+	if err := c.Load(); err != nil {
+		return err
+	}
+	t, err := template.New("render").Funcs(c.renderFuncs()).Option("missingkey=error").Parse(tmpl)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, nil); err != nil {
+		return err
+	}
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+// Render 使用默认 Client, 参考 Client.Render
+func Render(w io.Writer, tmpl string) error {
+	return std.Render(w, tmpl)
+}
+
+// RenderFile 渲染模板并写入 filename. 先写入同目录下的临时文件再重命名, 读取方不会读到不完整的内容.
+// 渲染结果包含密码明文, perm 建议使用 0600
+func (c *Client) RenderFile(filename, tmpl string, perm os.FileMode) error {
+	var buf bytes.Buffer
+	if err := c.Render(&buf, tmpl); err != nil {
+		return err
+	}
+	return WriteFileAtomic(filename, buf.Bytes(), perm)
+}
+
+// RenderFile 使用默认 Client, 参考 Client.RenderFile
+func RenderFile(filename, tmpl string, perm os.FileMode) error {
+	return std.RenderFile(filename, tmpl, perm)
+}
+
+// WriteFileAtomic 写入同目录下的临时文件后重命名, 读取方不会读到不完整的内容, filename 可以是正在读取的文件.
+// 临时文件创建时权限为 0600, 写入完成后再修改为 perm
+func WriteFileAtomic(filename string, data []byte, perm os.FileMode) (err error) {
+	f, err := ioutil.TempFile(filepath.Dir(filename), "."+filepath.Base(filename)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+
+	if _, err = f.Write(data); err != nil {
+		return err
+	}
+	if err = f.Sync(); err != nil {
+		return err
+	}
+	if err = f.Chmod(perm); err != nil {
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), filename)
+}
@@ -0,0 +1,122 @@
+package tcestuary
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"git.code.oa.com/tce-config/tcestuary-go/v4/configcenter"
+	"git.code.oa.com/tce-config/tcestuary-go/v4/tcesecurity"
+	"git.code.oa.com/tce-config/tcestuary-go/v4/tcesecurity/kmstest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetRedisConfig(t *testing.T) {
+	c, err := New(WithConfigDirectory("./_example"))
+	assert.NoError(t, err)
+
+	redis, err := c.GetRedisConfig("ckv_cas")
+	assert.NoError(t, err)
+	assert.Equal(t, &Redis{Host: "redis-1.ckv.yf-1.tcepoc.fsphere.cn", IP: "10.21.70.20", Port: 6379, Password: "redis_pass"}, redis)
+
+	_, err = c.GetRedisConfig("not_exist")
+	assert.Equal(t, ErrNotFound, err)
+}
+
+func TestRender(t *testing.T) {
+	c, err := New(WithConfigDirectory("./_example"))
+	assert.NoError(t, err)
+
+	var buf bytes.Buffer
+	tmpl := `db={{with mysql "ocloud_api3.api_sync"}}{{.User}}:{{.Password}}@{{.Host}}:{{.Port}}/{{.Database}}{{end}}
+redis={{(redis "ckv_cas").Password}}
+{{region.RegionName}} {{zone.ZoneName}} {{gaia.GaiaName}} {{mainRegion}}`
+	assert.NoError(t, c.Render(&buf, tmpl))
+	assert.Equal(t, `db=mysql_user:oU3C3zqppq6vxEQh@db-2.db.gaia-1.yf-1.chongqing.yf-1.tcepoc.fsphere.cn:22003/api_sync
+redis=redis_pass
+chongqing yf-1 gaia-1 chongqing`, buf.String())
+
+	// 配置项不存在时不输出
+	buf.Reset()
+	err = c.Render(&buf, `head {{(mysql "ocloud_api3.not_exist").Password}}`)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), ErrNotFound.Error())
+	assert.Empty(t, buf.String())
+
+	assert.Error(t, c.Render(&buf, `{{mysql`))
+}
+
+// mysql / redis 使用相同的密码解密规则, 同一次渲染共用同一个解密组件
+func TestRenderPasswdSecret(t *testing.T) {
+	srv := kmstest.NewServer()
+	defer srv.Close()
+
+	dir, err := ioutil.TempDir("", "render")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	passwd := configcenter.SecretConfig{
+		V1Aeskey: "f13c3f40c60db7f32ce6a5e0143f09ea",
+		Keys: []configcenter.SecretConfig{
+			{ID: "2022", Method: tcesecurity.KMSEnvelopeAlgorithm, KeyId: "passwd-key", SecretId: srv.SecretId, SecretKey: srv.SecretKey,
+				KMSServer: srv.Host(), KMSTransport: &configcenter.KMSTransport{CACert: srv.CACert()}},
+		},
+		ActiveKey: "2022",
+	}
+	file := filepath.Join(dir, "sdk.json")
+	writeExampleConfig(t, file, func(conf map[string]interface{}) {
+		conf["sdk"].(map[string]interface{})["passwd-secret"] = passwd
+	})
+	c, err := New(WithConfigFile(file))
+	assert.NoError(t, err)
+	s, err := c.NewPasswdSecret()
+	assert.NoError(t, err)
+	mysqlPass, err := s.Encrypt("mysql_pass")
+	assert.NoError(t, err)
+	redisPass, err := s.Encrypt("redis_pass")
+	assert.NoError(t, err)
+
+	writeExampleConfig(t, file, func(conf map[string]interface{}) {
+		conf["sdk"].(map[string]interface{})["passwd-secret"] = passwd
+		setMysqlPass(conf, "ocloud_api3", mysqlPass)
+		conf["redis"].(map[string]interface{})["ckv_cas"].(map[string]interface{})["password"] = redisPass
+	})
+	assert.NoError(t, c.Reload())
+
+	redis, err := c.GetRedisConfig("ckv_cas")
+	assert.NoError(t, err)
+	assert.Equal(t, "redis_pass", redis.Password)
+
+	decrypts := srv.Calls("Decrypt")
+	var buf bytes.Buffer
+	assert.NoError(t, c.Render(&buf, `{{(mysql "ocloud_api3.api_sync").Password}} {{(redis "ckv_cas").Password}}`))
+	assert.Equal(t, "mysql_pass redis_pass", buf.String())
+	assert.Equal(t, decrypts+1, srv.Calls("Decrypt"))
+}
+
+func TestRenderFile(t *testing.T) {
+	c, err := New(WithConfigDirectory("./_example"))
+	assert.NoError(t, err)
+
+	dir, err := ioutil.TempDir("", "render")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "redis.conf")
+
+	assert.NoError(t, c.RenderFile(filename, `requirepass {{(redis "ckv_cas").Password}}`, 0600))
+	data, err := ioutil.ReadFile(filename)
+	assert.NoError(t, err)
+	assert.Equal(t, "requirepass redis_pass", string(data))
+	stat, err := os.Stat(filename)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), stat.Mode().Perm())
+
+	// 渲染失败时保留原文件, 且不残留临时文件
+	assert.Error(t, c.RenderFile(filename, `{{(redis "not_exist").Password}}`, 0600))
+	data, _ = ioutil.ReadFile(filename)
+	assert.Equal(t, "requirepass redis_pass", string(data))
+	files, _ := ioutil.ReadDir(dir)
+	assert.Len(t, files, 1)
+}
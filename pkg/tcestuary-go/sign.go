@@ -12,18 +12,18 @@ type Signer interface {
 	Verify(msg, signValue string) (bool, error) // 验证签名
 }
 
-func parseSignConfig() (configcenter.SecretConfig, error) {
+func (c *Client) parseSignConfig() (configcenter.SecretConfig, error) {
 	var secretConf configcenter.SecretConfig
-	if err := std.Load(); err != nil {
+	if err := c.Load(); err != nil {
 		return secretConf, err
 	}
-	return std.ConfigCenter.SDK.SignSecret, nil
+	return c.ConfigCenter().SDK.SignSecret, nil
 }
 
 // 签名、验签组件
-func NewSigner() (Signer, error) {
+func (c *Client) NewSigner() (Signer, error) {
 	//  判断是否需要初始化TSM
-	tsmConf, err := parseTSMSecretConfig()
+	tsmConf, err := c.parseTSMSecretConfig()
 	if err != nil {
 		return nil, err
 	}
@@ -33,7 +33,7 @@ func NewSigner() (Signer, error) {
 		}
 	}
 	// 密钥配置
-	secretConf, err := parseSignConfig()
+	secretConf, err := c.parseSignConfig()
 	if err != nil {
 		return nil, err
 	}
@@ -48,7 +48,14 @@ func NewSigner() (Signer, error) {
 		SecretId:   secretConf.SecretId,
 		SecretKey:  secretConf.SecretKey,
 		KMSServer:  secretConf.KMSServer,
+		Transport:  newKMSTransportOpts(secretConf.KMSTransport, c.manager.logger),
+		Retry:      newKMSRetryOpts(secretConf.KMSRetry),
 		PublicKey:  secretConf.PublicKey,
 		PrivateKey: secretConf.PrivateKey,
 	})
 }
+
+// NewSigner 使用默认 Client, 参考 Client.NewSigner
+func NewSigner() (Signer, error) {
+	return std.NewSigner()
+}
@@ -4,33 +4,48 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"time"
+	"unicode/utf8"
 
 	"git.code.oa.com/tce-config/tcestuary-go/v4/configcenter"
+	"git.code.oa.com/tce-config/tcestuary-go/v4/logger"
 	"git.code.oa.com/tce-config/tcestuary-go/v4/tcesecurity"
 )
 
 type StorageSecurity interface {
 	Encrypt(string) (string, error) // 加密，明文输入长度限制与算法相关
 	Decrypt(string) (string, error) // 解密，密文输入长度限制与算法相关
+	// 加密并绑定上下文(如 表名、列名、主键), 解密时必须提供相同的上下文. 上下文为空时等价于 Encrypt.
+	// 仅 aes-256-gcm / tsm-sm4-128-gcm / kms-envelope / kms-envelope-sm4 支持, 其他算法返回 tcesecurity.ErrNotSupportContext
+	EncryptWithContext(plaintext string, aad []byte) (string, error)
+	DecryptWithContext(ciphertext string, aad []byte) (string, error)
 }
 
-func parseStorageSecretConfig() (configcenter.SecretConfig, error) {
+func (c *Client) parseStorageSecretConfig() (configcenter.SecretConfig, error) {
+	if c.storageSecret != nil {
+		return *c.storageSecret, nil
+	}
 	storageEnv := os.Getenv("STORAGE_SECRET")
 	var secretConf configcenter.SecretConfig
 	if storageEnv == "" {
-		if err := std.Load(); err != nil {
+		if err := c.Load(); err != nil {
 			return secretConf, err
 		}
-		return std.ConfigCenter.SDK.StorageSecret, nil
+		return c.ConfigCenter().SDK.StorageSecret, nil
 	}
 	err := json.Unmarshal([]byte(storageEnv), &secretConf)
 	return secretConf, err
 }
 
+// parseStorageSecretConfig 默认 Client 的配置
+func parseStorageSecretConfig() (configcenter.SecretConfig, error) {
+	return std.parseStorageSecretConfig()
+}
+
 // NewStorageSecurity 存储安全组件
-func NewStorageSecurity() (StorageSecurity, error) {
+func (c *Client) NewStorageSecurity() (StorageSecurity, error) {
 	// 判断是否需要初始化TSM
-	tsmConf, err := parseTSMSecretConfig()
+	tsmConf, err := c.parseTSMSecretConfig()
 	if err != nil {
 		return nil, err
 	}
@@ -40,51 +55,134 @@ func NewStorageSecurity() (StorageSecurity, error) {
 		}
 	}
 	// 密钥配置
-	secretConf, err := parseStorageSecretConfig()
+	secretConf, err := c.parseStorageSecretConfig()
 	if err != nil {
 		return nil, err
 	}
-	f, ok := tcesecurity.SupportAlgorithm[secretConf.Method]
-	if !ok {
-		return nil, fmt.Errorf("not support algorithm: %s", secretConf.Method)
-	}
+	return c.newCrypto(secretConf)
+}
 
-	return f(tcesecurity.CryptoOpts{
-		Method:     secretConf.Method,
-		AesKey:     secretConf.AesKey,
-		Sm4Key:     secretConf.Sm4Key,
-		PrivateKey: secretConf.PrivateKey,
-		PublicKey:  secretConf.PublicKey,
-		KeyId:      secretConf.KeyId,
-		SecretId:   secretConf.SecretId,
-		SecretKey:  secretConf.SecretKey,
-		KMSServer:  secretConf.KMSServer,
-	})
+// NewStorageSecurity 使用默认 Client, 参考 Client.NewStorageSecurity
+func NewStorageSecurity() (StorageSecurity, error) {
+	return std.NewStorageSecurity()
 }
 
 // NewPasswdSecret 存储安全组件
-func NewPasswdSecret() (StorageSecurity, error) {
-	// 判断是否需要初始化TSM
-	tsmConf, err := parseTSMSecretConfig()
-	if err != nil {
+func (c *Client) NewPasswdSecret() (StorageSecurity, error) {
+	if err := c.Load(); err != nil {
 		return nil, err
 	}
-	if tsmConf.PemAppid != "" {
+	return c.newPasswdSecret(c.ConfigCenter())
+}
+
+// NewPasswdSecret 使用默认 Client, 参考 Client.NewPasswdSecret
+func NewPasswdSecret() (StorageSecurity, error) {
+	return std.NewPasswdSecret()
+}
+
+// newPasswdSecret 使用 center 中的 passwd-secret 配置创建加解密组件
+func (c *Client) newPasswdSecret(center *configcenter.ConfigCenter) (tcesecurity.Crypto, error) {
+	// 判断是否需要初始化TSM
+	if tsmConf := center.SDK.TSMSecret; tsmConf.PemAppid != "" {
 		if err := InitTencentSMWithConfig(tsmConf); err != nil {
 			return nil, err
 		}
 	}
-	// 密钥配置
-	secretConf, err := parsePasswdSecretConfig()
-	if err != nil {
-		return nil, err
+	return c.newCrypto(passwdSecretConfig(center))
+}
+
+// passwordDecrypter 返回密码解密函数, 同一次调用中的多个密码共用 center 及同一个加解密组件.
+// 明文及不带 key id 的 AES+V1 密文兼容历史配置: passwd-secret 为 aes-256-cbc 且 aes_key 与 aeskey 不同时
+// (tce-sdk-encipher 使用 aes_key 加密), 先使用 aes_key 解密, 失败时使用 aeskey 解密.
+// 其它格式(包括密钥环密文)使用 passwd-secret 配置的算法.
+// 加解密组件在首次遇到此类密文时创建, 返回的函数不能并发调用
+func (c *Client) passwordDecrypter(center *configcenter.ConfigCenter) func(password string) (string, error) {
+	v1Key := center.SDK.PasswdSecret.V1Aeskey
+	var cbcKey string
+	if conf := passwdSecretConfig(center); conf.Method == tcesecurity.Aes256CbcAlgorithm && conf.AesKey != v1Key {
+		cbcKey = conf.AesKey
 	}
-	f, ok := tcesecurity.SupportAlgorithm[secretConf.Method]
-	if !ok {
-		return nil, fmt.Errorf("not support algorithm: %s", secretConf.Method)
+
+	var crypto tcesecurity.Crypto
+	return func(password string) (string, error) {
+		if !tcesecurity.IsCiphertext(password) {
+			return Decrypt(v1Key, password)
+		}
+		if _, id := tcesecurity.SplitKeyID(password); tcesecurity.AesV1WithPrefix(password) && id == "" {
+			if cbcKey != "" {
+				// 密钥不匹配时 AES-CBC 可能解出乱码, 乱码通常不是合法的 UTF-8
+				if plaintext, err := Decrypt(cbcKey, password); err == nil && utf8.ValidString(plaintext) {
+					return plaintext, nil
+				}
+			}
+			return Decrypt(v1Key, password)
+		}
+		if crypto == nil {
+			var err error
+			if crypto, err = c.newPasswdSecret(center); err != nil {
+				return "", err
+			}
+		}
+		return crypto.Decrypt(password)
+	}
+}
+
+// passwdSecretConfig 返回 passwd-secret 配置的副本, 不改动全局配置
+func passwdSecretConfig(center *configcenter.ConfigCenter) configcenter.SecretConfig {
+	// 兼容 method 为空场景，历史版本，走默认 aes
+	secretConf := center.SDK.PasswdSecret
+	if secretConf.Method == "" {
+		secretConf.Method = tcesecurity.Aes256CbcAlgorithm
+		secretConf.AesKey = secretConf.V1Aeskey
+	}
+	if len(secretConf.Keys) > 0 {
+		keys := make([]configcenter.SecretConfig, len(secretConf.Keys))
+		for i, key := range secretConf.Keys {
+			if key.AesKey == "" {
+				key.AesKey = key.V1Aeskey
+			}
+			keys[i] = key
+		}
+		secretConf.Keys = keys
+	}
+	return secretConf
+}
+
+// newKMSTransportOpts 将 sdk.json 中的 KMS 传输配置转换为组件参数, 告警日志输出到 log
+func newKMSTransportOpts(conf *configcenter.KMSTransport, log logger.Logger) tcesecurity.KMSTransportOpts {
+	opts := tcesecurity.KMSTransportOpts{Logger: log}
+	if conf == nil {
+		return opts
+	}
+	opts.CAFile = conf.CAFile
+	opts.CACert = conf.CACert
+	opts.CertFile = conf.CertFile
+	opts.KeyFile = conf.KeyFile
+	opts.ServerName = conf.ServerName
+	opts.InsecureSkipVerify = conf.InsecureSkipVerify
+	opts.Timeout = time.Duration(conf.Timeout) * time.Second
+	opts.DialTimeout = time.Duration(conf.DialTimeout) * time.Second
+	opts.Proxy = conf.Proxy
+	return opts
+}
+
+// newKMSRetryOpts 将 sdk.json 中的 KMS 重试、熔断配置转换为组件参数
+func newKMSRetryOpts(conf *configcenter.KMSRetry) tcesecurity.KMSRetryOpts {
+	if conf == nil {
+		return tcesecurity.KMSRetryOpts{}
 	}
+	return tcesecurity.KMSRetryOpts{
+		MaxRetries:       conf.MaxRetries,
+		BaseDelay:        time.Duration(conf.BaseDelay) * time.Millisecond,
+		MaxDelay:         time.Duration(conf.MaxDelay) * time.Millisecond,
+		BreakerThreshold: conf.BreakerThreshold,
+		BreakerCooldown:  time.Duration(conf.BreakerCooldown) * time.Second,
+	}
+}
 
-	return f(tcesecurity.CryptoOpts{
+// newCryptoOpts 将 sdk.json 中的密钥配置转换为加解密组件的参数
+func (c *Client) newCryptoOpts(secretConf configcenter.SecretConfig) tcesecurity.CryptoOpts {
+	return tcesecurity.CryptoOpts{
 		Method:     secretConf.Method,
 		AesKey:     secretConf.AesKey,
 		Sm4Key:     secretConf.Sm4Key,
@@ -94,18 +192,51 @@ func NewPasswdSecret() (StorageSecurity, error) {
 		SecretId:   secretConf.SecretId,
 		SecretKey:  secretConf.SecretKey,
 		KMSServer:  secretConf.KMSServer,
-	})
+		DataKeyTTL: time.Duration(secretConf.DataKeyTTL) * time.Second,
+		Transport:  newKMSTransportOpts(secretConf.KMSTransport, c.manager.logger),
+		Retry:      newKMSRetryOpts(secretConf.KMSRetry),
+	}
 }
 
-func parsePasswdSecretConfig() (configcenter.SecretConfig, error) {
-	var secretConf configcenter.SecretConfig
-	if err := std.Load(); err != nil {
-		return secretConf, err
+// newCrypto 根据密钥配置创建加解密组件.
+// 配置了 keys 时返回密钥环: 加密使用 active_key, 不带 key id 的历史密文使用外层密钥解密
+func (c *Client) newCrypto(secretConf configcenter.SecretConfig) (tcesecurity.Crypto, error) {
+	if len(secretConf.Keys) == 0 {
+		return c.newSingleCrypto(secretConf)
 	}
-	// 兼容 method 为空场景，历史版本，走默认 aes
-	if std.ConfigCenter.SDK.PasswdSecret.Method == "" {
-		std.ConfigCenter.SDK.PasswdSecret.Method = tcesecurity.Aes256CbcAlgorithm
-		std.ConfigCenter.SDK.PasswdSecret.AesKey = std.ConfigCenter.SDK.PasswdSecret.V1Aeskey
+
+	keys := make(map[string]tcesecurity.Crypto, len(secretConf.Keys))
+	for _, key := range secretConf.Keys {
+		if _, ok := keys[key.ID]; ok {
+			return nil, fmt.Errorf("duplicate key id: %q", key.ID)
+		}
+		conf, _ := secretConf.KeyConfig(key.ID)
+		crypto, err := c.newSingleCrypto(conf)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %s", key.ID, err)
+		}
+		keys[key.ID] = crypto
+	}
+
+	// 外层未配置密钥时, 历史密文使用 active 密钥解密
+	var legacy tcesecurity.Crypto
+	if secretConf.AesKey != "" || secretConf.Sm4Key != "" || secretConf.PrivateKey != "" ||
+		secretConf.PublicKey != "" || secretConf.KeyId != "" {
+		conf := secretConf
+		conf.Keys, conf.ActiveKey = nil, ""
+		crypto, err := c.newSingleCrypto(conf)
+		if err != nil {
+			return nil, err
+		}
+		legacy = crypto
+	}
+	return tcesecurity.NewKeyringCrypto(secretConf.ActiveKey, keys, legacy)
+}
+
+func (c *Client) newSingleCrypto(secretConf configcenter.SecretConfig) (tcesecurity.Crypto, error) {
+	f, ok := tcesecurity.SupportAlgorithm[secretConf.Method]
+	if !ok {
+		return nil, fmt.Errorf("not support algorithm: %s", secretConf.Method)
 	}
-	return std.ConfigCenter.SDK.PasswdSecret, nil
+	return f(c.newCryptoOpts(secretConf))
 }
@@ -1,10 +1,19 @@
 package tcestuary
 
 import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 
 	"git.code.oa.com/tce-config/tcestuary-go/v4/configcenter"
+	"git.code.oa.com/tce-config/tcestuary-go/v4/tcesecurity"
+	"git.code.oa.com/tce-config/tcestuary-go/v4/tcesecurity/kmstest"
+	"github.com/stretchr/testify/assert"
 )
 
 func Test_parseStorageSecretConfig(t *testing.T) {
@@ -37,3 +46,202 @@ func Test_parseStorageSecretConfig(t *testing.T) {
 		})
 	}
 }
+
+func TestStorageSecurityKeyring(t *testing.T) {
+	// 轮转前: 单密钥
+	old, err := NewStorageSecurity()
+	assert.NoError(t, err)
+	legacy, err := old.Encrypt("mysql_pass")
+	assert.NoError(t, err)
+
+	// 轮转后: 保留外层历史密钥, 新增 keys / active_key
+	os.Setenv("STORAGE_SECRET", `{
+		"method": "aes-256-gcm",
+		"aes_key": "5c2bd12683ceefb8830abba988339e67",
+		"keys": [
+			{"id": "2020", "aes_key": "5c2bd12683ceefb8830abba988339e67"},
+			{"id": "2021", "aes_key": "0f3c3f40c60db7f32ce6a5e0143f09eb"}
+		],
+		"active_key": "2021"
+	}`)
+	defer os.Unsetenv("STORAGE_SECRET")
+
+	s, err := NewStorageSecurity()
+	assert.NoError(t, err)
+	ciphertext, err := s.Encrypt("mysql_pass")
+	assert.NoError(t, err)
+	_, id := tcesecurity.SplitKeyID(ciphertext)
+	assert.Equal(t, "2021", id)
+
+	for _, c := range []string{legacy, ciphertext} {
+		plaintext, err := s.Decrypt(c)
+		assert.NoError(t, err)
+		assert.Equal(t, "mysql_pass", plaintext)
+	}
+
+	// 旧密钥无法解密新密文
+	_, err = old.Decrypt(ciphertext)
+	assert.Error(t, err)
+}
+
+func TestPasswdSecretKeyring(t *testing.T) {
+	conf := configcenter.SecretConfig{
+		V1Aeskey: "f13c3f40c60db7f32ce6a5e0143f09ea",
+		Keys: []configcenter.SecretConfig{
+			{ID: "2021", V1Aeskey: "5c2bd12683ceefb8830abba988339e67"},
+		},
+		ActiveKey: "2021",
+	}
+	conf.Method, conf.AesKey = tcesecurity.Aes256CbcAlgorithm, conf.V1Aeskey
+	key, ok := conf.KeyConfig("2021")
+	assert.True(t, ok)
+	assert.Equal(t, tcesecurity.Aes256CbcAlgorithm, key.Method)
+	assert.Empty(t, key.Keys)
+
+	old, err := std.newCrypto(configcenter.SecretConfig{Method: tcesecurity.Aes256CbcAlgorithm, AesKey: conf.V1Aeskey})
+	assert.NoError(t, err)
+	legacy, _ := old.Encrypt("mysql_pass")
+
+	conf.Keys[0].AesKey = conf.Keys[0].V1Aeskey
+	s, err := std.newCrypto(conf)
+	assert.NoError(t, err)
+	ciphertext, err := s.Encrypt("mysql_pass")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(ciphertext, "AES+V1.32303231+"))
+	for _, c := range []string{legacy, ciphertext} {
+		plaintext, err := s.Decrypt(c)
+		assert.NoError(t, err)
+		assert.Equal(t, "mysql_pass", plaintext)
+	}
+}
+
+// passwd-secret 轮转后, GetMysqlConfig 等接口同时读取历史密码及新密钥加密的密码
+func TestMysqlPasswdSecretRotate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tcestuary")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	passwd := configcenter.SecretConfig{
+		V1Aeskey:  "f13c3f40c60db7f32ce6a5e0143f09ea",
+		Keys:      []configcenter.SecretConfig{{ID: "2021", V1Aeskey: "0f3c3f40c60db7f32ce6a5e0143f09eb"}},
+		ActiveKey: "2021",
+	}
+	file := filepath.Join(dir, "sdk.json")
+	writeExampleConfig(t, file, func(conf map[string]interface{}) {
+		conf["sdk"].(map[string]interface{})["passwd-secret"] = passwd
+	})
+	c, err := New(WithConfigFile(file))
+	assert.NoError(t, err)
+	s, err := c.NewPasswdSecret()
+	assert.NoError(t, err)
+	rotated, err := s.Encrypt("rotated_pass")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(rotated, "AES+V1.32303231+"))
+	unknown := strings.Replace(rotated, "AES+V1.32303231+", "AES+V1.32303232+", 1)
+
+	// region 实例保留历史密码
+	writeExampleConfig(t, file, func(conf map[string]interface{}) {
+		conf["sdk"].(map[string]interface{})["passwd-secret"] = passwd
+		setMysqlPass(conf, "ocloud_api3", rotated)
+		setMysqlPass(conf, "dbsql_yje_yujie_data", rotated)
+		setMysqlPass(conf, "dbsql_gaia_data", unknown)
+	})
+	assert.NoError(t, c.Reload())
+
+	m, err := c.GetMysqlConfig("ocloud_api3.api_sync")
+	assert.NoError(t, err)
+	assert.Equal(t, "rotated_pass", m.Password)
+	regions, err := c.GetMysqlConfigAllRegion("dbsql_tcenter_CCDB4.CCDB4")
+	assert.NoError(t, err)
+	assert.Equal(t, "bHs6WmrGAfq2dnbQ", regions[0].Password)
+	zones, err := c.GetMysqlConfigAllZone("dbsql_yje_yujie_data.yujie_data")
+	assert.NoError(t, err)
+	assert.Equal(t, "rotated_pass", zones[0].Password)
+
+	// 未知 key id
+	_, err = c.GetMysqlConfigAllGaia("dbsql_gaia_data.gaia_data")
+	assert.Equal(t, ErrDecryptFail, err)
+}
+
+func TestStorageSecurityWithContext(t *testing.T) {
+	s, err := NewStorageSecurity()
+	assert.NoError(t, err)
+	ciphertext, err := s.EncryptWithContext("mysql_pass", []byte("user.password:1"))
+	assert.NoError(t, err)
+	plaintext, err := s.DecryptWithContext(ciphertext, []byte("user.password:1"))
+	assert.NoError(t, err)
+	assert.Equal(t, "mysql_pass", plaintext)
+	_, err = s.DecryptWithContext(ciphertext, []byte("user.password:2"))
+	assert.Error(t, err)
+
+	// passwd-secret 为 aes-256-cbc, 不支持上下文
+	p, err := NewPasswdSecret()
+	assert.NoError(t, err)
+	_, err = p.EncryptWithContext("mysql_pass", []byte("user.password:1"))
+	assert.Equal(t, tcesecurity.ErrNotSupportContext, err)
+}
+
+type bufferLogger struct {
+	strings.Builder
+}
+
+func (l *bufferLogger) Printf(format string, args ...interface{}) {
+	fmt.Fprintf(l, format+"\n", args...)
+}
+
+func TestStorageSecurityKMSTransport(t *testing.T) {
+	os.Setenv("STORAGE_SECRET", `{
+		"method": "kms-sm4-128-gcm",
+		"key_id": "key-1",
+		"kms_server": "kms.local:443",
+		"kms_transport": {"insecure_skip_verify": true, "timeout": 5}
+	}`)
+	defer os.Unsetenv("STORAGE_SECRET")
+
+	log := &bufferLogger{}
+	c, err := New(WithConfigDirectory("./_example"), WithLogger(log))
+	assert.NoError(t, err)
+	_, err = c.NewStorageSecurity()
+	assert.NoError(t, err)
+	assert.Contains(t, log.String(), "kms.local:443")
+	assert.Contains(t, log.String(), "insecure_skip_verify")
+
+	// 默认校验证书, 不输出告警
+	os.Setenv("STORAGE_SECRET", `{"method": "kms-sm4-128-gcm", "key_id": "key-1", "kms_server": "kms.local:443"}`)
+	log.Reset()
+	_, err = c.NewStorageSecurity()
+	assert.NoError(t, err)
+	assert.Empty(t, log.String())
+}
+
+func TestStorageSecurityKMS(t *testing.T) {
+	srv := kmstest.NewServer()
+	defer srv.Close()
+	defer os.Unsetenv("STORAGE_SECRET")
+
+	for _, method := range []string{tcesecurity.KMSSm4Algorithm, tcesecurity.KMSEnvelopeAlgorithm, tcesecurity.KMSEnvelopeSM4Algorithm} {
+		conf, _ := json.Marshal(configcenter.SecretConfig{
+			Method:       method,
+			KeyId:        "storage-key",
+			SecretId:     srv.SecretId,
+			SecretKey:    srv.SecretKey,
+			KMSServer:    srv.Host(),
+			KMSTransport: &configcenter.KMSTransport{CACert: srv.CACert()},
+		})
+		os.Setenv("STORAGE_SECRET", string(conf))
+		s, err := NewStorageSecurity()
+		assert.NoError(t, err, method)
+		ciphertext, err := s.Encrypt("mysql_pass")
+		assert.NoError(t, err, method)
+		assert.True(t, strings.HasPrefix(ciphertext, tcesecurity.AlreadyEncryptPrefix), method)
+		plaintext, err := s.Decrypt(ciphertext)
+		assert.NoError(t, err, method)
+		assert.Equal(t, "mysql_pass", plaintext, method)
+	}
+
+	// 密钥禁用后解密失败
+	srv.DisableKey("storage-key")
+	s, _ := NewStorageSecurity()
+	_, err := s.Encrypt("mysql_pass")
+	assert.Error(t, err)
+}
@@ -0,0 +1,219 @@
+package tcesecurity
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// AES+V1+ 密文格式, 兼容历史版本:
+// AES+V[版本号]+[hex(AES-CBC(加盐明文))]
+// AES-CBC 的初始向量取密钥前 16 字节, PKCS5 填充
+// 加盐格式: [盐长度(1Byte)][盐][明文], 盐长度取值 [1, 8]
+
+const (
+	aesV1Prefix  = "AES+"
+	aesV1Format  = "AES+V%d+%s"
+	aesV1Size    = 2 // aesV1Format 中的字段数
+	aesV1Version = 1
+	aesV1MaxSalt = 8 // 盐长度上限
+)
+
+// aesV1Encoder 密文格式的生成/解析工具, 每次加解密单独创建, 避免并发调用共享状态
+type aesV1Encoder struct {
+	salt    string
+	version int
+}
+
+func newAesV1Encoder() *aesV1Encoder {
+	e := &aesV1Encoder{version: aesV1Version}
+	e.salt = RandomSalt(int(randomByte()%aesV1MaxSalt) + 1)
+	return e
+}
+
+// 密文格式的生成/解析工具
+func (e *aesV1Encoder) Wrap(str string) string {
+	return fmt.Sprintf(aesV1Format, e.version, str)
+}
+func (e *aesV1Encoder) Unwrap(str string) (string, error) {
+	var crypted string
+
+	n, err := fmt.Sscanf(str, aesV1Format, &e.version, &crypted)
+	if err != nil {
+		return "", err
+	}
+	if n != aesV1Size {
+		return "", ErrorFormat
+	}
+	return crypted, nil
+}
+
+// 加盐格式: [盐长度(1Byte)][盐][明文]
+func (e *aesV1Encoder) Salt(str string) []byte {
+	buff := bytes.NewBufferString("")
+
+	buff.Grow(1 + len(e.salt) + len(str))
+
+	buff.WriteByte(byte(len(e.salt)))
+	buff.WriteString(e.salt)
+	buff.WriteString(str)
+
+	return buff.Bytes()
+}
+func (e *aesV1Encoder) Unsalt(source []byte) (string, error) {
+	buff := bytes.NewBuffer(source)
+
+	// 取盐长度
+	c, err := buff.ReadByte()
+	if err != nil {
+		return "", err
+	}
+	saltSize := int(c)
+	if saltSize < 1 || saltSize > aesV1MaxSalt {
+		return "", fmt.Errorf("salt size %d not in [1, %d]", saltSize, aesV1MaxSalt)
+	}
+
+	// 取盐
+	salt := make([]byte, saltSize)
+	n, err := buff.Read(salt)
+	if err != nil {
+		return "", err
+	}
+	if n != saltSize {
+		return "", fmt.Errorf("read salt error")
+	}
+	e.salt = string(salt)
+
+	// 原始明文
+	return string(buff.Bytes()), nil
+}
+
+// AesV1WithPrefix 检查是否可能是 AES+V1+ 加密格式
+func AesV1WithPrefix(str string) bool {
+	return strings.HasPrefix(str, aesV1Prefix)
+}
+
+// AesV1Encrypt 生成 AES+V1+ 格式密文
+func AesV1Encrypt(key []byte, origin string) (string, error) {
+	coder := newAesV1Encoder()
+
+	crypted, err := _AESEncrypt(key, coder.Salt(origin))
+	if err != nil {
+		return "", err
+	}
+
+	return coder.Wrap(hex.EncodeToString(crypted)), nil
+}
+
+// AesV1Decrypt 解密 AES+V1+ 格式密文.
+// 产品逻辑上需要兼容 明文密码 和 AES密码, 如果不是 AES 格式密文, 则认为是明文密码, 原样返回
+func AesV1Decrypt(key []byte, crypted string) (string, error) {
+	if !AesV1WithPrefix(crypted) {
+		return crypted, nil
+	}
+
+	coder := newAesV1Encoder()
+	crypted, err := coder.Unwrap(crypted)
+	if err != nil {
+		return "", err
+	}
+
+	bytes, err := hex.DecodeString(crypted)
+	if err != nil {
+		return "", err
+	}
+
+	originWithSalt, err := _AESDecrypt(key, bytes)
+	if err != nil {
+		return "", fmt.Errorf("decode. %s", err)
+	}
+
+	origin, err := coder.Unsalt(originWithSalt)
+	if err != nil {
+		return "", fmt.Errorf("decode. %s", err)
+	}
+
+	return origin, nil
+}
+
+// RandomSalt 随机盐生成器, 由大小写字母和数字组成. 也用于构建测试用例
+func RandomSalt(n int) string {
+	letters := []byte("0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ")
+	length := byte(len(letters))
+
+	var bb bytes.Buffer
+	bb.Grow(n)
+	for i := 0; i < n; i++ {
+		bb.WriteByte(letters[randomByte()%length])
+	}
+	return bb.String()
+}
+
+func randomByte() byte {
+	b := make([]byte, 1)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return b[0]
+}
+
+func _PKCS5Padding(plaintext []byte, blockSize int) []byte {
+	padding := blockSize - len(plaintext)%blockSize
+	padtext := bytes.Repeat([]byte{byte(padding)}, padding)
+	return append(plaintext, padtext...)
+}
+
+func _PKCS5UnPadding(origData []byte, blockSize int) ([]byte, error) {
+	length := len(origData)
+	if length == 0 {
+		return nil, errors.New("aes unpadding error. ciphertext is empty")
+	}
+	unpadding := int(origData[length-1])
+	// padding 的取值范围: [1, blockSize]
+	// fix: aeskey 和 密文 不匹配时, 潜在的 slice 操作越界
+	if unpadding > blockSize || unpadding < 1 {
+		return nil, errors.New("aes unpadding error. aeskey and ciphertext may not match")
+	}
+	return origData[:(length - unpadding)], nil
+}
+
+func _AESEncrypt(key, origData []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	//AES分组长度为 128 位，所以 blockSize=16 字节
+	blockSize := block.BlockSize()
+	origData = _PKCS5Padding(origData, blockSize)
+	blockMode := cipher.NewCBCEncrypter(block, key[:blockSize]) //初始向量的长度必须等于块block的长度16字节
+	crypted := make([]byte, len(origData))
+	blockMode.CryptBlocks(crypted, origData)
+	return crypted, nil
+}
+
+func _AESDecrypt(key, crypted []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	// AES分组长度为 128 位，所以 blockSize=16 字节
+	blockSize := block.BlockSize()
+	if len(crypted) == 0 || len(crypted)%blockSize != 0 {
+		return nil, errors.New("aes ciphertext is not a multiple of the block size")
+	}
+	blockMode := cipher.NewCBCDecrypter(block, key[:blockSize]) //初始向量的长度必须等于块block的长度16字节
+	origData := make([]byte, len(crypted))
+	blockMode.CryptBlocks(origData, crypted)
+	origData, err = _PKCS5UnPadding(origData, blockSize)
+	if err != nil {
+		return nil, err
+	}
+	return origData, nil
+}
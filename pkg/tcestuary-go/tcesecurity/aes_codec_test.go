@@ -0,0 +1,83 @@
+package tcesecurity
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// 密钥/密文不配对时, 代码不能 panic
+// go test -test.count 100000 -test.parallel 100 --run TestAESNotPanic
+func TestAESNotPanic(t *testing.T) {
+	// 加密
+	aeskey := RandomSalt(32)
+	plaintext := RandomSalt(32)
+
+	ciphertext, err := _AESEncrypt([]byte(aeskey), []byte(plaintext))
+	assert.NoError(t, err)
+
+	// 用错误密钥解密, 代码不能 panic
+	fakekey := RandomSalt(32)
+	assert.NotPanics(t, func() { _AESDecrypt([]byte(fakekey), ciphertext) })
+}
+
+// 密文格式: AES+V1+hex, 与解密结果互为逆运算
+func TestAesCbcCrypto(t *testing.T) {
+	aeskey := RandomSalt(32)
+	c, err := NewAesCbcCrypto(Aes256CbcAlgorithm, []byte(aeskey))
+	assert.NoError(t, err)
+
+	for _, origin := range []string{"", "mysql_pass", RandomSalt(100)} {
+		ciphertext, err := c.Encrypt(origin)
+		assert.NoError(t, err)
+		assert.Regexp(t, `^AES\+V1\+[0-9a-f]+$`, ciphertext)
+
+		plaintext, err := c.Decrypt(ciphertext)
+		assert.NoError(t, err)
+		assert.Equal(t, origin, plaintext)
+	}
+
+	// 已加密数据原样返回
+	ciphertext, _ := c.Encrypt("mysql_pass")
+	again, err := c.Encrypt(ciphertext)
+	assert.NoError(t, err)
+	assert.Equal(t, ciphertext, again)
+
+	// 密钥错误
+	_, err = AesV1Decrypt([]byte(RandomSalt(32)), ciphertext)
+	assert.Error(t, err)
+}
+
+// 兼容历史版本的导出方法
+func TestAesCbcCryptoDeprecated(t *testing.T) {
+	aeskey := RandomSalt(32)
+	c, err := NewAesCbcCrypto(Aes256CbcAlgorithm, []byte(aeskey))
+	assert.NoError(t, err)
+	assert.Equal(t, "AES+", c.Prefix)
+	assert.Len(t, c.RandomSalt(8), 8)
+
+	ciphertext, err := c.Encrypt("mysql_pass")
+	assert.NoError(t, err)
+	assert.True(t, c.WithPrefix(ciphertext))
+
+	crypted, err := c.Unwrap(ciphertext)
+	assert.NoError(t, err)
+	assert.Equal(t, ciphertext, c.Wrap(crypted))
+	_, err = c.Unwrap("AES+V1")
+	assert.Error(t, err)
+
+	b, err := hex.DecodeString(crypted)
+	assert.NoError(t, err)
+	originWithSalt, err := _AESDecrypt([]byte(aeskey), b)
+	assert.NoError(t, err)
+	plaintext, err := c.Unsalt(originWithSalt)
+	assert.NoError(t, err)
+	assert.Equal(t, "mysql_pass", plaintext)
+
+	// 盐长度取值 [1, 8]
+	for _, source := range [][]byte{{0, 'a'}, {9, 'a', 'b', 'c', 'd', 'e', 'f', 'g', 'h', 'i', 'j'}} {
+		_, err = c.Unsalt(source)
+		assert.Error(t, err)
+	}
+}
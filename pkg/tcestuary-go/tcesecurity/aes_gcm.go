@@ -5,6 +5,7 @@ import (
 	"crypto/cipher"
 	"encoding/hex"
 	"fmt"
+	"io"
 	"strings"
 )
 
@@ -20,17 +21,25 @@ func init() {
 }
 
 // AES-GCM算法
+// V2 密文格式: prefix:method:version:hex(nonce):hex(tag):hex(密文), nonce 每次加密随机生成,
+// prefix:method:version 与上下文作为 AAD, 防止篡改.
+// V1 密文格式: prefix:method:version:hex(tag):hex(密文), iv / aad 取自密钥, 仅用于解密历史数据
 type AesGcmCrypto struct {
 	Version string
 	Prefix  string
 	Method  string
 	aesKey  []byte
-	iv      []byte
-	aad     []byte
+	iv      []byte // V1
+	aad     []byte // V1
 }
 
 // Encrypt加密
 func (a *AesGcmCrypto) Encrypt(plaintext string) (string, error) {
+	return a.EncryptWithContext(plaintext, nil)
+}
+
+// EncryptWithContext 加密并绑定上下文
+func (a *AesGcmCrypto) EncryptWithContext(plaintext string, aad []byte) (string, error) {
 	// 1，如果加密数据带有已加密前缀信息，则直接返回
 	if strings.HasPrefix(plaintext, a.Prefix) {
 		return plaintext, nil
@@ -42,24 +51,34 @@ func (a *AesGcmCrypto) Encrypt(plaintext string) (string, error) {
 		return "", err
 	}
 
-	// 2.2 gcm
-	gcm, err := cipher.NewGCMWithNonceSize(c, 16)
+	// 2.2 gcm, 随机 nonce
+	gcm, err := cipher.NewGCM(c)
+	if err != nil {
+		return "", err
+	}
+	nonce, err := randomBytes(gcm.NonceSize())
 	if err != nil {
 		return "", err
 	}
 	// 2.3 seal
-	bts := gcm.Seal(nil, a.iv, []byte(plaintext), a.aad)
+	header := a.Prefix + ":" + a.Method + ":" + a.Version
+	bts := gcm.Seal(nil, nonce, []byte(plaintext), contextAAD(header, aad))
 
 	// cipher 将tag追加到密文后，16位
-	tag := bts[len(bts)-16:]
+	tag := bts[len(bts)-gcm.Overhead():]
 
 	// 3，构造返回
-	return a.Prefix + ":" + a.Method + ":" + a.Version + ":" +
-		hex.EncodeToString(tag) + ":" + hex.EncodeToString(bts[:len(bts)-16]), nil
+	return header + ":" + hex.EncodeToString(nonce) + ":" +
+		hex.EncodeToString(tag) + ":" + hex.EncodeToString(bts[:len(bts)-gcm.Overhead()]), nil
 }
 
-// Decrypt解密
+// Decrypt解密, 支持 V1 / V2 格式
 func (a *AesGcmCrypto) Decrypt(ciphertext string) (string, error) {
+	return a.DecryptWithContext(ciphertext, nil)
+}
+
+// DecryptWithContext 解密并校验上下文, V1 格式不支持上下文
+func (a *AesGcmCrypto) DecryptWithContext(ciphertext string, aad []byte) (string, error) {
 	// 1，如果解密数据前缀错误，直接返回
 	if !strings.HasPrefix(ciphertext, a.Prefix) {
 		return ciphertext, nil
@@ -67,12 +86,29 @@ func (a *AesGcmCrypto) Decrypt(ciphertext string) (string, error) {
 
 	// 2，验证method
 	items := strings.Split(ciphertext, ":")
-	if len(items) != 5 {
+	if len(items) != 5 && len(items) != 6 {
 		return "", fmt.Errorf("invalid ciphertext-data format")
 	}
 	if items[1] != a.Method {
 		return "", fmt.Errorf("invalid entrypted-data method")
 	}
+	version, err := hex.DecodeString(items[2])
+	if err != nil {
+		return "", fmt.Errorf("invalid entrypted-data version")
+	}
+	switch {
+	case string(version) == VERSION && len(items) == 5:
+		if len(aad) > 0 {
+			return "", ErrNotSupportContext
+		}
+		return a.decryptV1(items)
+	case string(version) == VERSION2 && len(items) == 6:
+		return a.decryptV2(items, aad)
+	}
+	return "", fmt.Errorf("invalid ciphertext-data format")
+}
+
+func (a *AesGcmCrypto) decryptV1(items []string) (string, error) {
 	// 3，解码
 	tag, err := hex.DecodeString(items[3])
 	if err != nil {
@@ -99,10 +135,79 @@ func (a *AesGcmCrypto) Decrypt(ciphertext string) (string, error) {
 	return string(bts), nil
 }
 
+func (a *AesGcmCrypto) decryptV2(items []string, aad []byte) (string, error) {
+	// 3，解码
+	nonce, err := hex.DecodeString(items[3])
+	if err != nil {
+		return "", fmt.Errorf("invalid entrypted-data nonce")
+	}
+	tag, err := hex.DecodeString(items[4])
+	if err != nil {
+		return "", fmt.Errorf("invalid entrypted-data tag")
+	}
+	rawEncrypted, err := hex.DecodeString(items[5])
+	if err != nil {
+		return "", fmt.Errorf("invalid entrypted-data format")
+	}
+
+	c, err := aes.NewCipher(a.aesKey)
+	if err != nil {
+		return "", err
+	}
+	gcm, err := cipher.NewGCM(c)
+	if err != nil {
+		return "", err
+	}
+	if len(nonce) != gcm.NonceSize() {
+		return "", fmt.Errorf("invalid entrypted-data nonce")
+	}
+	header := strings.Join(items[:3], ":")
+	bts, err := gcm.Open(nil, nonce, append(rawEncrypted, tag...), contextAAD(header, aad))
+	if err != nil {
+		return "", err
+	}
+	return string(bts), nil
+}
+
+// EncryptBytes 二进制数据加密, 格式参考 stream.go
+func (a *AesGcmCrypto) EncryptBytes(plaintext []byte) ([]byte, error) {
+	return encryptBytes(a, "", plaintext)
+}
+
+// DecryptBytes 二进制数据解密
+func (a *AesGcmCrypto) DecryptBytes(ciphertext []byte) ([]byte, error) {
+	return decryptBytes(singleResolver(a), ciphertext)
+}
+
+// NewEncryptWriter 流式加密, 写入完成后必须调用 Close
+func (a *AesGcmCrypto) NewEncryptWriter(w io.Writer) (io.WriteCloser, error) {
+	return newEncryptWriter(a, "", w)
+}
+
+// NewDecryptReader 流式解密
+func (a *AesGcmCrypto) NewDecryptReader(r io.Reader) (io.Reader, error) {
+	return newDecryptReader(singleResolver(a), r)
+}
+
+func (a *AesGcmCrypto) method() string {
+	return a.Method
+}
+
+func (a *AesGcmCrypto) newAEAD() (cipher.AEAD, error) {
+	c, err := aes.NewCipher(a.aesKey)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(c)
+}
+
 // NewAesGcmCrypto return AES-GCM Crypto
 func NewAesGcmCrypto(method string, aesKey []byte) (*AesGcmCrypto, error) {
+	if len(aesKey) < 16 {
+		return nil, fmt.Errorf("aes key length must be at least 16")
+	}
 	return &AesGcmCrypto{
-		Version: hex.EncodeToString([]byte(VERSION)),
+		Version: hex.EncodeToString([]byte(VERSION2)),
 		Prefix:  AlreadyEncryptPrefix + hex.EncodeToString([]byte(TceSecurity)),
 		Method:  hex.EncodeToString([]byte(method)),
 		aesKey:  []byte(aesKey),
@@ -0,0 +1,61 @@
+package tcesecurity
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAesGcmCrypto(t *testing.T) {
+	c, err := NewAesGcmCrypto(Aes256GcmAlgorithm, []byte("5c2bd12683ceefb8830abba988339e67"))
+	assert.NoError(t, err)
+
+	t.Run("v2-random-nonce", func(t *testing.T) {
+		c1, err := c.Encrypt("mysql_pass")
+		assert.NoError(t, err)
+		c2, err := c.Encrypt("mysql_pass")
+		assert.NoError(t, err)
+
+		// 相同明文, 每次加密的 nonce 和密文不同
+		items1, items2 := strings.Split(c1, ":"), strings.Split(c2, ":")
+		assert.Len(t, items1, 6)
+		assert.Equal(t, "5632", items1[2])
+		assert.NotEqual(t, items1[3], items2[3])
+		assert.NotEqual(t, c1, c2)
+
+		for _, ciphertext := range []string{c1, c2} {
+			plaintext, err := c.Decrypt(ciphertext)
+			assert.NoError(t, err)
+			assert.Equal(t, "mysql_pass", plaintext)
+		}
+	})
+
+	t.Run("v1-compatible", func(t *testing.T) {
+		v1 := "T5443455345435552495459:6165732d3235362d67636d:5631:af95720f64ba3d80377d183c845522fc:a3a2104a50a440f5a055"
+		plaintext, err := c.Decrypt(v1)
+		assert.NoError(t, err)
+		assert.Equal(t, "mysql_pass", plaintext)
+	})
+
+	t.Run("tampered", func(t *testing.T) {
+		ciphertext, err := c.Encrypt("mysql_pass")
+		assert.NoError(t, err)
+
+		// 修改版本号, 不能按 V1 解密
+		items := strings.Split(ciphertext, ":")
+		_, err = c.Decrypt(strings.Join(append(items[:2:2], "5631", items[4], items[5]), ":"))
+		assert.Error(t, err)
+
+		// 修改 nonce
+		items = strings.Split(ciphertext, ":")
+		items[3] = strings.Repeat("0", len(items[3]))
+		_, err = c.Decrypt(strings.Join(items, ":"))
+		assert.Error(t, err)
+	})
+
+	t.Run("key-too-short", func(t *testing.T) {
+		_, err := NewAesGcmCrypto(Aes256GcmAlgorithm, []byte("short"))
+		assert.Error(t, err)
+	})
+}
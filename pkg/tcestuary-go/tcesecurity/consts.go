@@ -2,6 +2,7 @@ package tcesecurity
 
 const (
 	VERSION              = "V1"
+	VERSION2             = "V2" // GCM 类算法: 每次加密使用随机 nonce, nonce 随密文保存
 	TceSecurity          = "TCESECURITY"
 	AlreadyEncryptPrefix = "T"
 )
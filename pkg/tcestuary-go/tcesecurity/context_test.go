@@ -0,0 +1,66 @@
+package tcesecurity
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// tsm-sm4-128-gcm 参考 TestTSM4CryptoPurego
+func TestCryptoWithContext(t *testing.T) {
+	aesGcm, _ := NewAesGcmCrypto(Aes256GcmAlgorithm, []byte("5c2bd12683ceefb8830abba988339e67"))
+	envelope := newKMSEnvelopeCrypto(KMSEnvelopeAlgorithm, "key-1", "kms.local", time.Minute, newFakeDataKeyClient(), nil)
+	ring, _ := NewKeyringCrypto("k1", map[string]Crypto{"k1": aesGcm}, nil)
+
+	row1 := []byte("user.password:1")
+	row2 := []byte("user.password:2")
+	for name, c := range map[string]Crypto{
+		Aes256GcmAlgorithm:   aesGcm,
+		KMSEnvelopeAlgorithm: envelope,
+		"keyring":            ring,
+	} {
+		t.Run(name, func(t *testing.T) {
+			ciphertext, err := c.EncryptWithContext("mysql_pass", row1)
+			assert.NoError(t, err)
+			plaintext, err := c.DecryptWithContext(ciphertext, row1)
+			assert.NoError(t, err)
+			assert.Equal(t, "mysql_pass", plaintext)
+
+			// 密文复制到其他行, 或不带上下文解密
+			_, err = c.DecryptWithContext(ciphertext, row2)
+			assert.Error(t, err)
+			_, err = c.Decrypt(ciphertext)
+			assert.Error(t, err)
+
+			// 上下文为空时与 Encrypt / Decrypt 兼容
+			ciphertext, err = c.Encrypt("mysql_pass")
+			assert.NoError(t, err)
+			plaintext, err = c.DecryptWithContext(ciphertext, nil)
+			assert.NoError(t, err)
+			assert.Equal(t, "mysql_pass", plaintext)
+			_, err = c.DecryptWithContext(ciphertext, row1)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestCryptoWithoutContext(t *testing.T) {
+	aesCbc, _ := NewAesCbcCrypto(Aes256CbcAlgorithm, []byte("f13c3f40c60db7f32ce6a5e0143f09ea"))
+	_, err := aesCbc.EncryptWithContext("mysql_pass", []byte("user.password:1"))
+	assert.Equal(t, ErrNotSupportContext, err)
+
+	ciphertext, err := aesCbc.EncryptWithContext("mysql_pass", nil)
+	assert.NoError(t, err)
+	_, err = aesCbc.DecryptWithContext(ciphertext, []byte("user.password:1"))
+	assert.Equal(t, ErrNotSupportContext, err)
+	plaintext, err := aesCbc.DecryptWithContext(ciphertext, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "mysql_pass", plaintext)
+
+	// V1 历史格式不支持上下文
+	aesGcm, _ := NewAesGcmCrypto(Aes256GcmAlgorithm, []byte("5c2bd12683ceefb8830abba988339e67"))
+	v1 := "T5443455345435552495459:6165732d3235362d67636d:5631:af95720f64ba3d80377d183c845522fc:a3a2104a50a440f5a055"
+	_, err = aesGcm.DecryptWithContext(v1, []byte("user.password:1"))
+	assert.Equal(t, ErrNotSupportContext, err)
+}
@@ -1,5 +1,12 @@
 package tcesecurity
 
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"time"
+)
+
 // 加密、解密
 
 // Crypto配置参数
@@ -19,12 +26,74 @@ type CryptoOpts struct {
 	SecretId  string
 	SecretKey string
 	KMSServer string
+	// For kms-envelope, 数据密钥缓存时间, 0 表示默认值
+	DataKeyTTL time.Duration
+	// For kms-*, 传输参数, 零值表示校验服务端证书
+	Transport KMSTransportOpts
+	// For kms-*, 重试、熔断参数, 零值表示默认值
+	Retry KMSRetryOpts
 }
 
 // Crypto 加密、解密接口
 type Crypto interface {
 	Encrypt(string) (string, error)
 	Decrypt(string) (string, error)
+	// EncryptWithContext 加密并绑定上下文(如 表名、列名、主键), 解密时必须提供相同的上下文.
+	// 上下文为空时等价于 Encrypt; 不支持上下文的算法返回 ErrNotSupportContext
+	EncryptWithContext(plaintext string, aad []byte) (string, error)
+	DecryptWithContext(ciphertext string, aad []byte) (string, error)
+}
+
+// CtxCrypto 支持 context 的加解密, 用于控制 KMS 调用的超时、取消. kms-* 算法及密钥环实现该接口
+type CtxCrypto interface {
+	EncryptCtx(ctx context.Context, plaintext string) (string, error)
+	DecryptCtx(ctx context.Context, ciphertext string) (string, error)
+}
+
+// encryptCtx 不访问 KMS 的算法忽略 ctx, 仅检查 ctx 是否已结束
+func encryptCtx(ctx context.Context, c Crypto, plaintext string) (string, error) {
+	if cc, ok := c.(CtxCrypto); ok {
+		return cc.EncryptCtx(ctx, plaintext)
+	}
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	return c.Encrypt(plaintext)
+}
+
+// decryptCtx 不访问 KMS 的算法忽略 ctx, 仅检查 ctx 是否已结束
+func decryptCtx(ctx context.Context, c Crypto, ciphertext string) (string, error) {
+	if cc, ok := c.(CtxCrypto); ok {
+		return cc.DecryptCtx(ctx, ciphertext)
+	}
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	return c.Decrypt(ciphertext)
+}
+
+// ErrNotSupportContext 算法或密文格式不支持绑定上下文
+var ErrNotSupportContext = errors.New("algorithm not support encryption context")
+
+// encryptWithoutContext 不支持上下文的算法, 上下文为空时使用 Encrypt
+func encryptWithoutContext(c Crypto, plaintext string, aad []byte) (string, error) {
+	if len(aad) > 0 {
+		return "", ErrNotSupportContext
+	}
+	return c.Encrypt(plaintext)
+}
+
+// decryptWithoutContext 不支持上下文的算法, 上下文为空时使用 Decrypt
+func decryptWithoutContext(c Crypto, ciphertext string, aad []byte) (string, error) {
+	if len(aad) > 0 {
+		return "", ErrNotSupportContext
+	}
+	return c.Decrypt(ciphertext)
+}
+
+// contextAAD 密文头部与上下文拼接作为 AAD. 同一算法的头部长度固定, 拼接结果无歧义
+func contextAAD(header string, aad []byte) []byte {
+	return append([]byte(header), aad...)
 }
 
 // 加密解密算法
@@ -37,3 +106,12 @@ var SupportAlgorithm = make(map[string]AlgorithmFunc, 0)
 func registerCryptoFunc(k string, f AlgorithmFunc) {
 	SupportAlgorithm[k] = f
 }
+
+// randomBytes 生成 n 字节随机数, 用于 nonce 等
+func randomBytes(n int) ([]byte, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
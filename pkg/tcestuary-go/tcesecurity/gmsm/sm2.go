@@ -0,0 +1,424 @@
+package gmsm
+
+import (
+	"bytes"
+	"crypto/elliptic"
+	"crypto/subtle"
+	"encoding/asn1"
+	"encoding/hex"
+	"errors"
+	"io"
+	"math/big"
+	"strings"
+	"sync"
+)
+
+// SM2 参考 GB/T 32918-2016, 曲线 sm2p256v1.
+// 公钥格式: hex(04 | X | Y), 130 字节; 私钥格式: hex(D), 64 字节, 与 tencentsm 一致
+
+// DefaultID 国标默认用户 ID
+const DefaultID = "1234567812345678"
+
+// CipherMode SM2 密文格式. C1 为随机点, C2 为密文, C3 为 SM3 杂凑值
+type CipherMode int
+
+const (
+	C1C3C2ASN1   CipherMode = iota // ASN.1 SEQUENCE{X, Y, C3, C2}, tencentsm 默认格式
+	C1C3C2                         // X | Y | C3 | C2
+	C1C2C3ASN1                     // ASN.1 SEQUENCE{X, Y, C2, C3}
+	C1C2C3                         // X | Y | C2 | C3
+	C1C3C2With04                   // 04 | X | Y | C3 | C2
+	C1C2C3With04                   // 04 | X | Y | C2 | C3
+)
+
+var (
+	ErrInvalidPublicKey  = errors.New("sm2: invalid public key")
+	ErrInvalidPrivateKey = errors.New("sm2: invalid private key")
+	ErrInvalidCiphertext = errors.New("sm2: invalid ciphertext")
+	ErrDecryption        = errors.New("sm2: decryption error")
+)
+
+var (
+	sm2Once  sync.Once
+	sm2Curve *elliptic.CurveParams
+)
+
+// P256 返回 sm2p256v1 曲线. a = p - 3, 可以直接使用 elliptic.CurveParams 的通用实现
+func P256() elliptic.Curve {
+	sm2Once.Do(func() {
+		c := &elliptic.CurveParams{Name: "SM2-P-256", BitSize: 256}
+		c.P, _ = new(big.Int).SetString("FFFFFFFEFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFF00000000FFFFFFFFFFFFFFFF", 16)
+		c.N, _ = new(big.Int).SetString("FFFFFFFEFFFFFFFFFFFFFFFFFFFFFFFF7203DF6B21C6052B53BBF40939D54123", 16)
+		c.B, _ = new(big.Int).SetString("28E9FA9E9D9F5E344D5A9E4BCF6509A7F39789F515AB8F92DDBCBD414D940E93", 16)
+		c.Gx, _ = new(big.Int).SetString("32C4AE2C1F1981195F9904466A39C9948FE30BBFF2660BE1715A4589334C74C7", 16)
+		c.Gy, _ = new(big.Int).SetString("BC3736A2F4F6779C59BDCEE36B692153D0A9877CC62A474002DF32E52139F0A0", 16)
+		sm2Curve = c
+	})
+	return sm2Curve
+}
+
+type PublicKey struct {
+	X, Y *big.Int
+}
+
+type PrivateKey struct {
+	PublicKey
+	D *big.Int
+}
+
+// GenerateKey 生成密钥对, D 取值 [1, n-2]
+func GenerateKey(rand io.Reader) (*PrivateKey, error) {
+	params := P256().Params()
+	max := new(big.Int).Sub(params.N, big.NewInt(2))
+	d, err := randScalar(rand, max)
+	if err != nil {
+		return nil, err
+	}
+	return newPrivateKey(d), nil
+}
+
+func newPrivateKey(d *big.Int) *PrivateKey {
+	priv := &PrivateKey{D: d}
+	priv.X, priv.Y = P256().ScalarBaseMult(padBytes(d.Bytes(), 32))
+	return priv
+}
+
+// ParsePublicKey 解析 hex(04 | X | Y) 格式公钥, 不区分大小写
+func ParsePublicKey(s string) (*PublicKey, error) {
+	b, err := hex.DecodeString(strings.TrimRight(s, "\x00"))
+	if err != nil || len(b) != 65 || b[0] != 4 {
+		return nil, ErrInvalidPublicKey
+	}
+	pub := &PublicKey{
+		X: new(big.Int).SetBytes(b[1:33]),
+		Y: new(big.Int).SetBytes(b[33:]),
+	}
+	if !P256().IsOnCurve(pub.X, pub.Y) {
+		return nil, ErrInvalidPublicKey
+	}
+	return pub, nil
+}
+
+// ParsePrivateKey 解析 hex(D) 格式私钥, 不区分大小写
+func ParsePrivateKey(s string) (*PrivateKey, error) {
+	b, err := hex.DecodeString(strings.TrimRight(s, "\x00"))
+	if err != nil || len(b) != 32 {
+		return nil, ErrInvalidPrivateKey
+	}
+	d := new(big.Int).SetBytes(b)
+	max := new(big.Int).Sub(P256().Params().N, big.NewInt(1))
+	if d.Sign() <= 0 || d.Cmp(max) >= 0 {
+		return nil, ErrInvalidPrivateKey
+	}
+	return newPrivateKey(d), nil
+}
+
+// String hex(04 | X | Y)
+func (pub *PublicKey) String() string {
+	return hex.EncodeToString(pub.bytes())
+}
+
+func (pub *PublicKey) bytes() []byte {
+	b := make([]byte, 0, 65)
+	b = append(b, 4)
+	b = append(b, padBytes(pub.X.Bytes(), 32)...)
+	return append(b, padBytes(pub.Y.Bytes(), 32)...)
+}
+
+// String hex(D)
+func (priv *PrivateKey) String() string {
+	return hex.EncodeToString(padBytes(priv.D.Bytes(), 32))
+}
+
+type sm2C1C3C2 struct {
+	X, Y *big.Int
+	C3   []byte
+	C2   []byte
+}
+
+type sm2C1C2C3 struct {
+	X, Y *big.Int
+	C2   []byte
+	C3   []byte
+}
+
+// Encrypt 公钥加密, 参考 GB/T 32918.4-2016 6.1
+func Encrypt(rand io.Reader, pub *PublicKey, msg []byte, mode CipherMode) ([]byte, error) {
+	if len(msg) == 0 {
+		return nil, errors.New("sm2: empty plaintext")
+	}
+	curve := P256()
+	max := new(big.Int).Sub(curve.Params().N, big.NewInt(1))
+	for {
+		k, err := randScalar(rand, max)
+		if err != nil {
+			return nil, err
+		}
+		kb := padBytes(k.Bytes(), 32)
+		x1, y1 := curve.ScalarBaseMult(kb)
+		x2, y2 := curve.ScalarMult(pub.X, pub.Y, kb)
+		x2b, y2b := padBytes(x2.Bytes(), 32), padBytes(y2.Bytes(), 32)
+
+		t := SM3KDF(append(append([]byte{}, x2b...), y2b...), len(msg))
+		if allZero(t) {
+			continue
+		}
+		c2 := xorBytes(msg, t)
+		c3 := sm2Hash(x2b, msg, y2b)
+		return marshalCipher(x1, y1, c2, c3, mode)
+	}
+}
+
+// Decrypt 私钥解密, 参考 GB/T 32918.4-2016 7.1
+func Decrypt(priv *PrivateKey, ciphertext []byte, mode CipherMode) ([]byte, error) {
+	x1, y1, c2, c3, err := unmarshalCipher(ciphertext, mode)
+	if err != nil {
+		return nil, err
+	}
+	curve := P256()
+	if !curve.IsOnCurve(x1, y1) {
+		return nil, ErrInvalidCiphertext
+	}
+	x2, y2 := curve.ScalarMult(x1, y1, padBytes(priv.D.Bytes(), 32))
+	x2b, y2b := padBytes(x2.Bytes(), 32), padBytes(y2.Bytes(), 32)
+
+	t := SM3KDF(append(append([]byte{}, x2b...), y2b...), len(c2))
+	if allZero(t) {
+		return nil, ErrDecryption
+	}
+	msg := xorBytes(c2, t)
+	if subtle.ConstantTimeCompare(sm2Hash(x2b, msg, y2b), c3) != 1 {
+		return nil, ErrDecryption
+	}
+	return msg, nil
+}
+
+func marshalCipher(x, y *big.Int, c2, c3 []byte, mode CipherMode) ([]byte, error) {
+	xb, yb := padBytes(x.Bytes(), 32), padBytes(y.Bytes(), 32)
+	switch mode {
+	case C1C3C2ASN1:
+		return asn1.Marshal(sm2C1C3C2{X: x, Y: y, C3: c3, C2: c2})
+	case C1C2C3ASN1:
+		return asn1.Marshal(sm2C1C2C3{X: x, Y: y, C2: c2, C3: c3})
+	case C1C3C2:
+		return concat(xb, yb, c3, c2), nil
+	case C1C2C3:
+		return concat(xb, yb, c2, c3), nil
+	case C1C3C2With04:
+		return concat([]byte{4}, xb, yb, c3, c2), nil
+	case C1C2C3With04:
+		return concat([]byte{4}, xb, yb, c2, c3), nil
+	}
+	return nil, errors.New("sm2: unsupported cipher mode")
+}
+
+func unmarshalCipher(in []byte, mode CipherMode) (x, y *big.Int, c2, c3 []byte, err error) {
+	switch mode {
+	case C1C3C2ASN1:
+		var v sm2C1C3C2
+		if rest, e := asn1.Unmarshal(in, &v); e != nil || len(rest) != 0 {
+			return nil, nil, nil, nil, ErrInvalidCiphertext
+		}
+		x, y, c2, c3 = v.X, v.Y, v.C2, v.C3
+	case C1C2C3ASN1:
+		var v sm2C1C2C3
+		if rest, e := asn1.Unmarshal(in, &v); e != nil || len(rest) != 0 {
+			return nil, nil, nil, nil, ErrInvalidCiphertext
+		}
+		x, y, c2, c3 = v.X, v.Y, v.C2, v.C3
+	case C1C3C2, C1C2C3, C1C3C2With04, C1C2C3With04:
+		if mode == C1C3C2With04 || mode == C1C2C3With04 {
+			if len(in) == 0 || in[0] != 4 {
+				return nil, nil, nil, nil, ErrInvalidCiphertext
+			}
+			in = in[1:]
+		}
+		if len(in) <= 64+SM3Size {
+			return nil, nil, nil, nil, ErrInvalidCiphertext
+		}
+		x, y = new(big.Int).SetBytes(in[:32]), new(big.Int).SetBytes(in[32:64])
+		if mode == C1C3C2 || mode == C1C3C2With04 {
+			c3, c2 = in[64:64+SM3Size], in[64+SM3Size:]
+		} else {
+			c2, c3 = in[64:len(in)-SM3Size], in[len(in)-SM3Size:]
+		}
+	default:
+		return nil, nil, nil, nil, errors.New("sm2: unsupported cipher mode")
+	}
+	if len(c3) != SM3Size || len(c2) == 0 {
+		return nil, nil, nil, nil, ErrInvalidCiphertext
+	}
+	return x, y, c2, c3, nil
+}
+
+type sm2Signature struct {
+	R, S *big.Int
+}
+
+// Sign 签名, 参考 GB/T 32918.2-2016 6.1. id 为空时使用 DefaultID
+func Sign(rand io.Reader, priv *PrivateKey, id, msg []byte) (r, s *big.Int, err error) {
+	e := new(big.Int).SetBytes(hashMsg(&priv.PublicKey, id, msg))
+	curve := P256()
+	n := curve.Params().N
+	max := new(big.Int).Sub(n, big.NewInt(1))
+	// (1 + d)^-1
+	dInv := new(big.Int).Add(priv.D, big.NewInt(1))
+	dInv.ModInverse(dInv, n)
+	for {
+		k, err := randScalar(rand, max)
+		if err != nil {
+			return nil, nil, err
+		}
+		x1, _ := curve.ScalarBaseMult(padBytes(k.Bytes(), 32))
+		r = new(big.Int).Add(e, x1)
+		r.Mod(r, n)
+		if r.Sign() == 0 || new(big.Int).Add(r, k).Cmp(n) == 0 {
+			continue
+		}
+		// s = (1 + d)^-1 * (k - r * d) mod n
+		s = new(big.Int).Mul(r, priv.D)
+		s.Sub(k, s)
+		s.Mul(s, dInv)
+		s.Mod(s, n)
+		if s.Sign() == 0 {
+			continue
+		}
+		return r, s, nil
+	}
+}
+
+// Verify 验签, 参考 GB/T 32918.2-2016 7.1. id 为空时使用 DefaultID
+func Verify(pub *PublicKey, id, msg []byte, r, s *big.Int) bool {
+	curve := P256()
+	n := curve.Params().N
+	if r.Sign() <= 0 || s.Sign() <= 0 || r.Cmp(n) >= 0 || s.Cmp(n) >= 0 {
+		return false
+	}
+	t := new(big.Int).Add(r, s)
+	t.Mod(t, n)
+	if t.Sign() == 0 {
+		return false
+	}
+	x1, y1 := curve.ScalarBaseMult(padBytes(s.Bytes(), 32))
+	x2, y2 := curve.ScalarMult(pub.X, pub.Y, padBytes(t.Bytes(), 32))
+	x, _ := curve.Add(x1, y1, x2, y2)
+
+	e := new(big.Int).SetBytes(hashMsg(pub, id, msg))
+	e.Add(e, x)
+	e.Mod(e, n)
+	return e.Cmp(r) == 0
+}
+
+// SignASN1 签名, 返回 ASN.1 SEQUENCE{R, S}
+func SignASN1(rand io.Reader, priv *PrivateKey, id, msg []byte) ([]byte, error) {
+	r, s, err := Sign(rand, priv, id, msg)
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(sm2Signature{R: r, S: s})
+}
+
+// VerifyASN1 验证 ASN.1 SEQUENCE{R, S} 格式签名
+func VerifyASN1(pub *PublicKey, id, msg, sig []byte) bool {
+	var v sm2Signature
+	if rest, err := asn1.Unmarshal(sig, &v); err != nil || len(rest) != 0 {
+		return false
+	}
+	return Verify(pub, id, msg, v.R, v.S)
+}
+
+// SignRaw 签名, 返回 R | S, 各 32 字节
+func SignRaw(rand io.Reader, priv *PrivateKey, id, msg []byte) ([]byte, error) {
+	r, s, err := Sign(rand, priv, id, msg)
+	if err != nil {
+		return nil, err
+	}
+	return concat(padBytes(r.Bytes(), 32), padBytes(s.Bytes(), 32)), nil
+}
+
+// VerifyRaw 验证 R | S 格式签名
+func VerifyRaw(pub *PublicKey, id, msg, sig []byte) bool {
+	if len(sig) != 64 {
+		return false
+	}
+	return Verify(pub, id, msg, new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:]))
+}
+
+// ZA 用户身份杂凑值 SM3(ENTL | ID | a | b | Gx | Gy | Px | Py)
+func ZA(pub *PublicKey, id []byte) []byte {
+	if len(id) == 0 {
+		id = []byte(DefaultID)
+	}
+	params := P256().Params()
+	a := new(big.Int).Sub(params.P, big.NewInt(3))
+	entl := len(id) * 8
+
+	h := NewSM3()
+	h.Write([]byte{byte(entl >> 8), byte(entl)})
+	h.Write(id)
+	h.Write(padBytes(a.Bytes(), 32))
+	h.Write(padBytes(params.B.Bytes(), 32))
+	h.Write(padBytes(params.Gx.Bytes(), 32))
+	h.Write(padBytes(params.Gy.Bytes(), 32))
+	h.Write(padBytes(pub.X.Bytes(), 32))
+	h.Write(padBytes(pub.Y.Bytes(), 32))
+	return h.Sum(nil)
+}
+
+// hashMsg e = SM3(ZA | M)
+func hashMsg(pub *PublicKey, id, msg []byte) []byte {
+	h := NewSM3()
+	h.Write(ZA(pub, id))
+	h.Write(msg)
+	return h.Sum(nil)
+}
+
+func sm2Hash(x2, msg, y2 []byte) []byte {
+	h := NewSM3()
+	h.Write(x2)
+	h.Write(msg)
+	h.Write(y2)
+	return h.Sum(nil)
+}
+
+// randScalar 返回 [1, max] 范围内的随机数
+func randScalar(rand io.Reader, max *big.Int) (*big.Int, error) {
+	b := make([]byte, 40) // 多取 64 bit, 降低取模带来的偏差
+	if _, err := io.ReadFull(rand, b); err != nil {
+		return nil, err
+	}
+	k := new(big.Int).SetBytes(b)
+	k.Mod(k, max)
+	return k.Add(k, big.NewInt(1)), nil
+}
+
+// padBytes 左侧补 0 至 size 字节
+func padBytes(b []byte, size int) []byte {
+	if len(b) >= size {
+		return b
+	}
+	out := make([]byte, size)
+	copy(out[size-len(b):], b)
+	return out
+}
+
+func concat(parts ...[]byte) []byte {
+	return bytes.Join(parts, nil)
+}
+
+func xorBytes(a, b []byte) []byte {
+	out := make([]byte, len(a))
+	for i := range a {
+		out[i] = a[i] ^ b[i]
+	}
+	return out
+}
+
+func allZero(b []byte) bool {
+	for _, v := range b {
+		if v != 0 {
+			return false
+		}
+	}
+	return true
+}
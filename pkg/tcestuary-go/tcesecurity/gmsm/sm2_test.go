@@ -0,0 +1,86 @@
+package gmsm
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// openssl genpkey -algorithm SM2
+const (
+	testPrivateKey = "8a00584b33908951a7c895300324f127e0f12242e91a8b966b264adf0a5bdf94"
+	testPublicKey  = "04a748a972994d5ba0eba799667cc571eb2eecf0a74ef8a35537c4fb37adfede00e7629e7b4470b888506e2a5f78f86270a74bcf9d24f393135916d16b601b2125"
+)
+
+func TestSM2Key(t *testing.T) {
+	priv, err := ParsePrivateKey(testPrivateKey)
+	assert.NoError(t, err)
+	assert.Equal(t, testPrivateKey, priv.String())
+	assert.Equal(t, testPublicKey, priv.PublicKey.String())
+
+	pub, err := ParsePublicKey("04A748A972994D5BA0EBA799667CC571EB2EECF0A74EF8A35537C4FB37ADFEDE00E7629E7B4470B888506E2A5F78F86270A74BCF9D24F393135916D16B601B2125")
+	assert.NoError(t, err)
+	assert.Equal(t, testPublicKey, pub.String())
+
+	_, err = ParsePublicKey(testPublicKey[:128] + "00")
+	assert.Equal(t, ErrInvalidPublicKey, err)
+	_, err = ParsePrivateKey(testPrivateKey[:62])
+	assert.Equal(t, ErrInvalidPrivateKey, err)
+
+	key, err := GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	parsed, err := ParsePrivateKey(key.String())
+	assert.NoError(t, err)
+	assert.Equal(t, key.PublicKey.String(), parsed.PublicKey.String())
+}
+
+func TestSM2Encrypt(t *testing.T) {
+	priv, _ := ParsePrivateKey(testPrivateKey)
+
+	// openssl pkeyutl -encrypt
+	ciphertext, _ := hex.DecodeString("3073022100e7164a677e68abcfd5b5a944ef52ffd519d3bf4f8b06de45cb2fbaad20c9e77e02201ffa49970cd9ad36e4dde37b50c505092ef88c112534237df108fa9e250e6f5704208fa3c521f22cbc0e90893d1d86389ca6cafa662fbd07c7c2fe0e2a9250391f26040a33bf7a4587e695d3b856")
+	plaintext, err := Decrypt(priv, ciphertext, C1C3C2ASN1)
+	assert.NoError(t, err)
+	assert.Equal(t, "mysql_pass", string(plaintext))
+
+	ciphertext[len(ciphertext)-1] ^= 1
+	_, err = Decrypt(priv, ciphertext, C1C3C2ASN1)
+	assert.Equal(t, ErrDecryption, err)
+
+	for _, mode := range []CipherMode{C1C3C2ASN1, C1C3C2, C1C2C3ASN1, C1C2C3, C1C3C2With04, C1C2C3With04} {
+		ciphertext, err := Encrypt(rand.Reader, &priv.PublicKey, []byte("mysql_pass"), mode)
+		assert.NoError(t, err)
+		plaintext, err := Decrypt(priv, ciphertext, mode)
+		assert.NoError(t, err)
+		assert.Equal(t, "mysql_pass", string(plaintext))
+	}
+	raw, _ := Encrypt(rand.Reader, &priv.PublicKey, []byte("mysql_pass"), C1C3C2)
+	assert.Len(t, raw, 96+len("mysql_pass"))
+}
+
+func TestSM2Sign(t *testing.T) {
+	priv, _ := ParsePrivateKey(testPrivateKey)
+	msg := []byte("mysql_pass")
+
+	// openssl dgst -sm3 -sign -sigopt distid:TCESECURITY
+	sig, _ := hex.DecodeString("30450220107b9734bf208217513ae9b296f783c65f92a8af574a28f1d596dcc11f3bfa4d022100b431a93520aef464e7d7d2ac57e4c47c4434e42139413b7e427a43bceece31d8")
+	assert.True(t, VerifyASN1(&priv.PublicKey, []byte("TCESECURITY"), msg, sig))
+	assert.False(t, VerifyASN1(&priv.PublicKey, nil, msg, sig))
+	assert.False(t, VerifyASN1(&priv.PublicKey, []byte("TCESECURITY"), []byte("mysql_pass2"), sig))
+
+	// openssl dgst -sm3 -sign -sigopt distid:1234567812345678, 默认 ID
+	sig, _ = hex.DecodeString("304502207a35697b94829373d5a0c82206ebdf45d509ac94befc34459ea9ca8a5f43f45c022100c8290054586f50b39c8349b53ee091c81c8482b5a0fef9440cb295c29d627c49")
+	assert.True(t, VerifyASN1(&priv.PublicKey, nil, msg, sig))
+	assert.True(t, VerifyASN1(&priv.PublicKey, []byte(DefaultID), msg, sig))
+
+	sig, err := SignASN1(rand.Reader, priv, []byte("TCESECURITY"), msg)
+	assert.NoError(t, err)
+	assert.True(t, VerifyASN1(&priv.PublicKey, []byte("TCESECURITY"), msg, sig))
+
+	sig, err = SignRaw(rand.Reader, priv, []byte("TCESECURITY"), msg)
+	assert.NoError(t, err)
+	assert.Len(t, sig, 64)
+	assert.True(t, VerifyRaw(&priv.PublicKey, []byte("TCESECURITY"), msg, sig))
+}
@@ -0,0 +1,160 @@
+// gmsm 纯 Go 实现的国密算法 SM2/SM3/SM4, 不依赖 cgo.
+// 密文、签名格式与 tencentsm 保持一致
+package gmsm
+
+import (
+	"encoding/binary"
+	"hash"
+	"math/bits"
+)
+
+const (
+	SM3Size      = 32
+	SM3BlockSize = 64
+)
+
+var sm3IV = [8]uint32{
+	0x7380166f, 0x4914b2b9, 0x172442d7, 0xda8a0600,
+	0xa96f30bc, 0x163138aa, 0xe38dee4d, 0xb0fb0e4e,
+}
+
+type sm3Digest struct {
+	h   [8]uint32
+	buf [SM3BlockSize]byte
+	n   int    // buf 中未处理的字节数
+	len uint64 // 已写入的总字节数
+}
+
+// NewSM3 返回 SM3 hash.Hash
+func NewSM3() hash.Hash {
+	d := new(sm3Digest)
+	d.Reset()
+	return d
+}
+
+// SumSM3 计算 SM3 摘要
+func SumSM3(data []byte) []byte {
+	h := NewSM3()
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+func (d *sm3Digest) Reset() {
+	d.h = sm3IV
+	d.n = 0
+	d.len = 0
+}
+
+func (d *sm3Digest) Size() int { return SM3Size }
+
+func (d *sm3Digest) BlockSize() int { return SM3BlockSize }
+
+func (d *sm3Digest) Write(p []byte) (int, error) {
+	nn := len(p)
+	d.len += uint64(nn)
+	if d.n > 0 {
+		n := copy(d.buf[d.n:], p)
+		d.n += n
+		p = p[n:]
+		if d.n < SM3BlockSize {
+			return nn, nil
+		}
+		d.block(d.buf[:])
+		d.n = 0
+	}
+	for len(p) >= SM3BlockSize {
+		d.block(p[:SM3BlockSize])
+		p = p[SM3BlockSize:]
+	}
+	d.n = copy(d.buf[:], p)
+	return nn, nil
+}
+
+// Sum 不修改当前状态, 可以继续写入
+func (d *sm3Digest) Sum(in []byte) []byte {
+	c := *d
+	length := c.len << 3
+
+	var pad [SM3BlockSize + 8]byte
+	pad[0] = 0x80
+	padLen := SM3BlockSize - int(c.len%SM3BlockSize)
+	if padLen < 9 {
+		padLen += SM3BlockSize
+	}
+	binary.BigEndian.PutUint64(pad[padLen-8:], length)
+	c.Write(pad[:padLen])
+
+	var out [SM3Size]byte
+	for i, v := range c.h {
+		binary.BigEndian.PutUint32(out[i*4:], v)
+	}
+	return append(in, out[:]...)
+}
+
+func sm3P0(x uint32) uint32 { return x ^ bits.RotateLeft32(x, 9) ^ bits.RotateLeft32(x, 17) }
+
+func sm3P1(x uint32) uint32 { return x ^ bits.RotateLeft32(x, 15) ^ bits.RotateLeft32(x, 23) }
+
+// block 压缩函数, 参考 GB/T 32905-2016
+func (d *sm3Digest) block(p []byte) {
+	var w [68]uint32
+	var w1 [64]uint32
+	for i := 0; i < 16; i++ {
+		w[i] = binary.BigEndian.Uint32(p[i*4:])
+	}
+	for i := 16; i < 68; i++ {
+		w[i] = sm3P1(w[i-16]^w[i-9]^bits.RotateLeft32(w[i-3], 15)) ^ bits.RotateLeft32(w[i-13], 7) ^ w[i-6]
+	}
+	for i := 0; i < 64; i++ {
+		w1[i] = w[i] ^ w[i+4]
+	}
+
+	a, b, c, dd, e, f, g, h := d.h[0], d.h[1], d.h[2], d.h[3], d.h[4], d.h[5], d.h[6], d.h[7]
+	for j := 0; j < 64; j++ {
+		var t, ff, gg uint32
+		if j < 16 {
+			t = 0x79cc4519
+			ff = a ^ b ^ c
+			gg = e ^ f ^ g
+		} else {
+			t = 0x7a879d8a
+			ff = (a & b) | (a & c) | (b & c)
+			gg = (e & f) | (^e & g)
+		}
+		ss1 := bits.RotateLeft32(bits.RotateLeft32(a, 12)+e+bits.RotateLeft32(t, j%32), 7)
+		ss2 := ss1 ^ bits.RotateLeft32(a, 12)
+		tt1 := ff + dd + ss2 + w1[j]
+		tt2 := gg + h + ss1 + w[j]
+		dd = c
+		c = bits.RotateLeft32(b, 9)
+		b = a
+		a = tt1
+		h = g
+		g = bits.RotateLeft32(f, 19)
+		f = e
+		e = sm3P0(tt2)
+	}
+	d.h[0] ^= a
+	d.h[1] ^= b
+	d.h[2] ^= c
+	d.h[3] ^= dd
+	d.h[4] ^= e
+	d.h[5] ^= f
+	d.h[6] ^= g
+	d.h[7] ^= h
+}
+
+// SM3KDF 密钥派生函数, 参考 GB/T 32918.4-2016 5.4.3
+func SM3KDF(z []byte, klen int) []byte {
+	out := make([]byte, 0, klen+SM3Size)
+	var ct [4]byte
+	h := NewSM3()
+	for i := uint32(1); len(out) < klen; i++ {
+		binary.BigEndian.PutUint32(ct[:], i)
+		h.Reset()
+		h.Write(z)
+		h.Write(ct[:])
+		out = h.Sum(out)
+	}
+	return out[:klen]
+}
@@ -0,0 +1,39 @@
+package gmsm
+
+import (
+	"crypto/hmac"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSM3(t *testing.T) {
+	// GB/T 32905-2016 附录 A
+	assert.Equal(t, "66c7f0f462eeedd9d1f2d46bdc10e4e24167c4875cf2f7a2297da02b8f4ba8e0",
+		hex.EncodeToString(SumSM3([]byte("abc"))))
+	assert.Equal(t, "debe9ff92275b8a138604889c18e5a4d6fdb70e5387e5765293dcba39c0c5732",
+		hex.EncodeToString(SumSM3([]byte(strings.Repeat("abcd", 16)))))
+
+	// 分段写入与一次写入结果一致
+	h := NewSM3()
+	h.Write([]byte("ab"))
+	sum := h.Sum(nil)
+	h.Write([]byte("c"))
+	assert.Equal(t, SumSM3([]byte("ab")), sum)
+	assert.Equal(t, SumSM3([]byte("abc")), h.Sum(nil))
+
+	// openssl dgst -sm3 -hmac secret
+	mac := hmac.New(NewSM3, []byte("secret"))
+	mac.Write([]byte("abc"))
+	assert.Equal(t, "96042a28529e7a438af81eece5b293e0699f481fd372c08c5ac01b8dc4b81856", hex.EncodeToString(mac.Sum(nil)))
+}
+
+func TestSM3KDF(t *testing.T) {
+	z := []byte("tcestuary")
+	k := SM3KDF(z, 48)
+	assert.Len(t, k, 48)
+	assert.Equal(t, SumSM3(append(z, 0, 0, 0, 1)), k[:32])
+	assert.Equal(t, k[:20], SM3KDF(z, 20))
+}
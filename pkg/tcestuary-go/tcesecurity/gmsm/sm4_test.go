@@ -0,0 +1,45 @@
+package gmsm
+
+import (
+	"crypto/cipher"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSM4(t *testing.T) {
+	// GB/T 32907-2016 附录 A
+	key, _ := hex.DecodeString("0123456789abcdeffedcba9876543210")
+	block, err := NewSM4Cipher(key)
+	assert.NoError(t, err)
+
+	out := make([]byte, SM4BlockSize)
+	block.Encrypt(out, key)
+	assert.Equal(t, "681edf34d206965e86b3e94f536e4246", hex.EncodeToString(out))
+	block.Decrypt(out, out)
+	assert.Equal(t, key, out)
+
+	_, err = NewSM4Cipher(key[:8])
+	assert.Equal(t, ErrSM4KeySize, err)
+}
+
+func TestSM4CBC(t *testing.T) {
+	key, _ := hex.DecodeString("0123456789abcdeffedcba9876543210")
+	iv, _ := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+	block, _ := NewSM4Cipher(key)
+
+	// openssl enc -sm4-cbc -K 0123456789abcdeffedcba9876543210 -iv 000102030405060708090a0b0c0d0e0f
+	in := PKCS7Padding([]byte("mysql_pass"), SM4BlockSize)
+	out := make([]byte, len(in))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(out, in)
+	assert.Equal(t, "baa6303074575c9d644efa20756de495", hex.EncodeToString(out))
+
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(out, out)
+	plaintext, err := PKCS7UnPadding(out, SM4BlockSize)
+	assert.NoError(t, err)
+	assert.Equal(t, "mysql_pass", string(plaintext))
+
+	_, err = PKCS7UnPadding(key, SM4BlockSize)
+	assert.Equal(t, ErrPadding, err)
+}
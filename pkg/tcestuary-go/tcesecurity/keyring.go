@@ -0,0 +1,209 @@
+package tcesecurity
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// 密钥环: 多个密钥并存, 用于密钥轮转.
+// 加密始终使用 active 密钥, 并在密文的版本号字段后追加 key id:
+// T 格式: prefix:method:hex(version).hex(keyid):...
+// AES+ 格式: AES+V1.hex(keyid)+...
+// 解密时根据密文中的 key id 选择密钥; 不带 key id 的历史密文使用 Default 解密
+
+const keyIDSeparator = "."
+
+// KeyringCrypto 多密钥加解密
+type KeyringCrypto struct {
+	Active  string
+	Keys    map[string]Crypto
+	Default Crypto // 解密不带 key id 的历史密文, 为空时使用 active 密钥
+}
+
+// NewKeyringCrypto keys 的 key 为密钥 ID, active 必须在 keys 中
+func NewKeyringCrypto(active string, keys map[string]Crypto, def Crypto) (*KeyringCrypto, error) {
+	if _, ok := keys[active]; !ok {
+		return nil, fmt.Errorf("active key not found in keyring: %q", active)
+	}
+	for id := range keys {
+		if id == "" {
+			return nil, fmt.Errorf("empty key id in keyring")
+		}
+	}
+	if def == nil {
+		def = keys[active]
+	}
+	return &KeyringCrypto{Active: active, Keys: keys, Default: def}, nil
+}
+
+// Encrypt 使用 active 密钥加密, 密文中携带 key id
+func (k *KeyringCrypto) Encrypt(plaintext string) (string, error) {
+	return k.EncryptWithContext(plaintext, nil)
+}
+
+// EncryptWithContext 使用 active 密钥加密并绑定上下文, 密文中携带 key id
+func (k *KeyringCrypto) EncryptWithContext(plaintext string, aad []byte) (string, error) {
+	ciphertext, err := k.Keys[k.Active].EncryptWithContext(plaintext, aad)
+	if err != nil {
+		return "", err
+	}
+	// 已加密数据原样返回
+	if ciphertext == plaintext {
+		return ciphertext, nil
+	}
+	return WithKeyID(ciphertext, k.Active)
+}
+
+// Decrypt 根据密文中的 key id 选择密钥解密
+func (k *KeyringCrypto) Decrypt(ciphertext string) (string, error) {
+	return k.DecryptWithContext(ciphertext, nil)
+}
+
+// EncryptCtx 使用 active 密钥加密, ctx 控制 KMS 调用的超时、取消
+func (k *KeyringCrypto) EncryptCtx(ctx context.Context, plaintext string) (string, error) {
+	ciphertext, err := encryptCtx(ctx, k.Keys[k.Active], plaintext)
+	if err != nil {
+		return "", err
+	}
+	if ciphertext == plaintext {
+		return ciphertext, nil
+	}
+	return WithKeyID(ciphertext, k.Active)
+}
+
+// DecryptCtx 根据密文中的 key id 选择密钥解密, ctx 控制 KMS 调用的超时、取消
+func (k *KeyringCrypto) DecryptCtx(ctx context.Context, ciphertext string) (string, error) {
+	origin, id := SplitKeyID(ciphertext)
+	if id == "" {
+		return decryptCtx(ctx, k.Default, ciphertext)
+	}
+	c, ok := k.Keys[id]
+	if !ok {
+		return "", fmt.Errorf("unknown key id: %q", id)
+	}
+	return decryptCtx(ctx, c, origin)
+}
+
+// DecryptWithContext 根据密文中的 key id 选择密钥解密并校验上下文
+func (k *KeyringCrypto) DecryptWithContext(ciphertext string, aad []byte) (string, error) {
+	origin, id := SplitKeyID(ciphertext)
+	if id == "" {
+		return k.Default.DecryptWithContext(ciphertext, aad)
+	}
+	c, ok := k.Keys[id]
+	if !ok {
+		return "", fmt.Errorf("unknown key id: %q", id)
+	}
+	return c.DecryptWithContext(origin, aad)
+}
+
+// WithKeyID 在密文的版本号字段后追加 key id
+func WithKeyID(ciphertext, id string) (string, error) {
+	suffix := keyIDSeparator + hex.EncodeToString([]byte(id))
+	switch {
+	case strings.HasPrefix(ciphertext, AlreadyEncryptPrefix):
+		items := strings.SplitN(ciphertext, ":", 4)
+		if len(items) < 4 {
+			return "", fmt.Errorf("invalid ciphertext-data format")
+		}
+		items[2] += suffix
+		return strings.Join(items, ":"), nil
+	case AesV1WithPrefix(ciphertext):
+		items := strings.SplitN(ciphertext, "+", 3)
+		if len(items) < 3 {
+			return "", ErrorFormat
+		}
+		items[1] += suffix
+		return strings.Join(items, "+"), nil
+	}
+	return "", fmt.Errorf("invalid ciphertext-data format")
+}
+
+// IsCiphertext 检查是否为已加密格式: AES+V1+ 或 T<TCESECURITY>:method:version:data, 用于避免重复加密
+func IsCiphertext(str string) bool {
+	return AesV1WithPrefix(str) || strings.HasPrefix(str, AlreadyEncryptPrefix+hex.EncodeToString([]byte(TceSecurity))+":")
+}
+
+// SplitKeyID 从密文中取出 key id, 返回去掉 key id 后的原始密文. 不带 key id 时 id 为空, 密文原样返回
+func SplitKeyID(ciphertext string) (origin, id string) {
+	var sep string
+	var index int // 版本号字段的位置
+	switch {
+	case AesV1WithPrefix(ciphertext):
+		sep, index = "+", 1
+	case strings.HasPrefix(ciphertext, AlreadyEncryptPrefix):
+		sep, index = ":", 2
+	default:
+		return ciphertext, ""
+	}
+
+	items := strings.SplitN(ciphertext, sep, index+2)
+	if len(items) < index+2 {
+		return ciphertext, ""
+	}
+	pos := strings.Index(items[index], keyIDSeparator)
+	if pos < 0 {
+		return ciphertext, ""
+	}
+	// key id 不合法时按不带 key id 处理, 由 Default 校验密文格式
+	raw, err := hex.DecodeString(items[index][pos+len(keyIDSeparator):])
+	if err != nil || len(raw) == 0 {
+		return ciphertext, ""
+	}
+	items[index] = items[index][:pos]
+	return strings.Join(items, sep), string(raw)
+}
+
+// EncryptBytes 使用 active 密钥加密二进制数据, key id 记录在头部. 仅支持 GCM 类算法
+func (k *KeyringCrypto) EncryptBytes(plaintext []byte) ([]byte, error) {
+	c, err := k.activeAEAD()
+	if err != nil {
+		return nil, err
+	}
+	return encryptBytes(c, k.Active, plaintext)
+}
+
+// DecryptBytes 根据头部中的 key id 选择密钥解密二进制数据
+func (k *KeyringCrypto) DecryptBytes(ciphertext []byte) ([]byte, error) {
+	return decryptBytes(k.resolve, ciphertext)
+}
+
+// NewEncryptWriter 使用 active 密钥流式加密, 写入完成后必须调用 Close
+func (k *KeyringCrypto) NewEncryptWriter(w io.Writer) (io.WriteCloser, error) {
+	c, err := k.activeAEAD()
+	if err != nil {
+		return nil, err
+	}
+	return newEncryptWriter(c, k.Active, w)
+}
+
+// NewDecryptReader 根据头部中的 key id 选择密钥流式解密
+func (k *KeyringCrypto) NewDecryptReader(r io.Reader) (io.Reader, error) {
+	return newDecryptReader(k.resolve, r)
+}
+
+func (k *KeyringCrypto) activeAEAD() (aeadCrypto, error) {
+	c, ok := k.Keys[k.Active].(aeadCrypto)
+	if !ok {
+		return nil, ErrNotSupportBytes
+	}
+	return c, nil
+}
+
+func (k *KeyringCrypto) resolve(method, keyID string) (aeadCrypto, error) {
+	c := k.Default
+	if keyID != "" {
+		var ok bool
+		if c, ok = k.Keys[keyID]; !ok {
+			return nil, fmt.Errorf("unknown key id: %q", keyID)
+		}
+	}
+	ac, ok := c.(aeadCrypto)
+	if !ok {
+		return nil, ErrNotSupportBytes
+	}
+	return singleResolver(ac)(method, keyID)
+}
@@ -0,0 +1,81 @@
+package tcesecurity
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyringCrypto(t *testing.T) {
+	oldKey, _ := NewAesGcmCrypto(Aes256GcmAlgorithm, []byte("5c2bd12683ceefb8830abba988339e67"))
+	newKey, _ := NewAesGcmCrypto(Aes256GcmAlgorithm, []byte("0f3c3f40c60db7f32ce6a5e0143f09eb"))
+
+	// 轮转前的历史密文, 不带 key id
+	legacy, err := oldKey.Encrypt("mysql_pass")
+	assert.NoError(t, err)
+
+	ring, err := NewKeyringCrypto("k2", map[string]Crypto{"k1": oldKey, "k2": newKey}, oldKey)
+	assert.NoError(t, err)
+
+	ciphertext, err := ring.Encrypt("mysql_pass")
+	assert.NoError(t, err)
+	assert.Equal(t, "5632.6b32", strings.Split(ciphertext, ":")[2])
+	origin, id := SplitKeyID(ciphertext)
+	assert.Equal(t, "k2", id)
+	assert.Equal(t, "5632", strings.Split(origin, ":")[2])
+
+	// 已加密数据原样返回
+	again, err := ring.Encrypt(ciphertext)
+	assert.NoError(t, err)
+	assert.Equal(t, ciphertext, again)
+
+	for _, c := range []string{ciphertext, legacy, "mysql_pass"} {
+		plaintext, err := ring.Decrypt(c)
+		assert.NoError(t, err)
+		assert.Equal(t, "mysql_pass", plaintext)
+	}
+
+	// 旧密钥加密的数据携带 key id
+	k1, err := WithKeyID(legacy, "k1")
+	assert.NoError(t, err)
+	plaintext, err := ring.Decrypt(k1)
+	assert.NoError(t, err)
+	assert.Equal(t, "mysql_pass", plaintext)
+
+	unknown, _ := WithKeyID(legacy, "k3")
+	_, err = ring.Decrypt(unknown)
+	assert.Error(t, err)
+
+	_, err = NewKeyringCrypto("k3", map[string]Crypto{"k1": oldKey}, nil)
+	assert.Error(t, err)
+}
+
+func TestKeyringCryptoAesV1(t *testing.T) {
+	oldKey, _ := NewAesCbcCrypto(Aes256CbcAlgorithm, []byte("f13c3f40c60db7f32ce6a5e0143f09ea"))
+	newKey, _ := NewAesCbcCrypto(Aes256CbcAlgorithm, []byte("5c2bd12683ceefb8830abba988339e67"))
+	legacy, _ := oldKey.Encrypt("mysql_pass")
+
+	ring, err := NewKeyringCrypto("2021", map[string]Crypto{"2020": oldKey, "2021": newKey}, oldKey)
+	assert.NoError(t, err)
+
+	ciphertext, err := ring.Encrypt("mysql_pass")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(ciphertext, "AES+V1.32303231+"))
+
+	for _, c := range []string{ciphertext, legacy} {
+		plaintext, err := ring.Decrypt(c)
+		assert.NoError(t, err)
+		assert.Equal(t, "mysql_pass", plaintext)
+	}
+}
+
+func TestIsCiphertext(t *testing.T) {
+	gcm, _ := NewAesGcmCrypto(Aes256GcmAlgorithm, []byte("5c2bd12683ceefb8830abba988339e67"))
+	ciphertext, err := gcm.Encrypt("mysql_pass")
+	assert.NoError(t, err)
+	assert.True(t, IsCiphertext(ciphertext))
+	assert.True(t, IsCiphertext("AES+V1+651baa08c1d1e9ec5a19fa7d90897e63"))
+	assert.False(t, IsCiphertext("mysql_pass"))
+	assert.False(t, IsCiphertext("Tom"))
+}
@@ -0,0 +1,318 @@
+package tcesecurity
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"git.code.oa.com/tce-config/tcestuary-go/v4/tcesecurity/gmsm"
+	kms "git.code.oa.com/tce-config/tcestuary-go/v4/tcesecurity/tseckms/v20190118"
+)
+
+// 信封加密: 通过 KMS GenerateDataKey 获取数据密钥, 在本地使用 AES-256-GCM(kms-envelope)或 SM4-GCM(kms-envelope-sm4)加密,
+// KMS 加密后的数据密钥(wrapped key)保存在密文头部, 解密时通过 KMS Decrypt 获取数据密钥明文.
+//
+// 密文格式: prefix:method:version:wrapped-key:hex(nonce):hex(tag):hex(密文)
+// prefix:method:version:wrapped-key 作为 AAD, 防止篡改
+
+const (
+	KMSEnvelopeAlgorithm    = "kms-envelope"
+	KMSEnvelopeSM4Algorithm = "kms-envelope-sm4"
+	EnvelopeKeySpec         = "AES_256"
+	EnvelopeSM4KeySize      = 16 // SM4 数据密钥长度, KMS KeySpec 不支持 SM4, 通过 NumberOfBytes 指定
+
+	// DefaultDataKeyTTL 数据密钥缓存时间. 加密时在有效期内复用同一个数据密钥, 解密时缓存数据密钥明文
+	DefaultDataKeyTTL = 5 * time.Minute
+)
+
+func init() {
+	f := func(opts CryptoOpts) (Crypto, error) {
+		cli, err := NewKMSClient(opts.SecretId, opts.SecretKey, opts.KMSServer, opts.Transport)
+		if err != nil {
+			return nil, err
+		}
+		inv := newKMSInvoker(opts.KMSServer, opts.Retry, opts.Transport.Logger)
+		return newKMSEnvelopeCrypto(opts.Method, opts.KeyId, opts.KMSServer, opts.DataKeyTTL, cli, inv), nil
+	}
+	registerCryptoFunc(KMSEnvelopeAlgorithm, f)
+	registerCryptoFunc(KMSEnvelopeSM4Algorithm, f)
+}
+
+// KMSDataKeyClient 信封加密依赖的 KMS 接口, *kms.Client 满足该接口
+type KMSDataKeyClient interface {
+	GenerateDataKey(request *kms.GenerateDataKeyRequest) (*kms.GenerateDataKeyResponse, error)
+	Decrypt(request *kms.DecryptRequest) (*kms.DecryptResponse, error)
+}
+
+type KMSEnvelopeCrypto struct {
+	Client     KMSDataKeyClient
+	Prefix     string
+	Method     string
+	Version    string
+	KeyId      string
+	KMSServer  string
+	DataKeyTTL time.Duration
+
+	mu      sync.Mutex
+	current *dataKey            // 当前用于加密的数据密钥
+	keys    map[string]*dataKey // wrapped key -> 数据密钥明文
+	now     func() time.Time    // 单元测试, 替换时钟
+	invoker *kmsInvoker
+	sm4     bool // 数据密钥用于 SM4-GCM
+}
+
+type dataKey struct {
+	wrapped   string
+	plaintext []byte
+	expire    time.Time
+}
+
+// Encrypt 加密
+func (c *KMSEnvelopeCrypto) Encrypt(plaintext string) (string, error) {
+	return c.EncryptWithContext(plaintext, nil)
+}
+
+// EncryptCtx 加密, ctx 控制获取数据密钥时 KMS 调用(含重试)的超时、取消
+func (c *KMSEnvelopeCrypto) EncryptCtx(ctx context.Context, plaintext string) (string, error) {
+	return c.encrypt(ctx, plaintext, nil)
+}
+
+// EncryptWithContext 加密并绑定上下文, 上下文参与本地 GCM 认证, 数据密钥仍可复用
+func (c *KMSEnvelopeCrypto) EncryptWithContext(plaintext string, aad []byte) (string, error) {
+	return c.encrypt(context.Background(), plaintext, aad)
+}
+
+func (c *KMSEnvelopeCrypto) encrypt(ctx context.Context, plaintext string, aad []byte) (string, error) {
+	// 1，如果加密数据带有已加密前缀信息，则直接返回
+	if strings.HasPrefix(plaintext, c.Prefix) {
+		return plaintext, nil
+	}
+
+	// 2，获取数据密钥
+	key, err := c.currentKey(ctx)
+	if err != nil {
+		return "", err
+	}
+	gcm, err := c.newGCM(key.plaintext)
+	if err != nil {
+		return "", err
+	}
+	nonce, err := randomBytes(gcm.NonceSize())
+	if err != nil {
+		return "", err
+	}
+
+	// 3，本地加密
+	header := c.Prefix + ":" + c.Method + ":" + c.Version + ":" + key.wrapped
+	bts := gcm.Seal(nil, nonce, []byte(plaintext), contextAAD(header, aad))
+	tag := bts[len(bts)-gcm.Overhead():]
+
+	// 4，构造返回
+	return header + ":" + hex.EncodeToString(nonce) + ":" +
+		hex.EncodeToString(tag) + ":" + hex.EncodeToString(bts[:len(bts)-gcm.Overhead()]), nil
+}
+
+// Decrypt 解密
+func (c *KMSEnvelopeCrypto) Decrypt(ciphertext string) (string, error) {
+	return c.DecryptWithContext(ciphertext, nil)
+}
+
+// DecryptCtx 解密, ctx 控制获取数据密钥时 KMS 调用(含重试)的超时、取消
+func (c *KMSEnvelopeCrypto) DecryptCtx(ctx context.Context, ciphertext string) (string, error) {
+	return c.decrypt(ctx, ciphertext, nil)
+}
+
+// DecryptWithContext 解密并校验上下文
+func (c *KMSEnvelopeCrypto) DecryptWithContext(ciphertext string, aad []byte) (string, error) {
+	return c.decrypt(context.Background(), ciphertext, aad)
+}
+
+func (c *KMSEnvelopeCrypto) decrypt(ctx context.Context, ciphertext string, aad []byte) (string, error) {
+	// 1，如果解密数据前缀错误，直接返回
+	if !strings.HasPrefix(ciphertext, c.Prefix) {
+		return ciphertext, nil
+	}
+	// 2，验证method
+	items := strings.Split(ciphertext, ":")
+	if len(items) != 7 {
+		return "", fmt.Errorf("invalid ciphertext-data format")
+	}
+	if items[1] != c.Method {
+		return "", fmt.Errorf("invalid entrypted-data method")
+	}
+	nonce, err := hex.DecodeString(items[4])
+	if err != nil {
+		return "", fmt.Errorf("invalid entrypted-data nonce")
+	}
+	tag, err := hex.DecodeString(items[5])
+	if err != nil {
+		return "", fmt.Errorf("invalid entrypted-data tag")
+	}
+	rawEncrypted, err := hex.DecodeString(items[6])
+	if err != nil {
+		return "", fmt.Errorf("invalid entrypted-data format")
+	}
+
+	// 3，获取数据密钥
+	key, err := c.unwrapKey(ctx, items[3])
+	if err != nil {
+		return "", err
+	}
+	gcm, err := c.newGCM(key.plaintext)
+	if err != nil {
+		return "", err
+	}
+	if len(nonce) != gcm.NonceSize() {
+		return "", fmt.Errorf("invalid entrypted-data nonce")
+	}
+
+	// 4，本地解密
+	header := strings.Join(items[:4], ":")
+	bts, err := gcm.Open(nil, nonce, append(rawEncrypted, tag...), contextAAD(header, aad))
+	if err != nil {
+		return "", err
+	}
+	return string(bts), nil
+}
+
+// currentKey 返回当前用于加密的数据密钥, 过期后重新生成.
+// 访问 KMS 时不持有锁, 与 unwrapKey 相同; 并发刷新时各自生成, 以最后一个为准
+func (c *KMSEnvelopeCrypto) currentKey(ctx context.Context) (*dataKey, error) {
+	c.mu.Lock()
+	if key := c.current; key != nil && c.now().Before(key.expire) {
+		c.mu.Unlock()
+		return key, nil
+	}
+	c.mu.Unlock()
+
+	req := kms.NewGenerateDataKeyRequest()
+	req.SetDomain(c.KMSServer)
+	req.KeyId = &c.KeyId
+	if c.sm4 {
+		size := uint64(EnvelopeSM4KeySize)
+		req.NumberOfBytes = &size
+	} else {
+		keySpec := EnvelopeKeySpec
+		req.KeySpec = &keySpec
+	}
+	var resp *kms.GenerateDataKeyResponse
+	err := kmsInvokerOf(c.invoker, c.KMSServer).do(ctx, "GenerateDataKey", func() (err error) {
+		resp, err = c.Client.GenerateDataKey(req)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if resp.Response == nil || resp.Response.Plaintext == nil || resp.Response.CiphertextBlob == nil {
+		return nil, fmt.Errorf("invalid kms GenerateDataKey response")
+	}
+	plaintext, err := base64.StdEncoding.DecodeString(*resp.Response.Plaintext)
+	if err != nil {
+		return nil, err
+	}
+
+	// 新生成的数据密钥同时放入解密缓存, 解密刚加密的数据时不需要访问 KMS
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.current = c.cacheKey(*resp.Response.CiphertextBlob, plaintext)
+	return c.current, nil
+}
+
+// unwrapKey 获取 wrapped key 对应的数据密钥明文, 缓存有效期内不重复访问 KMS
+func (c *KMSEnvelopeCrypto) unwrapKey(ctx context.Context, wrapped string) (*dataKey, error) {
+	c.mu.Lock()
+	if key, ok := c.keys[wrapped]; ok && c.now().Before(key.expire) {
+		c.mu.Unlock()
+		return key, nil
+	}
+	c.mu.Unlock()
+
+	req := kms.NewDecryptRequest()
+	req.SetDomain(c.KMSServer)
+	req.CiphertextBlob = &wrapped
+	var resp *kms.DecryptResponse
+	err := kmsInvokerOf(c.invoker, c.KMSServer).do(ctx, "Decrypt", func() (err error) {
+		resp, err = c.Client.Decrypt(req)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if resp.Response == nil || resp.Response.Plaintext == nil {
+		return nil, fmt.Errorf("invalid kms Decrypt response")
+	}
+	plaintext, err := base64.StdEncoding.DecodeString(*resp.Response.Plaintext)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.cacheKey(wrapped, plaintext), nil
+}
+
+// cacheKey 缓存数据密钥, 同时清理过期的缓存. 调用方持有锁
+func (c *KMSEnvelopeCrypto) cacheKey(wrapped string, plaintext []byte) *dataKey {
+	now := c.now()
+	for k, v := range c.keys {
+		if !now.Before(v.expire) {
+			delete(c.keys, k)
+		}
+	}
+	key := &dataKey{wrapped: wrapped, plaintext: plaintext, expire: now.Add(c.DataKeyTTL)}
+	c.keys[wrapped] = key
+	return key
+}
+
+// newGCM kms-envelope 使用 AES-GCM, kms-envelope-sm4 使用标准 SM4-GCM(不填充)
+func (c *KMSEnvelopeCrypto) newGCM(key []byte) (cipher.AEAD, error) {
+	var block cipher.Block
+	var err error
+	if c.sm4 {
+		if len(key) != gmsm.SM4KeySize {
+			return nil, fmt.Errorf("invalid sm4 data key length %d", len(key))
+		}
+		block, err = gmsm.NewSM4Cipher(key)
+	} else {
+		block, err = aes.NewCipher(key)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// NewKMSEnvelopeCrypto dataKeyTTL 为 0 时使用 DefaultDataKeyTTL. 校验 KMS 服务端证书, 自定义传输参数参考 NewKMSClient
+func NewKMSEnvelopeCrypto(method, keyId, secretId, secretKey, KMSServer string, dataKeyTTL time.Duration) (*KMSEnvelopeCrypto, error) {
+	cli, err := NewKMSClient(secretId, secretKey, KMSServer, KMSTransportOpts{})
+	if err != nil {
+		return nil, err
+	}
+	return newKMSEnvelopeCrypto(method, keyId, KMSServer, dataKeyTTL, cli, newKMSInvoker(KMSServer, KMSRetryOpts{}, nil)), nil
+}
+
+func newKMSEnvelopeCrypto(method, keyId, KMSServer string, dataKeyTTL time.Duration, cli KMSDataKeyClient,
+	inv *kmsInvoker) *KMSEnvelopeCrypto {
+	if dataKeyTTL <= 0 {
+		dataKeyTTL = DefaultDataKeyTTL
+	}
+	return &KMSEnvelopeCrypto{
+		Client:     cli,
+		Prefix:     AlreadyEncryptPrefix + hex.EncodeToString([]byte(TceSecurity)),
+		Method:     hex.EncodeToString([]byte(method)),
+		Version:    hex.EncodeToString([]byte(VERSION)),
+		KeyId:      keyId,
+		KMSServer:  KMSServer,
+		DataKeyTTL: dataKeyTTL,
+		keys:       make(map[string]*dataKey),
+		now:        time.Now,
+		invoker:    inv,
+		sm4:        method == KMSEnvelopeSM4Algorithm,
+	}
+}
@@ -0,0 +1,190 @@
+package tcesecurity
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	kms "git.code.oa.com/tce-config/tcestuary-go/v4/tcesecurity/tseckms/v20190118"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeDataKeyClient 在内存中模拟 KMS 数据密钥接口, 记录调用次数
+type fakeDataKeyClient struct {
+	mu       sync.Mutex
+	keys     map[string]string // wrapped key -> base64 数据密钥明文
+	generate int
+	decrypt  int
+	block    chan struct{} // 不为 nil 时 GenerateDataKey 等待关闭后返回
+}
+
+func newFakeDataKeyClient() *fakeDataKeyClient {
+	return &fakeDataKeyClient{keys: make(map[string]string)}
+}
+
+func (f *fakeDataKeyClient) GenerateDataKey(req *kms.GenerateDataKeyRequest) (*kms.GenerateDataKeyResponse, error) {
+	if f.block != nil {
+		<-f.block
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.generate++
+
+	size := 32
+	if req.NumberOfBytes != nil {
+		size = int(*req.NumberOfBytes)
+	}
+	key, _ := randomBytes(size)
+	plaintext := base64.StdEncoding.EncodeToString(key)
+	wrapped := base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%s/%d", *req.KeyId, f.generate)))
+	f.keys[wrapped] = plaintext
+
+	resp := kms.NewGenerateDataKeyResponse()
+	b, _ := json.Marshal(map[string]interface{}{
+		"Response": map[string]string{"KeyId": *req.KeyId, "Plaintext": plaintext, "CiphertextBlob": wrapped},
+	})
+	return resp, resp.FromJsonString(string(b))
+}
+
+func (f *fakeDataKeyClient) Decrypt(req *kms.DecryptRequest) (*kms.DecryptResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.decrypt++
+
+	plaintext, ok := f.keys[*req.CiphertextBlob]
+	if !ok {
+		return nil, fmt.Errorf("invalid ciphertext blob")
+	}
+	resp := kms.NewDecryptResponse()
+	b, _ := json.Marshal(map[string]interface{}{
+		"Response": map[string]string{"Plaintext": plaintext},
+	})
+	return resp, resp.FromJsonString(string(b))
+}
+
+func TestKMSEnvelopeCrypto(t *testing.T) {
+	cli := newFakeDataKeyClient()
+	c := newKMSEnvelopeCrypto(KMSEnvelopeAlgorithm, "key-1", "kms.local", time.Minute, cli, nil)
+
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	c1, err := c.Encrypt("mysql_pass")
+	assert.NoError(t, err)
+	c2, err := c.Encrypt("redis_pass")
+	assert.NoError(t, err)
+	assert.Len(t, strings.Split(c1, ":"), 7)
+
+	t.Run("reuse-data-key", func(t *testing.T) {
+		assert.Equal(t, 1, cli.generate)
+		assert.Equal(t, strings.Split(c1, ":")[3], strings.Split(c2, ":")[3])
+
+		plaintext, err := c.Decrypt(c1)
+		assert.NoError(t, err)
+		assert.Equal(t, "mysql_pass", plaintext)
+		assert.Equal(t, 0, cli.decrypt)
+	})
+
+	t.Run("cache-unwrapped-key", func(t *testing.T) {
+		other := newKMSEnvelopeCrypto(KMSEnvelopeAlgorithm, "key-1", "kms.local", time.Minute, cli, nil)
+		other.now = c.now
+		for _, ciphertext := range []string{c1, c2, c1} {
+			_, err := other.Decrypt(ciphertext)
+			assert.NoError(t, err)
+		}
+		assert.Equal(t, 1, cli.decrypt)
+	})
+
+	t.Run("ttl-expired", func(t *testing.T) {
+		now = now.Add(2 * time.Minute)
+		c3, err := c.Encrypt("mysql_pass")
+		assert.NoError(t, err)
+		assert.Equal(t, 2, cli.generate)
+		assert.NotEqual(t, strings.Split(c1, ":")[3], strings.Split(c3, ":")[3])
+
+		decrypts := cli.decrypt
+		plaintext, err := c.Decrypt(c1)
+		assert.NoError(t, err)
+		assert.Equal(t, "mysql_pass", plaintext)
+		assert.Equal(t, decrypts+1, cli.decrypt)
+	})
+
+	t.Run("tampered", func(t *testing.T) {
+		items := strings.Split(c1, ":")
+		items[3] = strings.Split(c2, ":")[3] + "x"
+		_, err := c.Decrypt(strings.Join(items, ":"))
+		assert.Error(t, err)
+
+		items = strings.Split(c1, ":")
+		items[5] = strings.Repeat("0", len(items[5]))
+		_, err = c.Decrypt(strings.Join(items, ":"))
+		assert.Error(t, err)
+	})
+}
+
+func TestKMSEnvelopeSM4(t *testing.T) {
+	cli := newFakeDataKeyClient()
+	c := newKMSEnvelopeCrypto(KMSEnvelopeSM4Algorithm, "key-1", "kms.local", time.Minute, cli, nil)
+
+	ciphertext, err := c.EncryptWithContext("mysql_pass", []byte("row-1"))
+	assert.NoError(t, err)
+	key, err := c.currentKey(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, key.plaintext, EnvelopeSM4KeySize)
+
+	other := newKMSEnvelopeCrypto(KMSEnvelopeSM4Algorithm, "key-1", "kms.local", time.Minute, cli, nil)
+	plaintext, err := other.DecryptWithContext(ciphertext, []byte("row-1"))
+	assert.NoError(t, err)
+	assert.Equal(t, "mysql_pass", plaintext)
+
+	// method 不同, AES 信封不能解密 SM4 信封
+	aes := newKMSEnvelopeCrypto(KMSEnvelopeAlgorithm, "key-1", "kms.local", time.Minute, cli, nil)
+	_, err = aes.Decrypt(ciphertext)
+	assert.Error(t, err)
+}
+
+// 刷新数据密钥时不持有锁, 解密缓存命中不受影响
+func TestKMSEnvelopeRefreshUnlocked(t *testing.T) {
+	cli := newFakeDataKeyClient()
+	c := newKMSEnvelopeCrypto(KMSEnvelopeAlgorithm, "key-1", "kms.local", time.Minute, cli, nil)
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	ciphertext, err := c.Encrypt("mysql_pass")
+	assert.NoError(t, err)
+
+	// 数据密钥过期, 加密阻塞在 GenerateDataKey
+	c.mu.Lock()
+	expired := *c.current
+	expired.expire = now
+	c.current = &expired
+	c.mu.Unlock()
+	cli.block = make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		_, err := c.Encrypt("redis_pass")
+		done <- err
+	}()
+
+	decrypted := make(chan string, 1)
+	go func() {
+		plaintext, _ := c.Decrypt(ciphertext)
+		decrypted <- plaintext
+	}()
+	select {
+	case plaintext := <-decrypted:
+		assert.Equal(t, "mysql_pass", plaintext)
+	case <-time.After(2 * time.Second):
+		t.Fatal("decrypt blocked by data key refresh")
+	}
+
+	close(cli.block)
+	assert.NoError(t, <-done)
+	assert.Equal(t, 2, cli.generate)
+	assert.Equal(t, 0, cli.decrypt)
+}
@@ -0,0 +1,331 @@
+package tcesecurity
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"git.code.oa.com/tce-config/tcestuary-go/v4/logger"
+	sdkerrors "github.com/tencentyun/tcecloud-sdk-go/tcecloud/common/errors"
+)
+
+// KMS 调用的重试、熔断及统计.
+// 可重试错误(网络错误、5xx、限频、KMS 内部错误)按指数退避重试; 同一 KMS 服务连续失败达到阈值后熔断,
+// 熔断期间直接返回 ErrKMSCircuitOpen, 熔断结束后放行一个请求探测, 成功则恢复
+
+const (
+	DefaultKMSMaxRetries       = 2
+	DefaultKMSBaseDelay        = 100 * time.Millisecond
+	DefaultKMSMaxDelay         = 2 * time.Second
+	DefaultKMSBreakerThreshold = 5
+	DefaultKMSBreakerCooldown  = 10 * time.Second
+)
+
+// ErrKMSCircuitOpen KMS 服务熔断中
+var ErrKMSCircuitOpen = errors.New("kms circuit breaker is open")
+
+// KMSRetryableCodes 可重试的 TceCloudSDKError 错误码, 按前缀匹配.
+// 不含 ResourceUnavailable, KMS 用其子错误码表示密钥不存在、已禁用等, 重试无意义
+var KMSRetryableCodes = []string{
+	"ClientError.NetworkError",
+	"ClientError.IOError",
+	"InternalError",
+	"RequestLimitExceeded",
+}
+
+// KMSRetryOpts 重试、熔断参数. 零值使用默认值, MaxRetries、BreakerThreshold 为负数表示不重试、不熔断
+type KMSRetryOpts struct {
+	MaxRetries       int           // 最大重试次数, 不含首次请求
+	BaseDelay        time.Duration // 首次重试等待时间, 之后每次翻倍
+	MaxDelay         time.Duration // 最大重试等待时间
+	BreakerThreshold int           // 连续失败次数达到阈值后熔断
+	BreakerCooldown  time.Duration // 熔断时间
+}
+
+func (o KMSRetryOpts) withDefault() KMSRetryOpts {
+	if o.MaxRetries == 0 {
+		o.MaxRetries = DefaultKMSMaxRetries
+	}
+	if o.BaseDelay <= 0 {
+		o.BaseDelay = DefaultKMSBaseDelay
+	}
+	if o.MaxDelay <= 0 {
+		o.MaxDelay = DefaultKMSMaxDelay
+	}
+	if o.BreakerThreshold == 0 {
+		o.BreakerThreshold = DefaultKMSBreakerThreshold
+	}
+	if o.BreakerCooldown <= 0 {
+		o.BreakerCooldown = DefaultKMSBreakerCooldown
+	}
+	return o
+}
+
+// backoff 第 attempt 次请求失败后的等待时间, 在 [d/2, d) 之间随机
+func (o KMSRetryOpts) backoff(attempt int) time.Duration {
+	d := o.BaseDelay
+	for i := 1; i < attempt && d < o.MaxDelay; i++ {
+		d *= 2
+	}
+	if d > o.MaxDelay {
+		d = o.MaxDelay
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// IsRetryableKMSError 判断 KMS 调用错误是否可重试
+func IsRetryableKMSError(err error) bool {
+	sdkErr, ok := err.(*sdkerrors.TceCloudSDKError)
+	if !ok {
+		return false
+	}
+	switch sdkErr.Code {
+	case "ClientError.HttpStatusCodeError":
+		return strings.Contains(sdkErr.Message, "status code: 5") || strings.Contains(sdkErr.Message, "status code: 429")
+	case "ClientError.NetworkError":
+		// 证书校验失败属于配置错误, 重试无意义
+		if strings.Contains(sdkErr.Message, "x509:") || strings.Contains(sdkErr.Message, "tls:") {
+			return false
+		}
+	}
+	for _, code := range KMSRetryableCodes {
+		if strings.HasPrefix(sdkErr.Code, code) {
+			return true
+		}
+	}
+	return false
+}
+
+// KMSCallInfo 一次 KMS 调用(含重试)的结果
+type KMSCallInfo struct {
+	Server   string
+	Action   string        // KMS 接口, 如 Encrypt
+	Attempts int           // 请求次数, 大于 1 表示发生了重试, 0 表示被熔断或 context 已结束
+	Latency  time.Duration // 总耗时, 含重试等待
+	Err      error
+}
+
+// KMSStats 单个 KMS 服务的调用统计
+type KMSStats struct {
+	Calls        int64         // 调用次数
+	Failures     int64         // 失败次数(重试后仍失败)
+	Retries      int64         // 重试次数
+	Rejected     int64         // 熔断拒绝次数
+	TotalLatency time.Duration // 总耗时, 平均耗时 = TotalLatency / Calls
+	MaxLatency   time.Duration
+	CircuitOpen  bool // 当前是否熔断
+}
+
+var (
+	kmsObserverMu sync.RWMutex
+	kmsObserver   func(KMSCallInfo)
+
+	kmsServersMu sync.Mutex
+	kmsServers   = make(map[string]*kmsServerState)
+)
+
+// SetKMSObserver 设置 KMS 调用回调, 用于上报监控. 回调在调用方 goroutine 中同步执行, 不应阻塞
+func SetKMSObserver(f func(KMSCallInfo)) {
+	kmsObserverMu.Lock()
+	defer kmsObserverMu.Unlock()
+	kmsObserver = f
+}
+
+// GetKMSStats 返回各 KMS 服务的调用统计, key 为 KMS 服务地址
+func GetKMSStats() map[string]KMSStats {
+	kmsServersMu.Lock()
+	defer kmsServersMu.Unlock()
+	now := time.Now()
+	stats := make(map[string]KMSStats, len(kmsServers))
+	for server, s := range kmsServers {
+		s.mu.Lock()
+		st := s.stats
+		st.CircuitOpen = now.Before(s.openUntil)
+		s.mu.Unlock()
+		stats[server] = st
+	}
+	return stats
+}
+
+// kmsServerState 单个 KMS 服务的熔断状态及统计, 同一服务的所有组件共享
+type kmsServerState struct {
+	mu        sync.Mutex
+	failures  int       // 连续失败次数
+	openUntil time.Time // 熔断结束时间
+	probing   bool      // 熔断结束后, 是否已放行探测请求
+	stats     KMSStats
+}
+
+func kmsServer(server string) *kmsServerState {
+	kmsServersMu.Lock()
+	defer kmsServersMu.Unlock()
+	s, ok := kmsServers[server]
+	if !ok {
+		s = &kmsServerState{}
+		kmsServers[server] = s
+	}
+	return s
+}
+
+// allow 熔断检查
+func (s *kmsServerState) allow(threshold int, now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if threshold < 0 || s.failures < threshold {
+		return true
+	}
+	if now.Before(s.openUntil) || s.probing {
+		return false
+	}
+	s.probing = true
+	return true
+}
+
+// record 记录一次请求结果, 返回是否因本次失败进入熔断
+func (s *kmsServerState) record(failed bool, opts KMSRetryOpts, now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.probing = false
+	if !failed {
+		s.failures = 0
+		return false
+	}
+	s.failures++
+	if opts.BreakerThreshold < 0 || s.failures < opts.BreakerThreshold {
+		return false
+	}
+	s.openUntil = now.Add(opts.BreakerCooldown)
+	return true
+}
+
+// release 请求未完成(context 结束), 不计入熔断
+func (s *kmsServerState) release() {
+	s.mu.Lock()
+	s.probing = false
+	s.mu.Unlock()
+}
+
+func (s *kmsServerState) observe(info KMSCallInfo, rejected bool) {
+	s.mu.Lock()
+	s.stats.Calls++
+	if info.Err != nil {
+		s.stats.Failures++
+	}
+	if info.Attempts > 1 {
+		s.stats.Retries += int64(info.Attempts - 1)
+	}
+	if rejected {
+		s.stats.Rejected++
+	}
+	s.stats.TotalLatency += info.Latency
+	if info.Latency > s.stats.MaxLatency {
+		s.stats.MaxLatency = info.Latency
+	}
+	s.mu.Unlock()
+
+	kmsObserverMu.RLock()
+	f := kmsObserver
+	kmsObserverMu.RUnlock()
+	if f != nil {
+		f(info)
+	}
+}
+
+// kmsInvoker 执行 KMS 调用, 负责超时、重试、熔断及统计
+type kmsInvoker struct {
+	server string
+	opts   KMSRetryOpts
+	log    logger.Logger
+	state  *kmsServerState
+	sleep  func(ctx context.Context, d time.Duration) error // 单元测试, 替换等待
+}
+
+func newKMSInvoker(server string, opts KMSRetryOpts, log logger.Logger) *kmsInvoker {
+	if log == nil {
+		log = logger.Global()
+	}
+	return &kmsInvoker{
+		server: server,
+		opts:   opts.withDefault(),
+		log:    log,
+		state:  kmsServer(server),
+		sleep:  sleepContext,
+	}
+}
+
+// kmsInvokerOf 直接构造的 KMS 组件没有 invoker, 使用默认参数
+func kmsInvokerOf(k *kmsInvoker, server string) *kmsInvoker {
+	if k == nil {
+		return newKMSInvoker(server, KMSRetryOpts{}, nil)
+	}
+	return k
+}
+
+// do 执行 call, call 中只能写入调用方在 do 返回 nil 后才读取的变量
+func (k *kmsInvoker) do(ctx context.Context, action string, call func() error) error {
+	start := time.Now()
+	info := KMSCallInfo{Server: k.server, Action: action}
+	rejected := false
+	for {
+		if info.Err = ctx.Err(); info.Err != nil {
+			break
+		}
+		if !k.state.allow(k.opts.BreakerThreshold, time.Now()) {
+			info.Err, rejected = ErrKMSCircuitOpen, true
+			break
+		}
+		info.Attempts++
+		info.Err = callContext(ctx, call)
+		retryable := IsRetryableKMSError(info.Err)
+		if info.Err == ctx.Err() && info.Err != nil {
+			// context 结束不代表 KMS 异常, 只释放探测请求
+			k.state.release()
+			break
+		}
+		if k.state.record(retryable, k.opts, time.Now()) {
+			k.log.Printf("[WARN] kms server %s: circuit breaker open for %s, last error: %s",
+				k.server, k.opts.BreakerCooldown, info.Err)
+		}
+		if !retryable || info.Attempts > k.opts.MaxRetries {
+			break
+		}
+		if err := k.sleep(ctx, k.opts.backoff(info.Attempts)); err != nil {
+			info.Err = err
+			break
+		}
+	}
+	info.Latency = time.Since(start)
+	k.state.observe(info, rejected)
+	return info.Err
+}
+
+// callContext 在 ctx 结束时提前返回. KMS SDK 不支持 context, 请求本身由 http 超时控制
+func callContext(ctx context.Context, call func() error) error {
+	if ctx.Done() == nil {
+		return call()
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- call()
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
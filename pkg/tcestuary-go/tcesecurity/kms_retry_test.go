@@ -0,0 +1,211 @@
+package tcesecurity
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"git.code.oa.com/tce-config/tcestuary-go/v4/tcesecurity/kmstest"
+	"github.com/stretchr/testify/assert"
+	sdkerrors "github.com/tencentyun/tcecloud-sdk-go/tcecloud/common/errors"
+)
+
+// newTestInvoker 每个用例使用独立的 server, 避免共享熔断状态; 不等待退避时间
+func newTestInvoker(t *testing.T, opts KMSRetryOpts) *kmsInvoker {
+	k := newKMSInvoker(t.Name(), opts, &captureLogger{})
+	k.sleep = func(ctx context.Context, d time.Duration) error { return ctx.Err() }
+	return k
+}
+
+func TestIsRetryableKMSError(t *testing.T) {
+	retryable := []error{
+		sdkerrors.NewTceCloudSDKError("ClientError.NetworkError", "Fail to get response because EOF", ""),
+		sdkerrors.NewTceCloudSDKError("ClientError.HttpStatusCodeError", "Request fail with http status code: 503 Service Unavailable", ""),
+		sdkerrors.NewTceCloudSDKError("ClientError.HttpStatusCodeError", "Request fail with http status code: 429 Too Many Requests", ""),
+		sdkerrors.NewTceCloudSDKError("InternalError", "", "1"),
+		sdkerrors.NewTceCloudSDKError("RequestLimitExceeded", "", "1"),
+	}
+	for _, err := range retryable {
+		assert.True(t, IsRetryableKMSError(err), err.Error())
+	}
+	notRetryable := []error{
+		nil,
+		ErrorFormat,
+		sdkerrors.NewTceCloudSDKError("ClientError.NetworkError", "Fail to get response because x509: certificate signed by unknown authority", ""),
+		sdkerrors.NewTceCloudSDKError("ClientError.HttpStatusCodeError", "Request fail with http status code: 403 Forbidden", ""),
+		sdkerrors.NewTceCloudSDKError("InvalidParameter", "", "1"),
+		sdkerrors.NewTceCloudSDKError("ResourceUnavailable.CmkDisabled", "", "1"),
+		sdkerrors.NewTceCloudSDKError("AuthFailure.SignatureFailure", "", "1"),
+	}
+	for _, err := range notRetryable {
+		assert.False(t, IsRetryableKMSError(err), "%v", err)
+	}
+}
+
+func TestKMSInvokerRetry(t *testing.T) {
+	networkErr := sdkerrors.NewTceCloudSDKError("ClientError.NetworkError", "Fail to get response because EOF", "")
+
+	t.Run("retry-then-success", func(t *testing.T) {
+		k := newTestInvoker(t, KMSRetryOpts{})
+		calls := 0
+		err := k.do(context.Background(), "Encrypt", func() error {
+			calls++
+			if calls < 3 {
+				return networkErr
+			}
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 3, calls)
+		stats := GetKMSStats()[t.Name()]
+		assert.Equal(t, int64(1), stats.Calls)
+		assert.Equal(t, int64(2), stats.Retries)
+		assert.Equal(t, int64(0), stats.Failures)
+	})
+
+	t.Run("retry-exhausted", func(t *testing.T) {
+		k := newTestInvoker(t, KMSRetryOpts{MaxRetries: 1})
+		calls := 0
+		err := k.do(context.Background(), "Encrypt", func() error {
+			calls++
+			return networkErr
+		})
+		assert.Equal(t, networkErr, err)
+		assert.Equal(t, 2, calls)
+		assert.Equal(t, int64(1), GetKMSStats()[t.Name()].Failures)
+	})
+
+	t.Run("not-retryable", func(t *testing.T) {
+		k := newTestInvoker(t, KMSRetryOpts{})
+		calls := 0
+		err := k.do(context.Background(), "Decrypt", func() error {
+			calls++
+			return sdkerrors.NewTceCloudSDKError("InvalidParameter", "", "1")
+		})
+		assert.Error(t, err)
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("no-retry", func(t *testing.T) {
+		k := newTestInvoker(t, KMSRetryOpts{MaxRetries: -1})
+		calls := 0
+		k.do(context.Background(), "Encrypt", func() error {
+			calls++
+			return networkErr
+		})
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("backoff", func(t *testing.T) {
+		opts := KMSRetryOpts{BaseDelay: 100 * time.Millisecond, MaxDelay: 300 * time.Millisecond}
+		for attempt, max := range []time.Duration{100, 200, 300, 300} {
+			d := opts.backoff(attempt + 1)
+			assert.True(t, d >= max*time.Millisecond/2 && d <= max*time.Millisecond, "attempt %d: %s", attempt+1, d)
+		}
+	})
+}
+
+func TestKMSInvokerContext(t *testing.T) {
+	k := newTestInvoker(t, KMSRetryOpts{})
+
+	// 已取消的 ctx 不发起请求
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	called := false
+	err := k.do(ctx, "Encrypt", func() error {
+		called = true
+		return nil
+	})
+	assert.Equal(t, context.Canceled, err)
+	assert.False(t, called)
+
+	// 请求未返回时 ctx 超时
+	block := make(chan struct{})
+	defer close(block)
+	ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err = k.do(ctx, "Encrypt", func() error {
+		<-block
+		return nil
+	})
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.True(t, time.Since(start) < time.Second)
+	// ctx 超时不计入熔断
+	assert.Equal(t, 0, k.state.failures)
+}
+
+func TestKMSInvokerBreaker(t *testing.T) {
+	logs := &captureLogger{}
+	k := newTestInvoker(t, KMSRetryOpts{MaxRetries: -1, BreakerThreshold: 2, BreakerCooldown: 50 * time.Millisecond})
+	k.log = logs
+	var infos []KMSCallInfo
+	SetKMSObserver(func(info KMSCallInfo) {
+		if info.Server == t.Name() {
+			infos = append(infos, info)
+		}
+	})
+	defer SetKMSObserver(nil)
+
+	calls := 0
+	fail := func() error {
+		calls++
+		return sdkerrors.NewTceCloudSDKError("InternalError", "", "1")
+	}
+	succeed := func() error {
+		calls++
+		return nil
+	}
+
+	assert.Error(t, k.do(context.Background(), "Encrypt", fail))
+	assert.Error(t, k.do(context.Background(), "Encrypt", fail))
+	assert.Len(t, logs.lines, 1)
+	assert.Contains(t, logs.lines[0], "circuit breaker open")
+
+	// 熔断期间不发起请求
+	assert.Equal(t, ErrKMSCircuitOpen, k.do(context.Background(), "Encrypt", succeed))
+	assert.Equal(t, 2, calls)
+	stats := GetKMSStats()[t.Name()]
+	assert.True(t, stats.CircuitOpen)
+	assert.Equal(t, int64(1), stats.Rejected)
+
+	// 熔断结束后探测成功, 恢复
+	time.Sleep(60 * time.Millisecond)
+	assert.NoError(t, k.do(context.Background(), "Encrypt", succeed))
+	assert.NoError(t, k.do(context.Background(), "Encrypt", succeed))
+	assert.Equal(t, 4, calls)
+	assert.False(t, GetKMSStats()[t.Name()].CircuitOpen)
+
+	assert.Len(t, infos, 5)
+	assert.Equal(t, 0, infos[2].Attempts)
+	assert.Equal(t, ErrKMSCircuitOpen, infos[2].Err)
+	assert.Equal(t, "Encrypt", infos[4].Action)
+}
+
+func TestKMSSm4Retry(t *testing.T) {
+	srv := kmstest.NewServer()
+	defer srv.Close()
+	host := srv.Host()
+
+	cli, err := NewKMSClient(srv.SecretId, srv.SecretKey, host, KMSTransportOpts{CACert: srv.CACert()})
+	assert.NoError(t, err)
+	inv := newKMSInvoker(host, KMSRetryOpts{BaseDelay: time.Millisecond}, nil)
+	c := newKMSSm4Crypto(KMSSm4Algorithm, "key-1", host, cli, inv)
+
+	// 503 及 KMS 内部错误重试后成功
+	srv.Fail("Encrypt", 1, "")
+	ciphertext, err := c.EncryptCtx(context.Background(), "mysql_pass")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, srv.Calls("Encrypt"))
+	srv.Fail("Decrypt", 1, "InternalError")
+	plaintext, err := c.DecryptCtx(context.Background(), ciphertext)
+	assert.NoError(t, err)
+	assert.Equal(t, "mysql_pass", plaintext)
+	assert.Equal(t, int64(2), GetKMSStats()[host].Retries)
+
+	// 密钥禁用不重试
+	srv.DisableKey("key-1")
+	_, err = c.Decrypt(ciphertext)
+	assert.Error(t, err)
+	assert.Equal(t, 3, srv.Calls("Decrypt"))
+}
@@ -1,11 +1,10 @@
 package tcesecurity
 
 import (
-	"crypto/tls"
+	"context"
 	"encoding/base64"
 	"encoding/hex"
 	"fmt"
-	"net/http"
 	"strings"
 
 	kms "git.code.oa.com/tce-config/tcestuary-go/v4/tcesecurity/tseckms/v20190118"
@@ -19,7 +18,12 @@ const (
 
 func init() {
 	f := func(opts SignOpts) (Signer, error) {
-		return NewKMSSign(opts.Method, opts.KeyId, opts.SecretId, opts.SecretKey, opts.KMSServer)
+		cli, err := NewKMSClient(opts.SecretId, opts.SecretKey, opts.KMSServer, opts.Transport)
+		if err != nil {
+			return nil, err
+		}
+		inv := newKMSInvoker(opts.KMSServer, opts.Retry, opts.Transport.Logger)
+		return newKMSSign(opts.Method, opts.KeyId, opts.KMSServer, cli, inv), nil
 	}
 	registerSignFunc(KMSSignAlgorithm, f)
 }
@@ -33,10 +37,17 @@ type KMSSign struct {
 	KMSServer       string
 	RemoteAlgorithm string
 	MessageType     string
+
+	invoker *kmsInvoker
 }
 
 // Sign 生成签名
 func (s *KMSSign) Sign(msg string) (string, error) {
+	return s.SignCtx(context.Background(), msg)
+}
+
+// SignCtx 生成签名, ctx 控制 KMS 调用(含重试)的超时、取消
+func (s *KMSSign) SignCtx(ctx context.Context, msg string) (string, error) {
 	req := kms.NewSignByAsymmetricKeyRequest()
 	req.SetDomain(s.KMSServer)
 	req.KeyId = &s.KeyId
@@ -44,7 +55,11 @@ func (s *KMSSign) Sign(msg string) (string, error) {
 	req.MessageType = &s.MessageType
 	b64Msg := base64.StdEncoding.EncodeToString([]byte(msg))
 	req.Message = &b64Msg
-	resp, err := s.Client.SignByAsymmetricKey(req)
+	var resp *kms.SignByAsymmetricKeyResponse
+	err := kmsInvokerOf(s.invoker, s.KMSServer).do(ctx, "SignByAsymmetricKey", func() (err error) {
+		resp, err = s.Client.SignByAsymmetricKey(req)
+		return err
+	})
 	if err != nil {
 		return "", err
 	}
@@ -54,6 +69,11 @@ func (s *KMSSign) Sign(msg string) (string, error) {
 
 // Verify 验证签名
 func (s *KMSSign) Verify(msg, signValue string) (bool, error) {
+	return s.VerifyCtx(context.Background(), msg, signValue)
+}
+
+// VerifyCtx 验证签名, ctx 控制 KMS 调用(含重试)的超时、取消
+func (s *KMSSign) VerifyCtx(ctx context.Context, msg, signValue string) (bool, error) {
 	// 1，如果解密数据前缀错误，直接返回
 	if !strings.HasPrefix(signValue, s.Prefix) {
 		return false, nil
@@ -77,28 +97,27 @@ func (s *KMSSign) Verify(msg, signValue string) (bool, error) {
 	req.SignatureValue = &realSign
 	b64Msg := base64.StdEncoding.EncodeToString([]byte(msg))
 	req.Message = &b64Msg
-	resp, err := s.Client.VerifyByAsymmetricKey(req)
+	var resp *kms.VerifyByAsymmetricKeyResponse
+	err := kmsInvokerOf(s.invoker, s.KMSServer).do(ctx, "VerifyByAsymmetricKey", func() (err error) {
+		resp, err = s.Client.VerifyByAsymmetricKey(req)
+		return err
+	})
 	if err != nil {
 		return false, err
 	}
 	return *resp.Response.SignatureValid, nil
 }
 
-// NewKMSSign
+// NewKMSSign 校验 KMS 服务端证书, 自定义传输参数参考 NewKMSClient
 func NewKMSSign(method, keyId, secretId, secretKey, KMSServer string) (*KMSSign, error) {
-	if KMSServer == "" {
-		return nil, fmt.Errorf("invalid kmsServer config")
-	}
-	cli, err := kms.NewClientWithSecretId(secretId, secretKey, DefaultRegion)
-
+	cli, err := NewKMSClient(secretId, secretKey, KMSServer, KMSTransportOpts{})
 	if err != nil {
 		return nil, err
 	}
-	// config transport
-	transport := &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-	}
-	cli.WithHttpTransport(transport)
+	return newKMSSign(method, keyId, KMSServer, cli, newKMSInvoker(KMSServer, KMSRetryOpts{}, nil)), nil
+}
+
+func newKMSSign(method, keyId, KMSServer string, cli *kms.Client, inv *kmsInvoker) *KMSSign {
 	return &KMSSign{
 		Prefix:          AlreadyEncryptPrefix + hex.EncodeToString([]byte(TceSecurity)),
 		Method:          hex.EncodeToString([]byte(method)),
@@ -108,5 +127,6 @@ func NewKMSSign(method, keyId, secretId, secretKey, KMSServer string) (*KMSSign,
 		KeyId:           keyId,
 		Client:          cli,
 		KMSServer:       KMSServer,
-	}, nil
+		invoker:         inv,
+	}
 }
@@ -1,11 +1,10 @@
 package tcesecurity
 
 import (
-	"crypto/tls"
+	"context"
 	"encoding/base64"
 	"encoding/hex"
 	"fmt"
-	"net/http"
 	"strings"
 
 	sm "git.code.oa.com/tce-config/tcestuary-go/v4/tcesecurity/tencentsm"
@@ -20,7 +19,12 @@ const (
 
 func init() {
 	f := func(opts CryptoOpts) (Crypto, error) {
-		return NewKMSSm2Crypto(opts.Method, opts.KeyId, opts.SecretId, opts.SecretKey, opts.KMSServer)
+		cli, err := NewKMSClient(opts.SecretId, opts.SecretKey, opts.KMSServer, opts.Transport)
+		if err != nil {
+			return nil, err
+		}
+		inv := newKMSInvoker(opts.KMSServer, opts.Retry, opts.Transport.Logger)
+		return newKMSSm2Crypto(opts.Method, opts.KeyId, opts.KMSServer, cli, inv), nil
 	}
 	registerCryptoFunc(KMSSm2Algorithm, f)
 }
@@ -32,6 +36,8 @@ type KMSSm2Crypto struct {
 	Version   string
 	KeyId     string
 	KMSServer string
+
+	invoker *kmsInvoker
 }
 
 // 生成摘要
@@ -47,6 +53,11 @@ func (c *KMSSm2Crypto) genDigest(body string) (string, error) {
 
 // Encrypt 加密
 func (c *KMSSm2Crypto) Encrypt(plaintext string) (string, error) {
+	return c.EncryptCtx(context.Background(), plaintext)
+}
+
+// EncryptCtx 加密, ctx 控制 KMS 调用(含重试)的超时、取消
+func (c *KMSSm2Crypto) EncryptCtx(ctx context.Context, plaintext string) (string, error) {
 	// 构造请求
 	req := kms.NewAsymmetricSm2EncryptRequest()
 	req.SetDomain(c.KMSServer)
@@ -60,7 +71,11 @@ func (c *KMSSm2Crypto) Encrypt(plaintext string) (string, error) {
 	)
 	// plaintext: 待加密数据，长度不能超过160字节
 	req.Plaintext = &newPlaintext
-	resp, err := c.Client.AsymmetricSm2Encrypt(req)
+	var resp *kms.AsymmetricSm2EncryptResponse
+	err = kmsInvokerOf(c.invoker, c.KMSServer).do(ctx, "AsymmetricSm2Encrypt", func() (err error) {
+		resp, err = c.Client.AsymmetricSm2Encrypt(req)
+		return err
+	})
 	if err != nil {
 		return "", err
 	}
@@ -70,6 +85,11 @@ func (c *KMSSm2Crypto) Encrypt(plaintext string) (string, error) {
 
 // Decrypt 解密
 func (c *KMSSm2Crypto) Decrypt(ciphertext string) (string, error) {
+	return c.DecryptCtx(context.Background(), ciphertext)
+}
+
+// DecryptCtx 解密, ctx 控制 KMS 调用(含重试)的超时、取消
+func (c *KMSSm2Crypto) DecryptCtx(ctx context.Context, ciphertext string) (string, error) {
 	// 1，如果解密数据前缀错误，直接返回
 	if !strings.HasPrefix(ciphertext, c.Prefix) {
 		return ciphertext, nil
@@ -89,7 +109,11 @@ func (c *KMSSm2Crypto) Decrypt(ciphertext string) (string, error) {
 	req.KeyId = &c.KeyId
 	req.Ciphertext = &realCiphertext
 
-	resp, err := c.Client.AsymmetricSm2Decrypt(req)
+	var resp *kms.AsymmetricSm2DecryptResponse
+	err := kmsInvokerOf(c.invoker, c.KMSServer).do(ctx, "AsymmetricSm2Decrypt", func() (err error) {
+		resp, err = c.Client.AsymmetricSm2Decrypt(req)
+		return err
+	})
 	if err != nil {
 		return "", err
 	}
@@ -120,22 +144,26 @@ func (c *KMSSm2Crypto) Decrypt(ciphertext string) (string, error) {
 	return string(originPlaintext), nil
 }
 
-// NewKMSSm2Crypto
-func NewKMSSm2Crypto(method, keyId, secretId, secretKey, KMSServer string) (*KMSSm2Crypto, error) {
-	if KMSServer == "" {
-		return nil, fmt.Errorf("invalid kmsServer config")
-	}
-	cli, err := kms.NewClientWithSecretId(secretId, secretKey, DefaultRegion)
+// EncryptWithContext KMS 接口无 EncryptionContext 参数, 不支持上下文, 上下文为空时等价于 Encrypt
+func (c *KMSSm2Crypto) EncryptWithContext(plaintext string, aad []byte) (string, error) {
+	return encryptWithoutContext(c, plaintext, aad)
+}
+
+// DecryptWithContext KMS 接口无 EncryptionContext 参数, 不支持上下文, 上下文为空时等价于 Decrypt
+func (c *KMSSm2Crypto) DecryptWithContext(ciphertext string, aad []byte) (string, error) {
+	return decryptWithoutContext(c, ciphertext, aad)
+}
 
+// NewKMSSm2Crypto 校验 KMS 服务端证书, 自定义传输参数参考 NewKMSClient
+func NewKMSSm2Crypto(method, keyId, secretId, secretKey, KMSServer string) (*KMSSm2Crypto, error) {
+	cli, err := NewKMSClient(secretId, secretKey, KMSServer, KMSTransportOpts{})
 	if err != nil {
 		return nil, err
 	}
-	// config transport
-	transport := &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-	}
-	cli.WithHttpTransport(transport)
+	return newKMSSm2Crypto(method, keyId, KMSServer, cli, newKMSInvoker(KMSServer, KMSRetryOpts{}, nil)), nil
+}
 
+func newKMSSm2Crypto(method, keyId, KMSServer string, cli *kms.Client, inv *kmsInvoker) *KMSSm2Crypto {
 	return &KMSSm2Crypto{
 		Prefix:    AlreadyEncryptPrefix + hex.EncodeToString([]byte(TceSecurity)),
 		Method:    hex.EncodeToString([]byte(method)),
@@ -143,5 +171,6 @@ func NewKMSSm2Crypto(method, keyId, secretId, secretKey, KMSServer string) (*KMS
 		KeyId:     keyId,
 		Client:    cli,
 		KMSServer: KMSServer,
-	}, nil
+		invoker:   inv,
+	}
 }
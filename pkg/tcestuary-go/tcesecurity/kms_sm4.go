@@ -1,11 +1,10 @@
 package tcesecurity
 
 import (
-	"crypto/tls"
+	"context"
 	"encoding/base64"
 	"encoding/hex"
 	"fmt"
-	"net/http"
 	"strings"
 
 	kms "git.code.oa.com/tce-config/tcestuary-go/v4/tcesecurity/tseckms/v20190118"
@@ -18,7 +17,12 @@ const (
 
 func init() {
 	f := func(opts CryptoOpts) (Crypto, error) {
-		return NewKMSSm4Crypto(opts.Method, opts.KeyId, opts.SecretId, opts.SecretKey, opts.KMSServer)
+		cli, err := NewKMSClient(opts.SecretId, opts.SecretKey, opts.KMSServer, opts.Transport)
+		if err != nil {
+			return nil, err
+		}
+		inv := newKMSInvoker(opts.KMSServer, opts.Retry, opts.Transport.Logger)
+		return newKMSSm4Crypto(opts.Method, opts.KeyId, opts.KMSServer, cli, inv), nil
 	}
 	registerCryptoFunc(KMSSm4Algorithm, f)
 }
@@ -31,10 +35,17 @@ type KMSSm4Crypto struct {
 	Version         string
 	KeyId           string
 	KMSServer       string
+
+	invoker *kmsInvoker
 }
 
 // Encrypt 加密
 func (c *KMSSm4Crypto) Encrypt(plaintext string) (string, error) {
+	return c.EncryptCtx(context.Background(), plaintext)
+}
+
+// EncryptCtx 加密, ctx 控制 KMS 调用(含重试)的超时、取消
+func (c *KMSSm4Crypto) EncryptCtx(ctx context.Context, plaintext string) (string, error) {
 	// 构造请求
 	req := kms.NewEncryptRequest()
 	req.SetDomain(c.KMSServer)
@@ -42,7 +53,11 @@ func (c *KMSSm4Crypto) Encrypt(plaintext string) (string, error) {
 	req.Algorithm = &c.RemoteAlgorithm
 	newPlaintext := base64.StdEncoding.EncodeToString([]byte(plaintext))
 	req.Plaintext = &newPlaintext
-	resp, err := c.Client.Encrypt(req)
+	var resp *kms.EncryptResponse
+	err := kmsInvokerOf(c.invoker, c.KMSServer).do(ctx, "Encrypt", func() (err error) {
+		resp, err = c.Client.Encrypt(req)
+		return err
+	})
 	if err != nil {
 		return "", err
 	}
@@ -52,24 +67,29 @@ func (c *KMSSm4Crypto) Encrypt(plaintext string) (string, error) {
 
 // Decrypt 解密
 func (c *KMSSm4Crypto) Decrypt(ciphertext string) (string, error) {
+	return c.DecryptCtx(context.Background(), ciphertext)
+}
+
+// DecryptCtx 解密, ctx 控制 KMS 调用(含重试)的超时、取消
+func (c *KMSSm4Crypto) DecryptCtx(ctx context.Context, ciphertext string) (string, error) {
 	// 1，如果解密数据前缀错误，直接返回
 	if !strings.HasPrefix(ciphertext, c.Prefix) {
 		return ciphertext, nil
 	}
 	// 2，验证method
-	items := strings.Split(ciphertext, ":")
-	if len(items) != 4 {
-		return "", fmt.Errorf("invalid ciphertext-data format")
-	}
-	if items[1] != c.Method {
-		return "", fmt.Errorf("invalid entrypted-data method")
+	realCiphertext, err := c.parse(ciphertext)
+	if err != nil {
+		return "", err
 	}
-	realCiphertext := items[3]
 	// 3，构造请求
 	req := kms.NewDecryptRequest()
 	req.SetDomain(c.KMSServer)
 	req.CiphertextBlob = &realCiphertext
-	resp, err := c.Client.Decrypt(req)
+	var resp *kms.DecryptResponse
+	err = kmsInvokerOf(c.invoker, c.KMSServer).do(ctx, "Decrypt", func() (err error) {
+		resp, err = c.Client.Decrypt(req)
+		return err
+	})
 	if err != nil {
 		return "", err
 	}
@@ -83,21 +103,64 @@ func (c *KMSSm4Crypto) Decrypt(ciphertext string) (string, error) {
 	return string(oriPlaintext), nil
 }
 
-// NewKMSSm4Crypto
-func NewKMSSm4Crypto(method, keyId, secretId, secretKey, KMSServer string) (*KMSSm4Crypto, error) {
-	if KMSServer == "" {
-		return nil, fmt.Errorf("invalid kmsServer config")
+// ReEncrypt 调用 KMS ReEncrypt 接口, 将密文重新加密到 keyId 对应的 CMK, 明文不离开 KMS
+func (c *KMSSm4Crypto) ReEncrypt(ciphertext, keyId string) (string, error) {
+	return c.ReEncryptCtx(context.Background(), ciphertext, keyId)
+}
+
+// ReEncryptCtx 重新加密, ctx 控制 KMS 调用(含重试)的超时、取消
+func (c *KMSSm4Crypto) ReEncryptCtx(ctx context.Context, ciphertext, keyId string) (string, error) {
+	realCiphertext, err := c.parse(ciphertext)
+	if err != nil {
+		return "", err
+	}
+	req := kms.NewReEncryptRequest()
+	req.SetDomain(c.KMSServer)
+	req.CiphertextBlob = &realCiphertext
+	req.DestinationKeyId = &keyId
+	var resp *kms.ReEncryptResponse
+	err = kmsInvokerOf(c.invoker, c.KMSServer).do(ctx, "ReEncrypt", func() (err error) {
+		resp, err = c.Client.ReEncrypt(req)
+		return err
+	})
+	if err != nil {
+		return "", err
 	}
-	cli, err := kms.NewClientWithSecretId(secretId, secretKey, DefaultRegion)
+	return c.Prefix + ":" + c.Method + ":" + c.Version + ":" + *resp.Response.CiphertextBlob, nil
+}
 
+// parse 校验密文格式, 返回 KMS 密文
+func (c *KMSSm4Crypto) parse(ciphertext string) (string, error) {
+	items := strings.Split(ciphertext, ":")
+	if len(items) != 4 {
+		return "", fmt.Errorf("invalid ciphertext-data format")
+	}
+	if items[1] != c.Method {
+		return "", fmt.Errorf("invalid entrypted-data method")
+	}
+	return items[3], nil
+}
+
+// EncryptWithContext KMS 接口无 EncryptionContext 参数, 不支持上下文, 上下文为空时等价于 Encrypt
+func (c *KMSSm4Crypto) EncryptWithContext(plaintext string, aad []byte) (string, error) {
+	return encryptWithoutContext(c, plaintext, aad)
+}
+
+// DecryptWithContext KMS 接口无 EncryptionContext 参数, 不支持上下文, 上下文为空时等价于 Decrypt
+func (c *KMSSm4Crypto) DecryptWithContext(ciphertext string, aad []byte) (string, error) {
+	return decryptWithoutContext(c, ciphertext, aad)
+}
+
+// NewKMSSm4Crypto 校验 KMS 服务端证书, 自定义传输参数参考 NewKMSClient
+func NewKMSSm4Crypto(method, keyId, secretId, secretKey, KMSServer string) (*KMSSm4Crypto, error) {
+	cli, err := NewKMSClient(secretId, secretKey, KMSServer, KMSTransportOpts{})
 	if err != nil {
 		return nil, err
 	}
-	// config transport
-	transport := &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-	}
-	cli.WithHttpTransport(transport)
+	return newKMSSm4Crypto(method, keyId, KMSServer, cli, newKMSInvoker(KMSServer, KMSRetryOpts{}, nil)), nil
+}
+
+func newKMSSm4Crypto(method, keyId, KMSServer string, cli *kms.Client, inv *kmsInvoker) *KMSSm4Crypto {
 	return &KMSSm4Crypto{
 		Prefix:          AlreadyEncryptPrefix + hex.EncodeToString([]byte(TceSecurity)),
 		RemoteAlgorithm: RemoteAlgorithm,
@@ -106,5 +169,6 @@ func NewKMSSm4Crypto(method, keyId, secretId, secretKey, KMSServer string) (*KMS
 		KeyId:           keyId,
 		Client:          cli,
 		KMSServer:       KMSServer,
-	}, nil
+		invoker:         inv,
+	}
 }
@@ -0,0 +1,120 @@
+package tcesecurity
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"git.code.oa.com/tce-config/tcestuary-go/v4/logger"
+	kms "git.code.oa.com/tce-config/tcestuary-go/v4/tcesecurity/tseckms/v20190118"
+	"github.com/tencentyun/tcecloud-sdk-go/tcecloud/common"
+	"github.com/tencentyun/tcecloud-sdk-go/tcecloud/common/profile"
+)
+
+const (
+	DefaultKMSTimeout     = 60 * time.Second
+	DefaultKMSDialTimeout = 30 * time.Second
+)
+
+// KMSTransportOpts KMS 客户端传输参数. 零值: 校验服务端证书, 使用系统 CA, 不使用代理
+type KMSTransportOpts struct {
+	CAFile     string // CA 证书(PEM)路径
+	CACert     string // CA 证书 PEM 内容, 与 CAFile 同时配置时均生效
+	CertFile   string // 客户端证书路径, 双向认证时配置
+	KeyFile    string // 客户端私钥路径
+	ServerName string // 校验证书使用的服务端名称, 为空时取 KMSServer 的主机名
+	// InsecureSkipVerify 不校验服务端证书, 存在中间人攻击风险, 仅用于测试环境
+	InsecureSkipVerify bool
+	Timeout            time.Duration // 请求超时, 0 使用 DefaultKMSTimeout
+	DialTimeout        time.Duration // 建立连接超时, 0 使用 DefaultKMSDialTimeout
+	Proxy              string        // 代理地址, 如 http://proxy:8080
+	Logger             logger.Logger // 输出告警日志, 为空时使用全局日志接口
+}
+
+// NewKMSTransport 根据传输参数创建 http.Transport
+func NewKMSTransport(KMSServer string, opts KMSTransportOpts) (*http.Transport, error) {
+	tlsConf := &tls.Config{ServerName: opts.ServerName}
+	if opts.InsecureSkipVerify {
+		log := opts.Logger
+		if log == nil {
+			log = logger.Global()
+		}
+		log.Printf("[WARN] kms server %s: tls certificate verification is disabled (insecure_skip_verify), "+
+			"do not use in production", KMSServer)
+		tlsConf.InsecureSkipVerify = true
+	}
+
+	if opts.CAFile != "" || opts.CACert != "" {
+		pool := x509.NewCertPool()
+		if opts.CAFile != "" {
+			pem, err := ioutil.ReadFile(opts.CAFile)
+			if err != nil {
+				return nil, fmt.Errorf("read kms ca file: %s", err)
+			}
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("invalid kms ca file: %s", opts.CAFile)
+			}
+		}
+		if opts.CACert != "" && !pool.AppendCertsFromPEM([]byte(opts.CACert)) {
+			return nil, fmt.Errorf("invalid kms ca cert")
+		}
+		tlsConf.RootCAs = pool
+	}
+
+	if opts.CertFile != "" || opts.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(opts.CertFile, opts.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load kms client cert: %s", err)
+		}
+		tlsConf.Certificates = []tls.Certificate{cert}
+	}
+
+	dialTimeout := opts.DialTimeout
+	if dialTimeout <= 0 {
+		dialTimeout = DefaultKMSDialTimeout
+	}
+	transport := &http.Transport{
+		TLSClientConfig:     tlsConf,
+		DialContext:         (&net.Dialer{Timeout: dialTimeout, KeepAlive: 30 * time.Second}).DialContext,
+		TLSHandshakeTimeout: dialTimeout,
+		MaxIdleConnsPerHost: 16,
+		IdleConnTimeout:     90 * time.Second,
+	}
+	if opts.Proxy != "" {
+		proxy, err := url.Parse(opts.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid kms proxy: %s", err)
+		}
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+	return transport, nil
+}
+
+// NewKMSClient 创建 KMS 客户端
+func NewKMSClient(secretId, secretKey, KMSServer string, opts KMSTransportOpts) (*kms.Client, error) {
+	if KMSServer == "" {
+		return nil, fmt.Errorf("invalid kmsServer config")
+	}
+	transport, err := NewKMSTransport(KMSServer, opts)
+	if err != nil {
+		return nil, err
+	}
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = DefaultKMSTimeout
+	}
+	cpf := profile.NewClientProfile()
+	// SDK 超时单位为秒, 向上取整
+	cpf.HttpProfile.ReqTimeout = int((timeout + time.Second - 1) / time.Second)
+	cli, err := kms.NewClient(common.NewCredential(secretId, secretKey), DefaultRegion, cpf)
+	if err != nil {
+		return nil, err
+	}
+	cli.WithHttpTransport(transport)
+	return cli, nil
+}
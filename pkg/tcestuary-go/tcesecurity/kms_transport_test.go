@@ -0,0 +1,69 @@
+package tcesecurity
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"testing"
+
+	"git.code.oa.com/tce-config/tcestuary-go/v4/tcesecurity/kmstest"
+	"github.com/stretchr/testify/assert"
+)
+
+type captureLogger struct {
+	lines []string
+}
+
+func (l *captureLogger) Printf(format string, args ...interface{}) {
+	l.lines = append(l.lines, fmt.Sprintf(format, args...))
+}
+
+func TestKMSTransport(t *testing.T) {
+	srv := kmstest.NewUnstartedServer()
+	// 忽略证书校验失败的握手日志
+	srv.Config.ErrorLog = log.New(ioutil.Discard, "", 0)
+	srv.StartTLS()
+	defer srv.Close()
+	host, caCert := srv.Host(), srv.CACert()
+
+	encrypt := func(opts KMSTransportOpts) error {
+		cli, err := NewKMSClient(srv.SecretId, srv.SecretKey, host, opts)
+		if err != nil {
+			return err
+		}
+		_, err = newKMSSm4Crypto(KMSSm4Algorithm, "key-1", host, cli, nil).Encrypt("mysql_pass")
+		return err
+	}
+
+	t.Run("verify-by-default", func(t *testing.T) {
+		err := encrypt(KMSTransportOpts{})
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "certificate")
+	})
+
+	t.Run("ca-cert", func(t *testing.T) {
+		assert.NoError(t, encrypt(KMSTransportOpts{CACert: caCert}))
+		// 证书与 server name 不匹配
+		assert.Error(t, encrypt(KMSTransportOpts{CACert: caCert, ServerName: "kms.local"}))
+	})
+
+	t.Run("insecure-skip-verify", func(t *testing.T) {
+		logs := &captureLogger{}
+		assert.NoError(t, encrypt(KMSTransportOpts{InsecureSkipVerify: true, Logger: logs}))
+		assert.Len(t, logs.lines, 1)
+		assert.Contains(t, logs.lines[0], "insecure_skip_verify")
+	})
+
+	t.Run("invalid-config", func(t *testing.T) {
+		_, err := NewKMSClient("id", "key", host, KMSTransportOpts{CACert: "invalid"})
+		assert.Error(t, err)
+		_, err = NewKMSClient("id", "key", host, KMSTransportOpts{CAFile: "/not/exist.pem"})
+		assert.Error(t, err)
+		_, err = NewKMSClient("id", "key", host, KMSTransportOpts{CertFile: "/not/exist.pem", KeyFile: "/not/exist.key"})
+		assert.Error(t, err)
+		_, err = NewKMSClient("id", "key", host, KMSTransportOpts{Proxy: "://bad"})
+		assert.Error(t, err)
+		_, err = NewKMSClient("id", "key", "", KMSTransportOpts{})
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,316 @@
+package kmstest
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+
+	"git.code.oa.com/tce-config/tcestuary-go/v4/tcesecurity/gmsm"
+	kms "git.code.oa.com/tce-config/tcestuary-go/v4/tcesecurity/tseckms/v20190118"
+)
+
+// 对称密文(CiphertextBlob): base64(len(keyId)(1) | keyId | nonce(12) | SM4-GCM 密文), keyId 作为 AAD.
+// SM2 密文: base64(C1C3C2 ASN.1); 签名: base64(ASN.1 SEQUENCE{R, S}), 用户 ID 为国标默认值
+
+const (
+	sm2SignAlgorithm = "SM2DSA"
+	rawMessageType   = "RAW"
+	maxPlaintextSize = 4096
+	maxDataKeySize   = 1024
+)
+
+type key struct {
+	id       string
+	aead     cipher.AEAD
+	sm2      *gmsm.PrivateKey
+	disabled bool
+}
+
+func newKey(id string) (*key, error) {
+	sm4Key := make([]byte, gmsm.SM4KeySize)
+	if _, err := rand.Read(sm4Key); err != nil {
+		return nil, err
+	}
+	block, err := gmsm.NewSM4Cipher(sm4Key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	priv, err := gmsm.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return &key{id: id, aead: aead, sm2: priv}, nil
+}
+
+// keyLocked 返回密钥, create 为 true 时不存在则创建. 调用方持有锁
+func (s *Server) keyLocked(keyId string, create bool) (*key, error) {
+	if keyId == "" {
+		return nil, errorf("MissingParameter", "KeyId is required")
+	}
+	k, ok := s.keys[keyId]
+	if !ok {
+		if !create {
+			return nil, errorf("ResourceUnavailable.CmkNotFound", "key not found: %s", keyId)
+		}
+		var err error
+		if k, err = newKey(keyId); err != nil {
+			return nil, err
+		}
+		s.keys[keyId] = k
+	}
+	return k, nil
+}
+
+// key 返回可用的密钥, 不存在时创建
+func (s *Server) key(keyId string, create bool) (*key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	k, err := s.keyLocked(keyId, create)
+	if err != nil {
+		return nil, err
+	}
+	if k.disabled {
+		return nil, errorf("ResourceUnavailable.CmkDisabled", "key is disabled: %s", keyId)
+	}
+	return k, nil
+}
+
+func (s *Server) seal(k *key, plaintext []byte) (string, error) {
+	nonce := make([]byte, k.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	out := append([]byte{byte(len(k.id))}, k.id...)
+	out = append(out, nonce...)
+	out = k.aead.Seal(out, nonce, plaintext, []byte(k.id))
+	return base64.StdEncoding.EncodeToString(out), nil
+}
+
+// open 解密对称密文, 返回密文对应的密钥
+func (s *Server) open(blob string) (*key, []byte, error) {
+	invalid := errorf("InvalidParameterValue.InvalidCiphertext", "invalid ciphertext")
+	raw, err := base64.StdEncoding.DecodeString(blob)
+	if err != nil || len(raw) == 0 || len(raw) < 1+int(raw[0]) {
+		return nil, nil, invalid
+	}
+	k, err := s.key(string(raw[1:1+int(raw[0])]), false)
+	if err != nil {
+		return nil, nil, err
+	}
+	data := raw[1+int(raw[0]):]
+	if len(data) < k.aead.NonceSize()+k.aead.Overhead() {
+		return nil, nil, invalid
+	}
+	size := k.aead.NonceSize()
+	plaintext, err := k.aead.Open(nil, data[:size], data[size:], []byte(k.id))
+	if err != nil {
+		return nil, nil, invalid
+	}
+	return k, plaintext, nil
+}
+
+func decodePlaintext(s *string, max int) ([]byte, error) {
+	if s == nil || *s == "" {
+		return nil, errorf("MissingParameter", "Plaintext is required")
+	}
+	b, err := base64.StdEncoding.DecodeString(*s)
+	if err != nil {
+		return nil, errorf("InvalidParameterValue", "Plaintext is not base64 encoded")
+	}
+	if len(b) > max {
+		return nil, errorf("InvalidParameterValue", "Plaintext is too long")
+	}
+	return b, nil
+}
+
+func str(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// handle 处理 KMS 接口请求, 返回 Response 中的字段
+func (s *Server) handle(action string, body []byte) (map[string]interface{}, error) {
+	unmarshal := func(req interface{}) error {
+		if err := json.Unmarshal(body, req); err != nil {
+			return errorf("InvalidParameter", "invalid request body: %s", err)
+		}
+		return nil
+	}
+	b64 := base64.StdEncoding.EncodeToString
+
+	switch action {
+	case "Encrypt":
+		var req kms.EncryptRequest
+		if err := unmarshal(&req); err != nil {
+			return nil, err
+		}
+		k, err := s.key(str(req.KeyId), true)
+		if err != nil {
+			return nil, err
+		}
+		plaintext, err := decodePlaintext(req.Plaintext, maxPlaintextSize)
+		if err != nil {
+			return nil, err
+		}
+		blob, err := s.seal(k, plaintext)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"KeyId": k.id, "CiphertextBlob": blob}, nil
+
+	case "Decrypt":
+		var req kms.DecryptRequest
+		if err := unmarshal(&req); err != nil {
+			return nil, err
+		}
+		k, plaintext, err := s.open(str(req.CiphertextBlob))
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"KeyId": k.id, "Plaintext": b64(plaintext)}, nil
+
+	case "ReEncrypt":
+		var req kms.ReEncryptRequest
+		if err := unmarshal(&req); err != nil {
+			return nil, err
+		}
+		src, plaintext, err := s.open(str(req.CiphertextBlob))
+		if err != nil {
+			return nil, err
+		}
+		dst := src
+		if id := str(req.DestinationKeyId); id != "" {
+			if dst, err = s.key(id, true); err != nil {
+				return nil, err
+			}
+		}
+		blob, err := s.seal(dst, plaintext)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"KeyId": dst.id, "SourceKeyId": src.id, "CiphertextBlob": blob}, nil
+
+	case "GenerateDataKey":
+		var req kms.GenerateDataKeyRequest
+		if err := unmarshal(&req); err != nil {
+			return nil, err
+		}
+		k, err := s.key(str(req.KeyId), true)
+		if err != nil {
+			return nil, err
+		}
+		var size int
+		switch {
+		case str(req.KeySpec) == "AES_256":
+			size = 32
+		case str(req.KeySpec) == "AES_128":
+			size = 16
+		case req.NumberOfBytes != nil && *req.NumberOfBytes > 0 && *req.NumberOfBytes <= maxDataKeySize:
+			size = int(*req.NumberOfBytes)
+		default:
+			return nil, errorf("InvalidParameterValue", "invalid KeySpec or NumberOfBytes")
+		}
+		plaintext := make([]byte, size)
+		if _, err := rand.Read(plaintext); err != nil {
+			return nil, err
+		}
+		blob, err := s.seal(k, plaintext)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"KeyId": k.id, "Plaintext": b64(plaintext), "CiphertextBlob": blob}, nil
+
+	case "AsymmetricSm2Encrypt":
+		var req kms.AsymmetricSm2EncryptRequest
+		if err := unmarshal(&req); err != nil {
+			return nil, err
+		}
+		k, err := s.key(str(req.KeyId), true)
+		if err != nil {
+			return nil, err
+		}
+		plaintext, err := decodePlaintext(req.Plaintext, maxPlaintextSize)
+		if err != nil {
+			return nil, err
+		}
+		ciphertext, err := gmsm.Encrypt(rand.Reader, &k.sm2.PublicKey, plaintext, gmsm.C1C3C2ASN1)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"KeyId": k.id, "Ciphertext": b64(ciphertext)}, nil
+
+	case "AsymmetricSm2Decrypt":
+		var req kms.AsymmetricSm2DecryptRequest
+		if err := unmarshal(&req); err != nil {
+			return nil, err
+		}
+		k, err := s.key(str(req.KeyId), false)
+		if err != nil {
+			return nil, err
+		}
+		ciphertext, err := base64.StdEncoding.DecodeString(str(req.Ciphertext))
+		if err != nil {
+			return nil, errorf("InvalidParameterValue.InvalidCiphertext", "invalid ciphertext")
+		}
+		plaintext, err := gmsm.Decrypt(k.sm2, ciphertext, gmsm.C1C3C2ASN1)
+		if err != nil {
+			return nil, errorf("InvalidParameterValue.InvalidCiphertext", "invalid ciphertext")
+		}
+		return map[string]interface{}{"KeyId": k.id, "Plaintext": b64(plaintext)}, nil
+
+	case "SignByAsymmetricKey":
+		var req kms.SignByAsymmetricKeyRequest
+		if err := unmarshal(&req); err != nil {
+			return nil, err
+		}
+		k, msg, err := s.signParams(req.KeyId, req.Algorithm, req.MessageType, req.Message, true)
+		if err != nil {
+			return nil, err
+		}
+		sig, err := gmsm.SignASN1(rand.Reader, k.sm2, []byte(gmsm.DefaultID), msg)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"Signature": b64(sig)}, nil
+
+	case "VerifyByAsymmetricKey":
+		var req kms.VerifyByAsymmetricKeyRequest
+		if err := unmarshal(&req); err != nil {
+			return nil, err
+		}
+		k, msg, err := s.signParams(req.KeyId, req.Algorithm, req.MessageType, req.Message, false)
+		if err != nil {
+			return nil, err
+		}
+		sig, err := base64.StdEncoding.DecodeString(str(req.SignatureValue))
+		valid := err == nil && gmsm.VerifyASN1(&k.sm2.PublicKey, []byte(gmsm.DefaultID), msg, sig)
+		return map[string]interface{}{"SignatureValid": valid}, nil
+	}
+	return nil, errorf("InvalidAction", "unsupported action: %s", action)
+}
+
+// signParams 校验签名、验签参数, 仅支持 SM2DSA 算法及 RAW 消息类型
+func (s *Server) signParams(keyId, alg, messageType, message *string, create bool) (*key, []byte, error) {
+	if str(alg) != sm2SignAlgorithm {
+		return nil, nil, errorf("InvalidParameterValue", "unsupported algorithm: %s", str(alg))
+	}
+	if t := str(messageType); t != "" && t != rawMessageType {
+		return nil, nil, errorf("InvalidParameterValue", "unsupported message type: %s", t)
+	}
+	k, err := s.key(str(keyId), create)
+	if err != nil {
+		return nil, nil, err
+	}
+	msg, err := base64.StdEncoding.DecodeString(str(message))
+	if err != nil {
+		return nil, nil, errorf("InvalidParameterValue", "Message is not base64 encoded")
+	}
+	return k, msg, nil
+}
@@ -0,0 +1,263 @@
+// Package kmstest 进程内 KMS 模拟服务, 用于 kms-* 算法的单元测试及离线开发.
+//
+// 服务端校验 TC3-HMAC-SHA256 签名, 支持 Encrypt / Decrypt / ReEncrypt / GenerateDataKey /
+// AsymmetricSm2Encrypt / AsymmetricSm2Decrypt / SignByAsymmetricKey / VerifyByAsymmetricKey 接口.
+// 密钥在首次使用时自动创建, 对称密钥使用 SM4-GCM, 非对称密钥使用 SM2, 密钥只保存在内存中.
+//
+//	srv := kmstest.NewServer()
+//	defer srv.Close()
+//	// kms_server 配置为 srv.Host(), kms_transport.ca_cert 配置为 srv.CACert(),
+//	// secret_id / secret_key 配置为 srv.SecretId / srv.SecretKey
+package kmstest
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	DefaultSecretId  = "AKIDkmstest"
+	DefaultSecretKey = "kmstest"
+
+	service    = "tseckms"
+	apiVersion = "2019-01-18"
+	algorithm  = "TC3-HMAC-SHA256"
+)
+
+// Server KMS 模拟服务
+type Server struct {
+	*httptest.Server
+	SecretId  string // 校验请求签名使用的密钥, 默认 DefaultSecretId / DefaultSecretKey
+	SecretKey string
+
+	mu        sync.Mutex
+	keys      map[string]*key
+	faults    []*fault
+	calls     map[string]int
+	requestID int64
+}
+
+// fault 故障注入
+type fault struct {
+	action string // 为空时匹配所有接口
+	times  int
+	code   string // 为空时返回 http 503
+}
+
+// Error KMS 错误响应
+type Error struct {
+	Code    string
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Code + ": " + e.Message
+}
+
+func errorf(code, format string, args ...interface{}) *Error {
+	return &Error{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// NewServer 创建并启动 https 服务, 使用 httptest 自签名证书
+func NewServer() *Server {
+	s := NewUnstartedServer()
+	s.StartTLS()
+	return s
+}
+
+// NewUnstartedServer 创建服务但不启动, 调用方可以修改 s.Server 的配置后调用 StartTLS / Start
+func NewUnstartedServer() *Server {
+	s := &Server{
+		SecretId:  DefaultSecretId,
+		SecretKey: DefaultSecretKey,
+		keys:      make(map[string]*key),
+		calls:     make(map[string]int),
+	}
+	s.Server = httptest.NewUnstartedServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// Host 服务地址(host:port), 用作 kms_server 配置
+func (s *Server) Host() string {
+	u := s.URL
+	if i := strings.Index(u, "://"); i >= 0 {
+		u = u[i+3:]
+	}
+	return u
+}
+
+// CACert 服务端证书 PEM, 用作 kms_transport.ca_cert 配置
+func (s *Server) CACert() string {
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: s.Certificate().Raw}))
+}
+
+// Fail 之后 times 次 action 接口调用返回错误码 code. action 为空时匹配所有接口; code 为空时返回 http 503
+func (s *Server) Fail(action string, times int, code string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = append(s.faults, &fault{action: action, times: times, code: code})
+}
+
+// Calls 返回 action 接口的调用次数(含失败), action 为空时返回所有接口的调用次数
+func (s *Server) Calls(action string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if action != "" {
+		return s.calls[action]
+	}
+	n := 0
+	for _, c := range s.calls {
+		n += c
+	}
+	return n
+}
+
+// DisableKey 禁用密钥, 之后使用该密钥的请求返回 ResourceUnavailable.CmkDisabled
+func (s *Server) DisableKey(keyId string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if k, err := s.keyLocked(keyId, true); err == nil {
+		k.disabled = true
+	}
+}
+
+// EnableKey 启用密钥
+func (s *Server) EnableKey(keyId string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if k, ok := s.keys[keyId]; ok {
+		k.disabled = false
+	}
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	requestID := fmt.Sprintf("kmstest-%d", atomic.AddInt64(&s.requestID, 1))
+	action := r.Header.Get("X-TC-Action")
+
+	s.mu.Lock()
+	s.calls[action]++
+	f := s.nextFaultLocked(action)
+	s.mu.Unlock()
+	if f != nil && f.code == "" {
+		http.Error(w, "kmstest: injected failure", http.StatusServiceUnavailable)
+		return
+	}
+
+	var resp map[string]interface{}
+	body, err := ioutil.ReadAll(r.Body)
+	switch {
+	case err != nil:
+		err = errorf("InternalError", "read body: %s", err)
+	case f != nil:
+		err = errorf(f.code, "kmstest: injected failure")
+	default:
+		if err = s.verify(r, body); err == nil {
+			resp, err = s.handle(action, body)
+		}
+	}
+	if err != nil {
+		e, ok := err.(*Error)
+		if !ok {
+			e = errorf("InternalError", "%s", err)
+		}
+		resp = map[string]interface{}{"Error": e}
+	}
+	resp["RequestId"] = requestID
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"Response": resp})
+}
+
+// nextFaultLocked 返回匹配的故障并扣减次数, 调用方持有锁
+func (s *Server) nextFaultLocked(action string) *fault {
+	for i, f := range s.faults {
+		if f.action != "" && f.action != action {
+			continue
+		}
+		f.times--
+		if f.times <= 0 {
+			s.faults = append(s.faults[:i], s.faults[i+1:]...)
+		}
+		return f
+	}
+	return nil
+}
+
+// verify 校验 TC3-HMAC-SHA256 签名, 与 tcecloud-sdk-go 的 sendWithSignatureV3 对应
+func (s *Server) verify(r *http.Request, body []byte) error {
+	if r.Method != http.MethodPost {
+		return errorf("InvalidParameter", "unsupported http method: %s", r.Method)
+	}
+	if v := r.Header.Get("X-TC-Version"); v != apiVersion {
+		return errorf("InvalidParameter", "unsupported version: %s", v)
+	}
+
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, algorithm+" ") {
+		return errorf("AuthFailure.SignatureFailure", "invalid authorization")
+	}
+	fields := make(map[string]string)
+	for _, item := range strings.Split(strings.TrimPrefix(auth, algorithm+" "), ", ") {
+		kv := strings.SplitN(item, "=", 2)
+		if len(kv) == 2 {
+			fields[kv[0]] = kv[1]
+		}
+	}
+	credential := strings.Split(fields["Credential"], "/")
+	if len(credential) != 4 || credential[2] != service || credential[3] != "tc3_request" {
+		return errorf("AuthFailure.SignatureFailure", "invalid credential")
+	}
+	if credential[0] != s.SecretId {
+		return errorf("AuthFailure.SecretIdNotFound", "secret id not found: %s", credential[0])
+	}
+
+	timestamp := r.Header.Get("X-TC-Timestamp")
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return errorf("AuthFailure.InvalidAuthorization", "invalid timestamp")
+	}
+	date := time.Unix(ts, 0).UTC().Format("2006-01-02")
+	if credential[1] != date {
+		return errorf("AuthFailure.SignatureFailure", "credential date mismatch")
+	}
+
+	payload := sha256hex(body)
+	if r.Header.Get("X-TC-Content-SHA256") == "UNSIGNED-PAYLOAD" {
+		payload = sha256hex([]byte("UNSIGNED-PAYLOAD"))
+	}
+	canonicalRequest := fmt.Sprintf("%s\n%s\n%s\ncontent-type:%s\nhost:%s\n\n%s\n%s",
+		r.Method, "/", "", r.Header.Get("Content-Type"), r.Host, fields["SignedHeaders"], payload)
+	scope := date + "/" + service + "/tc3_request"
+	string2sign := fmt.Sprintf("%s\n%s\n%s\n%s", algorithm, timestamp, scope, sha256hex([]byte(canonicalRequest)))
+
+	secretDate := hmacsha256([]byte("TC3"+s.SecretKey), date)
+	secretService := hmacsha256(secretDate, service)
+	secretSigning := hmacsha256(secretService, "tc3_request")
+	signature := hex.EncodeToString(hmacsha256(secretSigning, string2sign))
+	if !hmac.Equal([]byte(signature), []byte(fields["Signature"])) {
+		return errorf("AuthFailure.SignatureFailure", "signature mismatch")
+	}
+	return nil
+}
+
+func sha256hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacsha256(key []byte, s string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(s))
+	return h.Sum(nil)
+}
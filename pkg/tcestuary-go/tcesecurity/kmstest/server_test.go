@@ -0,0 +1,161 @@
+package kmstest_test
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"git.code.oa.com/tce-config/tcestuary-go/v4/tcesecurity"
+	"git.code.oa.com/tce-config/tcestuary-go/v4/tcesecurity/kmstest"
+	kms "git.code.oa.com/tce-config/tcestuary-go/v4/tcesecurity/tseckms/v20190118"
+	"github.com/stretchr/testify/assert"
+	sdkerrors "github.com/tencentyun/tcecloud-sdk-go/tcecloud/common/errors"
+)
+
+func newClient(t *testing.T, srv *kmstest.Server, secretKey string) *kms.Client {
+	cli, err := tcesecurity.NewKMSClient(srv.SecretId, secretKey, srv.Host(),
+		tcesecurity.KMSTransportOpts{CACert: srv.CACert()})
+	assert.NoError(t, err)
+	return cli
+}
+
+func errorCode(err error) string {
+	if e, ok := err.(*sdkerrors.TceCloudSDKError); ok {
+		return e.Code
+	}
+	return ""
+}
+
+func TestServerSymmetric(t *testing.T) {
+	srv := kmstest.NewServer()
+	defer srv.Close()
+	cli := newClient(t, srv, srv.SecretKey)
+	str := func(s string) *string { return &s }
+
+	encReq := kms.NewEncryptRequest()
+	encReq.SetDomain(srv.Host())
+	encReq.KeyId = str("key-1")
+	encReq.Plaintext = str(base64.StdEncoding.EncodeToString([]byte("mysql_pass")))
+	encResp, err := cli.Encrypt(encReq)
+	assert.NoError(t, err)
+
+	decReq := kms.NewDecryptRequest()
+	decReq.SetDomain(srv.Host())
+	decReq.CiphertextBlob = encResp.Response.CiphertextBlob
+	decResp, err := cli.Decrypt(decReq)
+	assert.NoError(t, err)
+	assert.Equal(t, "key-1", *decResp.Response.KeyId)
+	assert.Equal(t, *encReq.Plaintext, *decResp.Response.Plaintext)
+
+	reReq := kms.NewReEncryptRequest()
+	reReq.SetDomain(srv.Host())
+	reReq.CiphertextBlob = encResp.Response.CiphertextBlob
+	reReq.DestinationKeyId = str("key-2")
+	reResp, err := cli.ReEncrypt(reReq)
+	assert.NoError(t, err)
+	assert.Equal(t, "key-2", *reResp.Response.KeyId)
+	assert.Equal(t, "key-1", *reResp.Response.SourceKeyId)
+	decReq.CiphertextBlob = reResp.Response.CiphertextBlob
+	decResp, err = cli.Decrypt(decReq)
+	assert.NoError(t, err)
+	assert.Equal(t, "key-2", *decResp.Response.KeyId)
+	assert.Equal(t, *encReq.Plaintext, *decResp.Response.Plaintext)
+
+	genReq := kms.NewGenerateDataKeyRequest()
+	genReq.SetDomain(srv.Host())
+	genReq.KeyId = str("key-1")
+	genReq.KeySpec = str("AES_256")
+	genResp, err := cli.GenerateDataKey(genReq)
+	assert.NoError(t, err)
+	dataKey, _ := base64.StdEncoding.DecodeString(*genResp.Response.Plaintext)
+	assert.Len(t, dataKey, 32)
+	decReq.CiphertextBlob = genResp.Response.CiphertextBlob
+	decResp, err = cli.Decrypt(decReq)
+	assert.NoError(t, err)
+	assert.Equal(t, *genResp.Response.Plaintext, *decResp.Response.Plaintext)
+
+	// 篡改密文
+	blob, _ := base64.StdEncoding.DecodeString(*encResp.Response.CiphertextBlob)
+	blob[len(blob)-1] ^= 1
+	decReq.CiphertextBlob = str(base64.StdEncoding.EncodeToString(blob))
+	_, err = cli.Decrypt(decReq)
+	assert.Equal(t, "InvalidParameterValue.InvalidCiphertext", errorCode(err))
+}
+
+func TestServerCrypto(t *testing.T) {
+	srv := kmstest.NewServer()
+	defer srv.Close()
+	opts := tcesecurity.CryptoOpts{
+		KeyId:     "key-1",
+		SecretId:  srv.SecretId,
+		SecretKey: srv.SecretKey,
+		KMSServer: srv.Host(),
+		Transport: tcesecurity.KMSTransportOpts{CACert: srv.CACert()},
+	}
+	for _, method := range []string{tcesecurity.KMSSm4Algorithm, tcesecurity.KMSEnvelopeAlgorithm} {
+		opts.Method = method
+		c, err := tcesecurity.SupportAlgorithm[method](opts)
+		assert.NoError(t, err, method)
+		ciphertext, err := c.Encrypt("mysql_pass")
+		assert.NoError(t, err, method)
+		plaintext, err := c.Decrypt(ciphertext)
+		assert.NoError(t, err, method)
+		assert.Equal(t, "mysql_pass", plaintext, method)
+	}
+}
+
+func TestServerSign(t *testing.T) {
+	srv := kmstest.NewServer()
+	defer srv.Close()
+	signer, err := tcesecurity.SupportSignFunc[tcesecurity.KMSSignAlgorithm](tcesecurity.SignOpts{
+		Method:    tcesecurity.KMSSignAlgorithm,
+		KeyId:     "sign-key",
+		SecretId:  srv.SecretId,
+		SecretKey: srv.SecretKey,
+		KMSServer: srv.Host(),
+		Transport: tcesecurity.KMSTransportOpts{CACert: srv.CACert()},
+	})
+	assert.NoError(t, err)
+	signature, err := signer.Sign("message")
+	assert.NoError(t, err)
+	ok, err := signer.Verify("message", signature)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = signer.Verify("message2", signature)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, 1, srv.Calls("SignByAsymmetricKey"))
+	assert.Equal(t, 2, srv.Calls("VerifyByAsymmetricKey"))
+}
+
+func TestServerErrors(t *testing.T) {
+	srv := kmstest.NewServer()
+	defer srv.Close()
+	plaintext := base64.StdEncoding.EncodeToString([]byte("mysql_pass"))
+	encrypt := func(cli *kms.Client, keyId string) error {
+		req := kms.NewEncryptRequest()
+		req.SetDomain(srv.Host())
+		req.KeyId = &keyId
+		req.Plaintext = &plaintext
+		_, err := cli.Encrypt(req)
+		return err
+	}
+	cli := newClient(t, srv, srv.SecretKey)
+
+	// 签名错误
+	assert.Equal(t, "AuthFailure.SignatureFailure", errorCode(encrypt(newClient(t, srv, "wrong"), "key-1")))
+
+	// 故障注入
+	srv.Fail("Encrypt", 2, "InternalError")
+	assert.Equal(t, "InternalError", errorCode(encrypt(cli, "key-1")))
+	assert.Equal(t, "InternalError", errorCode(encrypt(cli, "key-1")))
+	assert.NoError(t, encrypt(cli, "key-1"))
+	srv.Fail("", 1, "")
+	assert.Equal(t, "ClientError.HttpStatusCodeError", errorCode(encrypt(cli, "key-1")))
+	assert.Equal(t, 5, srv.Calls("Encrypt"))
+
+	// 禁用密钥
+	srv.DisableKey("key-1")
+	assert.Equal(t, "ResourceUnavailable.CmkDisabled", errorCode(encrypt(cli, "key-1")))
+	srv.EnableKey("key-1")
+	assert.NoError(t, encrypt(cli, "key-1"))
+}
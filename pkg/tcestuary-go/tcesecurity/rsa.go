@@ -88,6 +88,16 @@ func (r *RsaCrypto) Decrypt(ciphertext string) (string, error) {
 	return string(bts), nil
 }
 
+// EncryptWithContext RSA PKCS1 v1.5 不支持上下文, 上下文为空时等价于 Encrypt
+func (r *RsaCrypto) EncryptWithContext(plaintext string, aad []byte) (string, error) {
+	return encryptWithoutContext(r, plaintext, aad)
+}
+
+// DecryptWithContext RSA PKCS1 v1.5 不支持上下文, 上下文为空时等价于 Decrypt
+func (r *RsaCrypto) DecryptWithContext(ciphertext string, aad []byte) (string, error) {
+	return decryptWithoutContext(r, ciphertext, aad)
+}
+
 // NewResCrypto
 func NewRsaCrypto(method string, publicKey, privateKey []byte) (*RsaCrypto, error) {
 	// 构造publicKey
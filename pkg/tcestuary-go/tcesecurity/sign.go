@@ -1,5 +1,7 @@
 package tcesecurity
 
+import "context"
+
 // 签名、验签
 
 // Sign配置参数
@@ -10,6 +12,8 @@ type SignOpts struct {
 	SecretId  string
 	SecretKey string
 	KMSServer string
+	Transport KMSTransportOpts // 零值表示校验服务端证书
+	Retry     KMSRetryOpts     // 零值表示默认值
 	// For tsm
 	PublicKey  string
 	PrivateKey string
@@ -21,6 +25,12 @@ type Signer interface {
 	Verify(string, string) (bool, error)
 }
 
+// CtxSigner 支持 context 的签名、验签, 用于控制 KMS 调用的超时、取消
+type CtxSigner interface {
+	SignCtx(ctx context.Context, msg string) (string, error)
+	VerifyCtx(ctx context.Context, msg, signValue string) (bool, error)
+}
+
 // 签名算法
 type SignFunc func(opts SignOpts) (Signer, error)
 
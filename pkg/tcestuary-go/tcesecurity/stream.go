@@ -0,0 +1,351 @@
+package tcesecurity
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// 二进制数据 / 流式加解密, 仅支持 GCM 类算法(aes-256-gcm, tsm-sm4-128-gcm), 使用标准 GCM, 不填充.
+// 头部: magic(4) | 类型(1) | len(method)(1) | method | len(keyid)(1) | keyid | 类型相关字段
+// 二进制数据: 头部 | nonce(12) | 密文 | tag, 头部作为 AAD
+// 流式: 头部 | chunk size(4) | nonce 前缀(7) | 密文块..., 每块 chunk size 字节明文加密为 chunk size + 16 字节,
+// 最后一块不超过 chunk size(可以为空). 块 nonce = nonce 前缀 | 块序号(4) | 最后一块标识(1), 头部作为 AAD,
+// 块被重排、截断时解密失败
+
+const (
+	bytesMagic         = "TCES"
+	bytesFormat   byte = 1
+	streamFormat  byte = 2
+	gcmNonceSize       = 12
+	streamPrefix       = 7
+	maxStreamSize      = 16 << 20 // 解密时允许的最大 chunk size, 防止异常数据占用过多内存
+
+	// StreamChunkSize 流式加密每块明文长度
+	StreamChunkSize = 64 << 10
+)
+
+var (
+	ErrNotSupportBytes = errors.New("algorithm not support bytes encryption")
+	ErrStreamTruncated = errors.New("encrypted stream truncated")
+)
+
+// BytesCrypto 二进制数据加解密, 避免 base64 / hex 转换
+type BytesCrypto interface {
+	EncryptBytes([]byte) ([]byte, error)
+	DecryptBytes([]byte) ([]byte, error)
+}
+
+// StreamCrypto 流式加解密, 数据分块加密认证, 适用于大文件.
+// 加密数据写入 NewEncryptWriter 返回的 Writer, 必须调用 Close 写入最后一块
+type StreamCrypto interface {
+	NewEncryptWriter(io.Writer) (io.WriteCloser, error)
+	NewDecryptReader(io.Reader) (io.Reader, error)
+}
+
+// aeadCrypto 支持二进制数据 / 流式加解密的算法
+type aeadCrypto interface {
+	method() string
+	newAEAD() (cipher.AEAD, error)
+}
+
+// aeadResolver 根据头部中的 method / key id 选择解密算法
+type aeadResolver func(method, keyID string) (aeadCrypto, error)
+
+// singleResolver 单密钥, 忽略 key id
+func singleResolver(c aeadCrypto) aeadResolver {
+	return func(method, keyID string) (aeadCrypto, error) {
+		if method != c.method() {
+			return nil, fmt.Errorf("invalid entrypted-data method")
+		}
+		return c, nil
+	}
+}
+
+type streamHeader struct {
+	format byte
+	method string
+	keyID  string
+}
+
+func (h streamHeader) marshal() ([]byte, error) {
+	if len(h.method) > 255 || len(h.keyID) > 255 {
+		return nil, fmt.Errorf("method or key id too long")
+	}
+	var buf bytes.Buffer
+	buf.WriteString(bytesMagic)
+	buf.WriteByte(h.format)
+	buf.WriteByte(byte(len(h.method)))
+	buf.WriteString(h.method)
+	buf.WriteByte(byte(len(h.keyID)))
+	buf.WriteString(h.keyID)
+	return buf.Bytes(), nil
+}
+
+// readStreamHeader 读取头部, 返回头部原始数据用作 AAD
+func readStreamHeader(r io.Reader, format byte) (streamHeader, []byte, error) {
+	var h streamHeader
+	raw := make([]byte, len(bytesMagic)+2)
+	if _, err := io.ReadFull(r, raw); err != nil {
+		return h, nil, ErrorFormat
+	}
+	if string(raw[:len(bytesMagic)]) != bytesMagic || raw[len(bytesMagic)] != format {
+		return h, nil, ErrorFormat
+	}
+	h.format = format
+
+	method := make([]byte, int(raw[len(raw)-1])+1)
+	if _, err := io.ReadFull(r, method); err != nil {
+		return h, nil, ErrorFormat
+	}
+	raw = append(raw, method...)
+	h.method = string(method[:len(method)-1])
+
+	keyID := make([]byte, int(method[len(method)-1]))
+	if _, err := io.ReadFull(r, keyID); err != nil {
+		return h, nil, ErrorFormat
+	}
+	raw = append(raw, keyID...)
+	h.keyID = string(keyID)
+	return h, raw, nil
+}
+
+func encryptBytes(c aeadCrypto, keyID string, plaintext []byte) ([]byte, error) {
+	header, err := streamHeader{format: bytesFormat, method: c.method(), keyID: keyID}.marshal()
+	if err != nil {
+		return nil, err
+	}
+	aead, err := c.newAEAD()
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := randomBytes(gcmNonceSize)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, 0, len(header)+len(nonce)+len(plaintext)+aead.Overhead())
+	out = append(append(out, header...), nonce...)
+	return aead.Seal(out, nonce, plaintext, header), nil
+}
+
+func decryptBytes(resolve aeadResolver, ciphertext []byte) ([]byte, error) {
+	r := bytes.NewReader(ciphertext)
+	h, header, err := readStreamHeader(r, bytesFormat)
+	if err != nil {
+		return nil, err
+	}
+	c, err := resolve(h.method, h.keyID)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := c.newAEAD()
+	if err != nil {
+		return nil, err
+	}
+	data := ciphertext[len(header):]
+	if len(data) < gcmNonceSize+aead.Overhead() {
+		return nil, ErrorFormat
+	}
+	return aead.Open(nil, data[:gcmNonceSize], data[gcmNonceSize:], header)
+}
+
+// streamWriter 分块加密
+type streamWriter struct {
+	w      io.Writer
+	aead   cipher.AEAD
+	header []byte
+	prefix []byte
+	buf    []byte
+	seq    uint32
+	err    error
+}
+
+func newEncryptWriter(c aeadCrypto, keyID string, w io.Writer) (io.WriteCloser, error) {
+	header, err := streamHeader{format: streamFormat, method: c.method(), keyID: keyID}.marshal()
+	if err != nil {
+		return nil, err
+	}
+	aead, err := c.newAEAD()
+	if err != nil {
+		return nil, err
+	}
+	prefix, err := randomBytes(streamPrefix)
+	if err != nil {
+		return nil, err
+	}
+	size := make([]byte, 4)
+	binary.BigEndian.PutUint32(size, StreamChunkSize)
+	header = append(append(header, size...), prefix...)
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+	return &streamWriter{
+		w:      w,
+		aead:   aead,
+		header: header,
+		prefix: prefix,
+		buf:    make([]byte, 0, StreamChunkSize+aead.Overhead()),
+	}, nil
+}
+
+// Write 缓存不足一块的数据, 满一块时加密写入
+func (s *streamWriter) Write(p []byte) (int, error) {
+	if s.err != nil {
+		return 0, s.err
+	}
+	n := 0
+	for len(p) > 0 {
+		// 数据恰好为整块时, 留到下次 Write 或 Close, 以确定是否为最后一块
+		if len(s.buf) == StreamChunkSize {
+			if s.err = s.flush(false); s.err != nil {
+				return n, s.err
+			}
+		}
+		m := StreamChunkSize - len(s.buf)
+		if m > len(p) {
+			m = len(p)
+		}
+		s.buf = append(s.buf, p[:m]...)
+		p = p[m:]
+		n += m
+	}
+	return n, nil
+}
+
+// Close 加密写入最后一块, 不关闭底层 Writer
+func (s *streamWriter) Close() error {
+	if s.err != nil {
+		return s.err
+	}
+	if s.err = s.flush(true); s.err != nil {
+		return s.err
+	}
+	s.err = errors.New("encrypt writer closed")
+	return nil
+}
+
+func (s *streamWriter) flush(last bool) error {
+	nonce := chunkNonce(s.prefix, s.seq, last)
+	if !last && s.seq == ^uint32(0) {
+		return errors.New("encrypted stream too large")
+	}
+	s.seq++
+	out := s.aead.Seal(s.buf[:0], nonce, s.buf, s.header)
+	if _, err := s.w.Write(out); err != nil {
+		return err
+	}
+	s.buf = s.buf[:0]
+	return nil
+}
+
+// streamReader 分块解密
+type streamReader struct {
+	r      *bufio.Reader
+	aead   cipher.AEAD
+	header []byte
+	prefix []byte
+	chunk  []byte // 当前块密文缓冲
+	out    []byte // 当前块未读取的明文
+	seq    uint32
+	done   bool
+	err    error
+}
+
+func newDecryptReader(resolve aeadResolver, r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	h, header, err := readStreamHeader(br, streamFormat)
+	if err != nil {
+		return nil, err
+	}
+	c, err := resolve(h.method, h.keyID)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := c.newAEAD()
+	if err != nil {
+		return nil, err
+	}
+	fields := make([]byte, 4+streamPrefix)
+	if _, err := io.ReadFull(br, fields); err != nil {
+		return nil, ErrorFormat
+	}
+	size := binary.BigEndian.Uint32(fields)
+	if size == 0 || size > maxStreamSize {
+		return nil, ErrorFormat
+	}
+	return &streamReader{
+		r:      br,
+		aead:   aead,
+		header: append(header, fields...),
+		prefix: fields[4:],
+		chunk:  make([]byte, int(size)+aead.Overhead()),
+	}, nil
+}
+
+func (s *streamReader) Read(p []byte) (int, error) {
+	for len(s.out) == 0 {
+		if s.err != nil {
+			return 0, s.err
+		}
+		if s.done {
+			return 0, io.EOF
+		}
+		s.err = s.next()
+	}
+	n := copy(p, s.out)
+	s.out = s.out[n:]
+	return n, nil
+}
+
+// next 读取并解密下一块. 整块之后无数据则为最后一块
+func (s *streamReader) next() error {
+	n, err := io.ReadFull(s.r, s.chunk)
+	switch err {
+	case nil:
+		if _, err := s.r.Peek(1); err == io.EOF {
+			s.done = true
+		} else if err != nil {
+			return err
+		}
+	case io.ErrUnexpectedEOF, io.EOF:
+		s.done = true
+	default:
+		return err
+	}
+	if n < s.aead.Overhead() {
+		return ErrStreamTruncated
+	}
+	// 最后一块解密失败时需要再次校验, 不能覆盖密文
+	dst := s.chunk[:0]
+	if s.done {
+		dst = nil
+	}
+	out, err := s.aead.Open(dst, chunkNonce(s.prefix, s.seq, s.done), s.chunk[:n], s.header)
+	if err != nil {
+		if !s.done {
+			return err
+		}
+		// 可能是数据被截断在块边界
+		if _, e := s.aead.Open(nil, chunkNonce(s.prefix, s.seq, false), s.chunk[:n], s.header); e == nil {
+			return ErrStreamTruncated
+		}
+		return err
+	}
+	s.seq++
+	s.out = out
+	return nil
+}
+
+func chunkNonce(prefix []byte, seq uint32, last bool) []byte {
+	nonce := make([]byte, gcmNonceSize)
+	copy(nonce, prefix)
+	binary.BigEndian.PutUint32(nonce[streamPrefix:], seq)
+	if last {
+		nonce[gcmNonceSize-1] = 1
+	}
+	return nonce
+}
@@ -0,0 +1,158 @@
+package tcesecurity
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newStreamCryptos(t *testing.T) map[string]interface {
+	BytesCrypto
+	StreamCrypto
+} {
+	aesGcm, err := NewAesGcmCrypto(Aes256GcmAlgorithm, []byte("5c2bd12683ceefb8830abba988339e67"))
+	assert.NoError(t, err)
+	sm4, err := NewTSM4Crypto(TSM4Algorithm, []byte("f13c3f40c60db7f3"))
+	assert.NoError(t, err)
+	return map[string]interface {
+		BytesCrypto
+		StreamCrypto
+	}{Aes256GcmAlgorithm: aesGcm, TSM4Algorithm: sm4}
+}
+
+func TestBytesCrypto(t *testing.T) {
+	for name, c := range newStreamCryptos(t) {
+		t.Run(name, func(t *testing.T) {
+			for _, plaintext := range [][]byte{{}, []byte("mysql_pass"), bytes.Repeat([]byte{0, 1, 2}, 1000)} {
+				ciphertext, err := c.EncryptBytes(plaintext)
+				assert.NoError(t, err)
+				ret, err := c.DecryptBytes(ciphertext)
+				assert.NoError(t, err)
+				assert.Equal(t, len(plaintext), len(ret))
+				assert.True(t, bytes.Equal(plaintext, ret))
+			}
+
+			ciphertext, _ := c.EncryptBytes([]byte("mysql_pass"))
+			ciphertext[len(ciphertext)-1] ^= 1
+			_, err := c.DecryptBytes(ciphertext)
+			assert.Error(t, err)
+			_, err = c.DecryptBytes([]byte("mysql_pass"))
+			assert.Equal(t, ErrorFormat, err)
+		})
+	}
+
+	// 算法不一致
+	cryptos := newStreamCryptos(t)
+	ciphertext, _ := cryptos[Aes256GcmAlgorithm].EncryptBytes([]byte("mysql_pass"))
+	_, err := cryptos[TSM4Algorithm].DecryptBytes(ciphertext)
+	assert.Error(t, err)
+}
+
+func TestStreamCrypto(t *testing.T) {
+	sizes := []int{0, 1, StreamChunkSize - 1, StreamChunkSize, StreamChunkSize + 1, 3*StreamChunkSize + 100}
+	for name, c := range newStreamCryptos(t) {
+		t.Run(name, func(t *testing.T) {
+			for _, size := range sizes {
+				plaintext := make([]byte, size)
+				fillPattern(plaintext)
+
+				var buf bytes.Buffer
+				w, err := c.NewEncryptWriter(&buf)
+				assert.NoError(t, err)
+				// 不规则写入
+				for p := plaintext; len(p) > 0; {
+					n := 1000
+					if n > len(p) {
+						n = len(p)
+					}
+					_, err := w.Write(p[:n])
+					assert.NoError(t, err)
+					p = p[n:]
+				}
+				assert.NoError(t, w.Close())
+				encrypted := buf.Bytes()
+
+				r, err := c.NewDecryptReader(bytes.NewReader(encrypted))
+				assert.NoError(t, err)
+				ret, err := ioutil.ReadAll(r)
+				assert.NoError(t, err)
+				assert.True(t, bytes.Equal(plaintext, ret), "size %d", size)
+
+				// 篡改
+				tampered := append([]byte(nil), encrypted...)
+				tampered[len(tampered)-1] ^= 1
+				r, err = c.NewDecryptReader(bytes.NewReader(tampered))
+				assert.NoError(t, err)
+				_, err = ioutil.ReadAll(r)
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestStreamCryptoTruncated(t *testing.T) {
+	c := newStreamCryptos(t)[Aes256GcmAlgorithm]
+	plaintext := make([]byte, 2*StreamChunkSize+10)
+
+	var buf bytes.Buffer
+	w, _ := c.NewEncryptWriter(&buf)
+	io.Copy(w, bytes.NewReader(plaintext))
+	assert.NoError(t, w.Close())
+	encrypted := buf.Bytes()
+	header := len(encrypted) - len(plaintext) - 3*16
+
+	// 截断在块边界 / 块中间 / 只剩头部
+	for _, n := range []int{header + StreamChunkSize + 16, header + 2*(StreamChunkSize+16), header + 100, header} {
+		r, err := c.NewDecryptReader(bytes.NewReader(encrypted[:n]))
+		assert.NoError(t, err)
+		_, err = ioutil.ReadAll(r)
+		assert.Error(t, err, "truncated at %d", n)
+	}
+	r, _ := c.NewDecryptReader(bytes.NewReader(encrypted[:header+StreamChunkSize+16]))
+	_, err := ioutil.ReadAll(r)
+	assert.Equal(t, ErrStreamTruncated, err)
+}
+
+func TestKeyringStream(t *testing.T) {
+	cryptos := newStreamCryptos(t)
+	old, _ := cryptos[Aes256GcmAlgorithm].EncryptBytes([]byte("backup"))
+	ring, err := NewKeyringCrypto("sm4", map[string]Crypto{
+		"aes": cryptos[Aes256GcmAlgorithm].(Crypto),
+		"sm4": cryptos[TSM4Algorithm].(Crypto),
+	}, cryptos[Aes256GcmAlgorithm].(Crypto))
+	assert.NoError(t, err)
+
+	ciphertext, err := ring.EncryptBytes([]byte("backup"))
+	assert.NoError(t, err)
+	_, err = cryptos[TSM4Algorithm].DecryptBytes(ciphertext)
+	assert.NoError(t, err)
+	for _, c := range [][]byte{old, ciphertext} {
+		plaintext, err := ring.DecryptBytes(c)
+		assert.NoError(t, err)
+		assert.Equal(t, "backup", string(plaintext))
+	}
+
+	var buf bytes.Buffer
+	w, err := ring.NewEncryptWriter(&buf)
+	assert.NoError(t, err)
+	w.Write([]byte("backup"))
+	w.Close()
+	r, err := ring.NewDecryptReader(&buf)
+	assert.NoError(t, err)
+	plaintext, err := ioutil.ReadAll(r)
+	assert.NoError(t, err)
+	assert.Equal(t, "backup", string(plaintext))
+
+	rsa := &KeyringCrypto{Active: "rsa", Keys: map[string]Crypto{"rsa": &RsaCrypto{}}}
+	_, err = rsa.EncryptBytes([]byte("backup"))
+	assert.Equal(t, ErrNotSupportBytes, err)
+}
+
+func fillPattern(b []byte) {
+	for i := range b {
+		b[i] = byte(i * 7)
+	}
+}
@@ -0,0 +1,92 @@
+//go:build !cgo || purego
+// +build !cgo purego
+
+package sm
+
+// 纯 Go 实现, 基于 tcesecurity/gmsm, 不依赖 libTencentSM.
+// 以下场景使用该实现: CGO_ENABLED=0, 或者指定 -tags purego.
+// 接口签名、密钥格式、密文格式与 cgo 版本保持一致, 失败统一返回 -1.
+// 不支持: 证书相关接口、SM2CalculateSharedKey、SM2SetRandomDataCtx、SM3BasedPBKDF2、
+// 非 NIST SP800-38D 版本的 SM4 GCM 接口
+
+import (
+	"sync"
+)
+
+const SM3_BLOCK_SIZE int = 64
+
+const SM3_DIGEST_LENGTH int = 32
+const SM3_HMAC_SIZE int = SM3_DIGEST_LENGTH
+
+const (
+	codeOK     = 0
+	codeFailed = -1
+)
+
+var ContextPool *sync.Pool
+
+var IsContextPoolEnable bool = false
+
+// Sm2签名模式
+type SM2SignMode int
+
+const (
+	SM2SignMode_RS_ASN1 SM2SignMode = iota
+	SM2SignMode_RS
+)
+
+// sm2加密模式
+type SM2CipherMode int
+
+const (
+	SM2CipherMode_C1C3C2_ASN1 SM2CipherMode = iota
+	SM2CipherMode_C1C3C2
+	SM2CipherMode_C1C2C3_ASN1
+	SM2CipherMode_C1C2C3
+	SM2CipherMode_04C1C3C2
+	SM2CipherMode_04C1C2C3
+)
+
+/**
+ *@brief 获取当前sdk版本
+ */
+func Version() string {
+	return "purego"
+}
+
+/**
+ *@brief 纯 Go 实现无需认证, 直接返回成功
+ */
+func InitTencentSM(appid []byte, token []byte) int {
+	return codeOK
+}
+
+/**
+ *@brief 纯 Go 实现无需证书初始化, 仅检查参数
+ */
+func InitTencentSMWithCert(appid []byte, bundleid []byte, cert []byte) int {
+	if appid == nil || len(appid) <= 0 || cert == nil || len(cert) <= 0 {
+		panic("invalid parameter")
+	}
+	return codeOK
+}
+
+func SetContextPoolEnable(size int) {
+	if size < 3 {
+		size = 3
+	}
+	IsContextPoolEnable = true
+
+	ContextPool = &sync.Pool{
+		New: func() interface{} {
+			return new(SM2_ctx_t)
+		},
+	}
+	for i := 0; i < size; i++ {
+		ContextPool.Put(new(SM2_ctx_t))
+	}
+}
+
+func ClearContextPool() {
+	IsContextPoolEnable = false
+}
@@ -0,0 +1,298 @@
+//go:build !cgo || purego
+// +build !cgo purego
+
+package sm
+
+import (
+	"crypto/rand"
+	"unsafe"
+
+	"git.code.oa.com/tce-config/tcestuary-go/v4/tcesecurity/gmsm"
+)
+
+// SM2上下文. 纯 Go 实现无预计算表, 仅缓存 SM2InitCtxWithPubKey 传入的公钥
+type SM2_ctx_t struct {
+	pubKey string
+	pub    *gmsm.PublicKey
+}
+
+/**
+ *@brief SM2上下文结构体的大小
+ */
+func SM2CtxSize() int {
+	return int(unsafe.Sizeof(SM2_ctx_t{}))
+}
+
+/**
+ *@brief 使用SM2获取公私钥或加解密之前，必须调用SM2InitCtx或者SM2InitCtxWithPubKey函数
+ */
+func SM2InitCtx(ctx *SM2_ctx_t) int {
+	if ctx == nil {
+		panic("invalid parameter")
+	}
+	if IsContextPoolEnable {
+		*ctx = *ContextPool.Get().(*SM2_ctx_t)
+		return codeOK
+	}
+	*ctx = SM2_ctx_t{}
+	return codeOK
+}
+
+/**
+ * @brief 初始化上下文并缓存公钥, 针对该公钥的运算不再重复解析
+ */
+func SM2InitCtxWithPubKey(ctx *SM2_ctx_t, pubkey []byte) int {
+	if ctx == nil || pubkey == nil {
+		panic("invalid parameter")
+	}
+	if len(pubkey) < 130 {
+		panic("memory len is too small")
+	}
+	pub, err := gmsm.ParsePublicKey(string(pubkey[:130]))
+	if err != nil {
+		return codeFailed
+	}
+	*ctx = SM2_ctx_t{pubKey: string(pubkey[:130]), pub: pub}
+	return codeOK
+}
+
+/**
+ *@brief 使用完SM2算法后，必须调用free函数释放
+ */
+func SM2FreeCtx(ctx *SM2_ctx_t) int {
+	if ctx == nil {
+		panic("invalid parameter")
+	}
+	if IsContextPoolEnable {
+		ContextPool.Put(ctx)
+	}
+	return codeOK
+}
+
+/**
+ *@brief 生成私钥, 输出 64 字节 hex 字符串, out 至少需分配 65 字节空间
+ */
+func GeneratePrivateKey(ctx *SM2_ctx_t, out []byte) int {
+	if ctx == nil || out == nil {
+		panic("invalid parameter")
+	}
+	priv, err := gmsm.GenerateKey(rand.Reader)
+	if err != nil {
+		return codeFailed
+	}
+	return copyCString(out, priv.String())
+}
+
+/**
+ *@brief 根据私钥生成对应公钥, 输出 130 字节 hex(04 | X | Y) 字符串, outPubKey 至少需分配 131 字节空间
+ */
+func GeneratePublicKey(ctx *SM2_ctx_t, privateKey []byte, outPubKey []byte) int {
+	if ctx == nil || privateKey == nil || outPubKey == nil {
+		panic("invalid parameter")
+	}
+	priv, err := gmsm.ParsePrivateKey(cString(privateKey))
+	if err != nil {
+		return codeFailed
+	}
+	return copyCString(outPubKey, priv.PublicKey.String())
+}
+
+/**
+ *@brief 生成公私钥对
+ */
+func GenerateKeyPair(ctx *SM2_ctx_t, outPriKey []byte, outPubKey []byte) int {
+	if ctx == nil || outPriKey == nil || outPubKey == nil {
+		panic("invalid parameter")
+	}
+	priv, err := gmsm.GenerateKey(rand.Reader)
+	if err != nil {
+		return codeFailed
+	}
+	if code := copyCString(outPriKey, priv.String()); code != codeOK {
+		return code
+	}
+	return copyCString(outPubKey, priv.PublicKey.String())
+}
+
+/**
+ *@brief SM2非对称加解密算法，加密. 密文格式 C1C3C2_ASN1
+ */
+func SM2Encrypt(ctx *SM2_ctx_t, in []byte, inlen int, strPubKey []byte, pubkeyLen int, out []byte, outlen *int) int {
+	return SM2EncryptWithMode(ctx, in, inlen, strPubKey, pubkeyLen, out, outlen, SM2CipherMode_C1C3C2_ASN1)
+}
+
+/**
+ *@brief SM2非对称加解密算法，解密. 密文格式 C1C3C2_ASN1
+ */
+func SM2Decrypt(ctx *SM2_ctx_t, in []byte, inlen int, strPriKey []byte, prikeyLen int, out []byte, outlen *int) int {
+	return SM2DecryptWithMode(ctx, in, inlen, strPriKey, prikeyLen, out, outlen, SM2CipherMode_C1C3C2_ASN1)
+}
+
+/**
+ *@brief SM2签名验签算法，签名. 签名格式 RS_ASN1
+ */
+func SM2Sign(ctx *SM2_ctx_t, msg []byte, msglen int, id []byte, idlen int, strPubKey []byte, pubkeyLen int, strPriKey []byte, prikeyLen int, sig []byte, siglen *int) int {
+	return SM2SignWithMode(ctx, msg, msglen, id, idlen, strPubKey, pubkeyLen, strPriKey, prikeyLen, sig, siglen, SM2SignMode_RS_ASN1)
+}
+
+/**
+ *@brief SM2签名验签算法，验签. 签名格式 RS_ASN1
+ */
+func SM2Verify(ctx *SM2_ctx_t, msg []byte, msglen int, id []byte, idlen int, sig []byte, siglen int, strPubKey []byte, pubkeyLen int) int {
+	return SM2VerifyWithMode(ctx, msg, msglen, id, idlen, sig, siglen, strPubKey, pubkeyLen, SM2SignMode_RS_ASN1)
+}
+
+/**
+ *@brief SM2非对称加解密算法，加密的兼容接口
+ */
+func SM2EncryptWithMode(ctx *SM2_ctx_t, in []byte, inlen int, strPubKey []byte, pubkeyLen int, out []byte, outlen *int, mode SM2CipherMode) int {
+	if ctx == nil || in == nil || inlen <= 0 || strPubKey == nil || pubkeyLen <= 0 || out == nil || outlen == nil {
+		panic("invalid parameter")
+	}
+	pub, err := ctx.publicKey(strPubKey[:pubkeyLen])
+	if err != nil {
+		return codeFailed
+	}
+	ciphertext, err := gmsm.Encrypt(rand.Reader, pub, in[:inlen], gmsmCipherMode(mode))
+	if err != nil {
+		return codeFailed
+	}
+	return copyOut(out, outlen, ciphertext)
+}
+
+/**
+ *@brief SM2非对称加解密算法，解密的兼容接口
+ */
+func SM2DecryptWithMode(ctx *SM2_ctx_t, in []byte, inlen int, strPriKey []byte, prikeyLen int, out []byte, outlen *int, mode SM2CipherMode) int {
+	if ctx == nil || in == nil || inlen <= 0 || strPriKey == nil || prikeyLen <= 0 || out == nil || outlen == nil {
+		panic("invalid parameter")
+	}
+	priv, err := gmsm.ParsePrivateKey(string(strPriKey[:prikeyLen]))
+	if err != nil {
+		return codeFailed
+	}
+	plaintext, err := gmsm.Decrypt(priv, in[:inlen], gmsmCipherMode(mode))
+	if err != nil {
+		return codeFailed
+	}
+	return copyOut(out, outlen, plaintext)
+}
+
+/**
+ *@brief SM2签名验签算法，签名的兼容接口. strPubKey 需与私钥匹配
+ */
+func SM2SignWithMode(ctx *SM2_ctx_t, msg []byte, msglen int, id []byte, idlen int, strPubKey []byte, pubkeyLen int, strPriKey []byte, prikeyLen int, sig []byte, siglen *int, signMode SM2SignMode) int {
+	if ctx == nil || msg == nil || msglen <= 0 || id == nil || idlen <= 0 || strPubKey == nil || pubkeyLen <= 0 ||
+		strPriKey == nil || prikeyLen <= 0 || sig == nil || siglen == nil {
+		panic("invalid parameter")
+	}
+	priv, err := gmsm.ParsePrivateKey(string(strPriKey[:prikeyLen]))
+	if err != nil {
+		return codeFailed
+	}
+	pub, err := ctx.publicKey(strPubKey[:pubkeyLen])
+	if err != nil || pub.X.Cmp(priv.X) != 0 || pub.Y.Cmp(priv.Y) != 0 {
+		return codeFailed
+	}
+
+	var signature []byte
+	if signMode == SM2SignMode_RS {
+		signature, err = gmsm.SignRaw(rand.Reader, priv, id[:idlen], msg[:msglen])
+	} else {
+		signature, err = gmsm.SignASN1(rand.Reader, priv, id[:idlen], msg[:msglen])
+	}
+	if err != nil {
+		return codeFailed
+	}
+	return copyOut(sig, siglen, signature)
+}
+
+/**
+ *@brief SM2签名验签算法，验签的兼容接口
+ */
+func SM2VerifyWithMode(ctx *SM2_ctx_t, msg []byte, msglen int, id []byte, idlen int, sig []byte, siglen int, strPubKey []byte, pubkeyLen int, signMode SM2SignMode) int {
+	if ctx == nil || msg == nil || msglen <= 0 || id == nil || idlen <= 0 || sig == nil || siglen <= 0 || strPubKey == nil || pubkeyLen <= 0 {
+		panic("invalid parameter")
+	}
+	pub, err := ctx.publicKey(strPubKey[:pubkeyLen])
+	if err != nil {
+		return codeFailed
+	}
+
+	var ok bool
+	if signMode == SM2SignMode_RS {
+		ok = gmsm.VerifyRaw(pub, id[:idlen], msg[:msglen], sig[:siglen])
+	} else {
+		ok = gmsm.VerifyASN1(pub, id[:idlen], msg[:msglen], sig[:siglen])
+	}
+	if !ok {
+		return codeFailed
+	}
+	return codeOK
+}
+
+/**
+ *@brief 为SM2增加外部熵源. 纯 Go 实现使用 crypto/rand, 忽略外部熵
+ */
+func SM2ReSeed(ctx *SM2_ctx_t, buf []byte, buflen int) int {
+	if ctx == nil || buf == nil || buflen <= 0 {
+		panic("invalid parameter")
+	}
+	return codeOK
+}
+
+// publicKey 优先使用 SM2InitCtxWithPubKey 缓存的公钥
+func (ctx *SM2_ctx_t) publicKey(strPubKey []byte) (*gmsm.PublicKey, error) {
+	s := cString(strPubKey)
+	if ctx.pub != nil && ctx.pubKey == s {
+		return ctx.pub, nil
+	}
+	return gmsm.ParsePublicKey(s)
+}
+
+func gmsmCipherMode(mode SM2CipherMode) gmsm.CipherMode {
+	switch mode {
+	case SM2CipherMode_C1C3C2:
+		return gmsm.C1C3C2
+	case SM2CipherMode_C1C2C3_ASN1:
+		return gmsm.C1C2C3ASN1
+	case SM2CipherMode_C1C2C3:
+		return gmsm.C1C2C3
+	case SM2CipherMode_04C1C3C2:
+		return gmsm.C1C3C2With04
+	case SM2CipherMode_04C1C2C3:
+		return gmsm.C1C2C3With04
+	default:
+		return gmsm.C1C3C2ASN1
+	}
+}
+
+// cString 截断 C 字符串结束符
+func cString(b []byte) string {
+	for i, c := range b {
+		if c == 0 {
+			return string(b[:i])
+		}
+	}
+	return string(b)
+}
+
+// copyCString 输出 C 风格字符串, 空间足够时追加结束符
+func copyCString(out []byte, s string) int {
+	if len(out) < len(s) {
+		return codeFailed
+	}
+	n := copy(out, s)
+	if n < len(out) {
+		out[n] = 0
+	}
+	return codeOK
+}
+
+func copyOut(out []byte, outlen *int, data []byte) int {
+	if len(out) < len(data) {
+		return codeFailed
+	}
+	*outlen = copy(out, data)
+	return codeOK
+}
@@ -0,0 +1,132 @@
+//go:build !cgo || purego
+// +build !cgo purego
+
+package sm
+
+import (
+	"crypto/hmac"
+	"hash"
+	"unsafe"
+
+	"git.code.oa.com/tce-config/tcestuary-go/v4/tcesecurity/gmsm"
+)
+
+// SM3上下文
+type SM3_ctx_t struct {
+	h hash.Hash
+}
+
+// SM3 HMAC上下文
+type HmacSm3Ctx struct {
+	h hash.Hash
+}
+
+/**
+ *@brief SM3上下文结构体的大小
+ */
+func SM3CtxSize() int {
+	return int(unsafe.Sizeof(SM3_ctx_t{}))
+}
+
+func SM3Init(ctx *SM3_ctx_t) int {
+	if ctx == nil {
+		panic("invalid parameter")
+	}
+	ctx.h = gmsm.NewSM3()
+	return codeOK
+}
+
+func SM3Update(ctx *SM3_ctx_t, data []byte, datalen int) int {
+	if ctx == nil || data == nil || datalen <= 0 {
+		panic("invalid parameter")
+	}
+	if ctx.h == nil {
+		return codeFailed
+	}
+	ctx.h.Write(data[:datalen])
+	return codeOK
+}
+
+func SM3Final(ctx *SM3_ctx_t, digest []byte) int {
+	if ctx == nil || digest == nil {
+		panic("invalid parameter")
+	}
+	if ctx.h == nil || len(digest) < SM3_DIGEST_LENGTH {
+		return codeFailed
+	}
+	copy(digest, ctx.h.Sum(nil))
+	return codeOK
+}
+
+/**
+ *@brief SM3 hash算法， 内部依次调用了init update和final三个接口
+ */
+func SM3(data []byte, datalen int, digest []byte) int {
+	if data == nil || digest == nil || datalen <= 0 {
+		panic("invalid parameter")
+	}
+	if len(digest) < SM3_DIGEST_LENGTH {
+		return codeFailed
+	}
+	copy(digest, gmsm.SumSM3(data[:datalen]))
+	return codeOK
+}
+
+/**
+ * @brief 基于sm3算法计算HMAC值 ctx init
+ */
+func SM3HMACInit(key []byte, keyLen int) *HmacSm3Ctx {
+	if key == nil || keyLen <= 0 {
+		panic("invalid parameter")
+	}
+	return &HmacSm3Ctx{h: hmac.New(gmsm.NewSM3, key[:keyLen])}
+}
+
+/**
+ * @brief 基于sm3算法计算HMAC值 update数据
+ */
+func SM3HmacUpdate(ctx *HmacSm3Ctx, data []byte, dataLen int) int {
+	if data == nil || ctx == nil || dataLen <= 0 {
+		panic("invalid parameter")
+	}
+	ctx.h.Write(data[:dataLen])
+	return codeOK
+}
+
+/**
+ * @brief 基于sm3算法计算HMAC值 最终计算HMAC值
+ */
+func SM3HmacFinal(ctx *HmacSm3Ctx, mac []byte, macLen int) int {
+	if mac == nil || len(mac) != macLen || macLen != SM3_HMAC_SIZE {
+		panic("invalid parameter")
+	}
+	copy(mac, ctx.h.Sum(nil))
+	return codeOK
+}
+
+/**
+ * @brief 基于sm3算法计算HMAC值, ctx 未使用
+ */
+func SM3_HMAC(ctx *HmacSm3Ctx, data []byte, dataLen int, key []byte, keyLen int, mac []byte, macLen int) int {
+	if data == nil || key == nil || mac == nil || len(mac) != macLen || macLen != SM3_HMAC_SIZE {
+		panic("invalid parameter")
+	}
+	h := hmac.New(gmsm.NewSM3, key[:keyLen])
+	h.Write(data[:dataLen])
+	copy(mac, h.Sum(nil))
+	return codeOK
+}
+
+/**
+ *@brief 密钥导出函数。sharelen不可大于1024字节。
+ */
+func SM3KDF(share []byte, shareLen int, outkey []byte, keyLen int) int {
+	if share == nil || shareLen <= 0 || outkey == nil || keyLen <= 0 {
+		panic("invalid parameter")
+	}
+	if shareLen > 1024 || len(outkey) < keyLen {
+		return codeFailed
+	}
+	copy(outkey, gmsm.SM3KDF(share[:shareLen], keyLen))
+	return codeOK
+}
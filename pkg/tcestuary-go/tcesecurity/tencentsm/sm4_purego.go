@@ -0,0 +1,264 @@
+//go:build !cgo || purego
+// +build !cgo purego
+
+package sm
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+
+	"git.code.oa.com/tce-config/tcestuary-go/v4/tcesecurity/gmsm"
+)
+
+// 与 cgo 版本一致, key 只使用前 16 字节, CBC / CTR 的 iv 只使用前 16 字节
+
+const sm4GcmTagSize = 16
+
+/**
+ *@brief 生成16字节128bit的SM4 Key，也可调用该接口生成SM4 CBC模式的初始化向量iv
+ */
+func GenerateSM4Key(outkey []byte) int {
+	if outkey == nil || len(outkey) < 16 {
+		panic("invalid parameter")
+	}
+	if _, err := rand.Read(outkey[:16]); err != nil {
+		return codeFailed
+	}
+	return codeOK
+}
+
+/**
+ *@brief SM4 CBC模式对称加解密。加密，使用PKCS#7填充标准
+ */
+func SM4_CBC_Encrypt(in []byte, inlen int, out []byte, outlen *int, key []byte, iv []byte) int {
+	if in == nil || inlen <= 0 || out == nil || outlen == nil || key == nil || iv == nil {
+		panic("invalid parameter")
+	}
+	return sm4CBCEncrypt(gmsm.PKCS7Padding(in[:inlen], gmsm.SM4BlockSize), out, outlen, key, iv)
+}
+
+/**
+ *@brief SM4 CBC模式对称加解密。解密，使用PKCS#7填充标准
+ */
+func SM4_CBC_Decrypt(in []byte, inlen int, out []byte, outlen *int, key []byte, iv []byte) int {
+	if in == nil || inlen <= 0 || out == nil || outlen == nil || key == nil || iv == nil {
+		panic("invalid parameter")
+	}
+	return sm4CBCDecrypt(in[:inlen], out, outlen, key, iv, true)
+}
+
+/**
+ *@brief SM4 CBC模式对称加解密。加密，无填充。请保证明文为16字节整数倍，否则加密会失败。
+ */
+func SM4_CBC_Encrypt_NoPadding(in []byte, inlen int, out []byte, outlen *int, key []byte, iv []byte) int {
+	if in == nil || inlen <= 0 || out == nil || outlen == nil || key == nil || iv == nil {
+		panic("invalid parameter")
+	}
+	return sm4CBCEncrypt(in[:inlen], out, outlen, key, iv)
+}
+
+/**
+ *@brief SM4 CBC模式对称加解密。解密，无填充。请保证密文为16字节整数倍，否则解密会失败。
+ */
+func SM4_CBC_Decrypt_NoPadding(in []byte, inlen int, out []byte, outlen *int, key []byte, iv []byte) int {
+	if in == nil || inlen <= 0 || out == nil || outlen == nil || key == nil || iv == nil {
+		panic("invalid parameter")
+	}
+	return sm4CBCDecrypt(in[:inlen], out, outlen, key, iv, false)
+}
+
+/**
+ *@brief SM4 ECB模式对称加解密。加密，使用PKCS#7填充标准
+ */
+func SM4_ECB_Encrypt(in []byte, inlen int, out []byte, outlen *int, key []byte) int {
+	if in == nil || inlen <= 0 || out == nil || outlen == nil || key == nil {
+		panic("invalid parameter")
+	}
+	return sm4ECB(gmsm.PKCS7Padding(in[:inlen], gmsm.SM4BlockSize), out, outlen, key, false, false)
+}
+
+/**
+ *@brief SM4 ECB模式对称加解密。解密，使用PKCS#7填充标准
+ */
+func SM4_ECB_Decrypt(in []byte, inlen int, out []byte, outlen *int, key []byte) int {
+	if in == nil || inlen <= 0 || out == nil || outlen == nil || key == nil {
+		panic("invalid parameter")
+	}
+	return sm4ECB(in[:inlen], out, outlen, key, true, true)
+}
+
+/**
+ *@brief SM4 ECB模式对称加解密。加密，无填充。请保证明文为16字节整数倍，否则加密会失败。
+ */
+func SM4_ECB_Encrypt_NoPadding(in []byte, inlen int, out []byte, outlen *int, key []byte) int {
+	if in == nil || inlen <= 0 || out == nil || outlen == nil || key == nil {
+		panic("invalid parameter")
+	}
+	return sm4ECB(in[:inlen], out, outlen, key, false, false)
+}
+
+/**
+ *@brief SM4 ECB模式对称加解密。解密，无填充。请保证密文为16字节整数倍，否则解密会失败。
+ */
+func SM4_ECB_Decrypt_NoPadding(in []byte, inlen int, out []byte, outlen *int, key []byte) int {
+	if in == nil || inlen <= 0 || out == nil || outlen == nil || key == nil {
+		panic("invalid parameter")
+	}
+	return sm4ECB(in[:inlen], out, outlen, key, true, false)
+}
+
+/**
+ *@brief SM4 GCM模式对称加解密。加密，使用PKCS7填充，iv 长度任意, tag 长度 16 字节
+ */
+func SM4_GCM_Encrypt_NIST_SP800_38D(in []byte, inlen int, out []byte, outlen *int, tag []byte, taglen *int, key []byte, iv []byte, ivlen int, aad []byte, aadlen int) int {
+	if in == nil || inlen <= 0 || out == nil || outlen == nil || tag == nil || taglen == nil || key == nil || iv == nil || ivlen <= 0 {
+		panic("invalid parameter")
+	}
+	return sm4GCMEncrypt(gmsm.PKCS7Padding(in[:inlen], gmsm.SM4BlockSize), out, outlen, tag, taglen, key, iv[:ivlen], aad, aadlen)
+}
+
+/**
+ *@brief SM4 GCM模式对称加解密。解密，使用PKCS7填充
+ */
+func SM4_GCM_Decrypt_NIST_SP800_38D(in []byte, inlen int, out []byte, outlen *int, tag []byte, taglen int, key []byte, iv []byte, ivlen int, aad []byte, aadlen int) int {
+	if in == nil || inlen <= 0 || out == nil || outlen == nil || tag == nil || taglen <= 0 || key == nil || iv == nil || ivlen <= 0 {
+		panic("invalid parameter")
+	}
+	return sm4GCMDecrypt(in[:inlen], out, outlen, tag[:taglen], key, iv[:ivlen], aad, aadlen, true)
+}
+
+/**
+ *@brief SM4 GCM模式对称加解密。加密，无填充
+ */
+func SM4_GCM_Encrypt_NoPadding_NIST_SP800_38D(in []byte, inlen int, out []byte, outlen *int, tag []byte, taglen *int, key []byte, iv []byte, ivlen int, aad []byte, aadlen int) int {
+	if in == nil || inlen <= 0 || out == nil || outlen == nil || tag == nil || taglen == nil || key == nil || iv == nil || ivlen <= 0 {
+		panic("invalid parameter")
+	}
+	return sm4GCMEncrypt(in[:inlen], out, outlen, tag, taglen, key, iv[:ivlen], aad, aadlen)
+}
+
+/**
+ *@brief SM4 GCM模式对称加解密。解密，无填充
+ */
+func SM4_GCM_Decrypt_NoPadding_NIST_SP800_38D(in []byte, inlen int, out []byte, outlen *int, tag []byte, taglen int, key []byte, iv []byte, ivlen int, aad []byte, aadlen int) int {
+	if in == nil || inlen <= 0 || out == nil || outlen == nil || tag == nil || taglen <= 0 || key == nil || iv == nil || ivlen <= 0 {
+		panic("invalid parameter")
+	}
+	return sm4GCMDecrypt(in[:inlen], out, outlen, tag[:taglen], key, iv[:ivlen], aad, aadlen, false)
+}
+
+/**
+ *@brief SM4 CTR模式对称加解密。加密，CTR模式不需要填充。
+ */
+func SM4_CTR_Encrypt_NoPadding(in []byte, inlen int, out []byte, outlen *int, key []byte, iv []byte) int {
+	if in == nil || inlen <= 0 || out == nil || outlen == nil || key == nil || iv == nil {
+		panic("invalid parameter")
+	}
+	return sm4CTR(in[:inlen], out, outlen, key, iv)
+}
+
+/**
+ *@brief SM4 CTR模式对称加解密。解密，CTR模式不需要填充。
+ */
+func SM4_CTR_Decrypt_NoPadding(in []byte, inlen int, out []byte, outlen *int, key []byte, iv []byte) int {
+	if in == nil || inlen <= 0 || out == nil || outlen == nil || key == nil || iv == nil {
+		panic("invalid parameter")
+	}
+	return sm4CTR(in[:inlen], out, outlen, key, iv)
+}
+
+func newSM4Block(key []byte) (cipher.Block, bool) {
+	if len(key) < gmsm.SM4KeySize {
+		return nil, false
+	}
+	block, err := gmsm.NewSM4Cipher(key[:gmsm.SM4KeySize])
+	return block, err == nil
+}
+
+func sm4CBCEncrypt(in, out []byte, outlen *int, key, iv []byte) int {
+	block, ok := newSM4Block(key)
+	if !ok || len(iv) < gmsm.SM4BlockSize || len(in)%gmsm.SM4BlockSize != 0 || len(out) < len(in) {
+		return codeFailed
+	}
+	cipher.NewCBCEncrypter(block, iv[:gmsm.SM4BlockSize]).CryptBlocks(out, in)
+	*outlen = len(in)
+	return codeOK
+}
+
+func sm4CBCDecrypt(in, out []byte, outlen *int, key, iv []byte, padding bool) int {
+	block, ok := newSM4Block(key)
+	if !ok || len(iv) < gmsm.SM4BlockSize || len(in)%gmsm.SM4BlockSize != 0 {
+		return codeFailed
+	}
+	buf := make([]byte, len(in))
+	cipher.NewCBCDecrypter(block, iv[:gmsm.SM4BlockSize]).CryptBlocks(buf, in)
+	return unpadOut(buf, out, outlen, padding)
+}
+
+func sm4ECB(in, out []byte, outlen *int, key []byte, decrypt, padding bool) int {
+	block, ok := newSM4Block(key)
+	if !ok || len(in)%gmsm.SM4BlockSize != 0 {
+		return codeFailed
+	}
+	buf := make([]byte, len(in))
+	for i := 0; i < len(in); i += gmsm.SM4BlockSize {
+		if decrypt {
+			block.Decrypt(buf[i:], in[i:])
+		} else {
+			block.Encrypt(buf[i:], in[i:])
+		}
+	}
+	return unpadOut(buf, out, outlen, padding)
+}
+
+func sm4CTR(in, out []byte, outlen *int, key, iv []byte) int {
+	block, ok := newSM4Block(key)
+	if !ok || len(iv) < gmsm.SM4BlockSize || len(out) < len(in) {
+		return codeFailed
+	}
+	cipher.NewCTR(block, iv[:gmsm.SM4BlockSize]).XORKeyStream(out, in)
+	*outlen = len(in)
+	return codeOK
+}
+
+func newSM4GCM(key, iv []byte) (cipher.AEAD, bool) {
+	block, ok := newSM4Block(key)
+	if !ok {
+		return nil, false
+	}
+	gcm, err := cipher.NewGCMWithNonceSize(block, len(iv))
+	return gcm, err == nil
+}
+
+func sm4GCMEncrypt(in, out []byte, outlen *int, tag []byte, taglen *int, key, iv, aad []byte, aadlen int) int {
+	gcm, ok := newSM4GCM(key, iv)
+	if !ok || len(out) < len(in) || len(tag) < sm4GcmTagSize {
+		return codeFailed
+	}
+	sealed := gcm.Seal(nil, iv, in, aad[:aadlen])
+	*outlen = copy(out, sealed[:len(in)])
+	*taglen = copy(tag, sealed[len(in):])
+	return codeOK
+}
+
+func sm4GCMDecrypt(in, out []byte, outlen *int, tag, key, iv, aad []byte, aadlen int, padding bool) int {
+	gcm, ok := newSM4GCM(key, iv)
+	if !ok || len(tag) != sm4GcmTagSize {
+		return codeFailed
+	}
+	buf, err := gcm.Open(nil, iv, append(append([]byte{}, in...), tag...), aad[:aadlen])
+	if err != nil {
+		return codeFailed
+	}
+	return unpadOut(buf, out, outlen, padding)
+}
+
+func unpadOut(buf, out []byte, outlen *int, padding bool) int {
+	if padding {
+		var err error
+		if buf, err = gmsm.PKCS7UnPadding(buf, gmsm.SM4BlockSize); err != nil {
+			return codeFailed
+		}
+	}
+	return copyOut(out, outlen, buf)
+}
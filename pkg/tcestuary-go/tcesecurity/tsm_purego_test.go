@@ -0,0 +1,99 @@
+//go:build !cgo || purego
+// +build !cgo purego
+
+package tcesecurity
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// 纯 Go 实现的 tsm-* 算法测试, go test -tags purego 或 CGO_ENABLED=0 时运行.
+// 密钥及 OpenSSL 生成的密文/签名与 gmsm 测试一致
+const (
+	testSM2PrivateKey = "8a00584b33908951a7c895300324f127e0f12242e91a8b966b264adf0a5bdf94"
+	testSM2PublicKey  = "04a748a972994d5ba0eba799667cc571eb2eecf0a74ef8a35537c4fb37adfede00e7629e7b4470b888506e2a5f78f86270a74bcf9d24f393135916d16b601b2125"
+)
+
+func TestTSM2CryptoPurego(t *testing.T) {
+	c, err := NewTSM2Crypto(Tsm2Algorithm, []byte(testSM2PublicKey), []byte(testSM2PrivateKey))
+	assert.NoError(t, err)
+
+	ciphertext, err := c.Encrypt("mysql_pass")
+	assert.NoError(t, err)
+	assert.Len(t, strings.Split(ciphertext, ":"), 4)
+	plaintext, err := c.Decrypt(ciphertext)
+	assert.NoError(t, err)
+	assert.Equal(t, "mysql_pass", plaintext)
+
+	// openssl pkeyutl -encrypt, C1C3C2_ASN1
+	raw, _ := hex.DecodeString("3073022100e7164a677e68abcfd5b5a944ef52ffd519d3bf4f8b06de45cb2fbaad20c9e77e02201ffa49970cd9ad36e4dde37b50c505092ef88c112534237df108fa9e250e6f5704208fa3c521f22cbc0e90893d1d86389ca6cafa662fbd07c7c2fe0e2a9250391f26040a33bf7a4587e695d3b856")
+	plaintext, err = c.Decrypt(c.Prefix + ":" + c.Method + ":" + c.Version + ":" + base64.StdEncoding.EncodeToString(raw))
+	assert.NoError(t, err)
+	assert.Equal(t, "mysql_pass", plaintext)
+
+	_, err = c.Decrypt(c.Prefix + ":" + c.Method + ":" + c.Version + ":" + base64.StdEncoding.EncodeToString(raw[:50]))
+	assert.Error(t, err)
+}
+
+func TestTSMSignPurego(t *testing.T) {
+	s, err := NewTSMSign(Tsm2SignAlgorithm, []byte(testSM2PublicKey), []byte(testSM2PrivateKey))
+	assert.NoError(t, err)
+
+	sign, err := s.Sign("mysql_pass")
+	assert.NoError(t, err)
+	ok, err := s.Verify("mysql_pass", sign)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	ok, _ = s.Verify("mysql_pass2", sign)
+	assert.False(t, ok)
+
+	// openssl dgst -sm3 -sign -sigopt distid:TCESECURITY
+	raw, _ := hex.DecodeString("30450220107b9734bf208217513ae9b296f783c65f92a8af574a28f1d596dcc11f3bfa4d022100b431a93520aef464e7d7d2ac57e4c47c4434e42139413b7e427a43bceece31d8")
+	ok, err = s.Verify("mysql_pass", s.Prefix+":"+s.Method+":"+s.Version+":"+base64.StdEncoding.EncodeToString(raw))
+	assert.NoError(t, err)
+	assert.True(t, ok)
+}
+
+func TestTSM4CryptoPurego(t *testing.T) {
+	c, err := NewTSM4Crypto(TSM4Algorithm, []byte("5c2bd12683ceefb8830abba988339e67"))
+	assert.NoError(t, err)
+
+	for _, origin := range []string{"mysql_pass", "0123456789abcdef"} {
+		ciphertext, err := c.Encrypt(origin)
+		assert.NoError(t, err)
+		items := strings.Split(ciphertext, ":")
+		assert.Len(t, items, 6)
+		// PKCS7 填充后加密, 密文长度为 16 的整数倍
+		body, _ := base64.StdEncoding.DecodeString(items[5])
+		assert.Equal(t, (len(origin)/16+1)*16, len(body))
+
+		plaintext, err := c.Decrypt(ciphertext)
+		assert.NoError(t, err)
+		assert.Equal(t, origin, plaintext)
+	}
+
+	// 绑定上下文
+	ciphertext, err := c.EncryptWithContext("mysql_pass", []byte("user.password:1"))
+	assert.NoError(t, err)
+	plaintext, err := c.DecryptWithContext(ciphertext, []byte("user.password:1"))
+	assert.NoError(t, err)
+	assert.Equal(t, "mysql_pass", plaintext)
+	_, err = c.DecryptWithContext(ciphertext, []byte("user.password:2"))
+	assert.Error(t, err)
+	_, err = c.Decrypt(ciphertext)
+	assert.Error(t, err)
+}
+
+func TestTSM3HashPurego(t *testing.T) {
+	h, err := SupportHashFunc[Tsm3Algorithm]()
+	assert.NoError(t, err)
+	assert.NoError(t, h.Update([]byte("abc")))
+	digest, err := h.Digest()
+	assert.NoError(t, err)
+	assert.Equal(t, "66c7f0f462eeedd9d1f2d46bdc10e4e24167c4875cf2f7a2297da02b8f4ba8e0", hex.EncodeToString(digest))
+}
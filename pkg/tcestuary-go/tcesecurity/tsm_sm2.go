@@ -81,7 +81,10 @@ func (c *TSM2Crypto) Decrypt(ciphertext string) (string, error) {
 	if err != nil {
 		return "", fmt.Errorf("invalid entrypted-data format")
 	}
-	// 4，解密
+	// 4，解密. 密文至少包含 C1(64) 和 C3(32)
+	if len(ciphertextByte) <= 96 {
+		return "", fmt.Errorf("invalid entrypted-data format")
+	}
 	plaintext := make([]byte, len(ciphertextByte)-96)
 	var plaintextLen int
 	if code := sm.SM2Decrypt(
@@ -89,7 +92,17 @@ func (c *TSM2Crypto) Decrypt(ciphertext string) (string, error) {
 		plaintext, &plaintextLen); code != 0 {
 		return "", fmt.Errorf("decrypt failed, code: %d", code)
 	}
-	return string(plaintext), nil
+	return string(plaintext[:plaintextLen]), nil
+}
+
+// EncryptWithContext SM2 不支持上下文, 上下文为空时等价于 Encrypt
+func (c *TSM2Crypto) EncryptWithContext(plaintext string, aad []byte) (string, error) {
+	return encryptWithoutContext(c, plaintext, aad)
+}
+
+// DecryptWithContext SM2 不支持上下文, 上下文为空时等价于 Decrypt
+func (c *TSM2Crypto) DecryptWithContext(ciphertext string, aad []byte) (string, error) {
+	return decryptWithoutContext(c, ciphertext, aad)
 }
 
 func NewTSM2Crypto(method string, publicKey, privateKey []byte) (*TSM2Crypto, error) {
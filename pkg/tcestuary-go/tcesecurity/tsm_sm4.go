@@ -1,11 +1,14 @@
 package tcesecurity
 
 import (
+	"crypto/cipher"
 	"encoding/base64"
 	"encoding/hex"
 	"fmt"
+	"io"
 	"strings"
 
+	"git.code.oa.com/tce-config/tcestuary-go/v4/tcesecurity/gmsm"
 	sm "git.code.oa.com/tce-config/tcestuary-go/v4/tcesecurity/tencentsm"
 )
 
@@ -22,22 +25,41 @@ func init() {
 	registerCryptoFunc(TSM4Algorithm, f)
 }
 
+// sm4GcmNonceSize V2 格式随机 nonce 长度, 参考 NIST SP800-38D 推荐值
+const sm4GcmNonceSize = 12
+
 // TSM-SM4算法
+// V2 密文格式: prefix:method:version:base64(nonce):base64(tag):base64(密文), nonce 每次加密随机生成,
+// prefix:method:version 与上下文作为 AAD, 防止篡改.
+// V1 密文格式: prefix:method:version:base64(tag):base64(密文), iv / aad 取自密钥, 仅用于解密历史数据
 type TSM4Crypto struct {
 	Version string
 	Prefix  string
 	Method  string
 	sm4Key  []byte
-	iv      []byte
-	aad     []byte
+	iv      []byte // V1
+	aad     []byte // V1
 }
 
 // Encrypt加密
 func (c *TSM4Crypto) Encrypt(plaintext string) (string, error) {
+	return c.EncryptWithContext(plaintext, nil)
+}
+
+// EncryptWithContext 加密并绑定上下文
+func (c *TSM4Crypto) EncryptWithContext(plaintext string, context []byte) (string, error) {
 	// 1，如果加密数据带有已加密前缀信息，则直接返回
 	if strings.HasPrefix(plaintext, c.Prefix) {
 		return plaintext, nil
 	}
+	// 2，加密, 随机 nonce
+	nonce, err := randomBytes(sm4GcmNonceSize)
+	if err != nil {
+		return "", err
+	}
+	header := c.Prefix + ":" + c.Method + ":" + c.Version
+	aad := contextAAD(header, context)
+
 	plaintextByte := []byte(plaintext)
 	tag := make([]byte, 16)
 	ciphertext := make([]byte, len(plaintextByte)+16-len(plaintextByte)%16)
@@ -46,18 +68,23 @@ func (c *TSM4Crypto) Encrypt(plaintext string) (string, error) {
 
 	code := sm.SM4_GCM_Encrypt_NIST_SP800_38D(
 		plaintextByte, len(plaintextByte), ciphertext, &ciphertextLen,
-		tag, &tagLen, c.sm4Key, c.iv, len(c.iv), c.aad, len(c.aad))
+		tag, &tagLen, c.sm4Key, nonce, len(nonce), aad, len(aad))
 	if code != 0 {
 		return "", fmt.Errorf("encrypt failed, code: %d", code)
 	}
 	// 3，构造返回
-	return c.Prefix + ":" + c.Method + ":" + c.Version + ":" +
+	return header + ":" + base64.StdEncoding.EncodeToString(nonce) + ":" +
 		base64.StdEncoding.EncodeToString(tag[:tagLen]) + ":" +
 		base64.StdEncoding.EncodeToString(ciphertext[:ciphertextLen]), nil
 }
 
-// Decrypt解密
+// Decrypt解密, 支持 V1 / V2 格式
 func (c *TSM4Crypto) Decrypt(ciphertext string) (string, error) {
+	return c.DecryptWithContext(ciphertext, nil)
+}
+
+// DecryptWithContext 解密并校验上下文, V1 格式不支持上下文
+func (c *TSM4Crypto) DecryptWithContext(ciphertext string, context []byte) (string, error) {
 	// 1，如果解密数据前缀错误，直接返回
 	if !strings.HasPrefix(ciphertext, c.Prefix) {
 		return ciphertext, nil
@@ -65,12 +92,34 @@ func (c *TSM4Crypto) Decrypt(ciphertext string) (string, error) {
 
 	// 2，验证method
 	items := strings.Split(ciphertext, ":")
-	if len(items) != 5 {
+	if len(items) != 5 && len(items) != 6 {
 		return "", fmt.Errorf("invalid ciphertext-data format")
 	}
 	if items[1] != c.Method {
 		return "", fmt.Errorf("invalid ciphertext-data method")
 	}
+	version, err := hex.DecodeString(items[2])
+	if err != nil {
+		return "", fmt.Errorf("invalid ciphertext-data version")
+	}
+
+	// V1: iv / aad 取自密钥; V2: 随机 nonce, 密文头部与上下文作为 aad
+	var nonce, aad []byte
+	switch {
+	case string(version) == VERSION && len(items) == 5:
+		if len(context) > 0 {
+			return "", ErrNotSupportContext
+		}
+		nonce, aad = c.iv, c.aad
+	case string(version) == VERSION2 && len(items) == 6:
+		if nonce, err = base64.StdEncoding.DecodeString(items[3]); err != nil {
+			return "", err
+		}
+		aad = contextAAD(strings.Join(items[:3], ":"), context)
+		items = items[1:]
+	default:
+		return "", fmt.Errorf("invalid ciphertext-data format")
+	}
 
 	tag, err := base64.StdEncoding.DecodeString(items[3])
 	if err != nil {
@@ -86,17 +135,53 @@ func (c *TSM4Crypto) Decrypt(ciphertext string) (string, error) {
 	var plaintextLen int
 	code := sm.SM4_GCM_Decrypt_NIST_SP800_38D(
 		realCiphertext, len(realCiphertext), plaintext, &plaintextLen,
-		tag, len(tag), c.sm4Key, c.iv, len(c.iv), c.aad, len(c.aad))
+		tag, len(tag), c.sm4Key, nonce, len(nonce), aad, len(aad))
 	if code != 0 {
 		return "", fmt.Errorf("decrypt failed, code: %d", code)
 	}
 	return string(plaintext[:plaintextLen]), nil
 }
 
+// EncryptBytes 二进制数据加密, 格式参考 stream.go
+func (c *TSM4Crypto) EncryptBytes(plaintext []byte) ([]byte, error) {
+	return encryptBytes(c, "", plaintext)
+}
+
+// DecryptBytes 二进制数据解密
+func (c *TSM4Crypto) DecryptBytes(ciphertext []byte) ([]byte, error) {
+	return decryptBytes(singleResolver(c), ciphertext)
+}
+
+// NewEncryptWriter 流式加密, 写入完成后必须调用 Close
+func (c *TSM4Crypto) NewEncryptWriter(w io.Writer) (io.WriteCloser, error) {
+	return newEncryptWriter(c, "", w)
+}
+
+// NewDecryptReader 流式解密
+func (c *TSM4Crypto) NewDecryptReader(r io.Reader) (io.Reader, error) {
+	return newDecryptReader(singleResolver(c), r)
+}
+
+func (c *TSM4Crypto) method() string {
+	return c.Method
+}
+
+// newAEAD 标准 SM4-GCM(不填充), 与 Encrypt 使用的 TencentSM 接口(PKCS7 填充)密文不兼容
+func (c *TSM4Crypto) newAEAD() (cipher.AEAD, error) {
+	block, err := gmsm.NewSM4Cipher(c.sm4Key[:gmsm.SM4KeySize])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
 // NewTSM4Crypto return TSM-SM4 Crypto
 func NewTSM4Crypto(method string, sm4Key []byte) (*TSM4Crypto, error) {
+	if len(sm4Key) < 16 {
+		return nil, fmt.Errorf("sm4 key length must be at least 16")
+	}
 	return &TSM4Crypto{
-		Version: hex.EncodeToString([]byte(VERSION)),
+		Version: hex.EncodeToString([]byte(VERSION2)),
 		Prefix:  AlreadyEncryptPrefix + hex.EncodeToString([]byte(TceSecurity)),
 		Method:  hex.EncodeToString([]byte(method)),
 		sm4Key:  []byte(sm4Key),
@@ -0,0 +1,83 @@
+package tcesecurity
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	sm "git.code.oa.com/tce-config/tcestuary-go/v4/tcesecurity/tencentsm"
+)
+
+// TencentSM 测试证书, 与 tsm_test.go 一致
+const testTSMCert = `-----BEGIN CERTIFICATE-----
+MIICqjCCAlGgAwIBAgIJMTAwMDAwMDE2MAoGCCqBHM9VAYN1MIHAMQswCQYDVQQG
+EwJDTjESMBAGA1UECAwJR3Vhbmdkb25nMREwDwYDVQQHDAhTaGVuemhlbjE6MDgG
+A1UECgwxU2hlbnpoZW4gVGVuY2VudCBDb21wdXRlciBTeXN0ZW1zIENvbXBhbnkg
+TGltaXRlZDEMMAoGA1UECwwDRmlUMRowGAYDVQQDDBFUZW5jZW50U00gUm9vdCBD
+QTEkMCIGCSqGSIb3DQEJARYVVGVuY2VudFNNQHRlbmNlbnQuY29tMCIYDzIwMjAx
+MjA5MDkxODI1WhgPMjAyMTAzMTkwOTE4MjVaMIGNMQswCQYDVQQGEwJDTjESMBAG
+A1UECAwJR3VhbmdEb25nMREwDwYDVQQHDAhTaGVuWmhlbjEVMBMGA1UECgwMVGVu
+Y2VudCBJbmMuMQwwCgYDVQQLDANmaXQxFDASBgNVBAMMC1RTTSBsaWNlbnNlMRww
+GgYDVQQNDBN0ZW5jZW50U21XZWIuY29tLmNuMFkwEwYHKoZIzj0CAQYIKoEcz1UB
+gi0DQgAEmVuMCmTg8d6E8erM1BfNOm4YmLPvjLumBjXIuhMvqVam1HHc/kBZam6Y
+hSHa7uGa1SdF6aGKmvV1OZdpockskqNhMF8wHwYDVR0jBBgwFoAUoHUwm/JhjsLU
+0gyxBmEGzO3vkAwwHQYDVR0OBBYEFHNC+Ci8Mgam8cAaDhn4hrauyKAeMAwGA1Ud
+EwEB/wQCMAAwDwYDVR0PAQH/BAUDAwc4ADAKBggqgRzPVQGDdQNHADBEAiAdJTC+
+6faaT3SAfAJZZ9DUDu7FrdD4WKb8rT9rcUkc8wIgbPa40xE7lF9RIUe3ZoBH/ibT
+fqAFHhS63CWKxWvsExw=
+-----END CERTIFICATE-----`
+
+// cgo 与纯 Go 两种实现对同一组 key/nonce/aad 必须得到逐字节相同的结果.
+// 向量由 cgo(TencentSM) 版本生成, go test 与 go test -tags purego 均需通过
+func TestTSM4Vector(t *testing.T) {
+	assert.Equal(t, 0, sm.InitTencentSMWithCert([]byte("tencentSmWeb.com.cn"), nil, []byte(testTSMCert)))
+	key := []byte("5c2bd12683ceefb8830abba988339e67")
+
+	tests := []struct {
+		name       string
+		nonce, aad string
+		plaintext  string
+		ciphertext string
+		tag        string
+	}{
+		// V2: 12 字节随机 nonce
+		{"nonce12", "000102030405060708090a0b", hex.EncodeToString([]byte("tce-aad")), "mysql_pass",
+			"4b51e95b7aaed40bc2d671f4fe969a42", "ff3c3837e9db04ff278da41b7b0f9d6e"},
+		// V1: iv 取密钥前 16 字节, aad 取密钥前 8 字节
+		{"iv16", hex.EncodeToString(key[:16]), hex.EncodeToString(key[:8]), "0123456789abcdef",
+			"9818b9ec1f759b63b0f1ac4f35c65b4bd3259c27872e1d385179a1aac16cd78a", "19f2d4d7c725932d9ca1252a4ae805ec"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nonce, _ := hex.DecodeString(tt.nonce)
+			aad, _ := hex.DecodeString(tt.aad)
+			in := []byte(tt.plaintext)
+
+			out := make([]byte, len(in)+16)
+			tag := make([]byte, 16)
+			var outLen int
+			tagLen := 16
+			assert.Equal(t, 0, sm.SM4_GCM_Encrypt_NIST_SP800_38D(in, len(in), out, &outLen, tag, &tagLen,
+				key, nonce, len(nonce), aad, len(aad)))
+			assert.Equal(t, tt.ciphertext, hex.EncodeToString(out[:outLen]))
+			assert.Equal(t, tt.tag, hex.EncodeToString(tag[:tagLen]))
+
+			plaintext := make([]byte, outLen)
+			var plaintextLen int
+			assert.Equal(t, 0, sm.SM4_GCM_Decrypt_NIST_SP800_38D(out, outLen, plaintext, &plaintextLen, tag, tagLen,
+				key, nonce, len(nonce), aad, len(aad)))
+			assert.Equal(t, tt.plaintext, string(plaintext[:plaintextLen]))
+		})
+	}
+
+	// cgo 版本 TSM4Crypto 生成的 V2 密文
+	c, err := NewTSM4Crypto(TSM4Algorithm, key)
+	assert.NoError(t, err)
+	plaintext, err := c.Decrypt("T5443455345435552495459:74736d2d736d342d3132382d67636d:5632:6kYZz6llRBBSq0K/:oUk9/um7V5zDyCKgGNxh+g==:t4nCchV9wWz0sDCb1Os4dA==")
+	assert.NoError(t, err)
+	assert.Equal(t, "mysql_pass", plaintext)
+	plaintext, err = c.DecryptWithContext("T5443455345435552495459:74736d2d736d342d3132382d67636d:5632:k3vtT9wg+7XxVTd7:8BqrWizGhBlviDGPfBqQbA==:ZeexJunNC9I+LZ4wiRUn8w==", []byte("user.password:1"))
+	assert.NoError(t, err)
+	assert.Equal(t, "mysql_pass", plaintext)
+}
@@ -2,17 +2,16 @@ package tcestuary
 
 import (
 	"errors"
-	"fmt"
-	"os"
-	"path/filepath"
 	"strings"
+	"time"
 
 	"git.code.oa.com/tce-config/tcestuary-go/v4/configcenter"
 	"git.code.oa.com/tce-config/tcestuary-go/v4/logger"
 	"github.com/jinzhu/copier"
 )
 
-var std *manager = newManager()
+// std 默认 Client, 包级函数均基于默认 Client 实现
+var std = &Client{manager: newManager()}
 
 var (
 	// ErrNotFound 配置项不存在
@@ -53,49 +52,34 @@ type (
 		RegionID int
 		ZoneID   int
 	}
+
+	// MysqlWithGaia 相比 Mysql 增加 ReginID / ZoneID / GaiaID 字段
+	MysqlWithGaia struct {
+		Mysql
+		RegionID int
+		ZoneID   int
+		GaiaID   int
+	}
 )
 
 // SetConfigDirectory 调试阶段和特殊场景时, 临时修改配置路径. 业务代码中请勿使用.
 // 配置文件路径变更, 会影响 SDK 版本 文件的输出. 原则上: SDK 版本文件与配置文件在相同路径下
 func SetConfigDirectory(dir string) error {
-	// 检查路径是否存在
-	stat, err := os.Stat(dir)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return fmt.Errorf("dir not exist, %s", dir)
-		}
-		return err
-	}
-	if !stat.IsDir() {
-		return fmt.Errorf("need directory, %s", dir)
-	}
-
-	// dir 换成绝对路径, 用于调试信息输出
-	dir, err = filepath.Abs(dir)
+	dir, sdkFile, err := checkConfigDirectory(dir)
 	if err != nil {
 		return err
 	}
 
-	// 检测关键配置, 是否存在
-	exist := func(file string) error {
-		_, err := os.Stat(file)
-		return err
-	}
-	sdkFile := filepath.Join(dir, "sdk.json")
-	if err := exist(sdkFile); err != nil {
-		return err
-	}
-
 	// 参数验证通过后, 一次性赋值. 防止局部赋值
-	std.Directory = dir
-	std.ConfigCenterFile = sdkFile
+	std.manager.Directory = dir
+	std.manager.ConfigCenterFile = sdkFile
 
 	return nil
 }
 
 // GetConfigDirectory 获取config 目录
 func GetConfigDirectory() string {
-	return std.ConfigCenterFile
+	return std.ConfigFile()
 }
 
 // GetMysqlConfig 包装配置文件读取 和 解密动作, 向业务提供密码明文
@@ -113,13 +97,48 @@ func GetConfigDirectory() string {
 // 一期实现: 兼容 password 密文 和 明文. 支持客户升级 和 向历史版本合并代码
 // 二期实现: 增加网络请求, 从密码库拉取配置
 // 默认配置优先级: 1.密码库; 2.本地密文密码; 3.本地明文密码;
-func GetMysqlConfig(key string) (*Mysql, error) {
+func (c *Client) GetMysqlConfig(key string) (*Mysql, error) {
 	// Load 内部逻辑保证仅加载一次配置
-	err := std.Load()
+	err := c.Load()
 	if err != nil {
 		return nil, err
 	}
 
+	// 同一次调用中使用同一份配置, 防止热加载导致前后不一致
+	center := c.ConfigCenter()
+	return getMysqlConfig(center, c.passwordDecrypter(center), key)
+}
+
+// getMysqlConfig 从 center 中读取 dbsql 配置, 使用 decrypt 解密密码
+func getMysqlConfig(center *configcenter.ConfigCenter, decrypt func(string) (string, error), key string) (*Mysql, error) {
+	l, err := newMysqlLookup(center, decrypt, key, configcenter.ScopeFlat)
+	if err != nil {
+		return nil, err
+	}
+
+	// 返回指向全局配置项的指针
+	m := center.FindMysql(l.dbsql, l.database)
+	if m == nil {
+		return nil, ErrNotFound
+	}
+
+	// 配置项检查
+	if err := m.Valid(); err != nil {
+		return nil, ErrConfigInValid
+	}
+	return l.mysql(m)
+}
+
+// mysqlLookup 一次 dbsql 查询使用的配置及密码解密函数
+type mysqlLookup struct {
+	center   *configcenter.ConfigCenter
+	decrypt  func(string) (string, error)
+	dbsql    string
+	database string
+}
+
+// newMysqlLookup 解析 key, 获取 dbsql 实例和数据库名称, 并检查资源等级是否为 scope
+func newMysqlLookup(center *configcenter.ConfigCenter, decrypt func(string) (string, error), key string, scope configcenter.Scope) (*mysqlLookup, error) {
 	// 解析输入参数, 获取 dbsql 实例 和 数据库名称
 	s := strings.Split(key, ".")
 	if len(s) != 2 {
@@ -128,39 +147,46 @@ func GetMysqlConfig(key string) (*Mysql, error) {
 	dbsql, database := s[0], s[1]
 
 	// 检查资源等级是否匹配
-	scope := std.ConfigCenter.FindMysqlScope(dbsql)
-	if scope == configcenter.ScopeUnknown {
+	switch center.FindMysqlScope(dbsql) {
+	case configcenter.ScopeUnknown:
 		return nil, ErrNotFound
-	} else if scope != configcenter.ScopeFlat {
+	case scope:
+	default:
 		return nil, ErrUsageInvalid
 	}
+	return &mysqlLookup{center: center, decrypt: decrypt, dbsql: dbsql, database: database}, nil
+}
 
-	// 返回指向全局配置项的指针
-	m := std.ConfigCenter.FindMysql(dbsql, database)
-	if m == nil {
-		return nil, ErrNotFound
-	}
-
-	// 配置项检查
-	if err := m.Valid(); err != nil {
-		return nil, ErrConfigInValid
+// lookupMysql 加载配置并创建 mysqlLookup. 同一次调用中使用同一份配置, 防止热加载导致前后不一致
+func (c *Client) lookupMysql(key string, scope configcenter.Scope) (*mysqlLookup, error) {
+	// Load 内部逻辑保证仅加载一次配置
+	if err := c.Load(); err != nil {
+		return nil, err
 	}
+	center := c.ConfigCenter()
+	return newMysqlLookup(center, c.passwordDecrypter(center), key, scope)
+}
 
-	// 创建临时对象, 防止暴露全局配置项指针.
+// mysql 创建临时对象, 防止暴露全局配置项指针. 填充数据库名称并解密密码
+func (l *mysqlLookup) mysql(m *configcenter.Mysql) (*Mysql, error) {
 	mysql := new(Mysql)
 	if err := copier.Copy(mysql, m); err != nil {
 		return nil, ErrConfigInValid
 	}
-	mysql.Database = database // 填充数据库名称
-	passwd, err := Decrypt(std.ConfigCenter.SDK.PasswdSecret.V1Aeskey, mysql.Password)
+	mysql.Database = l.database // 填充数据库名称
+	passwd, err := l.decrypt(mysql.Password)
 	if err != nil {
 		return nil, ErrDecryptFail
 	}
 	mysql.Password = passwd
-
 	return mysql, nil
 }
 
+// GetMysqlConfig 使用默认 Client, 参考 Client.GetMysqlConfig
+func GetMysqlConfig(key string) (*Mysql, error) {
+	return std.GetMysqlConfig(key)
+}
+
 // SetLogger 允许业务指定日志输出, 用于问题调试;
 // 默认: 不输出任何日志
 func SetLogger(log logger.Logger) {
@@ -172,6 +198,37 @@ func Debug() {
 	std.Debug()
 }
 
+// DebugUnsafe 向终端输出配置信息, 包括密钥、密码明文, 仅用于本地调试
+func DebugUnsafe() {
+	std.DebugUnsafe()
+}
+
+// Reload 立即重新加载 sdk.json. 解析失败时继续使用上一次成功加载的配置, 并返回错误信息
+func Reload() error {
+	return std.Reload()
+}
+
+// Watch 开启配置热加载: 每隔 interval 检查一次 sdk.json, 文件变化时重新解析并原子替换配置.
+// 默认不开启, 返回的 stop 函数用于停止检查
+func Watch(interval time.Duration) (stop func()) {
+	return std.Watch(interval)
+}
+
+// OnChange 注册配置变更回调, 热加载成功后调用. 回调中请勿阻塞
+func OnChange(f ChangeFunc) {
+	std.OnChange(f)
+}
+
+// OnReloadError 注册热加载失败回调, 例如 sdk.json 被写坏. 此时 SDK 继续使用上一次成功加载的配置
+func OnReloadError(f func(error)) {
+	std.OnReloadError(f)
+}
+
+// ReloadError 返回最近一次热加载的错误信息, nil 表示成功
+func ReloadError() error {
+	return std.ReloadError()
+}
+
 // GetMysqlConfigAllRegion 获取数据库 region 实例列表.
 // 使用条件:
 // 1. dbsql 组件声明为 region 级别;
@@ -179,51 +236,31 @@ func Debug() {
 // 条件不满足的情况下, 调用接口返回: ErrUsageInvalid
 //
 // key 规则: 参考 GetMysqlConfig 说明
-func GetMysqlConfigAllRegion(key string) ([]*MysqlWithRegion, error) {
-	// Load 内部逻辑保证仅加载一次配置
-	err := std.Load()
+func (c *Client) GetMysqlConfigAllRegion(key string) ([]*MysqlWithRegion, error) {
+	l, err := c.lookupMysql(key, configcenter.ScopeAllRegion)
 	if err != nil {
 		return nil, err
 	}
 
-	// 解析输入参数, 获取 dbsql 实例 和 数据库名称
-	s := strings.Split(key, ".")
-	if len(s) != 2 {
-		return nil, ErrKeyFormat
-	}
-	dbsql, database := s[0], s[1]
-
-	// 检查资源等级是否匹配
-	scope := std.ConfigCenter.FindMysqlScope(dbsql)
-	if scope == configcenter.ScopeUnknown {
+	// 查找包含 database 的实例
+	mysqls := l.center.FindMysqlAllRegion(l.dbsql, l.database)
+	if len(mysqls) == 0 {
 		return nil, ErrNotFound
-	} else if scope != configcenter.ScopeAllRegion {
-		return nil, ErrUsageInvalid
 	}
-
-	// 查找配置
-	mysqlR := make([]*MysqlWithRegion, 0)
-	mysqls := std.ConfigCenter.FindMysqlAllRegion(dbsql, database)
+	items := make([]*MysqlWithRegion, 0, len(mysqls))
 	for _, m := range mysqls {
-		r := new(MysqlWithRegion)
-
-		if err := copier.Copy(r, m.Service); err != nil {
-			return nil, ErrConfigInValid
-		}
-		passwd, err := Decrypt(std.ConfigCenter.SDK.PasswdSecret.V1Aeskey, r.Password)
+		mysql, err := l.mysql(&m.Service)
 		if err != nil {
-			return nil, ErrDecryptFail
+			return nil, err
 		}
-
-		r.Database = database // 填充数据库名称
-		r.Password = passwd
-		r.RegionID = m.Base.RegionID
-		r.RegionName = m.Base.RegionName
-
-		mysqlR = append(mysqlR, r)
+		items = append(items, &MysqlWithRegion{Mysql: *mysql, RegionID: m.Base.RegionID, RegionName: m.Base.RegionName})
 	}
+	return items, nil
+}
 
-	return mysqlR, nil
+// GetMysqlConfigAllRegion 使用默认 Client, 参考 Client.GetMysqlConfigAllRegion
+func GetMysqlConfigAllRegion(key string) ([]*MysqlWithRegion, error) {
+	return std.GetMysqlConfigAllRegion(key)
 }
 
 // GetMysqlConfigAllZone 获取数据库 zone 实例列表.
@@ -233,61 +270,81 @@ func GetMysqlConfigAllRegion(key string) ([]*MysqlWithRegion, error) {
 // 条件不满足的情况下, 调用接口返回: ErrUsageInvalid
 //
 // key 规则: 参考 GetMysqlConfig 说明
-func GetMysqlConfigAllZone(key string) ([]*MysqlWithZone, error) {
-	// Load 内部逻辑保证仅加载一次配置
-	err := std.Load()
+func (c *Client) GetMysqlConfigAllZone(key string) ([]*MysqlWithZone, error) {
+	l, err := c.lookupMysql(key, configcenter.ScopeAllZone)
 	if err != nil {
 		return nil, err
 	}
 
-	// 解析输入参数, 获取 dbsql 实例 和 数据库名称
-	s := strings.Split(key, ".")
-	if len(s) != 2 {
-		return nil, ErrKeyFormat
-	}
-	dbsql, database := s[0], s[1]
-
-	// 检查资源等级是否匹配
-	scope := std.ConfigCenter.FindMysqlScope(dbsql)
-	if scope == configcenter.ScopeUnknown {
+	// 查找包含 database 的实例
+	mysqls := l.center.FindMysqlAllZone(l.dbsql, l.database)
+	if len(mysqls) == 0 {
 		return nil, ErrNotFound
-	} else if scope != configcenter.ScopeAllZone {
-		return nil, ErrUsageInvalid
 	}
-
-	// 查找配置
-	mysqlZ := make([]*MysqlWithZone, 0)
-	mysqls := std.ConfigCenter.FindMysqlAllZone(dbsql, database)
+	items := make([]*MysqlWithZone, 0, len(mysqls))
 	for _, m := range mysqls {
-		z := new(MysqlWithZone)
-
-		if err := copier.Copy(z, m.Service); err != nil {
-			return nil, ErrConfigInValid
-		}
-		passwd, err := Decrypt(std.ConfigCenter.SDK.PasswdSecret.V1Aeskey, z.Password)
+		mysql, err := l.mysql(&m.Service)
 		if err != nil {
-			return nil, ErrDecryptFail
+			return nil, err
 		}
+		items = append(items, &MysqlWithZone{Mysql: *mysql, RegionID: m.Base.RegionID, ZoneID: m.Base.ZoneID})
+	}
+	return items, nil
+}
+
+// GetMysqlConfigAllZone 使用默认 Client, 参考 Client.GetMysqlConfigAllZone
+func GetMysqlConfigAllZone(key string) ([]*MysqlWithZone, error) {
+	return std.GetMysqlConfigAllZone(key)
+}
 
-		z.Database = database // 填充数据库名称
-		z.Password = passwd
-		z.RegionID = m.Base.RegionID
-		z.ZoneID = m.Base.ZoneID
+// GetMysqlConfigAllGaia 获取数据库 gaia 实例列表.
+// 使用条件:
+// 1. dbsql 组件声明为 gaia 级别;
+// 2. cc.declear.json 中声明为 all_gaia 级别引用;
+// 条件不满足的情况下, 调用接口返回: ErrUsageInvalid
+//
+// key 规则: 参考 GetMysqlConfig 说明
+func (c *Client) GetMysqlConfigAllGaia(key string) ([]*MysqlWithGaia, error) {
+	l, err := c.lookupMysql(key, configcenter.ScopeAllGaia)
+	if err != nil {
+		return nil, err
+	}
 
-		mysqlZ = append(mysqlZ, z)
+	// 查找包含 database 的实例
+	mysqls := l.center.FindMysqlAllGaia(l.dbsql, l.database)
+	if len(mysqls) == 0 {
+		return nil, ErrNotFound
 	}
+	items := make([]*MysqlWithGaia, 0, len(mysqls))
+	for _, m := range mysqls {
+		mysql, err := l.mysql(&m.Service)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, &MysqlWithGaia{Mysql: *mysql, RegionID: m.Base.RegionID, ZoneID: m.Base.ZoneID, GaiaID: m.Base.GaiaID})
+	}
+	return items, nil
+}
 
-	return mysqlZ, nil
+// GetMysqlConfigAllGaia 使用默认 Client, 参考 Client.GetMysqlConfigAllGaia
+func GetMysqlConfigAllGaia(key string) ([]*MysqlWithGaia, error) {
+	return std.GetMysqlConfigAllGaia(key)
 }
 
-func GetMainRegionName() (string, error) {
-	if len(std.ConfigCenter.Base.ScopeExtInfo.MainRegionName) == 0 {
-		err := std.Load()
+// GetMainRegionName 获取主地域名称
+func (c *Client) GetMainRegionName() (string, error) {
+	if len(c.ConfigCenter().Base.ScopeExtInfo.MainRegionName) == 0 {
+		err := c.Load()
 		if err != nil {
 			return "", err
 		}
 	}
-	return std.ConfigCenter.Base.ScopeExtInfo.MainRegionName, nil
+	return c.ConfigCenter().Base.ScopeExtInfo.MainRegionName, nil
+}
+
+// GetMainRegionName 使用默认 Client, 参考 Client.GetMainRegionName
+func GetMainRegionName() (string, error) {
+	return std.GetMainRegionName()
 }
 
 // Region 地域信息
@@ -303,14 +360,18 @@ type Region struct {
 }
 
 // GetRegion 当 regionID 不满足业务需求, 调用接口获取完整描述信息
-func GetRegion(regionID int) (*Region, error) {
+func (c *Client) GetRegion(regionID int) (*Region, error) {
 	// Load 内部逻辑保证仅加载一次配置
-	err := std.Load()
+	err := c.Load()
 	if err != nil {
 		return nil, err
 	}
+	return getRegion(c.ConfigCenter(), regionID)
+}
 
-	r := std.ConfigCenter.FindRegion(regionID)
+// getRegion 从 center 中查找地域信息
+func getRegion(center *configcenter.ConfigCenter, regionID int) (*Region, error) {
+	r := center.FindRegion(regionID)
 	if r == nil {
 		return nil, ErrNotFound
 	}
@@ -321,6 +382,11 @@ func GetRegion(regionID int) (*Region, error) {
 	return region, nil
 }
 
+// GetRegion 使用默认 Client, 参考 Client.GetRegion
+func GetRegion(regionID int) (*Region, error) {
+	return std.GetRegion(regionID)
+}
+
 // Zone 可用区信息
 type Zone struct {
 	RegionID   int    `json:"region_id"`
@@ -330,14 +396,18 @@ type Zone struct {
 }
 
 // GetZone 当 ZoneID 不满足业务需求, 调用接口获取完整描述信息
-func GetZone(regionID int, zoneID int) (*Zone, error) {
+func (c *Client) GetZone(regionID int, zoneID int) (*Zone, error) {
 	// Load 内部逻辑保证仅加载一次配置
-	err := std.Load()
+	err := c.Load()
 	if err != nil {
 		return nil, err
 	}
+	return getZone(c.ConfigCenter(), regionID, zoneID)
+}
 
-	z := std.ConfigCenter.FindZone(regionID, zoneID)
+// getZone 从 center 中查找可用区信息
+func getZone(center *configcenter.ConfigCenter, regionID int, zoneID int) (*Zone, error) {
+	z := center.FindZone(regionID, zoneID)
 	if z == nil {
 		return nil, ErrNotFound
 	}
@@ -348,7 +418,106 @@ func GetZone(regionID int, zoneID int) (*Zone, error) {
 	return zone, nil
 }
 
+// GetZone 使用默认 Client, 参考 Client.GetZone
+func GetZone(regionID int, zoneID int) (*Zone, error) {
+	return std.GetZone(regionID, zoneID)
+}
+
+// Gaia Gaia 信息
+type Gaia struct {
+	RegionID int    `json:"region_id"`
+	ZoneID   int    `json:"zone_id"`
+	GaiaID   int    `json:"gaia_id"`
+	GaiaName string `json:"gaia_name"`
+}
+
+// GetGaia 当 GaiaID 不满足业务需求, 调用接口获取完整描述信息
+func (c *Client) GetGaia(gaiaID int) (*Gaia, error) {
+	// Load 内部逻辑保证仅加载一次配置
+	err := c.Load()
+	if err != nil {
+		return nil, err
+	}
+	return getGaia(c.ConfigCenter(), gaiaID)
+}
+
+// getGaia 从 center 中查找Gaia信息
+func getGaia(center *configcenter.ConfigCenter, gaiaID int) (*Gaia, error) {
+	g := center.FindGaia(gaiaID)
+	if g == nil {
+		return nil, ErrNotFound
+	}
+
+	gaia := new(Gaia)
+	copier.Copy(gaia, g)
+
+	return gaia, nil
+}
+
+// GetGaia 使用默认 Client, 参考 Client.GetGaia
+func GetGaia(gaiaID int) (*Gaia, error) {
+	return std.GetGaia(gaiaID)
+}
+
+// DeclaredService cc.declare.json 中声明的服务
+type DeclaredService struct {
+	Name      string // 服务名称, 与 sdk.json 中的配置项名称一致
+	ServiceID string // _service_id
+	Scope     string // 资源级别, 取值与 _scop 相同. 未声明时为 flat, 表示 global / region / zone / gaia
+}
+
+// GetDeclaredServices 获取 cc.declare.json 中声明的服务列表, 按名称排序.
+// 配置目录中不存在 cc.declare.json 时, 返回 ErrNotFound
+func (c *Client) GetDeclaredServices() ([]*DeclaredService, error) {
+	// Load 内部逻辑保证仅加载一次配置
+	err := c.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	declaration := c.ConfigCenter().Declaration
+	if declaration == nil {
+		return nil, ErrNotFound
+	}
+
+	services := make([]*DeclaredService, 0, len(declaration.Services))
+	for _, s := range declaration.ServiceList() {
+		services = append(services, &DeclaredService{
+			Name:      s.Name,
+			ServiceID: s.ServiceID,
+			Scope:     s.Scope.String(),
+		})
+	}
+	return services, nil
+}
+
+// GetDeclaredServices 使用默认 Client, 参考 Client.GetDeclaredServices
+func GetDeclaredServices() ([]*DeclaredService, error) {
+	return std.GetDeclaredServices()
+}
+
+// GetDeclareIssues 获取 cc.declare.json 与 sdk.json 不一致的问题, 例如: 声明的服务在 sdk.json 中不存在,
+// 资源描述结构与声明的资源级别不一致(此时该配置项被忽略, 查询时返回 ErrNotFound)
+func (c *Client) GetDeclareIssues() ([]string, error) {
+	// Load 内部逻辑保证仅加载一次配置
+	err := c.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	issues := make([]string, 0)
+	for _, issue := range c.ConfigCenter().DeclareIssues {
+		issues = append(issues, issue.String())
+	}
+	return issues, nil
+}
+
+// GetDeclareIssues 使用默认 Client, 参考 Client.GetDeclareIssues
+func GetDeclareIssues() ([]string, error) {
+	return std.GetDeclareIssues()
+}
+
 // GetConfigCenterPtr 支持 sdk.json 加密工具, 请勿调用
 func GetConfigCenterPtr() *configcenter.ConfigCenter {
-	return std.ConfigCenter
+	return std.ConfigCenter()
 }
@@ -1,6 +1,7 @@
 package tcestuary
 
 import (
+	"fmt"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -59,6 +60,9 @@ func TestGetMysqlConfigAllRegion(t *testing.T) {
 		db, err := GetMysqlConfigAllRegion("not_exist.not_exist")
 		assert.Equal(t, ErrNotFound, err)
 		assert.Nil(t, db)
+		db, err = GetMysqlConfigAllRegion("dbsql_tcenter_CCDB4.not_exist")
+		assert.Equal(t, ErrNotFound, err)
+		assert.Nil(t, db)
 	})
 
 	t.Run("scope-error", func(t *testing.T) {
@@ -91,6 +95,9 @@ func TestGetMysqlConfigAllZone(t *testing.T) {
 		db, err := GetMysqlConfigAllZone("not_exist.not_exist")
 		assert.Equal(t, ErrNotFound, err)
 		assert.Nil(t, db)
+		db, err = GetMysqlConfigAllZone("dbsql_yje_yujie_data.not_exist")
+		assert.Equal(t, ErrNotFound, err)
+		assert.Nil(t, db)
 	})
 
 	t.Run("scope-error", func(t *testing.T) {
@@ -104,6 +111,41 @@ func TestGetMysqlConfigAllZone(t *testing.T) {
 
 }
 
+func TestGetMysqlConfigAllGaia(t *testing.T) {
+
+	// 测试期间, 临时设置配置路径
+	SetConfigDirectory("./_example")
+
+	t.Run("key-exist", func(t *testing.T) {
+		dbs, err := GetMysqlConfigAllGaia("dbsql_gaia_data.gaia_data")
+		assert.NoError(t, err)
+		assert.Len(t, dbs, 1)
+		assert.Equal(t, 50000005, dbs[0].RegionID)
+		assert.Equal(t, 50050002, dbs[0].ZoneID)
+		assert.Equal(t, 50050201, dbs[0].GaiaID)
+		assert.Equal(t, "gaia_data", dbs[0].Database)
+	})
+
+	t.Run("key-not-exist", func(t *testing.T) {
+		db, err := GetMysqlConfigAllGaia("not_exist.not_exist")
+		assert.Equal(t, ErrNotFound, err)
+		assert.Nil(t, db)
+		db, err = GetMysqlConfigAllGaia("dbsql_gaia_data.not_exist")
+		assert.Equal(t, ErrNotFound, err)
+		assert.Nil(t, db)
+	})
+
+	t.Run("scope-error", func(t *testing.T) {
+		db, err := GetMysqlConfigAllGaia("dbsql_yje_yujie_data.yujie_data")
+		assert.Equal(t, ErrUsageInvalid, err)
+		assert.Nil(t, db)
+		zdb, err := GetMysqlConfigAllZone("dbsql_gaia_data.gaia_data")
+		assert.Equal(t, ErrUsageInvalid, err)
+		assert.Nil(t, zdb)
+	})
+
+}
+
 func TestLoadConfigOnce(t *testing.T) {
 	// 测试期间, 临时设置配置路径
 	SetConfigDirectory("./_example")
@@ -116,7 +158,7 @@ func TestLoadConfigOnce(t *testing.T) {
 	assert.NoError(t, err)
 
 	// 仅在启动时写一次版本文件
-	assert.Equal(t, int32(1), std.loadCounter)
+	assert.Equal(t, int32(1), std.manager.loadCounter)
 }
 
 func TestWriteVersionOnce(t *testing.T) {
@@ -128,7 +170,7 @@ func TestWriteVersionOnce(t *testing.T) {
 	GetMysqlConfig("ocloud_api3.api_sync")
 
 	// 仅在启动时写一次版本文件
-	assert.Equal(t, int32(1), std.writeCounter)
+	assert.Equal(t, int32(1), std.manager.writeCounter)
 }
 
 func TestDebug(t *testing.T) {
@@ -143,6 +185,15 @@ func TestDebug(t *testing.T) {
 	Debug()
 }
 
+func TestMysqlString(t *testing.T) {
+	db := MysqlWithRegion{Mysql: Mysql{Host: "db-2.db", Password: "mysql_pass"}, RegionID: 1}
+	for _, out := range []string{fmt.Sprintf("%v", db), fmt.Sprintf("%v", &db.Mysql)} {
+		assert.NotContains(t, out, "mysql_pass")
+		assert.Contains(t, out, "db-2.db")
+	}
+	assert.Contains(t, fmt.Sprintf("%v", db), "RegionID:1")
+}
+
 func TestGetRegion(t *testing.T) {
 
 	// 测试期间, 临时设置配置路径
@@ -180,3 +231,42 @@ func TestGetZone(t *testing.T) {
 		assert.Nil(t, r)
 	})
 }
+
+func TestGetGaia(t *testing.T) {
+
+	// 测试期间, 临时设置配置路径
+	SetConfigDirectory("./_example")
+
+	t.Run("key-exist", func(t *testing.T) {
+		g, err := GetGaia(50050201)
+		assert.NoError(t, err)
+		assert.NotNil(t, g)
+		assert.Equal(t, 50050002, g.ZoneID)
+		assert.Equal(t, "gaia-1", g.GaiaName)
+	})
+	t.Run("key-not-exist", func(t *testing.T) {
+		g, err := GetGaia(50050200)
+		assert.Equal(t, ErrNotFound, err)
+		assert.Nil(t, g)
+	})
+}
+
+func TestGetDeclaredServices(t *testing.T) {
+
+	// 测试期间, 临时设置配置路径
+	SetConfigDirectory("./_example")
+
+	services, err := GetDeclaredServices()
+	assert.NoError(t, err)
+	scopes := make(map[string]string)
+	for _, s := range services {
+		scopes[s.Name] = s.Scope
+	}
+	assert.Equal(t, "flat", scopes["ocloud_api3"])
+	assert.Equal(t, "all_zone", scopes["dbsql_yje_yujie_data"])
+	assert.Equal(t, "all_gaia", scopes["dbsql_gaia_data"])
+
+	issues, err := GetDeclareIssues()
+	assert.NoError(t, err)
+	assert.Empty(t, issues)
+}
@@ -24,18 +24,23 @@ func (h *thasher) New() (THash, error) {
 	return h.f()
 }
 
-func parseHashSecretConfig() (configcenter.HashConfig, error) {
+func (c *Client) parseHashSecretConfig() (configcenter.HashConfig, error) {
 	var hashConf configcenter.HashConfig
-	if err := std.Load(); err != nil {
+	if err := c.Load(); err != nil {
 		return hashConf, err
 	}
-	return std.ConfigCenter.SDK.HashSecret, nil
+	return c.ConfigCenter().SDK.HashSecret, nil
+}
+
+// parseHashSecretConfig 默认 Client 的配置
+func parseHashSecretConfig() (configcenter.HashConfig, error) {
+	return std.parseHashSecretConfig()
 }
 
 // NewHasher 返回Hash生成器
-func NewTHasher() (THasher, error) {
+func (c *Client) NewTHasher() (THasher, error) {
 	//  判断是否需要初始化TSM
-	tsmConf, err := parseTSMSecretConfig()
+	tsmConf, err := c.parseTSMSecretConfig()
 	if err != nil {
 		return nil, err
 	}
@@ -44,7 +49,7 @@ func NewTHasher() (THasher, error) {
 			return nil, err
 		}
 	}
-	hashConf, err := parseHashSecretConfig()
+	hashConf, err := c.parseHashSecretConfig()
 	if err != nil {
 		return nil, err
 	}
@@ -54,3 +59,8 @@ func NewTHasher() (THasher, error) {
 	}
 	return &thasher{f}, nil
 }
+
+// NewTHasher 使用默认 Client, 参考 Client.NewTHasher
+func NewTHasher() (THasher, error) {
+	return std.NewTHasher()
+}
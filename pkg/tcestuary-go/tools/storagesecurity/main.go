@@ -2,8 +2,10 @@ package main
 
 import (
 	"fmt"
+	"io/ioutil"
 	"log"
 	"os"
+	"strings"
 
 	"git.code.oa.com/tce-config/tcestuary-go/v4"
 	"github.com/urfave/cli/v2"
@@ -15,7 +17,7 @@ const ToolError int = 200
 func main() {
 	app := &cli.App{
 		Name:  "storage security",
-		Usage: "tce config center shell tool",
+		Usage: "tce config center shell tool (已废弃, 请使用 tcestuary crypto storage)",
 		Authors: []*cli.Author{
 			&cli.Author{
 				Name:  "wentaoyin",
@@ -59,6 +61,35 @@ func main() {
 					},
 				},
 			},
+			{
+				Name:      "Rekey",
+				Aliases:   []string{"rekey"},
+				Usage:     "重新加密文件中的密文, 每行一条, 用于切换加密算法或轮转密钥",
+				ArgsUsage: "",
+				Action:    Rekey,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "configDirectory",
+						Usage:    "解密使用的 sdk.json 配置文件路径 `DIR`",
+						Required: false,
+					},
+					&cli.StringFlag{
+						Name:     "targetConfigDirectory",
+						Usage:    "加密使用的 sdk.json 配置文件路径 `DIR`, 默认与 configDirectory 相同",
+						Required: false,
+					},
+					&cli.StringFlag{
+						Name:     "input",
+						Usage:    "密文文件 `FILE`",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:     "output",
+						Usage:    "输出文件 `FILE`, 可以与 input 相同, 默认输出到标准输出",
+						Required: false,
+					},
+				},
+			},
 		},
 	}
 
@@ -106,3 +137,55 @@ func Decrypt(ctx *cli.Context) error {
 	fmt.Println("解析明文：", ret)
 	return nil
 }
+
+// 重新加密
+func Rekey(ctx *cli.Context) error {
+	var opts []tcestuary.Option
+	if ctx.IsSet("configDirectory") {
+		opts = append(opts, tcestuary.WithConfigDirectory(ctx.String("configDirectory")))
+	}
+	fromClient, err := tcestuary.New(opts...)
+	if err != nil {
+		return err
+	}
+	toClient := fromClient
+	if ctx.IsSet("targetConfigDirectory") {
+		toClient, err = tcestuary.New(tcestuary.WithConfigDirectory(ctx.String("targetConfigDirectory")))
+		if err != nil {
+			return err
+		}
+	}
+	from, err := fromClient.NewStorageSecurity()
+	if err != nil {
+		return err
+	}
+	to, err := toClient.NewStorageSecurity()
+	if err != nil {
+		return err
+	}
+
+	data, err := ioutil.ReadFile(ctx.String("input"))
+	if err != nil {
+		return err
+	}
+	// 全部处理成功后再输出, 避免输出不完整的文件; 空行及换行符(\n 或 \r\n)原样保留
+	lines := strings.Split(string(data), "\n")
+	for i, line := range lines {
+		text := strings.TrimRight(line, "\r")
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		ret, err := tcestuary.Reencrypt(text, from, to)
+		if err != nil {
+			return cli.NewExitError(fmt.Sprintf("line %d: %s", i+1, err), ToolError)
+		}
+		lines[i] = ret + line[len(text):]
+	}
+	out := strings.Join(lines, "\n")
+	if !ctx.IsSet("output") {
+		fmt.Print(out)
+		return nil
+	}
+	// output 可以与 input 相同, 写入临时文件后重命名
+	return tcestuary.WriteFileAtomic(ctx.String("output"), []byte(out), 0600)
+}
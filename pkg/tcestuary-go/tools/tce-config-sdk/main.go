@@ -19,7 +19,7 @@ const (
 func main() {
 	app := &cli.App{
 		Name:  "tce-config-sdk",
-		Usage: "tce config tool for shell",
+		Usage: "tce config tool for shell (已废弃, 请使用 tcestuary config)",
 		Authors: []*cli.Author{
 			&cli.Author{
 				Name:  "torwang",
@@ -39,7 +39,12 @@ func main() {
 				Usage:     "print sdk config",
 				ArgsUsage: " ",
 				Action:    debug,
-				Flags:     []cli.Flag{},
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "unsafe",
+						Usage: "print secrets and passwords in plaintext",
+					},
+				},
 			},
 		},
 	}
@@ -68,6 +73,10 @@ func debug(c *cli.Context) error {
 	// 触发一次加载
 	tcestuary.GetMysqlConfig("dbsql.not-exist")
 
+	if c.Bool("unsafe") {
+		tcestuary.DebugUnsafe()
+		return nil
+	}
 	tcestuary.Debug()
 	return nil
 }
@@ -16,7 +16,7 @@ const decryptError int = 200
 func main() {
 	app := &cli.App{
 		Name:  "encipher",
-		Usage: "tce tool for password management",
+		Usage: "tce tool for password management (已废弃, 请使用 tcestuary crypto passwd --aeskey)",
 		Authors: []*cli.Author{
 			&cli.Author{
 				Name:  "torwang",
@@ -1,14 +1,17 @@
 package main
 
 import (
-	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"log"
 	"os"
+	"path/filepath"
 	"strings"
 
 	"git.code.oa.com/tce-config/tcestuary-go/v4"
 	"git.code.oa.com/tce-config/tcestuary-go/v4/configcenter"
+	"git.code.oa.com/tce-config/tcestuary-go/v4/middlewareconfig"
+	"git.code.oa.com/tce-config/tcestuary-go/v4/tcesecurity"
 	"github.com/urfave/cli/v2"
 )
 
@@ -18,14 +21,30 @@ const decryptError int = 200
 
 func main() {
 	app := &cli.App{
-		Name:  "tce-sdk-encipher",
-		Usage: "encrypt password field in sdk.json, for test only",
+		Name: "tce-sdk-encipher",
+		Usage: "使用 passwd-secret 配置的算法加密 sdk.json 中所有中间件的密码字段(middlewareconfig 中 encrypted:\"true\" 的字段). " +
+			"已加密的字段保持不变, 其它内容按原样输出",
 		Authors: []*cli.Author{
 			&cli.Author{
 				Name:  "torwang",
 				Email: "torwang@tencent.com",
 			},
 		},
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "in",
+				Usage: "sdk.json 文件路径 `FILE`",
+				Value: "/tce/conf/config/tce.config.center/sdk.json",
+			},
+			&cli.StringFlag{
+				Name:  "out",
+				Usage: "输出文件 `FILE`, 默认覆盖 --in, - 表示标准输出",
+			},
+			&cli.BoolFlag{
+				Name:  "dry-run",
+				Usage: "只列出需要加密的字段, 不写出文件",
+			},
+		},
 		Action: encrypt,
 	}
 
@@ -35,107 +54,156 @@ func main() {
 	}
 }
 
-func encrypt(c *cli.Context) error {
-	// for test
-	// tcestuary.SetConfigDirectory(".")
-
-	// 触发配置加载
-	_, err := tcestuary.GetMysqlConfig("not-exist.not-exist")
-	if err != tcestuary.ErrNotFound {
-		return cli.NewExitError(err.Error(), 100)
-	}
+// field 需要加密的字符串节点, path 形如:
+// 1. kind.name.field / kind.name.field[i]
+// 2. kind.name[i]._service.field / kind.name[i]._service.field[j]
+type field struct {
+	path string
+	node *configcenter.Node
+}
 
-	// 加密配置
-	aeskey := tcestuary.GetConfigCenterPtr().SDK.PasswdSecret.V1Aeskey
-	if len(aeskey) != 16 && len(aeskey) != 24 && len(aeskey) != 32 {
-		return cli.NewExitError("aeskey length must be 16/24/32 byte", 100)
+// encryptedFields 按文档顺序返回所有需要加密的字符串节点
+func encryptedFields(doc *configcenter.Document) []field {
+	var fields []field
+	root := doc.Root()
+	if root.Kind() != configcenter.NodeObject {
+		return nil
 	}
-
-	// 加密配置项
-	center := tcestuary.GetConfigCenterPtr()
-	for _, wrap := range center.Mysqls {
-		switch wrap.Scope {
-		case configcenter.ScopeFlat:
-			mysql := wrap.Object.(*configcenter.Mysql)
-			if !strings.HasPrefix(mysql.Password, "AES+") {
-				p, err := tcestuary.Encrypt(aeskey, mysql.Password)
-				if err != nil {
-					return cli.NewExitError("encode error, "+err.Error(), 100)
-				}
-				mysql.Password = p
+	for _, kind := range root.Keys() {
+		names := middlewareconfig.EncryptedFields(kind)
+		services := root.Field(kind)
+		if len(names) == 0 || services.Kind() != configcenter.NodeObject {
+			continue
+		}
+		for _, name := range services.Keys() {
+			prefix := kind + "." + name
+			n := services.Field(name)
+			if n.Kind() == configcenter.NodeObject {
+				fields = appendFields(fields, prefix, n, names)
+				continue
 			}
-		case configcenter.ScopeAllRegion:
-			mysqlR := wrap.Object.([]*configcenter.MysqlWithRegion)
-			for _, mysql := range mysqlR {
-				if !strings.HasPrefix(mysql.Service.Password, "AES+") {
-					p, err := tcestuary.Encrypt(aeskey, mysql.Service.Password)
-					if err != nil {
-						return cli.NewExitError("encode error, "+err.Error(), 100)
-					}
-					mysql.Service.Password = p
+			for i := 0; n.Kind() == configcenter.NodeArray && i < n.Len(); i++ {
+				if service := n.Index(i).Field("_service"); service != nil && service.Kind() == configcenter.NodeObject {
+					fields = appendFields(fields, fmt.Sprintf("%s[%d]._service", prefix, i), service, names)
 				}
 			}
-		case configcenter.ScopeAllZone:
-			mysqlZ := wrap.Object.([]*configcenter.MysqlWithZone)
-			for _, mysql := range mysqlZ {
-				if !strings.HasPrefix(mysql.Service.Password, "AES+") {
-					p, err := tcestuary.Encrypt(aeskey, mysql.Service.Password)
-					if err != nil {
-						return cli.NewExitError("encode error, "+err.Error(), 100)
-					}
-					mysql.Service.Password = p
+		}
+	}
+	return fields
+}
+
+// appendFields 追加 service 中名称匹配 names 的字符串或字符串数组
+func appendFields(fields []field, prefix string, service *configcenter.Node, names []string) []field {
+	for _, key := range service.Keys() {
+		matched := false
+		for _, name := range names {
+			matched = matched || strings.EqualFold(name, key)
+		}
+		if !matched {
+			continue
+		}
+		n := service.Field(key)
+		switch n.Kind() {
+		case configcenter.NodeString:
+			fields = append(fields, field{path: prefix + "." + key, node: n})
+		case configcenter.NodeArray:
+			for i := 0; i < n.Len(); i++ {
+				if item := n.Index(i); item.Kind() == configcenter.NodeString {
+					fields = append(fields, field{path: fmt.Sprintf("%s.%s[%d]", prefix, key, i), node: item})
 				}
 			}
 		}
+	}
+	return fields
+}
 
+func encrypt(c *cli.Context) error {
+	in := c.String("in")
+	data, err := ioutil.ReadFile(in)
+	if err != nil {
+		return cli.NewExitError(err, encryptError)
+	}
+	doc, err := configcenter.ParseDocument(data)
+	if err != nil {
+		return cli.NewExitError(fmt.Sprintf("parse %s error, %s", in, err), encryptError)
 	}
 
-	// 还原成原始配置结构
-	origin, err := PackIntoOriginConfigCenter(tcestuary.GetConfigCenterPtr())
+	// 使用同一文件中的 passwd-secret
+	client, err := tcestuary.New(tcestuary.WithConfigFile(in))
+	if err != nil {
+		return cli.NewExitError(err, encryptError)
+	}
+	s, err := client.NewPasswdSecret()
 	if err != nil {
-		return cli.NewExitError(err, 100)
+		return cli.NewExitError("passwd-secret error, "+err.Error(), encryptError)
 	}
+
+	// 只替换需要加密的字符串, 其它内容由 Document 原样输出
+	count := 0
+	for _, f := range encryptedFields(doc) {
+		value, _ := f.node.StringValue()
+		if value == "" || tcesecurity.IsCiphertext(value) {
+			continue
+		}
+		count++
+		if c.Bool("dry-run") {
+			fmt.Println(f.path)
+			continue
+		}
+		cipher, err := s.Encrypt(value)
+		if err != nil {
+			return cli.NewExitError(fmt.Sprintf("encrypt %s error, %s", f.path, err), encryptError)
+		}
+		if err := f.node.SetValue(cipher); err != nil {
+			return cli.NewExitError(err, encryptError)
+		}
+	}
+	if c.Bool("dry-run") {
+		log.Printf("%d field(s) to encrypt", count)
+		return nil
+	}
+	out := doc.Bytes()
+
 	// 写出配置
-	err = write(origin, "/tce/conf/config/tce.config.center/sdk.json")
+	dst := c.String("out")
+	if dst == "" {
+		dst = in
+	}
+	if dst == "-" {
+		_, err = os.Stdout.Write(out)
+	} else {
+		err = write(dst, out, in)
+	}
 	if err != nil {
-		return cli.NewExitError("overwrite sdk.json error", 100)
+		return cli.NewExitError("write sdk.json error, "+err.Error(), encryptError)
 	}
 
-	log.Println("encrypt sdk.json done")
+	log.Printf("encrypt sdk.json done, %d field(s) encrypted", count)
 
 	return nil
 }
 
-// write 覆盖写 sdk.json
-func write(cc *configcenter.OriginConfigCenter, filename string) error {
-	data, err := json.MarshalIndent(cc, "", "    ")
+// write 写入临时文件后重命名, 文件权限与 --in 相同
+func write(filename string, data []byte, in string) error {
+	stat, err := os.Stat(in)
 	if err != nil {
 		return err
 	}
-
-	return ioutil.WriteFile(filename, data, 0644)
-}
-
-// PackIntoOriginConfigCenter 从内存结构化格式还原成原始配置格式
-func PackIntoOriginConfigCenter(cc *configcenter.ConfigCenter) (*configcenter.OriginConfigCenter, error) {
-	origin := new(configcenter.OriginConfigCenter)
-
-	// Base 拷贝
-	origin.Base.Regions = cc.Base.Regions
-	origin.Base.Zones = cc.Base.Zones
-
-	// SDK 拷贝
-	origin.SDK.PasswdSecret = cc.SDK.PasswdSecret
-
-	// Mysql 拷贝
-	origin.Mysqls = make(map[string]json.RawMessage, len(cc.Mysqls))
-	for dbsql, wrap := range cc.Mysqls {
-		raw, err := json.MarshalIndent(wrap.Object, "", "    ")
-		if err != nil {
-			return nil, err
-		}
-		origin.Mysqls[dbsql] = raw
+	f, err := ioutil.TempFile(filepath.Dir(filename), "."+filepath.Base(filename)+".tmp")
+	if err != nil {
+		return err
 	}
-
-	return origin, nil
+	defer os.Remove(f.Name())
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Chmod(stat.Mode().Perm()); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), filename)
 }
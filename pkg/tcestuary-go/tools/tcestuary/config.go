@@ -0,0 +1,244 @@
+package main
+
+import (
+	"fmt"
+
+	"git.code.oa.com/tce-config/tcestuary-go/v4"
+	"github.com/urfave/cli/v2"
+)
+
+// mysql --scope 取值
+const (
+	scopeFlat       = "flat"
+	scopeAllRegion  = "all_region"
+	scopeAllZone    = "all_zone"
+	scopeAllGaia    = "all_gaia"
+	scopeMainRegion = "main_region"
+)
+
+func configCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "config",
+		Usage: "查询、检查 sdk.json 配置",
+		Subcommands: []*cli.Command{
+			{
+				Name:      "mysql",
+				Usage:     "获取数据库配置信息, 密码已解密",
+				ArgsUsage: "dbsql.database",
+				Action:    configMysql,
+				Flags: append([]cli.Flag{
+					&cli.StringFlag{
+						Name:  "scope",
+						Usage: "资源级别 `SCOPE`: flat / all_region / all_zone / all_gaia / main_region",
+						Value: scopeFlat,
+					},
+				}, formatFlags()...),
+			},
+			middlewareCommand(),
+			{
+				Name:   "base",
+				Usage:  "获取当前地域、可用区、Gaia 信息",
+				Action: configBase,
+				Flags:  formatFlags(),
+			},
+			{
+				Name:   "validate",
+				Usage:  "检查 sdk.json 配置, 存在 error 级别问题时返回非 0",
+				Action: configValidate,
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "strict",
+						Usage: "warning 级别问题同样返回非 0",
+					},
+				},
+			},
+			{
+				Name:   "debug",
+				Usage:  "输出已加载的配置, 敏感字段只显示长度及指纹",
+				Action: configDebug,
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "unsafe",
+						Usage: "输出密钥、密码明文, 仅用于本地调试",
+					},
+				},
+			},
+		},
+	}
+}
+
+// mysqlError 解密失败返回 ExitCrypto, 其它返回 ExitConfig
+func mysqlError(err error) error {
+	if err == tcestuary.ErrDecryptFail {
+		return cryptoError(err)
+	}
+	return configError(err)
+}
+
+func mysqlRecord(m tcestuary.Mysql, extra ...field) record {
+	r := record{
+		{"host", m.Host},
+		{"ip", m.IP},
+		{"port", m.Port},
+		{"user", m.User},
+		{"password", m.Password},
+		{"database", m.Database},
+	}
+	return append(r, extra...)
+}
+
+func configMysql(ctx *cli.Context) error {
+	if ctx.Args().Len() != 1 {
+		return usageError("need exactly one argument: dbsql.database")
+	}
+	c, err := newClient(ctx)
+	if err != nil {
+		return err
+	}
+	return exportMysql(ctx, c, ctx.Args().First(), ctx.String("scope"))
+}
+
+// exportMysql 按 --format / --output 输出数据库配置, 兼容命令指定 --format 时同样使用
+func exportMysql(ctx *cli.Context, c *tcestuary.Client, key, scope string) error {
+	p, err := newPrinter(ctx, "mysql")
+	if err != nil {
+		return err
+	}
+	p.dsn = mysqlDSN
+
+	switch scope {
+	case scopeFlat:
+		m, err := c.GetMysqlConfig(key)
+		if err != nil {
+			return mysqlError(err)
+		}
+		return p.one(mysqlRecord(*m))
+
+	case scopeAllRegion, scopeMainRegion:
+		items, err := c.GetMysqlConfigAllRegion(key)
+		if err != nil {
+			return mysqlError(err)
+		}
+		var main string
+		if scope == scopeMainRegion {
+			if main, err = c.GetMainRegionName(); err != nil {
+				return configError(err)
+			}
+		}
+		records := make([]record, 0, len(items))
+		for _, item := range items {
+			r := mysqlRecord(item.Mysql, field{"region_id", item.RegionID}, field{"region_name", item.RegionName})
+			if scope == scopeMainRegion && item.RegionName == main {
+				return p.one(r)
+			}
+			records = append(records, r)
+		}
+		if scope == scopeMainRegion {
+			return configError(fmt.Errorf("main region %s not found in %s", main, key))
+		}
+		return p.list(records)
+
+	case scopeAllZone:
+		items, err := c.GetMysqlConfigAllZone(key)
+		if err != nil {
+			return mysqlError(err)
+		}
+		records := make([]record, 0, len(items))
+		for _, item := range items {
+			records = append(records, mysqlRecord(item.Mysql, field{"region_id", item.RegionID}, field{"zone_id", item.ZoneID}))
+		}
+		return p.list(records)
+
+	case scopeAllGaia:
+		items, err := c.GetMysqlConfigAllGaia(key)
+		if err != nil {
+			return mysqlError(err)
+		}
+		records := make([]record, 0, len(items))
+		for _, item := range items {
+			records = append(records, mysqlRecord(item.Mysql,
+				field{"region_id", item.RegionID}, field{"zone_id", item.ZoneID}, field{"gaia_id", item.GaiaID}))
+		}
+		return p.list(records)
+
+	default:
+		return usageError("unknown scope: %s", scope)
+	}
+}
+
+func configBase(ctx *cli.Context) error {
+	p, err := newPrinter(ctx, "tce")
+	if err != nil {
+		return err
+	}
+	c, err := newClient(ctx)
+	if err != nil {
+		return err
+	}
+	if err := c.Load(); err != nil {
+		return configError(err)
+	}
+	base := c.ConfigCenter().Base
+	return p.one(record{
+		{"region_id", base.Region.RegionID},
+		{"region_name", base.Region.RegionName},
+		{"zone_id", base.Zone.ZoneID},
+		{"zone_name", base.Zone.ZoneName},
+		{"gaia_id", base.Gaia.GaiaID},
+		{"gaia_name", base.Gaia.GaiaName},
+		{"main_region_name", base.ScopeExtInfo.MainRegionName},
+	})
+}
+
+func configValidate(ctx *cli.Context) error {
+	p, err := newPrinter(ctx, "issue")
+	if err != nil {
+		return err
+	}
+	report, err := tcestuary.Validate(ctx.String("config-dir"))
+	if err != nil {
+		return configError(err)
+	}
+
+	if p.format == outputJSON {
+		if report.Issues == nil {
+			report.Issues = []tcestuary.ValidateIssue{}
+		}
+		if err := p.json(report); err != nil {
+			return err
+		}
+	} else {
+		records := make([]record, 0, len(report.Issues))
+		for _, issue := range report.Issues {
+			if p.format == outputText {
+				records = append(records, record{{"issue", issue}})
+				continue
+			}
+			records = append(records, record{{"level", issue.Level}, {"path", issue.Path}, {"message", issue.Message}})
+		}
+		if err := p.list(records); err != nil {
+			return err
+		}
+	}
+
+	if report.HasErrors() || (ctx.Bool("strict") && len(report.Issues) > 0) {
+		return cli.NewExitError(fmt.Sprintf("%s: %d issue(s) found", report.File, len(report.Issues)), ExitValidate)
+	}
+	return nil
+}
+
+func configDebug(ctx *cli.Context) error {
+	c, err := newClient(ctx)
+	if err != nil {
+		return err
+	}
+	if err := c.Load(); err != nil {
+		return configError(err)
+	}
+	if ctx.Bool("unsafe") {
+		c.DebugUnsafe()
+		return nil
+	}
+	c.Debug()
+	return nil
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"git.code.oa.com/tce-config/tcestuary-go/v4"
+	"git.code.oa.com/tce-config/tcestuary-go/v4/tcesecurity"
+	"github.com/urfave/cli/v2"
+)
+
+// 加解密组件, 对应 sdk.json 中的 storage-secret / transport-secret / passwd-secret
+const (
+	secretStorage   = "storage"
+	secretTransport = "transport"
+	secretPasswd    = "passwd"
+)
+
+func cryptoCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "crypto",
+		Usage: "使用 sdk.json 中的密钥配置加密、解密、重新加密",
+		Subcommands: []*cli.Command{
+			secretCommand(secretStorage, "storage-secret 存储加解密"),
+			secretCommand(secretTransport, "transport-secret 传输加解密"),
+			secretCommand(secretPasswd, "passwd-secret 密码加解密, 与 sdk.json 中的 AES+ 密码格式相同"),
+		},
+	}
+}
+
+func secretCommand(name, usage string) *cli.Command {
+	contextFlag := &cli.StringFlag{
+		Name:  "context",
+		Usage: "绑定的上下文 `AAD`, 如 表名.列名, 解密时必须相同",
+	}
+	var keyFlags []cli.Flag
+	if name == secretPasswd {
+		keyFlags = append(keyFlags, &cli.StringFlag{
+			Name:    "aeskey",
+			Aliases: []string{"k"},
+			Usage:   "直接指定 AES `KEY`(16/24/32 字节), 不读取 sdk.json",
+		})
+	}
+	return &cli.Command{
+		Name:  name,
+		Usage: usage,
+		Subcommands: []*cli.Command{
+			{
+				Name:      "encrypt",
+				Usage:     "加密, 未指定参数时从标准输入读取明文",
+				ArgsUsage: "[plaintext]",
+				Action:    func(ctx *cli.Context) error { return cryptoAction(ctx, name, true) },
+				Flags:     append([]cli.Flag{contextFlag}, keyFlags...),
+			},
+			{
+				Name:      "decrypt",
+				Usage:     "解密, 未指定参数时从标准输入读取密文",
+				ArgsUsage: "[ciphertext]",
+				Action:    func(ctx *cli.Context) error { return cryptoAction(ctx, name, false) },
+				Flags:     append([]cli.Flag{contextFlag}, keyFlags...),
+			},
+			{
+				Name:   "rekey",
+				Usage:  "重新加密文件中的密文, 每行一条, 用于切换加密算法或轮转密钥. 输出与输入格式相同, 不受 --output 影响",
+				Action: func(ctx *cli.Context) error { return cryptoRekey(ctx, name) },
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "target-config-dir",
+						Usage: "加密使用的 sdk.json 所在目录 `DIR`, 默认与 --config-dir 相同",
+					},
+					&cli.StringFlag{
+						Name:  "in",
+						Usage: "密文文件 `FILE`, 默认读取标准输入",
+					},
+					&cli.StringFlag{
+						Name:  "out",
+						Usage: "输出文件 `FILE`, 默认输出到标准输出",
+					},
+				},
+			},
+		},
+	}
+}
+
+// newSecurity 创建加解密组件. passwd 指定 --aeskey 时使用 AES+V1 格式, 不读取配置
+func newSecurity(ctx *cli.Context, c *tcestuary.Client, name string) (tcestuary.StorageSecurity, error) {
+	var s tcestuary.StorageSecurity
+	var err error
+	switch name {
+	case secretStorage:
+		s, err = c.NewStorageSecurity()
+	case secretTransport:
+		s, err = c.NewTransportSecurity()
+	case secretPasswd:
+		if ctx.IsSet("aeskey") {
+			key := ctx.String("aeskey")
+			if length := len(key); length != 16 && length != 24 && length != 32 {
+				return nil, usageError("aeskey should be of 16/24/32 Byte")
+			}
+			return tcesecurity.NewAesCbcCrypto(tcesecurity.Aes256CbcAlgorithm, []byte(key))
+		}
+		s, err = c.NewPasswdSecret()
+	}
+	if err != nil {
+		return nil, configError(err)
+	}
+	return s, nil
+}
+
+func cryptoAction(ctx *cli.Context, name string, encrypt bool) error {
+	input := "plaintext"
+	if !encrypt {
+		input = "ciphertext"
+	}
+	value, err := argOrStdin(ctx, 0, input)
+	if err != nil {
+		return err
+	}
+	output := "ciphertext"
+	if !encrypt {
+		output = "plaintext"
+	}
+	p, err := newPrinter(ctx, "")
+	if err != nil {
+		return err
+	}
+
+	var c *tcestuary.Client
+	if !ctx.IsSet("aeskey") {
+		if c, err = newClient(ctx); err != nil {
+			return err
+		}
+	}
+	s, err := newSecurity(ctx, c, name)
+	if err != nil {
+		return err
+	}
+
+	aad := []byte(ctx.String("context"))
+	var ret string
+	if encrypt {
+		ret, err = s.EncryptWithContext(value, aad)
+	} else {
+		ret, err = s.DecryptWithContext(value, aad)
+	}
+	if err != nil {
+		return cryptoError(err)
+	}
+	return p.one(record{{output, ret}})
+}
+
+func cryptoRekey(ctx *cli.Context, name string) error {
+	fromClient, err := newClient(ctx)
+	if err != nil {
+		return err
+	}
+	toClient := fromClient
+	if ctx.IsSet("target-config-dir") {
+		if toClient, err = newClientWithDirectory(ctx, ctx.String("target-config-dir")); err != nil {
+			return err
+		}
+	}
+	from, err := newSecurity(ctx, fromClient, name)
+	if err != nil {
+		return err
+	}
+	to, err := newSecurity(ctx, toClient, name)
+	if err != nil {
+		return err
+	}
+
+	var data []byte
+	if ctx.IsSet("in") {
+		data, err = ioutil.ReadFile(ctx.String("in"))
+	} else {
+		data, err = ioutil.ReadAll(os.Stdin)
+	}
+	if err != nil {
+		return err
+	}
+	// 全部处理成功后再输出, 避免输出不完整的文件; 空行原样保留
+	lines := strings.Split(string(data), "\n")
+	for i, line := range lines {
+		line = strings.TrimRight(line, "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		ret, err := tcestuary.Reencrypt(line, from, to)
+		if err != nil {
+			return cryptoError(fmt.Errorf("line %d: %s", i+1, err))
+		}
+		lines[i] = ret
+	}
+	out := strings.Join(lines, "\n")
+	if !ctx.IsSet("out") {
+		fmt.Print(out)
+		return nil
+	}
+	return ioutil.WriteFile(ctx.String("out"), []byte(out), 0600)
+}
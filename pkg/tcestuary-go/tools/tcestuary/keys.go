@@ -0,0 +1,202 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+
+	"git.code.oa.com/tce-config/tcestuary-go/v4/configcenter"
+	"git.code.oa.com/tce-config/tcestuary-go/v4/tcesecurity"
+	"git.code.oa.com/tce-config/tcestuary-go/v4/tcesecurity/gmsm"
+	"github.com/urfave/cli/v2"
+)
+
+func keysCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "keys",
+		Usage: "生成密钥、查看已配置的密钥",
+		Subcommands: []*cli.Command{
+			{
+				Name:   "generate",
+				Usage:  "生成密钥, --output json 的结果可直接作为 sdk.json 中的密钥配置或 keys 中的一项",
+				Action: keysGenerate,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "method",
+						Usage: "算法 `METHOD`: aes-256-gcm / aes-256-cbc / tsm-sm4-128-gcm / rsa-2048 / rsa-1024 / tsm-sm2 / tsm-sign",
+						Value: tcesecurity.Aes256GcmAlgorithm,
+					},
+					&cli.StringFlag{
+						Name:  "id",
+						Usage: "密钥 `ID`, 用于密钥环",
+					},
+				},
+			},
+			{
+				Name:   "list",
+				Usage:  "列出 sdk.json 中配置的密钥, 只显示指纹",
+				Action: keysList,
+			},
+		},
+	}
+}
+
+const keyAlphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// randomKey 生成 n 个字符的随机密钥, 字符取自 keyAlphabet, 与配置中心下发的密钥格式一致
+func randomKey(n int) (string, error) {
+	key := make([]byte, 0, n)
+	buf := make([]byte, n)
+	limit := byte(256 - 256%len(keyAlphabet)) // 拒绝采样, 保证每个字符等概率
+	for len(key) < n {
+		if _, err := rand.Read(buf); err != nil {
+			return "", err
+		}
+		for _, b := range buf {
+			if b < limit && len(key) < n {
+				key = append(key, keyAlphabet[int(b)%len(keyAlphabet)])
+			}
+		}
+	}
+	return string(key), nil
+}
+
+// generateKey 生成密钥, 返回 sdk.json 中对应的字段
+func generateKey(method string) (record, error) {
+	switch method {
+	case tcesecurity.Aes256GcmAlgorithm, tcesecurity.Aes256CbcAlgorithm:
+		key, err := randomKey(32)
+		return record{{"aes_key", key}}, err
+
+	case tcesecurity.TSM4Algorithm:
+		key, err := randomKey(16)
+		return record{{"sm4_key", key}}, err
+
+	case tcesecurity.Rsa2048Algorithm, tcesecurity.Rsa1024Algorithm:
+		bits := 2048
+		if method == tcesecurity.Rsa1024Algorithm {
+			bits = 1024
+		}
+		priv, err := rsa.GenerateKey(rand.Reader, bits)
+		if err != nil {
+			return nil, err
+		}
+		pub, err := x509.MarshalPKIXPublicKey(&priv.PublicKey)
+		if err != nil {
+			return nil, err
+		}
+		pubPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pub})
+		privPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(priv)})
+		return record{
+			{"public_key", base64.StdEncoding.EncodeToString(pubPEM)},
+			{"private_key", base64.StdEncoding.EncodeToString(privPEM)},
+		}, nil
+
+	case tcesecurity.Tsm2Algorithm, tcesecurity.Tsm2SignAlgorithm:
+		// TencentSM 密钥为 hex 字符串: 私钥 D, 公钥 04 || X || Y
+		priv, err := gmsm.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		pub := "04" + hex32(priv.X) + hex32(priv.Y)
+		return record{
+			{"public_key", base64.StdEncoding.EncodeToString([]byte(pub))},
+			{"private_key", base64.StdEncoding.EncodeToString([]byte(hex32(priv.D)))},
+		}, nil
+	}
+	return nil, usageError("can not generate key for method: %s", method)
+}
+
+// hex32 大整数转为 32 字节定长 hex
+func hex32(n *big.Int) string {
+	b := n.Bytes()
+	if len(b) < 32 {
+		b = append(make([]byte, 32-len(b)), b...)
+	}
+	return hex.EncodeToString(b)
+}
+
+func keysGenerate(ctx *cli.Context) error {
+	p, err := newPrinter(ctx, "")
+	if err != nil {
+		return err
+	}
+	method := ctx.String("method")
+	r := record{{"method", method}}
+	if id := ctx.String("id"); id != "" {
+		r = record{{"id", id}, {"method", method}}
+	}
+	key, err := generateKey(method)
+	if err != nil {
+		return err
+	}
+	return p.one(append(r, key...))
+}
+
+// keyFingerprint 密钥指纹, kms-* 算法返回 key_id
+func keyFingerprint(conf configcenter.SecretConfig) string {
+	if conf.KeyId != "" {
+		return conf.KeyId
+	}
+	for _, key := range []string{conf.AesKey, conf.V1Aeskey, conf.Sm4Key, conf.PrivateKey} {
+		if key != "" {
+			return configcenter.Redact(key)
+		}
+	}
+	return ""
+}
+
+func keysList(ctx *cli.Context) error {
+	p, err := newPrinter(ctx, "key")
+	if err != nil {
+		return err
+	}
+	c, err := newClient(ctx)
+	if err != nil {
+		return err
+	}
+	if err := c.Load(); err != nil {
+		return configError(err)
+	}
+	sdk := c.ConfigCenter().SDK
+	var records []record
+	for _, item := range []struct {
+		name string
+		conf configcenter.SecretConfig
+	}{
+		{"passwd-secret", sdk.PasswdSecret},
+		{"storage-secret", sdk.StorageSecret},
+		{"transport-secret", sdk.TransportSecret},
+		{"sign-secret", sdk.SignSecret},
+	} {
+		conf := item.conf
+		if conf.Method == "" && item.name == "passwd-secret" && conf.V1Aeskey != "" {
+			conf.Method = tcesecurity.Aes256CbcAlgorithm
+		}
+		if fp := keyFingerprint(conf); fp != "" {
+			records = append(records, record{
+				{"secret", item.name}, {"id", ""}, {"method", conf.Method}, {"active", len(conf.Keys) == 0},
+				{"fingerprint", fp},
+			})
+		}
+		for _, key := range conf.Keys {
+			merged, _ := conf.KeyConfig(key.ID)
+			records = append(records, record{
+				{"secret", item.name}, {"id", key.ID}, {"method", merged.Method}, {"active", key.ID == conf.ActiveKey},
+				{"fingerprint", keyFingerprint(merged)},
+			})
+		}
+	}
+	if p.format == outputText {
+		for _, r := range records {
+			fmt.Fprintf(p.w, "%-16s %-8s %-16s %-6v %s\n", r[0].value, r[1].value, r[2].value, r[3].value, r[4].value)
+		}
+		return nil
+	}
+	return p.list(records)
+}
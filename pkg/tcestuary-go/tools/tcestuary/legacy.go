@@ -0,0 +1,256 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"git.code.oa.com/tce-config/tcestuary-go/v4"
+	"github.com/urfave/cli/v2"
+)
+
+// 兼容命令沿用原有的参数及错误码, 新脚本请使用 config / crypto 等子命令
+
+// ToolError 定义命令行错误码, 便于自动化集成识别异常
+const ToolError int = 200
+
+// ValidateError validate 命令发现配置问题时的错误码, 与 ToolError(文件读取失败等) 区分
+const ValidateError int = 201
+
+func legacyCommands() []*cli.Command {
+	return []*cli.Command{
+		{
+			Category:  categoryLegacy,
+			Name:      "getMysqlConfig",
+			Usage:     "获取数据库配置信息",
+			ArgsUsage: "dbsql.database",
+			Action:    GetMysqlConfig,
+			Flags: append([]cli.Flag{
+				&cli.StringFlag{
+					Name:     "configDirectory",
+					Usage:    "sdk.json 配置文件路径 `DIR`",
+					Required: false,
+				},
+			}, formatFlags()...),
+		},
+		{
+			Category:  categoryLegacy,
+			Name:      "getMysqlConfigAllRegion",
+			Usage:     "获取数据库配置信息(所有 Region 实例)",
+			ArgsUsage: "dbsql.database",
+			Action:    GetMysqlConfigAllRegion,
+			Flags: append([]cli.Flag{
+				&cli.StringFlag{
+					Name:     "configDirectory",
+					Usage:    "sdk.json 配置文件路径 `DIR`",
+					Required: false,
+				},
+			}, formatFlags()...),
+		},
+		{
+			Category:  categoryLegacy,
+			Name:      "getMysqlConfigMainRegion",
+			Usage:     "获取数据库配置信息(Main Region 实例)",
+			ArgsUsage: "dbsql.database",
+			Action:    GetMysqlConfigMainRegion,
+			Flags: append([]cli.Flag{
+				&cli.StringFlag{
+					Name:     "configDirectory",
+					Usage:    "sdk.json 配置文件路径 `DIR`",
+					Required: false,
+				},
+			}, formatFlags()...),
+		},
+		{
+			Category:  categoryLegacy,
+			Name:      "getMysqlConfigAllZone",
+			Usage:     "获取数据库配置信息(所有 Zone 实例)",
+			ArgsUsage: "dbsql.database",
+			Action:    GetMysqlConfigAllZone,
+			Flags: append([]cli.Flag{
+				&cli.StringFlag{
+					Name:     "configDirectory",
+					Usage:    "sdk.json 配置文件路径 `DIR`",
+					Required: false,
+				},
+			}, formatFlags()...),
+		},
+		{
+			Category:  categoryLegacy,
+			Name:      "validate",
+			Usage:     "检查 sdk.json 配置, 存在 error 级别问题时返回非 0",
+			ArgsUsage: " ",
+			Action:    Validate,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:     "configDirectory",
+					Usage:    "sdk.json 配置文件路径 `DIR`",
+					Required: false,
+				},
+				&cli.BoolFlag{
+					Name:  "strict",
+					Usage: "warning 级别问题同样返回非 0",
+				},
+				&cli.BoolFlag{
+					Name:  "json",
+					Usage: "以 JSON 格式输出检查结果",
+				},
+			},
+		},
+	}
+}
+
+// legacyExportMysql 指定 --format 时按新格式输出, 错误码仍为 ToolError
+func legacyExportMysql(ctx *cli.Context, scope string) error {
+	dir := defaultConfigDirectory
+	if ctx.IsSet("configDirectory") {
+		dir = ctx.String("configDirectory")
+	}
+	c, err := newClientWithDirectory(ctx, dir)
+	if err == nil {
+		err = exportMysql(ctx, c, ctx.Args().First(), scope)
+	}
+	if err != nil {
+		return cli.NewExitError(err.Error(), ToolError)
+	}
+	return nil
+}
+
+// GetMysqlConfig 成功时, 向 STDOUT 顺序写出: host / ip / port / user / passwd
+func GetMysqlConfig(ctx *cli.Context) error {
+	if ctx.Args().Len() != 1 {
+		cli.ShowCommandHelpAndExit(ctx, "getMysqlConfig", ToolError)
+	}
+
+	if ctx.IsSet("format") {
+		return legacyExportMysql(ctx, scopeFlat)
+	}
+
+	if ctx.IsSet("configDirectory") {
+		tcestuary.SetConfigDirectory(ctx.String("configDirectory"))
+	}
+
+	mysql, err := tcestuary.GetMysqlConfig(ctx.Args().First())
+	if err != nil {
+		return cli.NewExitError(err, ToolError)
+	}
+
+	fmt.Println(mysql.Host, mysql.IP, mysql.Port, mysql.User, mysql.Password)
+
+	return nil
+}
+
+// GetMysqlConfigAllRegion 成功时, 向 STDOUT 顺序写出多行: host / ip / port / user / passwd / regionid
+func GetMysqlConfigAllRegion(ctx *cli.Context) error {
+	if ctx.Args().Len() != 1 {
+		cli.ShowCommandHelpAndExit(ctx, "getMysqlConfigAllRegion", ToolError)
+	}
+
+	if ctx.IsSet("format") {
+		return legacyExportMysql(ctx, scopeAllRegion)
+	}
+
+	if ctx.IsSet("configDirectory") {
+		tcestuary.SetConfigDirectory(ctx.String("configDirectory"))
+	}
+
+	mysql, err := tcestuary.GetMysqlConfigAllRegion(ctx.Args().First())
+	if err != nil {
+		return cli.NewExitError(err, ToolError)
+	}
+
+	for _, item := range mysql {
+		fmt.Println(item.Host, item.IP, item.Port, item.User, item.Password, item.RegionID)
+	}
+
+	return nil
+}
+
+// GetMysqlConfigMainRegion 成功时, 向 STDOUT 顺序写出多行: host / ip / port / user / passwd / regionid
+func GetMysqlConfigMainRegion(ctx *cli.Context) error {
+	if ctx.Args().Len() != 1 {
+		cli.ShowCommandHelpAndExit(ctx, "getMysqlConfigMainRegion", ToolError)
+	}
+
+	if ctx.IsSet("format") {
+		return legacyExportMysql(ctx, scopeMainRegion)
+	}
+
+	if ctx.IsSet("configDirectory") {
+		tcestuary.SetConfigDirectory(ctx.String("configDirectory"))
+	}
+
+	mysql, err := tcestuary.GetMysqlConfigAllRegion(ctx.Args().First())
+	if err != nil {
+		return cli.NewExitError(err, ToolError)
+	}
+
+	mainRegionName, err := tcestuary.GetMainRegionName()
+	if err != nil {
+		return cli.NewExitError(err, ToolError)
+	}
+
+	for _, item := range mysql {
+		if mainRegionName == item.RegionName {
+			fmt.Println(item.Host, item.IP, item.Port, item.User, item.Password, item.RegionID)
+			break
+		}
+	}
+
+	return nil
+}
+
+// GetMysqlConfigAllZone 成功时, 向 STDOUT 顺序写出多行: host / ip / port / user / passwd / regionid / zoneid
+func GetMysqlConfigAllZone(ctx *cli.Context) error {
+	if ctx.Args().Len() != 1 {
+		cli.ShowCommandHelpAndExit(ctx, "getMysqlConfigAllZone", ToolError)
+	}
+
+	if ctx.IsSet("format") {
+		return legacyExportMysql(ctx, scopeAllZone)
+	}
+
+	if ctx.IsSet("configDirectory") {
+		tcestuary.SetConfigDirectory(ctx.String("configDirectory"))
+	}
+
+	mysql, err := tcestuary.GetMysqlConfigAllZone(ctx.Args().First())
+	if err != nil {
+		return cli.NewExitError(err, ToolError)
+	}
+
+	for _, item := range mysql {
+		fmt.Println(item.Host, item.IP, item.Port, item.User, item.Password, item.RegionID, item.ZoneID)
+	}
+
+	return nil
+}
+
+// Validate 向 STDOUT 逐行写出问题: level path: message. 存在 error 级别问题时返回 ValidateError
+func Validate(ctx *cli.Context) error {
+	dir := defaultConfigDirectory
+	if ctx.IsSet("configDirectory") {
+		dir = ctx.String("configDirectory")
+	}
+
+	report, err := tcestuary.Validate(dir)
+	if err != nil {
+		return cli.NewExitError(err, ToolError)
+	}
+
+	if ctx.Bool("json") {
+		if report.Issues == nil {
+			report.Issues = []tcestuary.ValidateIssue{}
+		}
+		buff, _ := json.MarshalIndent(report, "", "  ")
+		fmt.Println(string(buff))
+	} else {
+		for _, issue := range report.Issues {
+			fmt.Println(issue)
+		}
+	}
+
+	if report.HasErrors() || (ctx.Bool("strict") && len(report.Issues) > 0) {
+		return cli.NewExitError(fmt.Sprintf("%s: %d issue(s) found", report.File, len(report.Issues)), ValidateError)
+	}
+	return nil
+}
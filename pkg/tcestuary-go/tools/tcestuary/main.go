@@ -9,171 +9,111 @@ import (
 	"github.com/urfave/cli/v2"
 )
 
-// ToolError 定义命令行错误码, 便于自动化集成识别异常
-const ToolError int = 200
-
-func main() {
-	app := &cli.App{
-		Name:  "tcestuary",
-		Usage: "tce config center shell tool",
-		Authors: []*cli.Author{
-			&cli.Author{
-				Name:  "torwang",
-				Email: "torwang@tencent.com",
-			},
-		},
-		Commands: []*cli.Command{
-			{
-				Name:      "getMysqlConfig",
-				Usage:     "获取数据库配置信息",
-				ArgsUsage: "dbsql.database",
-				Action:    GetMysqlConfig,
-				Flags: []cli.Flag{
-					&cli.StringFlag{
-						Name:     "configDirectory",
-						Usage:    "sdk.json 配置文件路径 `DIR`",
-						Required: false,
-					},
-				},
-			},
-			{
-				Name:      "getMysqlConfigAllRegion",
-				Usage:     "获取数据库配置信息(所有 Region 实例)",
-				ArgsUsage: "dbsql.database",
-				Action:    GetMysqlConfigAllRegion,
-				Flags: []cli.Flag{
-					&cli.StringFlag{
-						Name:     "configDirectory",
-						Usage:    "sdk.json 配置文件路径 `DIR`",
-						Required: false,
-					},
-				},
-			},
-			{
-				Name:      "getMysqlConfigMainRegion",
-				Usage:     "获取数据库配置信息(Main Region 实例)",
-				ArgsUsage: "dbsql.database",
-				Action:    GetMysqlConfigMainRegion,
-				Flags: []cli.Flag{
-					&cli.StringFlag{
-						Name:     "configDirectory",
-						Usage:    "sdk.json 配置文件路径 `DIR`",
-						Required: false,
-					},
-				},
-			},
-			{
-				Name:      "getMysqlConfigAllZone",
-				Usage:     "获取数据库配置信息(所有 Zone 实例)",
-				ArgsUsage: "dbsql.database",
-				Action:    GetMysqlConfigAllZone,
-				Flags: []cli.Flag{
-					&cli.StringFlag{
-						Name:     "configDirectory",
-						Usage:    "sdk.json 配置文件路径 `DIR`",
-						Required: false,
-					},
-				},
-			},
-		},
-	}
+// 退出码, 便于自动化集成识别异常. 兼容命令沿用 ToolError / ValidateError
+const (
+	ExitOK           = 0
+	ExitError        = 1 // 未分类错误
+	ExitUsage        = 2 // 参数错误
+	ExitConfig       = 3 // 配置加载失败, 配置项不存在或不合法
+	ExitCrypto       = 4 // 加解密、签名、散列失败
+	ExitVerifyFailed = 5 // 验签不通过
+	ExitValidate     = 6 // config validate 发现问题
+)
 
-	err := app.Run(os.Args)
-	if err != nil {
-		log.Fatal(err)
-	}
-}
+// defaultConfigDirectory 未指定 --config-dir 时使用的配置路径
+const defaultConfigDirectory = "/tce/conf/config/tce.config.center"
 
-// GetMysqlConfig 成功时, 向 STDOUT 顺序写出: host / ip / port / user / passwd
-func GetMysqlConfig(ctx *cli.Context) error {
-	if ctx.Args().Len() != 1 {
-		cli.ShowCommandHelpAndExit(ctx, "getMysqlConfig", ToolError)
-	}
+const categoryLegacy = "兼容命令"
 
-	if ctx.IsSet("configDirectory") {
-		tcestuary.SetConfigDirectory(ctx.String("configDirectory"))
-	}
-
-	mysql, err := tcestuary.GetMysqlConfig(ctx.Args().First())
-	if err != nil {
-		return cli.NewExitError(err, ToolError)
-	}
-
-	fmt.Println(mysql.Host, mysql.IP, mysql.Port, mysql.User, mysql.Password)
-
-	return nil
+func usageError(format string, args ...interface{}) error {
+	return cli.NewExitError(fmt.Sprintf(format, args...), ExitUsage)
 }
 
-// GetMysqlConfigAllRegion 成功时, 向 STDOUT 顺序写出多行: host / ip / port / user / passwd / regionid
-func GetMysqlConfigAllRegion(ctx *cli.Context) error {
-	if ctx.Args().Len() != 1 {
-		cli.ShowCommandHelpAndExit(ctx, "getMysqlConfigAllRegion", ToolError)
-	}
-
-	if ctx.IsSet("configDirectory") {
-		tcestuary.SetConfigDirectory(ctx.String("configDirectory"))
-	}
-
-	mysql, err := tcestuary.GetMysqlConfigAllRegion(ctx.Args().First())
-	if err != nil {
-		return cli.NewExitError(err, ToolError)
-	}
-
-	for _, item := range mysql {
-		fmt.Println(item.Host, item.IP, item.Port, item.User, item.Password, item.RegionID)
-	}
-
-	return nil
+func configError(err error) error {
+	return cli.NewExitError(err, ExitConfig)
 }
 
-// GetMysqlConfigMainRegion 成功时, 向 STDOUT 顺序写出多行: host / ip / port / user / passwd / regionid
-func GetMysqlConfigMainRegion(ctx *cli.Context) error {
-	if ctx.Args().Len() != 1 {
-		cli.ShowCommandHelpAndExit(ctx, "getMysqlConfigMainRegion", ToolError)
-	}
-
-	if ctx.IsSet("configDirectory") {
-		tcestuary.SetConfigDirectory(ctx.String("configDirectory"))
-	}
-
-	mysql, err := tcestuary.GetMysqlConfigAllRegion(ctx.Args().First())
-	if err != nil {
-		return cli.NewExitError(err, ToolError)
-	}
+func cryptoError(err error) error {
+	return cli.NewExitError(err, ExitCrypto)
+}
 
-	mainRegionName, err := tcestuary.GetMainRegionName()
-	if err != nil {
-		return cli.NewExitError(err, ToolError)
-	}
+// onUsageError 参数解析失败时返回 ExitUsage
+func onUsageError(ctx *cli.Context, err error, isSubcommand bool) error {
+	return cli.NewExitError(err, ExitUsage)
+}
 
-	for _, item := range mysql {
-		if mainRegionName == item.RegionName {
-			fmt.Println(item.Host, item.IP, item.Port, item.User, item.Password, item.RegionID)
-			break
-		}
+// setUsageError 为所有子命令设置 OnUsageError
+func setUsageError(cmds []*cli.Command) {
+	for _, cmd := range cmds {
+		cmd.OnUsageError = onUsageError
+		setUsageError(cmd.Subcommands)
 	}
-
-	return nil
 }
 
-// GetMysqlConfigAllZone 成功时, 向 STDOUT 顺序写出多行: host / ip / port / user / passwd / regionid / zoneid
-func GetMysqlConfigAllZone(ctx *cli.Context) error {
-	if ctx.Args().Len() != 1 {
-		cli.ShowCommandHelpAndExit(ctx, "getMysqlConfigAllZone", ToolError)
-	}
+// newClient 按全局参数创建 Client, --verbose 时 SDK 日志输出到 stderr
+func newClient(ctx *cli.Context) (*tcestuary.Client, error) {
+	return newClientWithDirectory(ctx, ctx.String("config-dir"))
+}
 
-	if ctx.IsSet("configDirectory") {
-		tcestuary.SetConfigDirectory(ctx.String("configDirectory"))
+// newClientWithDirectory 使用指定的配置目录创建 Client, 其它参数同 newClient
+func newClientWithDirectory(ctx *cli.Context, dir string) (*tcestuary.Client, error) {
+	opts := []tcestuary.Option{tcestuary.WithConfigDirectory(dir)}
+	if ctx.Bool("verbose") {
+		opts = append(opts, tcestuary.WithLogger(log.New(os.Stderr, "tcestuary ", log.LstdFlags)))
 	}
-
-	mysql, err := tcestuary.GetMysqlConfigAllZone(ctx.Args().First())
+	c, err := tcestuary.New(opts...)
 	if err != nil {
-		return cli.NewExitError(err, ToolError)
+		return nil, configError(err)
 	}
+	return c, nil
+}
 
-	for _, item := range mysql {
-		fmt.Println(item.Host, item.IP, item.Port, item.User, item.Password, item.RegionID, item.ZoneID)
+func main() {
+	app := &cli.App{
+		Name:  "tcestuary",
+		Usage: "tce config center shell tool",
+		Description: "退出码: 0 成功; 1 未分类错误; 2 参数错误; 3 配置错误; 4 加解密/签名失败; 5 验签不通过; 6 配置检查发现问题.\n" +
+			"   兼容命令沿用原有退出码: 200 执行失败; 201 validate 发现问题.",
+		Authors: []*cli.Author{
+			&cli.Author{
+				Name:  "torwang",
+				Email: "torwang@tencent.com",
+			},
+		},
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:    "config-dir",
+				Aliases: []string{"c"},
+				Usage:   "sdk.json 所在目录 `DIR`",
+				Value:   defaultConfigDirectory,
+				EnvVars: []string{"TCESTUARY_CONFIG_DIR"},
+			},
+			&cli.StringFlag{
+				Name:    "output",
+				Aliases: []string{"o"},
+				Usage:   "输出格式 `FORMAT`: text / json / env / dsn / template",
+				Value:   outputText,
+			},
+			&cli.BoolFlag{
+				Name:  "verbose",
+				Usage: "SDK 日志输出到 stderr",
+			},
+		},
+		OnUsageError: onUsageError,
+		Commands: append([]*cli.Command{
+			configCommand(),
+			cryptoCommand(),
+			signCommand(),
+			hashCommand(),
+			keysCommand(),
+			renderCommand(),
+		}, legacyCommands()...),
+	}
+	setUsageError(app.Commands)
+
+	// ExitCoder 错误由 app.Run 处理退出码, 其它错误在此处理
+	if err := app.Run(os.Args); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(ExitError)
 	}
-
-	return nil
 }
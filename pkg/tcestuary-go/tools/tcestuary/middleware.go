@@ -0,0 +1,211 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode"
+
+	"git.code.oa.com/tce-config/tcestuary-go/v4"
+	"git.code.oa.com/tce-config/tcestuary-go/v4/configcenter"
+	"git.code.oa.com/tce-config/tcestuary-go/v4/middlewareconfig"
+	"github.com/urfave/cli/v2"
+)
+
+// middlewareKind 中间件类型对应的配置结构及 all_region / all_zone 查询函数.
+// allRegion / allZone 返回 []*XxxConfigWithRegion / []*XxxConfigWithZone
+type middlewareKind struct {
+	newConfig func() middlewareconfig.MiddleWareConfig
+	allRegion func(name string) (interface{}, error)
+	allZone   func(name string) (interface{}, error)
+}
+
+// middlewareKinds 支持的中间件类型
+var middlewareKinds = map[string]middlewareKind{
+	middlewareconfig.ConfigMysql: {
+		newConfig: func() middlewareconfig.MiddleWareConfig { return &middlewareconfig.MysqlConfig{} },
+		allRegion: func(name string) (interface{}, error) { return middlewareconfig.GetMysqlConfigAllRegion(name) },
+		allZone:   func(name string) (interface{}, error) { return middlewareconfig.GetMysqlConfigAllZone(name) },
+	},
+	middlewareconfig.ConfigRedis: {
+		newConfig: func() middlewareconfig.MiddleWareConfig { return &middlewareconfig.RedisConfig{} },
+		allRegion: func(name string) (interface{}, error) { return middlewareconfig.GetRedisConfigAllRegion(name) },
+		allZone:   func(name string) (interface{}, error) { return middlewareconfig.GetRedisConfigAllZone(name) },
+	},
+	middlewareconfig.ConfigKafka: {
+		newConfig: func() middlewareconfig.MiddleWareConfig { return &middlewareconfig.KafkaConfig{} },
+		allRegion: func(name string) (interface{}, error) { return middlewareconfig.GetKafkaConfigAllRegion(name) },
+		allZone:   func(name string) (interface{}, error) { return middlewareconfig.GetKafkaConfigAllZone(name) },
+	},
+	middlewareconfig.ConfigCMQ: {
+		newConfig: func() middlewareconfig.MiddleWareConfig { return &middlewareconfig.CMQConfig{} },
+		allRegion: func(name string) (interface{}, error) { return middlewareconfig.GetCMQConfigAllRegion(name) },
+		allZone:   func(name string) (interface{}, error) { return middlewareconfig.GetCMQConfigAllZone(name) },
+	},
+	middlewareconfig.ConfigCSP: {
+		newConfig: func() middlewareconfig.MiddleWareConfig { return &middlewareconfig.CSPConfig{} },
+		allRegion: func(name string) (interface{}, error) { return middlewareconfig.GetCSPConfigAllRegion(name) },
+		allZone:   func(name string) (interface{}, error) { return middlewareconfig.GetCSPConfigAllZone(name) },
+	},
+	middlewareconfig.ConfigMongodb: {
+		newConfig: func() middlewareconfig.MiddleWareConfig { return &middlewareconfig.MongodbConfig{} },
+		allRegion: func(name string) (interface{}, error) { return middlewareconfig.GetMongodbConfigAllRegion(name) },
+		allZone:   func(name string) (interface{}, error) { return middlewareconfig.GetMongodbConfigAllZone(name) },
+	},
+	middlewareconfig.ConfigES: {
+		newConfig: func() middlewareconfig.MiddleWareConfig { return &middlewareconfig.ESConfig{} },
+		allRegion: func(name string) (interface{}, error) { return middlewareconfig.GetESConfigAllRegion(name) },
+		allZone:   func(name string) (interface{}, error) { return middlewareconfig.GetESConfigAllZone(name) },
+	},
+	middlewareconfig.ConfigZK: {
+		newConfig: func() middlewareconfig.MiddleWareConfig { return &middlewareconfig.ZKConfig{} },
+		allRegion: func(name string) (interface{}, error) { return middlewareconfig.GetZKConfigAllRegion(name) },
+		allZone:   func(name string) (interface{}, error) { return middlewareconfig.GetZKConfigAllZone(name) },
+	},
+	middlewareconfig.ConfigHdfs: {
+		newConfig: func() middlewareconfig.MiddleWareConfig { return &middlewareconfig.HdfsConfig{} },
+		allRegion: func(name string) (interface{}, error) { return middlewareconfig.GetHdfsConfigAllRegion(name) },
+		allZone:   func(name string) (interface{}, error) { return middlewareconfig.GetHdfsConfigAllZone(name) },
+	},
+}
+
+func middlewareKindNames() string {
+	names := make([]string, 0, len(middlewareKinds))
+	for name := range middlewareKinds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, " / ")
+}
+
+func middlewareCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "middleware",
+		Usage:     "获取中间件配置信息, 密码已解密. KIND: " + middlewareKindNames(),
+		ArgsUsage: "KIND name",
+		Action:    configMiddleware,
+		Flags: append([]cli.Flag{
+			&cli.StringFlag{
+				Name:  "scope",
+				Usage: "资源级别 `SCOPE`: flat / all_region / all_zone / main_region",
+				Value: scopeFlat,
+			},
+		}, formatFlags()...),
+	}
+}
+
+// structRecord 按字段顺序将配置转为 record, 字段名取 json tag, 未设置时转为下划线格式. 匿名结构体字段展开
+func structRecord(v reflect.Value) record {
+	if v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	var r record
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			r = append(r, structRecord(v.Field(i))...)
+			continue
+		}
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if name == "" {
+			name = snakeCase(f.Name)
+		}
+		r = append(r, field{name, v.Field(i).Interface()})
+	}
+	return r
+}
+
+// snakeCase RegionID -> region_id, DBNameList -> db_name_list
+func snakeCase(s string) string {
+	runes := []rune(s)
+	var b strings.Builder
+	for i, c := range runes {
+		if i > 0 && unicode.IsUpper(c) &&
+			(unicode.IsLower(runes[i-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1]))) {
+			b.WriteByte('_')
+		}
+		b.WriteRune(unicode.ToLower(c))
+	}
+	return b.String()
+}
+
+// middlewareError 配置项不存在或资源级别不匹配时给出具体的配置项
+func middlewareError(kind, name string, err error) error {
+	switch err {
+	case tcestuary.ErrNotFound:
+		return configError(fmt.Errorf("%s %s not found", kind, name))
+	case tcestuary.ErrUsageInvalid:
+		return configError(fmt.Errorf("%s %s: scope mismatch, check --scope", kind, name))
+	}
+	return configError(err)
+}
+
+func configMiddleware(ctx *cli.Context) error {
+	if ctx.Args().Len() != 2 {
+		return usageError("need exactly two arguments: KIND name")
+	}
+	kind, name := ctx.Args().Get(0), ctx.Args().Get(1)
+	mk, ok := middlewareKinds[kind]
+	if !ok {
+		return usageError("unknown middleware kind: %s, expect %s", kind, middlewareKindNames())
+	}
+	p, err := newPrinter(ctx, kind)
+	if err != nil {
+		return err
+	}
+	p.dsn = middlewareDSN[kind]
+
+	// middlewareconfig 使用全局配置目录
+	if err := tcestuary.SetConfigDirectory(filepath.Clean(ctx.String("config-dir"))); err != nil {
+		return configError(err)
+	}
+	m := mk.newConfig()
+	switch scope := ctx.String("scope"); scope {
+	case scopeFlat:
+		if err := m.GetConfig(name); err != nil {
+			return middlewareError(kind, name, err)
+		}
+		return p.one(structRecord(reflect.ValueOf(m)))
+
+	case scopeMainRegion:
+		mr, ok := m.(middlewareconfig.MainRegionConfig)
+		if !ok {
+			return usageError("%s not support scope %s", kind, scopeMainRegion)
+		}
+		if err := mr.GetConfigMainRegion(name); err != nil {
+			return middlewareError(kind, name, err)
+		}
+		return p.one(structRecord(reflect.ValueOf(m)))
+
+	case scopeAllRegion, scopeAllZone:
+		all := mk.allRegion
+		if scope == scopeAllZone {
+			all = mk.allZone
+		}
+		configs, err := all(name)
+		if err != nil {
+			return middlewareError(kind, name, err)
+		}
+		// 每一项附加 _base 中的 region_id / region_name / zone_id
+		items := reflect.ValueOf(configs)
+		records := make([]record, 0, items.Len())
+		for i := 0; i < items.Len(); i++ {
+			item := items.Index(i).Elem()
+			r := structRecord(item.Field(0))
+			switch base := item.Field(1).Interface().(type) {
+			case configcenter.Region:
+				r = append(r, field{"region_id", base.RegionID}, field{"region_name", base.RegionName})
+			case configcenter.Zone:
+				r = append(r, field{"region_id", base.RegionID}, field{"zone_id", base.ZoneID})
+			}
+			records = append(records, r)
+		}
+		return p.list(records)
+
+	default:
+		return usageError("unknown scope: %s", scope)
+	}
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"text/template"
+
+	"github.com/urfave/cli/v2"
+)
+
+// --output 取值
+const (
+	outputText = "text" // 单字段只输出值; 多字段每行 name: value
+	outputJSON = "json"
+	outputEnv  = "env" // PREFIX_NAME='value', 可直接 eval
+	outputDSN  = "dsn" // 连接串, 每条结果一行, 仅部分命令支持
+	// 每条结果执行一次 --template 指定的 text/template, 字段名与 json 输出相同, 如 {{.host}}
+	outputTemplate = "template"
+)
+
+// formatFlags 配置导出类命令的输出参数. --format 优先于全局 --output, 便于写在子命令之后
+func formatFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:    "format",
+			Aliases: []string{"f"},
+			Usage:   "输出格式 `FORMAT`: text / json / env / dsn / template, 默认同 --output",
+		},
+		&cli.StringFlag{
+			Name:  "template",
+			Usage: "--format template 使用的 Go text/template `TEXT`",
+		},
+		&cli.StringFlag{
+			Name:  "template-file",
+			Usage: "--format template 使用的模板文件 `FILE`",
+		},
+	}
+}
+
+type field struct {
+	name  string
+	value interface{}
+}
+
+// record 有序字段, JSON 输出时保持字段顺序
+type record []field
+
+func (r record) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, f := range r {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(f.name)
+		value, err := json.Marshal(f.value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// values 转为 map, 用于执行模板
+func (r record) values() map[string]interface{} {
+	m := make(map[string]interface{}, len(r))
+	for _, f := range r {
+		m[f.name] = f.value
+	}
+	return m
+}
+
+// str 返回字段的字符串形式, 字符串列表以逗号分隔. 字段不存在时返回空串
+func (r record) str(name string) string {
+	switch v := r.value(name).(type) {
+	case nil:
+		return ""
+	case []string:
+		return strings.Join(v, ",")
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// value 返回字段值, 字段不存在时返回 nil
+func (r record) value(name string) interface{} {
+	for _, f := range r {
+		if f.name == name {
+			return f.value
+		}
+	}
+	return nil
+}
+
+// printer 按 --output 输出命令结果
+type printer struct {
+	w      io.Writer
+	format string
+	prefix string // env 格式的变量名前缀, 如 MYSQL
+	// dsn 根据字段生成连接串, 为 nil 时不支持 dsn 格式
+	dsn  func(r record) (string, error)
+	tmpl *template.Template
+}
+
+func newPrinter(ctx *cli.Context, prefix string) (*printer, error) {
+	format := ctx.String("output")
+	if ctx.IsSet("format") {
+		format = ctx.String("format")
+	}
+	p := &printer{w: os.Stdout, format: format, prefix: prefix}
+	switch format {
+	case outputText, outputJSON, outputEnv, outputDSN:
+	case outputTemplate:
+		text := ctx.String("template")
+		if file := ctx.String("template-file"); file != "" {
+			data, err := ioutil.ReadFile(file)
+			if err != nil {
+				return nil, usageError("read template file error: %s", err)
+			}
+			text = string(data)
+		}
+		if text == "" {
+			return nil, usageError("--template or --template-file is required for template format")
+		}
+		tmpl, err := template.New("output").Funcs(template.FuncMap{"shellquote": shellQuote}).Option("missingkey=error").Parse(text)
+		if err != nil {
+			return nil, usageError("parse template error: %s", err)
+		}
+		p.tmpl = tmpl
+	default:
+		return nil, usageError("unknown output format: %s, expect text / json / env / dsn / template", format)
+	}
+	return p, nil
+}
+
+// one 输出单条结果
+func (p *printer) one(r record) error {
+	switch p.format {
+	case outputJSON:
+		return p.json(r)
+	case outputEnv:
+		p.env(p.prefix, r)
+	case outputDSN, outputTemplate:
+		return p.line(r)
+	default:
+		p.text(r)
+	}
+	return nil
+}
+
+// list 输出多条结果. env 格式额外输出 PREFIX_COUNT, 字段名为 PREFIX_<序号>_NAME
+func (p *printer) list(rs []record) error {
+	switch p.format {
+	case outputJSON:
+		if rs == nil {
+			rs = []record{}
+		}
+		return p.json(rs)
+	case outputEnv:
+		fmt.Fprintf(p.w, "%s=%d\n", envName(p.prefix, "count"), len(rs))
+		for i, r := range rs {
+			p.env(envName(p.prefix, fmt.Sprint(i)), r)
+		}
+	case outputDSN, outputTemplate:
+		for _, r := range rs {
+			if err := p.line(r); err != nil {
+				return err
+			}
+		}
+	default:
+		for i, r := range rs {
+			if i > 0 && len(r) > 1 {
+				fmt.Fprintln(p.w)
+			}
+			p.text(r)
+		}
+	}
+	return nil
+}
+
+func (p *printer) json(v interface{}) error {
+	buff, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(p.w, string(buff))
+	return nil
+}
+
+// line 按 dsn / template 格式输出一条结果, 末尾没有换行时补充换行
+func (p *printer) line(r record) error {
+	var out string
+	if p.format == outputDSN {
+		if p.dsn == nil {
+			return usageError("dsn format is not supported by this command")
+		}
+		dsn, err := p.dsn(r)
+		if err != nil {
+			return usageError("%s", err)
+		}
+		out = dsn
+	} else {
+		var buf bytes.Buffer
+		if err := p.tmpl.Execute(&buf, r.values()); err != nil {
+			return usageError("execute template error: %s", err)
+		}
+		out = buf.String()
+	}
+	if !strings.HasSuffix(out, "\n") {
+		out += "\n"
+	}
+	_, err := io.WriteString(p.w, out)
+	return err
+}
+
+func (p *printer) text(r record) {
+	if len(r) == 1 {
+		fmt.Fprintln(p.w, r[0].value)
+		return
+	}
+	for _, f := range r {
+		fmt.Fprintf(p.w, "%s: %v\n", f.name, f.value)
+	}
+}
+
+func (p *printer) env(prefix string, r record) {
+	for _, f := range r {
+		fmt.Fprintf(p.w, "%s=%s\n", envName(prefix, f.name), shellQuote(r.str(f.name)))
+	}
+}
+
+// envName 拼接环境变量名, 非字母数字替换为 _
+func envName(parts ...string) string {
+	name := strings.ToUpper(strings.Join(parts, "_"))
+	name = strings.TrimLeft(name, "_")
+	return strings.Map(func(r rune) rune {
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '_' {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// shellQuote 单引号转义, 值中包含空格、引号时 eval 结果不变
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+// argOrStdin 返回第 i 个参数, 参数不存在或为 - 时读取标准输入(去掉末尾换行), 避免敏感信息出现在进程参数中
+func argOrStdin(ctx *cli.Context, i int, name string) (string, error) {
+	if arg := ctx.Args().Get(i); arg != "" && arg != "-" {
+		return arg, nil
+	}
+	if stat, err := os.Stdin.Stat(); err == nil && stat.Mode()&os.ModeCharDevice != 0 {
+		return "", usageError("%s is required, pass it as an argument or via stdin", name)
+	}
+	data, err := ioutil.ReadAll(bufio.NewReader(os.Stdin))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
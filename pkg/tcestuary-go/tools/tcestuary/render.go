@@ -0,0 +1,63 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"strconv"
+
+	"github.com/urfave/cli/v2"
+)
+
+func renderCommand() *cli.Command {
+	return &cli.Command{
+		Name: "render",
+		Usage: "使用配置渲染 Go text/template 模板, 生成 nginx、php-fpm 等组件的配置文件. " +
+			"模板函数: mysql \"dbsql.database\" / redis \"name\" / region / zone / gaia / mainRegion",
+		ArgsUsage: "[template-file]",
+		Action:    render,
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "out",
+				Usage: "输出文件 `FILE`, 先写入临时文件再重命名. 默认输出到标准输出",
+			},
+			&cli.StringFlag{
+				Name:  "mode",
+				Usage: "输出文件权限 `MODE`, 八进制",
+				Value: "0600",
+			},
+		},
+	}
+}
+
+func render(ctx *cli.Context) error {
+	mode, err := strconv.ParseUint(ctx.String("mode"), 8, 32)
+	if err != nil || mode > 0777 {
+		return usageError("invalid mode: %s", ctx.String("mode"))
+	}
+	var tmpl []byte
+	if name := ctx.Args().First(); name != "" && name != "-" {
+		if tmpl, err = ioutil.ReadFile(name); err != nil {
+			return usageError("read template error: %s", err)
+		}
+	} else {
+		text, err := argOrStdin(ctx, 0, "template")
+		if err != nil {
+			return err
+		}
+		tmpl = []byte(text)
+	}
+
+	c, err := newClient(ctx)
+	if err != nil {
+		return err
+	}
+	if out := ctx.String("out"); out != "" {
+		err = c.RenderFile(out, string(tmpl), os.FileMode(mode))
+	} else {
+		err = c.Render(os.Stdout, string(tmpl))
+	}
+	if err != nil {
+		return configError(err)
+	}
+	return nil
+}
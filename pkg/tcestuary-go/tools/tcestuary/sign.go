@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/hex"
+
+	"github.com/urfave/cli/v2"
+)
+
+func signCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "sign",
+		Usage: "使用 sign-secret 签名、验签",
+		Subcommands: []*cli.Command{
+			{
+				Name:      "create",
+				Usage:     "签名, 未指定参数时从标准输入读取消息",
+				ArgsUsage: "[message]",
+				Action:    signCreate,
+			},
+			{
+				Name:      "verify",
+				Usage:     "验签, 签名不匹配时返回 5",
+				ArgsUsage: "signature [message]",
+				Action:    signVerify,
+			},
+		},
+	}
+}
+
+func hashCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "hash",
+		Usage:     "使用 hash-secret 计算散列值(hex), 未指定参数时从标准输入读取消息",
+		ArgsUsage: "[message]",
+		Action:    hashAction,
+	}
+}
+
+func signCreate(ctx *cli.Context) error {
+	msg, err := argOrStdin(ctx, 0, "message")
+	if err != nil {
+		return err
+	}
+	p, err := newPrinter(ctx, "")
+	if err != nil {
+		return err
+	}
+	c, err := newClient(ctx)
+	if err != nil {
+		return err
+	}
+	s, err := c.NewSigner()
+	if err != nil {
+		return configError(err)
+	}
+	signature, err := s.Sign(msg)
+	if err != nil {
+		return cryptoError(err)
+	}
+	return p.one(record{{"signature", signature}})
+}
+
+func signVerify(ctx *cli.Context) error {
+	if ctx.Args().Len() < 1 {
+		return usageError("signature is required")
+	}
+	signature := ctx.Args().First()
+	msg, err := argOrStdin(ctx, 1, "message")
+	if err != nil {
+		return err
+	}
+	p, err := newPrinter(ctx, "")
+	if err != nil {
+		return err
+	}
+	c, err := newClient(ctx)
+	if err != nil {
+		return err
+	}
+	s, err := c.NewSigner()
+	if err != nil {
+		return configError(err)
+	}
+	valid, err := s.Verify(msg, signature)
+	if err != nil {
+		return cryptoError(err)
+	}
+	if err := p.one(record{{"valid", valid}}); err != nil {
+		return err
+	}
+	if !valid {
+		return cli.NewExitError("", ExitVerifyFailed)
+	}
+	return nil
+}
+
+func hashAction(ctx *cli.Context) error {
+	msg, err := argOrStdin(ctx, 0, "message")
+	if err != nil {
+		return err
+	}
+	p, err := newPrinter(ctx, "")
+	if err != nil {
+		return err
+	}
+	c, err := newClient(ctx)
+	if err != nil {
+		return err
+	}
+	hasher, err := c.NewTHasher()
+	if err != nil {
+		return configError(err)
+	}
+	h, err := hasher.New()
+	if err != nil {
+		return cryptoError(err)
+	}
+	if err := h.Update([]byte(msg)); err != nil {
+		return cryptoError(err)
+	}
+	digest, err := h.Digest()
+	if err != nil {
+		return cryptoError(err)
+	}
+	return p.one(record{{"digest", hex.EncodeToString(digest)}})
+}
@@ -17,7 +17,7 @@ const ToolError int = 200
 func main() {
 	app := &cli.App{
 		Name:  "Tencent Hash Client",
-		Usage: "tce config center shell tool",
+		Usage: "tce config center shell tool (已废弃, 请使用 tcestuary hash)",
 		Authors: []*cli.Author{
 			&cli.Author{
 				Name:  "wentaoyin",
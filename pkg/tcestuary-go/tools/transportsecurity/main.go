@@ -16,7 +16,7 @@ const ToolError int = 200
 func main() {
 	app := &cli.App{
 		Name:  "transport security",
-		Usage: "tce config center shell tool",
+		Usage: "tce config center shell tool (已废弃, 请使用 tcestuary crypto transport)",
 		Authors: []*cli.Author{
 			&cli.Author{
 				Name:  "wentaoyin",
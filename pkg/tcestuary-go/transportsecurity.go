@@ -2,35 +2,45 @@ package tcestuary
 
 import (
 	"encoding/json"
-	"fmt"
 	"os"
 
 	"git.code.oa.com/tce-config/tcestuary-go/v4/configcenter"
-	"git.code.oa.com/tce-config/tcestuary-go/v4/tcesecurity"
 )
 
 type TransportSecurity interface {
 	Encrypt(string) (string, error) // 加密，明文输入长度限制与算法相关
 	Decrypt(string) (string, error) // 解密，密文输入长度限制与算法相关
+	// 加密并绑定上下文(如 表名、列名、主键), 解密时必须提供相同的上下文. 上下文为空时等价于 Encrypt.
+	// 仅 aes-256-gcm / tsm-sm4-128-gcm / kms-envelope / kms-envelope-sm4 支持, 其他算法返回 tcesecurity.ErrNotSupportContext
+	EncryptWithContext(plaintext string, aad []byte) (string, error)
+	DecryptWithContext(ciphertext string, aad []byte) (string, error)
 }
 
-func parseTransportSecretConfig() (configcenter.SecretConfig, error) {
+func (c *Client) parseTransportSecretConfig() (configcenter.SecretConfig, error) {
+	if c.transportSecret != nil {
+		return *c.transportSecret, nil
+	}
 	transportEnv := os.Getenv("TRANSPORT_SECRET")
 	var secretConf configcenter.SecretConfig
 	if transportEnv == "" {
-		if err := std.Load(); err != nil {
+		if err := c.Load(); err != nil {
 			return secretConf, err
 		}
-		return std.ConfigCenter.SDK.TransportSecret, nil
+		return c.ConfigCenter().SDK.TransportSecret, nil
 	}
 	err := json.Unmarshal([]byte(transportEnv), &secretConf)
 	return secretConf, err
 }
 
+// parseTransportSecretConfig 默认 Client 的配置
+func parseTransportSecretConfig() (configcenter.SecretConfig, error) {
+	return std.parseTransportSecretConfig()
+}
+
 // NewTransportSecurity 传输安全组件
-func NewTransportSecurity() (TransportSecurity, error) {
+func (c *Client) NewTransportSecurity() (TransportSecurity, error) {
 	// 判断是否加载TSM
-	tsmConf, err := parseTSMSecretConfig()
+	tsmConf, err := c.parseTSMSecretConfig()
 	if err != nil {
 		return nil, err
 	}
@@ -40,24 +50,14 @@ func NewTransportSecurity() (TransportSecurity, error) {
 		}
 	}
 	// 密钥配置
-	secretConf, err := parseTransportSecretConfig()
+	secretConf, err := c.parseTransportSecretConfig()
 	if err != nil {
 		return nil, err
 	}
+	return c.newCrypto(secretConf)
+}
 
-	f, ok := tcesecurity.SupportAlgorithm[secretConf.Method]
-	if !ok {
-		return nil, fmt.Errorf("not support algorithm: %s", secretConf.Method)
-	}
-	return f(tcesecurity.CryptoOpts{
-		Method:     secretConf.Method,
-		AesKey:     secretConf.AesKey,
-		Sm4Key:     secretConf.Sm4Key,
-		PrivateKey: secretConf.PrivateKey,
-		PublicKey:  secretConf.PublicKey,
-		KeyId:      secretConf.KeyId,
-		SecretId:   secretConf.SecretId,
-		SecretKey:  secretConf.SecretKey,
-		KMSServer:  secretConf.KMSServer,
-	})
+// NewTransportSecurity 使用默认 Client, 参考 Client.NewTransportSecurity
+func NewTransportSecurity() (TransportSecurity, error) {
+	return std.NewTransportSecurity()
 }
@@ -0,0 +1,42 @@
+//go:build !cgo || purego
+// +build !cgo purego
+
+package tcestuary
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"git.code.oa.com/tce-config/tcestuary-go/v4/configcenter"
+	"git.code.oa.com/tce-config/tcestuary-go/v4/tcesecurity"
+	"git.code.oa.com/tce-config/tcestuary-go/v4/tcesecurity/kmstest"
+	"github.com/stretchr/testify/assert"
+)
+
+// kms-sm2 本地使用 SM3 计算摘要, cgo 版本需要先初始化 TencentSM
+
+func TestTransportSecurityKMS(t *testing.T) {
+	srv := kmstest.NewServer()
+	defer srv.Close()
+	conf, _ := json.Marshal(configcenter.SecretConfig{
+		Method:       tcesecurity.KMSSm2Algorithm,
+		KeyId:        "transport-key",
+		SecretId:     srv.SecretId,
+		SecretKey:    srv.SecretKey,
+		KMSServer:    srv.Host(),
+		KMSTransport: &configcenter.KMSTransport{CACert: srv.CACert()},
+	})
+	os.Setenv("TRANSPORT_SECRET", string(conf))
+	defer os.Unsetenv("TRANSPORT_SECRET")
+
+	s, err := NewTransportSecurity()
+	assert.NoError(t, err)
+	ciphertext, err := s.Encrypt("mysql_pass")
+	assert.NoError(t, err)
+	plaintext, err := s.Decrypt(ciphertext)
+	assert.NoError(t, err)
+	assert.Equal(t, "mysql_pass", plaintext)
+	assert.Equal(t, 1, srv.Calls("AsymmetricSm2Encrypt"))
+	assert.Equal(t, 1, srv.Calls("AsymmetricSm2Decrypt"))
+}
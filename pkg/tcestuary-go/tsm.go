@@ -11,12 +11,17 @@ import (
 
 var initOnce sync.Once
 
-func parseTSMSecretConfig() (configcenter.TSMConfig, error) {
+func (c *Client) parseTSMSecretConfig() (configcenter.TSMConfig, error) {
 	var tsmConf configcenter.TSMConfig
-	if err := std.Load(); err != nil {
+	if err := c.Load(); err != nil {
 		return tsmConf, nil
 	}
-	return std.ConfigCenter.SDK.TSMSecret, nil
+	return c.ConfigCenter().SDK.TSMSecret, nil
+}
+
+// parseTSMSecretConfig 默认 Client 的配置
+func parseTSMSecretConfig() (configcenter.TSMConfig, error) {
+	return std.parseTSMSecretConfig()
 }
 
 // 初始化TSM
@@ -0,0 +1,563 @@
+package tcestuary
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"git.code.oa.com/tce-config/tcestuary-go/v4/configcenter"
+	"git.code.oa.com/tce-config/tcestuary-go/v4/tcesecurity"
+)
+
+// 配置检查问题级别
+const (
+	LevelError   = "error"   // 运行时会失败或配置项被忽略
+	LevelWarning = "warning" // 可以运行, 但存在风险
+)
+
+// ValidateIssue 配置检查发现的问题. Path 为问题在 sdk.json 中的 JSON 路径, 如 $.sdk["storage-secret"].aes_key
+type ValidateIssue struct {
+	Level   string `json:"level"`
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+func (issue ValidateIssue) String() string {
+	return fmt.Sprintf("%s %s: %s", issue.Level, issue.Path, issue.Message)
+}
+
+// ValidateReport 配置检查结果
+type ValidateReport struct {
+	File   string          `json:"file"`
+	Issues []ValidateIssue `json:"issues"`
+}
+
+// HasErrors 是否存在 error 级别的问题
+func (r *ValidateReport) HasErrors() bool {
+	for _, issue := range r.Issues {
+		if issue.Level == LevelError {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate 检查配置目录下的 sdk.json 及 cc.declare.json, 返回所有问题. 仅在文件读取失败时返回错误.
+// 检查不访问网络, kms-* 算法只检查配置项是否完整
+func Validate(dir string) (*ValidateReport, error) {
+	file := filepath.Join(dir, "sdk.json")
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	declareFile := filepath.Join(dir, declareFileName)
+	d, err := ioutil.ReadFile(declareFile)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	var declaration *configcenter.Declaration
+	var declareErr error
+	if len(d) > 0 {
+		declaration, declareErr = configcenter.ParseDeclaration(d)
+	}
+
+	report := validateConfig(data, declaration)
+	report.File = file
+	if declareErr != nil {
+		report.Issues = append([]ValidateIssue{{
+			Level:   LevelWarning,
+			Path:    "$",
+			Message: fmt.Sprintf("parse %s error, ignore declaration, %s", declareFileName, declareErr),
+		}}, report.Issues...)
+	}
+	return report, nil
+}
+
+// ValidateConfig 检查 sdk.json 内容, 参考 Validate
+func ValidateConfig(data []byte) *ValidateReport {
+	return validateConfig(data, nil)
+}
+
+type validator struct {
+	issues []ValidateIssue
+}
+
+func (v *validator) errorf(path, format string, args ...interface{}) {
+	v.issues = append(v.issues, ValidateIssue{Level: LevelError, Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) warnf(path, format string, args ...interface{}) {
+	v.issues = append(v.issues, ValidateIssue{Level: LevelWarning, Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+// decode 反序列化配置项, 失败时记录错误
+func (v *validator) decode(path string, message json.RawMessage, out interface{}) bool {
+	if err := json.Unmarshal(message, out); err != nil {
+		v.errorf(path, "%s", err)
+		return false
+	}
+	return true
+}
+
+// fieldErrors 记录 Valid 返回的错误, 字段错误映射为字段的 JSON 路径
+func (v *validator) fieldErrors(path string, err error) {
+	errs, ok := err.(configcenter.FieldErrors)
+	if !ok {
+		if err != nil {
+			v.errorf(path, "%s", err)
+		}
+		return
+	}
+	for _, e := range errs {
+		fieldPath := path
+		if e.Field != "" {
+			fieldPath = jsonPath(path, e.Field)
+		}
+		v.errorf(fieldPath, "%s", e.Message)
+	}
+}
+
+var identifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// jsonPath 拼接 JSON 路径, key 不是标识符时使用 ["key"] 形式
+func jsonPath(parent string, key string) string {
+	if identifier.MatchString(key) {
+		return parent + "." + key
+	}
+	return parent + "[" + strconv.Quote(key) + "]"
+}
+
+func indexPath(parent string, i int) string {
+	return parent + "[" + strconv.Itoa(i) + "]"
+}
+
+// sortedKeys map 的 key 排序后返回, 保证输出顺序稳定
+func sortedKeys(m map[string]json.RawMessage) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func validateConfig(data []byte, declaration *configcenter.Declaration) *ValidateReport {
+	v := &validator{}
+	report := &ValidateReport{}
+
+	sections := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(data, &sections); err != nil {
+		if e, ok := err.(*json.SyntaxError); ok {
+			line := bytes.Count(data[:e.Offset], []byte("\n")) + 1
+			v.errorf("$", "invalid json at line %d: %s", line, err)
+		} else {
+			v.errorf("$", "%s", err)
+		}
+		report.Issues = v.issues
+		return report
+	}
+
+	if message, ok := sections["base"]; ok {
+		v.validateBase("$.base", message)
+	}
+	if message, ok := sections["sdk"]; ok {
+		v.validateSDK("$.sdk", message)
+	}
+
+	// 与 ConfigCenter 解析结果对照, 确保检查规则遗漏时也能发现被忽略的配置项
+	center := configcenter.NewConfigCenter()
+	if err := center.ParseWithDeclaration(data, declaration); err != nil {
+		v.errorf("$", "%s", err)
+	}
+	for _, kind := range configcenter.ServiceKinds {
+		message, ok := sections[kind]
+		if !ok {
+			continue
+		}
+		path := jsonPath("$", kind)
+		services := make(map[string]json.RawMessage)
+		if !v.decode(path, message, &services) {
+			continue
+		}
+		for _, name := range sortedKeys(services) {
+			servicePath := jsonPath(path, name)
+			n := len(v.issues)
+			v.validateService(kind, servicePath, services[name])
+			if len(v.issues) == n && center.FindService(kind, name) == nil {
+				v.errorf(servicePath, "unknown %s config type, config is ignored", kind)
+			}
+		}
+	}
+	for _, issue := range center.DeclareIssues {
+		v.warnf(declarePath(sections, issue.Service), "%s: %s", declareFileName, issue.Message)
+	}
+
+	report.Issues = v.issues
+	return report
+}
+
+// declarePath 声明问题对应的配置项路径, 配置项不存在时返回 $
+func declarePath(sections map[string]json.RawMessage, name string) string {
+	for _, kind := range append([]string{"sdk"}, configcenter.ServiceKinds...) {
+		items := make(map[string]json.RawMessage)
+		if json.Unmarshal(sections[kind], &items) == nil {
+			if _, ok := items[name]; ok {
+				return jsonPath(jsonPath("$", kind), name)
+			}
+		}
+	}
+	return "$"
+}
+
+func (v *validator) validateBase(path string, message json.RawMessage) {
+	base := make(map[string]json.RawMessage)
+	if !v.decode(path, message, &base) {
+		return
+	}
+	if local, ok := base["local"]; ok {
+		// local 中的字段与部署级别有关, 只检查类型
+		localPath := jsonPath(path, "local")
+		for _, out := range []interface{}{&configcenter.Region{}, &configcenter.Zone{}, &configcenter.Gaia{}} {
+			if !v.decode(localPath, local, out) {
+				break
+			}
+		}
+	}
+	if message, ok := base["region_list"]; ok {
+		var regions []configcenter.Region
+		listPath := jsonPath(path, "region_list")
+		if v.decode(listPath, message, &regions) {
+			for i := range regions {
+				v.fieldErrors(indexPath(listPath, i), regions[i].Valid())
+			}
+		}
+	}
+	if message, ok := base["zone_list"]; ok {
+		var zones []configcenter.Zone
+		listPath := jsonPath(path, "zone_list")
+		if v.decode(listPath, message, &zones) {
+			for i := range zones {
+				v.fieldErrors(indexPath(listPath, i), zones[i].Valid())
+			}
+		}
+	}
+	if message, ok := base["gaia_list"]; ok {
+		var gaias []configcenter.Gaia
+		listPath := jsonPath(path, "gaia_list")
+		if v.decode(listPath, message, &gaias) {
+			for i := range gaias {
+				v.fieldErrors(indexPath(listPath, i), gaias[i].Valid())
+			}
+		}
+	}
+}
+
+// secret 的用途, 决定可用的算法
+const (
+	secretCrypto = iota
+	secretSign
+)
+
+func (v *validator) validateSDK(path string, message json.RawMessage) {
+	sdk := make(map[string]json.RawMessage)
+	if !v.decode(path, message, &sdk) {
+		return
+	}
+	for _, name := range []string{"passwd-secret", "storage-secret", "transport-secret", "sign-secret"} {
+		message, ok := sdk[name]
+		if !ok {
+			continue
+		}
+		secretPath := jsonPath(path, name)
+		var conf configcenter.SecretConfig
+		if !v.decode(secretPath, message, &conf) {
+			continue
+		}
+		usage := secretCrypto
+		if name == "sign-secret" {
+			usage = secretSign
+		}
+		if name == "passwd-secret" && conf.Method == "" {
+			// 兼容历史版本, method 为空时使用 aeskey 及 aes-256-cbc, 与 passwdSecretConfig 相同
+			if len(conf.Keys) == 0 {
+				if conf.V1Aeskey != "" {
+					v.validateAesKey(jsonPath(secretPath, "aeskey"), conf.V1Aeskey)
+				}
+				continue
+			}
+			conf.Method, conf.AesKey = tcesecurity.Aes256CbcAlgorithm, conf.V1Aeskey
+			keys := make([]configcenter.SecretConfig, len(conf.Keys))
+			for i, key := range conf.Keys {
+				if key.AesKey == "" {
+					key.AesKey = key.V1Aeskey
+				}
+				keys[i] = key
+			}
+			conf.Keys = keys
+		}
+		v.validateSecret(secretPath, usage, conf)
+	}
+
+	if message, ok := sdk["hash-secret"]; ok {
+		hashPath := jsonPath(path, "hash-secret")
+		var conf configcenter.HashConfig
+		if v.decode(hashPath, message, &conf) && conf.Method != "" {
+			if _, ok := tcesecurity.SupportHashFunc[conf.Method]; !ok {
+				v.errorf(jsonPath(hashPath, "method"), "not support algorithm: %s", conf.Method)
+			}
+		}
+	}
+	if message, ok := sdk["tsm"]; ok {
+		var conf configcenter.TSMConfig
+		v.decode(jsonPath(path, "tsm"), message, &conf)
+	}
+}
+
+// validateSecret 检查密钥配置. 配置了 keys 时逐个检查密钥环中的密钥, 外层配置了密钥时同时检查外层
+func (v *validator) validateSecret(path string, usage int, conf configcenter.SecretConfig) {
+	if len(conf.Keys) == 0 {
+		v.validateKey(path, usage, conf)
+		return
+	}
+
+	keysPath := jsonPath(path, "keys")
+	ids := make(map[string]bool, len(conf.Keys))
+	for i, key := range conf.Keys {
+		keyPath := indexPath(keysPath, i)
+		if key.ID == "" {
+			v.errorf(jsonPath(keyPath, "id"), "key id is empty")
+			continue
+		}
+		if ids[key.ID] {
+			v.errorf(jsonPath(keyPath, "id"), "duplicate key id: %q", key.ID)
+			continue
+		}
+		ids[key.ID] = true
+		merged, _ := conf.KeyConfig(key.ID)
+		v.validateKey(keyPath, usage, merged)
+	}
+	if usage == secretCrypto && !ids[conf.ActiveKey] {
+		v.errorf(jsonPath(path, "active_key"), "active key not found in keyring: %q", conf.ActiveKey)
+	}
+	if conf.AesKey != "" || conf.Sm4Key != "" || conf.PrivateKey != "" || conf.PublicKey != "" || conf.KeyId != "" {
+		outer := conf
+		outer.Keys, outer.ActiveKey = nil, ""
+		v.validateKey(path, usage, outer)
+	}
+}
+
+// validateKey 检查单个密钥的算法及密钥内容
+func (v *validator) validateKey(path string, usage int, conf configcenter.SecretConfig) {
+	methodPath := jsonPath(path, "method")
+	if conf.Method == "" {
+		v.errorf(methodPath, "method is empty")
+		return
+	}
+	supported := false
+	if usage == secretSign {
+		_, supported = tcesecurity.SupportSignFunc[conf.Method]
+	} else {
+		_, supported = tcesecurity.SupportAlgorithm[conf.Method]
+	}
+	if !supported {
+		v.errorf(methodPath, "not support algorithm: %s", conf.Method)
+		return
+	}
+
+	switch conf.Method {
+	case tcesecurity.Aes256CbcAlgorithm, tcesecurity.Aes256GcmAlgorithm:
+		v.validateAesKey(jsonPath(path, "aes_key"), conf.AesKey)
+
+	case tcesecurity.TSM4Algorithm:
+		if len(conf.Sm4Key) < 16 {
+			v.errorf(jsonPath(path, "sm4_key"), "sm4 key length must be at least 16, got %d", len(conf.Sm4Key))
+		}
+
+	case tcesecurity.Rsa2048Algorithm, tcesecurity.Rsa1024Algorithm:
+		pub, priv := v.decodeKeyPair(path, conf)
+		if pub != nil && priv != nil {
+			if _, err := tcesecurity.NewRsaCrypto(conf.Method, pub, priv); err != nil {
+				v.errorf(jsonPath(path, "private_key"), "%s", err)
+			}
+		}
+
+	case tcesecurity.Tsm2Algorithm, tcesecurity.Tsm2SignAlgorithm:
+		v.decodeKeyPair(path, conf)
+
+	case tcesecurity.KMSSm4Algorithm, tcesecurity.KMSSm2Algorithm, tcesecurity.KMSEnvelopeAlgorithm, tcesecurity.KMSEnvelopeSM4Algorithm,
+		tcesecurity.KMSSignAlgorithm:
+		v.validateKMS(path, conf)
+	}
+}
+
+// validateAesKey aes 密钥长度需为 16 / 24 / 32, aes-256 推荐 32
+func (v *validator) validateAesKey(path string, key string) {
+	switch len(key) {
+	case 32:
+	case 16, 24:
+		v.warnf(path, "aes-256 expects a 32-byte key, got %d", len(key))
+	default:
+		v.errorf(path, "aes key length must be 16, 24 or 32, got %d", len(key))
+	}
+}
+
+// decodeKeyPair 公钥、私钥需为 base64 编码
+func (v *validator) decodeKeyPair(path string, conf configcenter.SecretConfig) (pub, priv []byte) {
+	decode := func(field, value string) []byte {
+		if value == "" {
+			v.errorf(jsonPath(path, field), "%s is empty", field)
+			return nil
+		}
+		b, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			v.errorf(jsonPath(path, field), "%s is not base64 encoded: %s", field, err)
+			return nil
+		}
+		return b
+	}
+	return decode("public_key", conf.PublicKey), decode("private_key", conf.PrivateKey)
+}
+
+// nopLogger 检查传输配置时不输出告警日志, 告警记录到检查结果中
+type nopLogger struct{}
+
+func (nopLogger) Printf(string, ...interface{}) {}
+
+// validateKMS 检查 kms-* 算法的必填项及传输、重试配置, 不访问 KMS 服务
+func (v *validator) validateKMS(path string, conf configcenter.SecretConfig) {
+	required := []struct{ field, value string }{
+		{"key_id", conf.KeyId},
+		{"kms_server", conf.KMSServer},
+		{"secret_id", conf.SecretId},
+		{"secret_key", conf.SecretKey},
+	}
+	for _, item := range required {
+		if item.value == "" {
+			v.errorf(jsonPath(path, item.field), "%s is empty", item.field)
+		}
+	}
+	if conf.DataKeyTTL < 0 {
+		v.errorf(jsonPath(path, "data_key_ttl"), "data_key_ttl must not be negative")
+	}
+
+	if t := conf.KMSTransport; t != nil {
+		transportPath := jsonPath(path, "kms_transport")
+		if t.InsecureSkipVerify {
+			v.warnf(jsonPath(transportPath, "insecure_skip_verify"), "tls certificate verification is disabled, do not use in production")
+		}
+		if t.Timeout < 0 {
+			v.errorf(jsonPath(transportPath, "timeout"), "timeout must not be negative")
+		}
+		if t.DialTimeout < 0 {
+			v.errorf(jsonPath(transportPath, "dial_timeout"), "dial_timeout must not be negative")
+		}
+		opts := newKMSTransportOpts(t, nopLogger{})
+		if _, err := tcesecurity.NewKMSTransport(conf.KMSServer, opts); err != nil {
+			v.errorf(transportPath, "%s", err)
+		}
+	}
+
+	if r := conf.KMSRetry; r != nil {
+		retryPath := jsonPath(path, "kms_retry")
+		if r.MaxRetries < -1 {
+			v.errorf(jsonPath(retryPath, "max_retries"), "max_retries must be -1 or greater")
+		}
+		if r.BreakerThreshold < -1 {
+			v.errorf(jsonPath(retryPath, "breaker_threshold"), "breaker_threshold must be -1 or greater")
+		}
+		durations := []struct {
+			field string
+			value int
+		}{
+			{"base_delay", r.BaseDelay},
+			{"max_delay", r.MaxDelay},
+			{"breaker_cooldown", r.BreakerCooldown},
+		}
+		for _, item := range durations {
+			if item.value < 0 {
+				v.errorf(jsonPath(retryPath, item.field), "%s must not be negative", item.field)
+			}
+		}
+		if r.BaseDelay > 0 && r.MaxDelay > 0 && r.BaseDelay > r.MaxDelay {
+			v.warnf(retryPath, "base_delay %s is greater than max_delay %s",
+				time.Duration(r.BaseDelay)*time.Millisecond, time.Duration(r.MaxDelay)*time.Millisecond)
+		}
+	}
+}
+
+// validateService 检查中间件配置. 对象为扁平资源描述, 数组为 ALL_REGION / ALL_ZONE / ALL_GAIA 资源描述
+func (v *validator) validateService(kind, path string, message json.RawMessage) {
+	trimmed := bytes.TrimSpace(message)
+	if len(trimmed) == 0 || trimmed[0] != '[' {
+		v.validateFlatService(kind, path, message)
+		return
+	}
+
+	var items []struct {
+		Base    json.RawMessage `json:"_base"`
+		Service json.RawMessage `json:"_service"`
+	}
+	if !v.decode(path, message, &items) {
+		return
+	}
+	if len(items) == 0 {
+		v.errorf(path, "config is empty")
+		return
+	}
+	for i, item := range items {
+		itemPath := indexPath(path, i)
+		v.validateScopeBase(jsonPath(itemPath, "_base"), item.Base)
+		v.validateFlatService(kind, jsonPath(itemPath, "_service"), item.Service)
+	}
+}
+
+// validateScopeBase _base 至少满足 Region / Zone / Gaia 之一
+func (v *validator) validateScopeBase(path string, message json.RawMessage) {
+	if len(message) == 0 {
+		v.errorf(path, "_base is empty")
+		return
+	}
+	var region configcenter.Region
+	var zone configcenter.Zone
+	var gaia configcenter.Gaia
+	if !v.decode(path, message, &region) || !v.decode(path, message, &zone) || !v.decode(path, message, &gaia) {
+		return
+	}
+	if gaia.Valid() == nil || zone.Valid() == nil {
+		return
+	}
+	v.fieldErrors(path, region.Valid())
+}
+
+// validateFlatService 检查扁平资源描述, 使用 Mysql.Valid / Endpoint.Valid
+func (v *validator) validateFlatService(kind, path string, message json.RawMessage) {
+	if kind == configcenter.KindMysql {
+		var mysql configcenter.Mysql
+		if v.decode(path, message, &mysql) {
+			v.fieldErrors(path, mysql.Valid())
+		}
+		return
+	}
+
+	fields := make(map[string]json.RawMessage)
+	if !v.decode(path, message, &fields) {
+		return
+	}
+	if len(fields) == 0 {
+		v.errorf(path, "config is empty")
+		return
+	}
+	var endpoint configcenter.Endpoint
+	if v.decode(path, message, &endpoint) {
+		v.fieldErrors(path, endpoint.Valid())
+	}
+}
@@ -0,0 +1,130 @@
+package tcestuary
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const invalidConfig = `{
+  "base": {
+    "region_list": [{"region_id": 1, "region_name": "chongqing"}, {"region_id": 0, "region_name": "x"}]
+  },
+  "sdk": {
+    "passwd-secret": {"aeskey": "short"},
+    "storage-secret": {
+      "method": "aes-256-gcm",
+      "aes_key": "5c2bd12683ceefb8830abba988339e67",
+      "active_key": "v3",
+      "keys": [
+        {"id": "v1"},
+        {"id": "v2", "method": "tsm-sm4-128-gcm", "sm4_key": "123"},
+        {"id": "v2", "aes_key": "5c2bd12683ceefb8830abba988339e67"}
+      ]
+    },
+    "transport-secret": {"method": "aes-512"},
+    "sign-secret": {
+      "method": "kms-sign",
+      "key_id": "sign-key",
+      "kms_server": "kms.tce.com",
+      "kms_transport": {"insecure_skip_verify": true, "ca_file": "/not/exist"},
+      "kms_retry": {"max_retries": -2}
+    },
+    "hash-secret": {"method": "md5"}
+  },
+  "mysql": {
+    "ok": {"host": "db", "ipv4": "10.0.0.1", "port": 3306, "user": "root", "pass": "p"},
+    "bad": {"host": "db", "ipv4": "10.0.0.1", "port": 0, "user": "root"},
+    "dbsql-region": [
+      {"_base": {"region_id": 1, "region_name": "chongqing"}, "_service": {"host": "db", "ipv4": "10.0.0.1", "port": 3306, "user": "root", "pass": "p"}},
+      {"_base": {"region_id": 2}, "_service": {"host": "db", "ipv4": "10.0.0.1", "port": 3306, "user": "root", "pass": "p"}}
+    ]
+  },
+  "redis": {
+    "empty": {},
+    "no_address": {"port": 70000}
+  }
+}`
+
+func TestValidateConfig(t *testing.T) {
+	report := ValidateConfig([]byte(invalidConfig))
+	assert.True(t, report.HasErrors())
+
+	issues := make(map[string]string)
+	for _, issue := range report.Issues {
+		t.Log(issue)
+		issues[issue.Path] = issue.Level
+	}
+	for path, level := range map[string]string{
+		`$.base.region_list[1].region_id`:                         LevelError,
+		`$.sdk["passwd-secret"].aeskey`:                           LevelError,
+		`$.sdk["storage-secret"].keys[1].sm4_key`:                 LevelError,
+		`$.sdk["storage-secret"].keys[2].id`:                      LevelError,
+		`$.sdk["storage-secret"].active_key`:                      LevelError,
+		`$.sdk["transport-secret"].method`:                        LevelError,
+		`$.sdk["sign-secret"].secret_id`:                          LevelError,
+		`$.sdk["sign-secret"].secret_key`:                         LevelError,
+		`$.sdk["sign-secret"].kms_transport`:                      LevelError,
+		`$.sdk["sign-secret"].kms_transport.insecure_skip_verify`: LevelWarning,
+		`$.sdk["sign-secret"].kms_retry.max_retries`:              LevelError,
+		`$.sdk["hash-secret"].method`:                             LevelError,
+		`$.mysql.bad.port`:                                        LevelError,
+		`$.mysql.bad.pass`:                                        LevelError,
+		`$.mysql["dbsql-region"][1]._base.region_name`:            LevelError,
+		`$.redis.empty`:                                           LevelError,
+		`$.redis.no_address`:                                      LevelError,
+		`$.redis.no_address.port`:                                 LevelError,
+	} {
+		assert.Equal(t, level, issues[path], path)
+	}
+	// 合法的配置项没有问题
+	for _, path := range []string{`$.mysql.ok`, `$.sdk["storage-secret"].keys[0].method`, `$.sdk["storage-secret"].aes_key`} {
+		_, ok := issues[path]
+		assert.False(t, ok, path)
+	}
+
+	report = ValidateConfig([]byte("{\n  \"sdk\": {,\n}"))
+	assert.True(t, report.HasErrors())
+	assert.Equal(t, "$", report.Issues[0].Path)
+	assert.Contains(t, report.Issues[0].Message, "line 2")
+}
+
+func TestValidate(t *testing.T) {
+	report, err := Validate("./_example")
+	assert.NoError(t, err)
+	assert.False(t, report.HasErrors(), "%v", report.Issues)
+
+	dir, err := ioutil.TempDir("", "tcestuary")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	_, err = Validate(dir)
+	assert.Error(t, err)
+
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "sdk.json"), []byte(invalidConfig), 0644))
+	report, err = Validate(dir)
+	assert.NoError(t, err)
+	assert.True(t, report.HasErrors())
+	assert.Equal(t, filepath.Join(dir, "sdk.json"), report.File)
+
+	// 按声明的资源级别被忽略的 dbsql 给出警告
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "cc.declare.json"), []byte(`{
+  "config": {
+    "base": {"_scop": ["global", "all_region"]},
+    "service": {"bad": {"_service_id": "dbsql-bad"}}
+  }
+}`), 0644))
+	report, err = Validate(dir)
+	assert.NoError(t, err)
+	var found bool
+	for _, issue := range report.Issues {
+		if issue.Path == `$.mysql.bad` && issue.Level == LevelWarning {
+			found = true
+			assert.Contains(t, issue.Message, "password is empty")
+		}
+	}
+	assert.True(t, found, "%v", report.Issues)
+}